@@ -1,58 +1,180 @@
 package baur
 
 import (
+	"context"
 	"fmt"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/xid"
 
 	"github.com/simplesurance/baur/cfg"
 	"github.com/simplesurance/baur/digest"
-	"github.com/simplesurance/baur/digest/sha384"
+	"github.com/simplesurance/baur/fs"
 	"github.com/simplesurance/baur/log"
 	"github.com/simplesurance/baur/resolve/gitpath"
 	"github.com/simplesurance/baur/resolve/glob"
 	"github.com/simplesurance/baur/resolve/gosource"
+	"github.com/simplesurance/baur/upload/azure"
+	"github.com/simplesurance/baur/upload/cmdupload"
+	"github.com/simplesurance/baur/upload/filecopy"
+	"github.com/simplesurance/baur/upload/s3"
 	"github.com/simplesurance/baur/upload/scheduler"
 )
 
 // App represents an application
 type App struct {
-	RelPath          string
-	Path             string
-	Name             string
-	BuildCmd         string
+	RelPath      string
+	Path         string
+	Name         string
+	BuildCmd     string
+	BuildArgs    []string
+	PreBuildCmd  string
+	PostBuildCmd string
+	// BuildTimeout is the duration after which the build command is
+	// killed, 0 means no timeout
+	BuildTimeout time.Duration
+	// BuildDir is the absolute path of the directory the build commands
+	// are run in
+	BuildDir         string
 	Repository       *Repository
 	Outputs          []BuildOutput
 	totalInputDigest *digest.Digest
 
 	UnresolvedInputs []*cfg.BuildInput
 	buildInputs      []*File
+	envInputs        []*EnvVar
+	urlInputs        []*URLInput
 }
 
-func replaceUUIDvar(in string) string {
-	return strings.Replace(in, "$UUID", xid.New().String(), -1)
-}
+// Names of variables that can be used in output configurations
+const (
+	varAppName   = "APPNAME"
+	varUUID      = "UUID"
+	varGitCommit = "GITCOMMIT"
+	varOS        = "OS"
+	varArch      = "ARCH"
+)
 
 func replaceROOTvar(in string, r *Repository) string {
 	return strings.Replace(in, "$ROOT", r.Path, -1)
 }
 
-func replaceAppNameVar(in, appName string) string {
-	return strings.Replace(in, "$APPNAME", appName, -1)
+// resolveVars replaces all variable references in template with their values
+// from vars. Variable names are matched as prefix, see cfg.ReplaceVarRefs. The keys in vars are the variable names without the $ prefix.
+// If template references a variable that is not in vars an error is returned.
+func resolveVars(template string, vars map[string]string) (string, error) {
+	var unknownVar string
+
+	res := cfg.ReplaceVarRefs(template, func(ref string) string {
+		val, exist := vars[ref[1:]]
+		if !exist {
+			if unknownVar == "" {
+				unknownVar = ref
+			}
+
+			return ref
+		}
+
+		return val
+	})
+
+	if unknownVar != "" {
+		return "", fmt.Errorf("unknown variable %s in %q", unknownVar, template)
+	}
+
+	return res, nil
 }
 
-func replaceGitCommitVar(in string, r *Repository) (string, error) {
-	commitID, err := r.GitCommitID()
+// resolveOutputVars replaces the variables in template, only the variables
+// in allowedVars can be used.
+// The $GITCOMMIT variable is only evaluated when template references it, to
+// not require a git repository for other variables.
+func (a *App) resolveOutputVars(template string, allowedVars ...string) (string, error) {
+	vars := make(map[string]string, len(allowedVars))
+
+	for _, name := range allowedVars {
+		switch name {
+		case varAppName:
+			vars[name] = a.Name
+
+		case varUUID:
+			vars[name] = xid.New().String()
+
+		case varGitCommit:
+			if !strings.Contains(template, "$"+varGitCommit) {
+				continue
+			}
+
+			commitID, err := a.Repository.GitCommitID()
+			if err != nil {
+				return "", errors.Wrap(err, "determining Git commit ID failed")
+			}
+
+			vars[name] = commitID
+
+		case varOS:
+			vars[name] = runtime.GOOS
+
+		case varArch:
+			vars[name] = runtime.GOARCH
+
+		default:
+			panic(fmt.Sprintf("resolveOutputVars: unsupported variable %q", name))
+		}
+	}
+
+	return resolveVars(template, vars)
+}
+
+// resolveWorkingDir replaces the variables in dir and returns it as absolute
+// path, relative paths are relative to the application directory. If dir is
+// empty the application directory is returned.
+// An error is returned if the directory does not exist or is not part of the
+// repository.
+func (a *App) resolveWorkingDir(dir string) (string, error) {
+	if len(dir) == 0 {
+		return a.Path, nil
+	}
+
+	dir, err := a.resolveOutputVars(replaceROOTvar(dir, a.Repository), varAppName)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(a.Path, dir)
+	}
+	dir = filepath.Clean(dir)
+
+	isDir, err := fs.IsDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if !isDir {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if dir == filepath.Clean(a.Repository.Path) {
+		return dir, nil
+	}
+
+	inRepo, err := fs.IsInDir(dir, a.Repository.Path)
 	if err != nil {
 		return "", err
 	}
 
-	return strings.Replace(in, "$GITCOMMIT", commitID, -1), nil
+	if !inRepo {
+		return "", fmt.Errorf("%s is not part of the repository", dir)
+	}
+
+	return dir, nil
 }
 
 func (a *App) addBuildOutput(buildOutput *cfg.BuildOutput) error {
@@ -69,16 +191,48 @@ func (a *App) addBuildOutput(buildOutput *cfg.BuildOutput) error {
 
 func (a *App) addDockerBuildOutputs(buildOutput *cfg.BuildOutput) error {
 	for _, di := range buildOutput.DockerImage {
-		tag, err := replaceGitCommitVar(di.RegistryUpload.Tag, a.Repository)
+		tag, err := a.resolveOutputVars(di.RegistryUpload.Tag,
+			varAppName, varUUID, varGitCommit, varOS, varArch)
+		if err != nil {
+			return errors.Wrap(err, "resolving variables in tag failed")
+		}
+
+		repository, err := a.resolveOutputVars(di.RegistryUpload.Repository, varAppName)
 		if err != nil {
-			return errors.Wrap(err, "replacing $GITCOMMIT in tag failed")
+			return errors.Wrap(err, "resolving variables in repository failed")
 		}
 
-		tag = replaceUUIDvar(tag)
-		repository := replaceAppNameVar(di.RegistryUpload.Repository, a.Name)
+		if di.IsMultiPlatform() {
+			var images []*DockerPlatformImage
+
+			for i, platform := range di.Platforms {
+				idFile, err := a.resolveOutputVars(di.IDFiles[i], varAppName)
+				if err != nil {
+					return errors.Wrap(err, "resolving variables in idfiles failed")
+				}
+
+				images = append(images, &DockerPlatformImage{
+					Platform:    platform,
+					ImageIDFile: path.Join(a.Path, idFile),
+				})
+			}
+
+			a.Outputs = append(a.Outputs, &DockerManifestArtifact{
+				Images:     images,
+				Tag:        tag,
+				Repository: repository,
+			})
+
+			continue
+		}
+
+		idFile, err := a.resolveOutputVars(di.IDFile, varAppName)
+		if err != nil {
+			return errors.Wrap(err, "resolving variables in idfile failed")
+		}
 
 		a.Outputs = append(a.Outputs, &DockerArtifact{
-			ImageIDFile: path.Join(a.Path, replaceAppNameVar(di.IDFile, a.Name)),
+			ImageIDFile: path.Join(a.Path, idFile),
 			Tag:         tag,
 			Repository:  repository,
 		})
@@ -89,17 +243,50 @@ func (a *App) addDockerBuildOutputs(buildOutput *cfg.BuildOutput) error {
 
 func (a *App) addFileOutputs(buildOutput *cfg.BuildOutput) error {
 	for _, f := range buildOutput.File {
-		filePath := replaceAppNameVar(f.Path, a.Name)
+		filePath, err := a.resolveOutputVars(f.Path, varAppName, varOS, varArch)
+		if err != nil {
+			return errors.Wrap(err, "resolving variables in path failed")
+		}
+
+		inAppDir, err := fs.IsInDir(path.Join(a.Path, filePath), a.Path)
+		if err != nil {
+			return errors.Wrapf(err, "checking location of path %q failed", filePath)
+		}
+
+		if !inAppDir {
+			return cfg.NewValidationError("path", "%q refers to a location outside of the application directory", filePath)
+		}
+
 		if !f.S3Upload.IsEmpty() {
-			destFile, err := replaceGitCommitVar(f.S3Upload.DestFile, a.Repository)
+			destFile, err := a.resolveOutputVars(f.S3Upload.DestFile,
+				varAppName, varUUID, varGitCommit, varOS, varArch)
 			if err != nil {
-				return errors.Wrap(err, "replacing $GITCOMMIT in dest_file failed")
+				return errors.Wrap(err, "resolving variables in dest_file failed")
 			}
 
-			destFile = replaceUUIDvar(replaceAppNameVar(destFile, a.Name))
-			s3Bucket := replaceAppNameVar(f.S3Upload.Bucket, a.Name)
+			s3Bucket, err := a.resolveOutputVars(f.S3Upload.Bucket, varAppName)
+			if err != nil {
+				return errors.Wrap(err, "resolving variables in bucket failed")
+			}
 			url := "s3://" + s3Bucket + "/" + destFile
 
+			retryPolicy := s3.DefaultRetryPolicy
+			switch {
+			case f.S3Upload.MaxRetries == -1:
+				retryPolicy.MaxRetries = 0
+			case f.S3Upload.MaxRetries > 0:
+				retryPolicy.MaxRetries = f.S3Upload.MaxRetries
+			}
+
+			retryBaseDelay, err := f.S3Upload.RetryBaseDelayDuration()
+			if err != nil {
+				return errors.Wrap(err, "parsing retry_base_delay failed")
+			}
+
+			if retryBaseDelay > 0 {
+				retryPolicy.BaseDelay = retryBaseDelay
+			}
+
 			src := path.Join(a.Path, filePath)
 
 			a.Outputs = append(a.Outputs, &FileArtifact{
@@ -108,29 +295,122 @@ func (a *App) addFileOutputs(buildOutput *cfg.BuildOutput) error {
 				DestFile:  destFile,
 				UploadURL: url,
 				uploadJob: &scheduler.S3Job{
+					DestURL:     url,
+					FilePath:    src,
+					RetryPolicy: retryPolicy,
+					Endpoint: s3.Endpoint{
+						URL:            f.S3Upload.Endpoint,
+						ForcePathStyle: f.S3Upload.ForcePathStyle,
+					},
+				},
+			})
+		}
+
+		if !f.GCSUpload.IsEmpty() {
+			destFile, err := a.resolveOutputVars(f.GCSUpload.DestFile,
+				varAppName, varUUID, varGitCommit, varOS, varArch)
+			if err != nil {
+				return errors.Wrap(err, "resolving variables in dest_file failed")
+			}
+
+			bucket, err := a.resolveOutputVars(f.GCSUpload.Bucket, varAppName)
+			if err != nil {
+				return errors.Wrap(err, "resolving variables in bucket failed")
+			}
+			url := "gs://" + bucket + "/" + destFile
+
+			src := path.Join(a.Path, filePath)
+
+			a.Outputs = append(a.Outputs, &FileArtifact{
+				RelPath:   path.Join(a.RelPath, filePath),
+				Path:      src,
+				DestFile:  destFile,
+				UploadURL: url,
+				uploadJob: &scheduler.GCSJob{
 					DestURL:  url,
 					FilePath: src,
 				},
 			})
 		}
 
-		if !f.FileCopy.IsEmpty() {
-			dest, err := replaceGitCommitVar(f.FileCopy.Path, a.Repository)
+		if !f.AzureBlobUpload.IsEmpty() {
+			destBlob, err := a.resolveOutputVars(f.AzureBlobUpload.DestBlob,
+				varAppName, varUUID, varGitCommit, varOS, varArch)
+			if err != nil {
+				return errors.Wrap(err, "resolving variables in dest_blob failed")
+			}
+
+			container, err := a.resolveOutputVars(f.AzureBlobUpload.Container, varAppName)
 			if err != nil {
-				return errors.Wrap(err, "replacing $GITCOMMIT in path failed")
+				return errors.Wrap(err, "resolving variables in container failed")
 			}
+			url := "azblob://" + container + "/" + destBlob
 
-			dest = replaceUUIDvar(replaceAppNameVar(dest, a.Name))
 			src := path.Join(a.Path, filePath)
 
 			a.Outputs = append(a.Outputs, &FileArtifact{
 				RelPath:   path.Join(a.RelPath, filePath),
 				Path:      src,
-				DestFile:  dest,
-				UploadURL: dest,
+				DestFile:  destBlob,
+				UploadURL: url,
+				uploadJob: &scheduler.AzureBlobJob{
+					DestURL:  url,
+					FilePath: src,
+					Options: azure.UploadOptions{
+						AccountURL: f.AzureBlobUpload.AccountURL,
+					},
+				},
+			})
+		}
+
+		if !f.CommandUpload.IsEmpty() {
+			commitID, err := a.Repository.GitCommitID()
+			if err != nil {
+				return errors.Wrap(err, "CommandUpload")
+			}
+
+			src := path.Join(a.Path, filePath)
+
+			a.Outputs = append(a.Outputs, &FileArtifact{
+				RelPath:   path.Join(a.RelPath, filePath),
+				Path:      src,
+				UploadURL: f.CommandUpload.Command,
+				uploadJob: &scheduler.CommandJob{
+					FilePath: src,
+					Command:  f.CommandUpload.Command,
+					Options: cmdupload.UploadOptions{
+						Dir: a.Path,
+						Env: []string{
+							cmdupload.EnvVarAppName + "=" + a.Name,
+							cmdupload.EnvVarGitCommit + "=" + commitID,
+						},
+					},
+				},
+			})
+		}
+
+		if !f.FileCopy.IsEmpty() {
+			dest, err := a.resolveOutputVars(f.FileCopy.Path,
+				varAppName, varUUID, varGitCommit, varOS, varArch)
+			if err != nil {
+				return errors.Wrap(err, "resolving variables in path failed")
+			}
+			src := path.Join(a.Path, filePath)
+
+			a.Outputs = append(a.Outputs, &FileArtifact{
+				RelPath:      path.Join(a.RelPath, filePath),
+				Path:         src,
+				DestFile:     dest,
+				UploadURL:    dest,
+				destIsUnique: strings.Contains(f.FileCopy.Path, "$"+varUUID),
 				uploadJob: &scheduler.FileCopyJob{
 					Src: src,
 					Dst: dest,
+					Options: filecopy.Options{
+						Atomic:             f.FileCopy.IsAtomic(),
+						PreserveMode:       f.FileCopy.PreserveMode,
+						PreserveTimestamps: f.FileCopy.PreserveTimestamps,
+					},
 				},
 			})
 
@@ -140,24 +420,68 @@ func (a *App) addFileOutputs(buildOutput *cfg.BuildOutput) error {
 	return nil
 }
 
+// LocalOutputPaths returns the paths of the local files that are created
+// when the application is built and its outputs are uploaded. These are the
+// paths of file outputs, the destinations of FileCopy uploads and docker
+// image ID files.
+// FileCopy destinations that contain the $UUID variable are not returned, they
+// differ on every run.
+func (a *App) LocalOutputPaths() []string {
+	var res []string
+	seen := map[string]struct{}{}
+
+	add := func(p string) {
+		if _, exist := seen[p]; exist {
+			return
+		}
+
+		seen[p] = struct{}{}
+		res = append(res, p)
+	}
+
+	for _, o := range a.Outputs {
+		switch art := o.(type) {
+		case *FileArtifact:
+			add(art.Path)
+
+			if job, ok := art.uploadJob.(*scheduler.FileCopyJob); ok && !art.destIsUnique {
+				add(job.Dst)
+			}
+
+		case *DockerArtifact:
+			add(art.ImageIDFile)
+
+		case *DockerManifestArtifact:
+			for _, img := range art.Images {
+				add(img.ImageIDFile)
+			}
+		}
+	}
+
+	return res
+}
+
 func (a *App) include(inc *cfg.Include) error {
 	a.UnresolvedInputs = append(a.UnresolvedInputs, &inc.BuildInput)
 
 	return a.addBuildOutput(&inc.BuildOutput)
 }
 
+// includeAbsPath returns the absolute path of an include file that is
+// referenced in the application config
+func (a *App) includeAbsPath(includePath string) string {
+	return a.Repository.IncludeAbsPath(a.Path, includePath)
+}
+
 func (a *App) loadIncludes(appCfg *cfg.App) error {
 	for _, includePath := range appCfg.Build.Includes {
-		path := replaceROOTvar(includePath, a.Repository)
-		if !filepath.IsAbs(path) {
-			path = filepath.Join(a.Path, path)
-		}
-
-		inc, err := a.Repository.includeCache.load(path)
+		inc, err := a.Repository.includeCache.load(a.includeAbsPath(includePath))
 		if err != nil {
 			return errors.Wrapf(err, "loading include '%s' failed", includePath)
 		}
 
+		log.Debugf("%s: merging include '%s'", a.Name, a.includeAbsPath(includePath))
+
 		err = a.include(inc)
 		if err != nil {
 			return errors.Wrapf(err, "including '%s' failed", includePath)
@@ -202,6 +526,18 @@ func NewApp(repository *Repository, cfgPath string) (*App, error) {
 		RelPath:    appRelPath,
 		Name:       appCfg.Name,
 		BuildCmd:   strings.TrimSpace(appCfg.Build.Command),
+		BuildArgs:  appCfg.Build.Args,
+
+		PreBuildCmd:  strings.TrimSpace(appCfg.Build.PreCommand),
+		PostBuildCmd: strings.TrimSpace(appCfg.Build.PostCommand),
+	}
+
+	// the timeout was validated before, the error can not happen
+	app.BuildTimeout, _ = appCfg.Build.TimeoutDuration()
+
+	app.BuildDir, err = app.resolveWorkingDir(appCfg.Build.WorkingDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: resolving Build.working_dir failed", app.Name)
 	}
 
 	err = app.addBuildOutput(&appCfg.Build.Output)
@@ -217,9 +553,28 @@ func NewApp(repository *Repository, cfgPath string) (*App, error) {
 		return nil, errors.Wrapf(err, "%s: processing application config failed failed", app.Name)
 	}
 
+	var urlInputs []*cfg.URLInput
+	for _, bi := range app.UnresolvedInputs {
+		urlInputs = append(urlInputs, bi.URLs.File...)
+	}
+
+	if err := cfg.URLInputConflict(urlInputs); err != nil {
+		return nil, errors.Wrapf(err, "%s: validating Build.Input.URLs of application config and includes failed", app.Name)
+	}
+
 	return &app, nil
 }
 
+// BuildCmdString returns the build command and it's arguments as a single
+// string.
+func (a *App) BuildCmdString() string {
+	if len(a.BuildArgs) == 0 {
+		return a.BuildCmd
+	}
+
+	return fmt.Sprintf("%s %q", a.BuildCmd, a.BuildArgs)
+}
+
 // String returns the string representation of an app
 func (a *App) String() string {
 	return a.Name
@@ -243,7 +598,43 @@ func (a *App) pathsToUniqFiles(paths []string) ([]*File, error) {
 
 		// TODO: should resolving the relative path be done in
 		// Newfile() instead?
-		res = append(res, NewFile(a.Repository.Path, relPath))
+		res = append(res, NewFile(a.Repository.Path, relPath, a.Repository.DigestAlgorithm))
+	}
+
+	return res, nil
+}
+
+func (a *App) absGlobPath(globPath string) string {
+	if strings.HasPrefix(globPath, "$ROOT") {
+		globPath = filepath.Clean(replaceROOTvar(globPath, a.Repository))
+	}
+
+	if !filepath.IsAbs(globPath) {
+		globPath = filepath.Join(a.Path, globPath)
+	}
+
+	return globPath
+}
+
+// resolveGlobFileExcludes resolves the Files.Excludes glob paths of all
+// UnresolvedInputs and returns the matched paths as set.
+func (a *App) resolveGlobFileExcludes() (map[string]struct{}, error) {
+	res := map[string]struct{}{}
+
+	for _, bi := range a.UnresolvedInputs {
+		globPaths := make([]string, 0, len(bi.Files.Excludes))
+		for _, globPath := range bi.Files.Excludes {
+			globPaths = append(globPaths, a.absGlobPath(globPath))
+		}
+
+		paths, err := glob.ResolveFileInputs(a.Path, globPaths, a.Repository.statCache)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range paths {
+			res[p] = struct{}{}
+		}
 	}
 
 	return res, nil
@@ -252,27 +643,65 @@ func (a *App) pathsToUniqFiles(paths []string) ([]*File, error) {
 func (a *App) resolveGlobFileInputs() ([]string, error) {
 	var res []string
 
+	excludes, err := a.resolveGlobFileExcludes()
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving excludes failed")
+	}
+
+	var gitIgnoreMatcher *fs.GitIgnoreMatcher
+
 	for _, bi := range a.UnresolvedInputs {
-		for _, globPath := range bi.Files.Paths {
-			if strings.HasPrefix(globPath, "$ROOT") {
-				globPath = filepath.Clean(replaceROOTvar(globPath, a.Repository))
+		if bi.Files.GitIgnore && gitIgnoreMatcher == nil {
+			gitIgnoreMatcher, err = fs.NewGitIgnoreMatcher(a.Repository.Path)
+			if err != nil {
+				return nil, err
 			}
+		}
 
-			if !filepath.IsAbs(globPath) {
-				globPath = filepath.Join(a.Path, globPath)
-			}
+		for _, globPath := range bi.Files.Paths {
+			globPath = a.absGlobPath(globPath)
 
-			resolver := glob.NewResolver(globPath)
+			resolver := glob.NewResolver(globPath).WithStatCache(a.Repository.statCache)
 			paths, err := resolver.Resolve()
 			if err != nil {
 				return nil, errors.Wrap(err, globPath)
 			}
 
 			if len(paths) == 0 {
-				return nil, fmt.Errorf("'%s' matched 0 files", globPath)
+				if !bi.Files.Optional {
+					return nil, fmt.Errorf("path '%s' matched 0 files, "+
+						"set optional = true in the [Build.Input.Files] section if it is allowed to match none",
+						globPath)
+				}
+
+				log.Debugf("%s: optional Files input '%s' matched 0 files", a.Name, globPath)
+				continue
 			}
 
-			res = append(res, paths...)
+			log.Debugf("%s: Files input '%s' matched %d files", a.Name, globPath, len(paths))
+
+			for _, p := range paths {
+				log.Tracef("%s: Files input '%s' matched '%s'", a.Name, globPath, p)
+
+				if _, exist := excludes[p]; exist {
+					log.Debugf("%s: excluded Build Input '%s'", a.Name, p)
+					continue
+				}
+
+				if bi.Files.GitIgnore {
+					ignored, err := gitIgnoreMatcher.Match(p)
+					if err != nil {
+						return nil, errors.Wrapf(err, "matching %s against .gitignore files failed", p)
+					}
+
+					if ignored {
+						log.Debugf("%s: Build Input '%s' is ignored by .gitignore", a.Name, p)
+						continue
+					}
+				}
+
+				res = append(res, p)
+			}
 		}
 	}
 
@@ -303,7 +732,9 @@ func (a *App) resolveGitFileInputs() ([]string, error) {
 			paths = append(paths, relPath)
 		}
 
-		resolver := gitpath.NewResolver(a.Path, paths...)
+		resolver := gitpath.NewResolver(a.Path, paths...).
+			WithBackend(a.Repository.GitBackend).
+			WithStatCache(a.Repository.statCache)
 		paths, err := resolver.Resolve()
 		if err != nil {
 			return nil, err
@@ -312,12 +743,51 @@ func (a *App) resolveGitFileInputs() ([]string, error) {
 		if len(paths) == 0 {
 			return nil, fmt.Errorf("'%s' matched 0 files", strings.Join(paths, ", "))
 		}
+
+		log.Debugf("%s: GitFiles inputs %v matched %d files", a.Name, bi.GitFiles.Paths, len(paths))
 		res = append(res, paths...)
 	}
 
 	return res, nil
 }
 
+// goSrcEnvironment returns the environment that is used to resolve the
+// GolangSources of bi. It consists of the repository environment and the
+// GolangSources environment, the latter overrides variables with the same name.
+func (a *App) goSrcEnvironment(bi *cfg.BuildInput) []string {
+	goSrcEnv := make([]string, 0, len(bi.GolangSources.Environment))
+	for _, val := range bi.GolangSources.Environment {
+		goSrcEnv = append(goSrcEnv, path.Clean(replaceROOTvar(val, a.Repository)))
+	}
+
+	return mergeEnv(a.Repository.Environment, goSrcEnv)
+}
+
+// mergeEnv returns an environment containing the variables of base and
+// override. If a variable is defined in both, the one from override is used.
+func mergeEnv(base, override []string) []string {
+	overridden := make(map[string]struct{}, len(override))
+	for _, e := range override {
+		overridden[envKey(e)] = struct{}{}
+	}
+
+	res := make([]string, 0, len(base)+len(override))
+	for _, e := range base {
+		if _, exist := overridden[envKey(e)]; exist {
+			continue
+		}
+
+		res = append(res, e)
+	}
+
+	return append(res, override...)
+}
+
+// envKey returns the name of an environment variable in the format KEY=VALUE.
+func envKey(env string) string {
+	return strings.SplitN(env, "=", 2)[0]
+}
+
 func (a *App) resolveGoSrcInputs() ([]string, error) {
 	var res []string
 
@@ -332,12 +802,11 @@ func (a *App) resolveGoSrcInputs() ([]string, error) {
 			absGoSourcePaths = append(absGoSourcePaths, absPath)
 		}
 
-		goSrcEnv := make([]string, 0, len(bi.GolangSources.Environment))
-		for _, val := range bi.GolangSources.Environment {
-			goSrcEnv = append(goSrcEnv, path.Clean(replaceROOTvar(val, a.Repository)))
+		resolver := gosource.NewResolver(log.Debugf, a.goSrcEnvironment(bi), absGoSourcePaths...)
+		if a.Repository.GoSourceCacheDir != "" {
+			resolver.WithCache(gosource.NewCache(a.Repository.GoSourceCacheDir))
 		}
 
-		resolver := gosource.NewResolver(log.Debugf, goSrcEnv, absGoSourcePaths...)
 		paths, err := resolver.Resolve()
 		if err != nil {
 			return nil, err
@@ -347,7 +816,66 @@ func (a *App) resolveGoSrcInputs() ([]string, error) {
 			return nil, fmt.Errorf("'%s' matched 0 files", strings.Join(paths, ", "))
 		}
 
-		res = append(res, paths...)
+		log.Debugf("%s: GolangSources inputs %v resolved to %d files", a.Name, bi.GolangSources.Paths, len(paths))
+
+		res = append(res, a.withoutExcludedGoSrcFiles(paths, bi.GolangSources.Excludes)...)
+	}
+
+	return res, nil
+}
+
+// withoutExcludedGoSrcFiles returns the paths whose file names in their
+// package directory do not match one of the exclude glob patterns.
+func (a *App) withoutExcludedGoSrcFiles(paths, excludes []string) []string {
+	if len(excludes) == 0 {
+		return paths
+	}
+
+	res := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		if matchesGoSrcExclude(filepath.Base(p), excludes) {
+			log.Debugf("%s: excluded Build Input '%s'", a.Name, p)
+			continue
+		}
+
+		res = append(res, p)
+	}
+
+	return res
+}
+
+func matchesGoSrcExclude(filename string, excludes []string) bool {
+	for _, pattern := range excludes {
+		// patterns are validated when the config is loaded, errors
+		// can not happen
+		if matched, _ := filepath.Match(pattern, filename); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *App) resolveDirInputs() ([]string, error) {
+	var res []string
+
+	for _, bi := range a.UnresolvedInputs {
+		for _, dir := range bi.Directories.Paths {
+			absDir := a.absGlobPath(dir)
+
+			if err := fs.DirsExist(absDir); err != nil {
+				return nil, err
+			}
+
+			err := fs.WalkFiles(absDir, func(path string) error {
+				res = append(res, path)
+				return nil
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "walking %s failed", absDir)
+			}
+		}
 	}
 
 	return res, nil
@@ -359,6 +887,11 @@ func (a *App) resolveBuildInputPaths() ([]string, error) {
 		return nil, errors.Wrapf(err, "resolving File BuildInputs failed")
 	}
 
+	dirPaths, err := a.resolveDirInputs()
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving Directory BuildInputs failed")
+	}
+
 	gitPaths, err := a.resolveGitFileInputs()
 	if err != nil {
 		return nil, errors.Wrapf(err, "resolving GitFile BuildInputs failed")
@@ -369,8 +902,9 @@ func (a *App) resolveBuildInputPaths() ([]string, error) {
 		return nil, errors.Wrapf(err, "resolving GoLangSources BuildInputs failed")
 	}
 
-	paths := make([]string, 0, len(globPaths)+len(gitPaths)+len(goSrcPaths))
+	paths := make([]string, 0, len(globPaths)+len(dirPaths)+len(gitPaths)+len(goSrcPaths))
 	paths = append(paths, globPaths...)
+	paths = append(paths, dirPaths...)
 	paths = append(paths, gitPaths...)
 	paths = append(paths, goSrcPaths...)
 
@@ -384,6 +918,10 @@ func (a *App) HasBuildInputs() bool {
 			return true
 		}
 
+		if len(bi.Directories.Paths) != 0 {
+			return true
+		}
+
 		if len(bi.GitFiles.Paths) != 0 {
 			return true
 		}
@@ -391,11 +929,96 @@ func (a *App) HasBuildInputs() bool {
 		if len(bi.GolangSources.Paths) != 0 {
 			return true
 		}
+
+		if len(bi.Environment.Names) != 0 {
+			return true
+		}
+
+		if len(bi.URLs.File) != 0 {
+			return true
+		}
 	}
 
 	return false
 }
 
+// EnvironmentInputs returns the environment variables that are defined as
+// build inputs of the app.
+// Duplicate names are removed, a warning is logged for them.
+// On following calls the stored EnvVars are returned.
+func (a *App) EnvironmentInputs() []*EnvVar {
+	if a.envInputs != nil {
+		return a.envInputs
+	}
+
+	res := []*EnvVar{}
+	dedupMap := map[string]struct{}{}
+
+	for _, bi := range a.UnresolvedInputs {
+		for _, name := range bi.Environment.Names {
+			if _, exist := dedupMap[name]; exist {
+				log.Warnf("%s: environment variable %q is specified multiple times as input\n", a.Name, name)
+				continue
+			}
+			dedupMap[name] = struct{}{}
+
+			res = append(res, NewEnvVar(name, a.Repository.DigestAlgorithm))
+		}
+	}
+
+	a.envInputs = res
+
+	return a.envInputs
+}
+
+// FetchURLInputs ensures that the URLInputs of the app exist in cacheDir
+// and returns their paths, in the same order as URLInputs() returns them.
+func (a *App) FetchURLInputs(cacheDir string) ([]string, error) {
+	urlInputs := a.URLInputs()
+	res := make([]string, 0, len(urlInputs))
+
+	for _, u := range urlInputs {
+		path, err := u.Fetch(cacheDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetching %s failed", u)
+		}
+
+		res = append(res, path)
+	}
+
+	return res, nil
+}
+
+// URLInputs returns the files that are downloaded via HTTP(S) and are build
+// inputs of the app.
+// Duplicate URLs are removed, a warning is logged for them. Duplicates with
+// different sha256 digests are rejected when the App is created.
+// On following calls the stored URLInputs are returned.
+func (a *App) URLInputs() []*URLInput {
+	if a.urlInputs != nil {
+		return a.urlInputs
+	}
+
+	res := []*URLInput{}
+	dedupMap := map[string]struct{}{}
+
+	for _, bi := range a.UnresolvedInputs {
+		for _, f := range bi.URLs.File {
+			if _, exist := dedupMap[f.URL]; exist {
+				log.Warnf("%s: URL %q is specified multiple times as input\n", a.Name, f.URL)
+				continue
+			}
+			dedupMap[f.URL] = struct{}{}
+
+			res = append(res, NewURLInput(f.URL, f.SHA256, a.Repository.DigestAlgorithm))
+		}
+	}
+
+	a.urlInputs = res
+
+	return a.urlInputs
+}
+
 // BuildInputs resolves all build inputs of the app.
 // The BuildInputs are deduplicates before they are returned.
 // If one more resolved path does not match a file an error is generated.
@@ -433,7 +1056,14 @@ func (a *App) TotalInputDigest() (digest.Digest, error) {
 		return digest.Digest{}, err
 	}
 
-	digests := make([]*digest.Digest, 0, len(buildInputs))
+	if err := digestFiles(context.Background(), buildInputs, runtime.NumCPU()); err != nil {
+		return digest.Digest{}, err
+	}
+
+	envInputs := a.EnvironmentInputs()
+	urlInputs := a.URLInputs()
+
+	digests := make([]*digest.Digest, 0, len(buildInputs)+len(envInputs)+len(urlInputs))
 	for _, bi := range buildInputs {
 		d, err := bi.Digest()
 		if err != nil {
@@ -443,7 +1073,25 @@ func (a *App) TotalInputDigest() (digest.Digest, error) {
 		digests = append(digests, &d)
 	}
 
-	totalDigest, err := sha384.Sum(digests)
+	for _, e := range envInputs {
+		d, err := e.Digest()
+		if err != nil {
+			return digest.Digest{}, errors.Wrapf(err, "calculating input digest of %q failed", e)
+		}
+
+		digests = append(digests, &d)
+	}
+
+	for _, u := range urlInputs {
+		d, err := u.Digest()
+		if err != nil {
+			return digest.Digest{}, errors.Wrapf(err, "calculating input digest of %q failed", u)
+		}
+
+		digests = append(digests, &d)
+	}
+
+	totalDigest, err := sumDigests(a.Repository.DigestAlgorithm, digests)
 	if err != nil {
 		return digest.Digest{}, errors.Wrap(err, "calculating total input digest")
 	}
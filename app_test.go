@@ -0,0 +1,478 @@
+package baur
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/cfg"
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func Test_resolveVars(t *testing.T) {
+	vars := map[string]string{
+		"APPNAME": "calc",
+		"OS":      "linux",
+		"ARCH":    "amd64",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{template: "dist/app.tar.xz", want: "dist/app.tar.xz"},
+		{template: "$APPNAME.tar.xz", want: "calc.tar.xz"},
+		{template: "$APPNAME-$OS-$ARCH", want: "calc-linux-amd64"},
+		{template: "$APPNAME/$APPNAME", want: "calc/calc"},
+		{template: "", want: ""},
+		{template: "price$", want: "price$"},
+		{template: "dist/$APPNAME_bin.tar", want: "dist/calc_bin.tar"},
+		{template: "$APPNAME_", want: "calc_"},
+		{template: "$APPNAME_$OS.tar", want: "calc_linux.tar"},
+		{template: "$OSX", want: "linuxX"},
+		{template: "$ 1", want: "$ 1"},
+
+		{template: "$APPNANE.tar", wantErr: true},
+		{template: "$APPNAME-$UUID", wantErr: true},
+		{template: "$appname", wantErr: true},
+		{template: "$APPNAME_$GITCOMMIT.tar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := resolveVars(tt.template, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("resolveVars() returned %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveOutputVarsOSArch(t *testing.T) {
+	app := &App{Name: "calc"}
+
+	got, err := app.resolveOutputVars("dist/$APPNAME-$OS-$ARCH.tar.xz", varAppName, varOS, varArch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "dist/calc-" + runtime.GOOS + "-" + runtime.GOARCH + ".tar.xz"
+	if got != expected {
+		t.Errorf("resolveOutputVars() returned %q, expected %q", got, expected)
+	}
+
+	if _, err := app.resolveOutputVars("$APPNAME-$GITCOMMIT", varAppName); err == nil {
+		t.Error("resolveOutputVars() succeeded for a variable that is not allowed")
+	}
+}
+
+func TestTotalInputDigestUsesRepositoryAlgorithm(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "app")
+	createTestApp(t, appDir, "app")
+
+	digests := map[digest.Algorithm]string{}
+
+	for _, algorithm := range []digest.Algorithm{digest.SHA256, digest.SHA384} {
+		repo, err := NewRepository(repoCfgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		repo.DigestAlgorithm = algorithm
+
+		app, err := NewApp(repo, filepath.Join(appDir, AppCfgFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := app.TotalInputDigest()
+		if err != nil {
+			t.Fatal("calculating total input digest failed:", err)
+		}
+
+		if d.Algorithm != algorithm {
+			t.Errorf("total input digest was calculated with %s, expected %s", d.Algorithm, algorithm)
+		}
+
+		if !strings.HasPrefix(d.String(), algorithm.String()+":") {
+			t.Errorf("total input digest %q is not prefixed with the algorithm name %q", d.String(), algorithm)
+		}
+
+		digests[algorithm] = d.String()
+	}
+
+	if digests[digest.SHA256] == digests[digest.SHA384] {
+		t.Error("total input digests calculated with different algorithms are equal")
+	}
+}
+
+func TestGoSrcEnvironmentAppOverridesRepository(t *testing.T) {
+	app := App{
+		Repository: &Repository{
+			Path:        "/repo",
+			Environment: []string{"GOFLAGS=-mod=vendor", "GOPATH=/repo/go", "CGO_ENABLED=0"},
+		},
+	}
+
+	bi := cfg.BuildInput{
+		GolangSources: cfg.GolangSources{
+			Paths:       []string{"."},
+			Environment: []string{"GOPATH=$ROOT/app/go", "GO111MODULE=on"},
+		},
+	}
+
+	expected := []string{"GOFLAGS=-mod=vendor", "CGO_ENABLED=0", "GOPATH=/repo/app/go", "GO111MODULE=on"}
+
+	env := app.goSrcEnvironment(&bi)
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("goSrcEnvironment() returned %q, expected %q", env, expected)
+	}
+}
+
+func TestGolangSourcesExcludes(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "app")
+	createTestApp(t, appDir, "app")
+
+	goSrcDir := filepath.Join(appDir, "go")
+	mainGoPath := filepath.Join(goSrcDir, "main.go")
+	genGoPath := filepath.Join(goSrcDir, "enum_gen.go")
+
+	if err := os.Mkdir(goSrcDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fstest.WriteToFile(t, []byte("module example.com/app\n"), filepath.Join(goSrcDir, "go.mod"))
+	fstest.WriteToFile(t, []byte("package main\n\nfunc main() {}\n"), mainGoPath)
+	fstest.WriteToFile(t, []byte("package main\n\nconst generated = true\n"), genGoPath)
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.GoSourceCacheDir = ""
+
+	app, err := NewApp(repo, filepath.Join(appDir, AppCfgFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.UnresolvedInputs = []*cfg.BuildInput{{
+		GolangSources: cfg.GolangSources{
+			Paths:    []string{"go"},
+			Excludes: []string{"*_gen.go"},
+		},
+	}}
+
+	paths, err := app.resolveGoSrcInputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(paths, []string{mainGoPath}) {
+		t.Errorf("resolveGoSrcInputs() returned %q, expected only %q", paths, mainGoPath)
+	}
+}
+
+func TestFileOutputThroughSymlinkOutsideAppDirFails(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "app")
+	outsideDir := filepath.Join(tempDir, "outside")
+
+	createTestApp(t, appDir, "app")
+
+	if err := os.Mkdir(outsideDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(outsideDir, filepath.Join(appDir, "dist")); err != nil {
+		t.Fatal(err)
+	}
+
+	fstest.WriteToFile(t, []byte(`
+name = "app"
+
+[Build]
+command = "make"
+
+[Build.Input.Files]
+paths = ["main.c"]
+
+[[Build.Output.File]]
+path = "dist/app.tar"
+
+[Build.Output.File.FileCopy]
+path = "/mnt/app.tar"
+`), filepath.Join(appDir, AppCfgFile))
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewApp(repo, filepath.Join(appDir, AppCfgFile)); err == nil {
+		t.Error("NewApp succeeded for an output path that resolves outside of the application directory")
+	}
+}
+
+func TestWorkingDir(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "app")
+
+	outsideDir, outsideCleanupFn := fstest.CreateTempDir(t)
+	defer outsideCleanupFn()
+
+	createTestApp(t, appDir, "app")
+
+	for _, dir := range []string{filepath.Join(appDir, "src"), filepath.Join(tempDir, "build", "app")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		workingDir string
+		expected   string
+		wantErr    bool
+	}{
+		{name: "default", workingDir: "", expected: appDir},
+		{name: "relative", workingDir: "src", expected: filepath.Join(appDir, "src")},
+		{name: "parent", workingDir: "..", expected: tempDir},
+		{name: "vars", workingDir: "$ROOT/build/$APPNAME", expected: filepath.Join(tempDir, "build", "app")},
+		{name: "not_exist", workingDir: "missing", wantErr: true},
+		{name: "outside_repository", workingDir: outsideDir, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fstest.WriteToFile(t, []byte(`
+name = "app"
+
+[Build]
+command = "make"
+working_dir = "`+tt.workingDir+`"
+
+[Build.Input.Files]
+paths = ["main.c"]
+`), filepath.Join(appDir, AppCfgFile))
+
+			app, err := NewApp(repo, filepath.Join(appDir, AppCfgFile))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewApp succeeded for working_dir %q, expected an error", tt.workingDir)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if app.BuildDir != tt.expected {
+				t.Errorf("BuildDir is %q, expected %q", app.BuildDir, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileInputMatchingNoFiles(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "app")
+
+	createTestApp(t, appDir, "app")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		optional bool
+		wantErr  bool
+	}{
+		{name: "required", optional: false, wantErr: true},
+		{name: "optional", optional: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fstest.WriteToFile(t, []byte(fmt.Sprintf(`
+name = "app"
+
+[Build]
+command = "make"
+
+[Build.Input.Files]
+paths = ["main.c", "generated/*.c"]
+optional = %t
+`, tt.optional)), filepath.Join(appDir, AppCfgFile))
+
+			app, err := NewApp(repo, filepath.Join(appDir, AppCfgFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			inputs, err := app.BuildInputs()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("BuildInputs succeeded for a required path that matches no files")
+				}
+
+				if !strings.Contains(err.Error(), "generated/*.c") {
+					t.Errorf("error %q does not contain the path that matched no files", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found bool
+			for _, in := range inputs {
+				if in.RepoRelPath() == "app/main.c" {
+					found = true
+				}
+			}
+
+			if !found {
+				t.Errorf("BuildInputs returned %v, expected it to contain app/main.c", inputs)
+			}
+		})
+	}
+}
+
+// totalInputDigestFixture maps repository relative paths to the content of the
+// files of the fixture that is used to test the stability of the total input
+// digest.
+var totalInputDigestFixture = map[string]string{
+	"Makefile":          "all:\n\tcc -o app main.c\n",
+	"app/main.c":        "int main(void) { return 0; }\n",
+	"app/src/util.c":    "int util(void) { return 1; }\n",
+	"app/src/util.h":    "int util(void);\n",
+	"app/src/z/last.c":  "int last(void) { return 2; }\n",
+	"app/README.ignore": "not an input\n",
+}
+
+const totalInputDigestFixtureApp = `
+name = "app"
+
+[Build]
+command = "make"
+
+[Build.Input.Files]
+paths = ["main.c", "src/**", "$ROOT/Makefile"]
+
+[Build.Input.Environment]
+names = ["BAUR_TEST_TOTAL_INPUT_DIGEST_UNSET"]
+
+[[Build.Input.URLs.File]]
+url = "https://example.com/toolchain.tar.gz"
+sha256 = "0000000000000000000000000000000000000000000000000000000000000000"
+`
+
+// createTotalInputDigestFixture creates the fixture repository in dir, files
+// are written in the order of paths.
+func createTotalInputDigestFixture(t *testing.T, dir string, paths []string) *App {
+	t.Helper()
+
+	repoCfgPath := createTestRepository(t, dir)
+
+	for _, p := range paths {
+		absPath := filepath.Join(dir, p)
+
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		fstest.WriteToFile(t, []byte(totalInputDigestFixture[p]), absPath)
+	}
+
+	appCfgPath := filepath.Join(dir, "app", AppCfgFile)
+	fstest.WriteToFile(t, []byte(totalInputDigestFixtureApp), appCfgPath)
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := NewApp(repo, appCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return app
+}
+
+func TestTotalInputDigestGolden(t *testing.T) {
+	const expected = "sha256:8a01235199632e768f918823f5ad8c0a80f6a07a809462f6ac3d9c6d98d96051"
+
+	if err := os.Unsetenv("BAUR_TEST_TOTAL_INPUT_DIGEST_UNSET"); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for p := range totalInputDigestFixture {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	reversedPaths := make([]string, 0, len(paths))
+	for i := len(paths) - 1; i >= 0; i-- {
+		reversedPaths = append(reversedPaths, paths[i])
+	}
+
+	for name, order := range map[string][]string{"sorted": paths, "reversed": reversedPaths} {
+		t.Run(name, func(t *testing.T) {
+			tempDir, cleanupFn := fstest.CreateTempDir(t)
+			defer cleanupFn()
+
+			app := createTotalInputDigestFixture(t, tempDir, order)
+
+			d, err := app.TotalInputDigest()
+			if err != nil {
+				t.Fatal("calculating total input digest failed:", err)
+			}
+
+			if d.String() != expected {
+				t.Errorf("total input digest is %q, expected %q", d.String(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package baur
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/cfg"
+)
+
+// MergedConfig reads the configuration file of the application and returns
+// it with the BuildInput and BuildOutput sections of its includes merged into
+// the Build section. The Includes field of the returned config is empty.
+// If resolveVars is true, the variables in the configuration are replaced
+// with their values.
+func (a *App) MergedConfig(resolveVars bool) (*cfg.App, error) {
+	cfgPath := filepath.Join(a.Path, AppCfgFile)
+
+	appCfg, err := cfg.AppFromFile(cfgPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading application config %s failed", cfgPath)
+	}
+
+	for _, includePath := range appCfg.Build.Includes {
+		inc, err := a.Repository.includeCache.load(a.includeAbsPath(includePath))
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading include '%s' failed", includePath)
+		}
+
+		appCfg.Build.Input.Merge(&inc.BuildInput)
+		appCfg.Build.Output.Merge(&inc.BuildOutput)
+	}
+
+	appCfg.Build.Includes = nil
+
+	if !resolveVars {
+		return appCfg, nil
+	}
+
+	a.resolveInputCfgVars(&appCfg.Build.Input)
+
+	if err := a.resolveOutputCfgVars(&appCfg.Build.Output); err != nil {
+		return nil, err
+	}
+
+	return appCfg, nil
+}
+
+func (a *App) replaceROOTvars(in []string) {
+	for i := range in {
+		in[i] = replaceROOTvar(in[i], a.Repository)
+	}
+}
+
+// resolveInputCfgVars replaces the $ROOT variables in the BuildInput section
+func (a *App) resolveInputCfgVars(in *cfg.BuildInput) {
+	a.replaceROOTvars(in.Files.Paths)
+	a.replaceROOTvars(in.Files.Excludes)
+	a.replaceROOTvars(in.Directories.Paths)
+	a.replaceROOTvars(in.GitFiles.Paths)
+	a.replaceROOTvars(in.GolangSources.Paths)
+	a.replaceROOTvars(in.GolangSources.Environment)
+}
+
+// resolveOutputCfgVars replaces the variables in the BuildOutput section,
+// the same variables as for the outputs of the App are supported.
+func (a *App) resolveOutputCfgVars(out *cfg.BuildOutput) error {
+	var err error
+
+	for _, f := range out.File {
+		if f.Path, err = a.resolveOutputVars(f.Path, varAppName, varOS, varArch); err != nil {
+			return errors.Wrap(err, "resolving variables in path failed")
+		}
+
+		if f.FileCopy.Path, err = a.resolveOutputVars(f.FileCopy.Path,
+			varAppName, varUUID, varGitCommit, varOS, varArch); err != nil {
+			return errors.Wrap(err, "resolving variables in FileCopy path failed")
+		}
+
+		if f.S3Upload.Bucket, err = a.resolveOutputVars(f.S3Upload.Bucket, varAppName); err != nil {
+			return errors.Wrap(err, "resolving variables in S3Upload bucket failed")
+		}
+
+		if f.S3Upload.DestFile, err = a.resolveOutputVars(f.S3Upload.DestFile,
+			varAppName, varUUID, varGitCommit, varOS, varArch); err != nil {
+			return errors.Wrap(err, "resolving variables in S3Upload dest_file failed")
+		}
+
+		if f.GCSUpload.Bucket, err = a.resolveOutputVars(f.GCSUpload.Bucket, varAppName); err != nil {
+			return errors.Wrap(err, "resolving variables in GCSUpload bucket failed")
+		}
+
+		if f.GCSUpload.DestFile, err = a.resolveOutputVars(f.GCSUpload.DestFile,
+			varAppName, varUUID, varGitCommit, varOS, varArch); err != nil {
+			return errors.Wrap(err, "resolving variables in GCSUpload dest_file failed")
+		}
+
+		if f.AzureBlobUpload.Container, err = a.resolveOutputVars(f.AzureBlobUpload.Container, varAppName); err != nil {
+			return errors.Wrap(err, "resolving variables in AzureBlobUpload container failed")
+		}
+
+		if f.AzureBlobUpload.DestBlob, err = a.resolveOutputVars(f.AzureBlobUpload.DestBlob,
+			varAppName, varUUID, varGitCommit, varOS, varArch); err != nil {
+			return errors.Wrap(err, "resolving variables in AzureBlobUpload dest_blob failed")
+		}
+	}
+
+	for _, d := range out.DockerImage {
+		if d.IDFile, err = a.resolveOutputVars(d.IDFile, varAppName); err != nil {
+			return errors.Wrap(err, "resolving variables in idfile failed")
+		}
+
+		for i, idFile := range d.IDFiles {
+			if d.IDFiles[i], err = a.resolveOutputVars(idFile, varAppName); err != nil {
+				return errors.Wrap(err, "resolving variables in idfiles failed")
+			}
+		}
+
+		if d.RegistryUpload.Repository, err = a.resolveOutputVars(d.RegistryUpload.Repository,
+			varAppName); err != nil {
+			return errors.Wrap(err, "resolving variables in repository failed")
+		}
+
+		if d.RegistryUpload.Tag, err = a.resolveOutputVars(d.RegistryUpload.Tag,
+			varAppName, varUUID, varGitCommit, varOS, varArch); err != nil {
+			return errors.Wrap(err, "resolving variables in tag failed")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package baur
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestMergedConfig(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+
+	fstest.WriteToFile(t, []byte("content"), filepath.Join(tempDir, "Makefile"))
+	fstest.WriteToFile(t, []byte(`
+[BuildInput.Files]
+paths = ["$ROOT/Makefile"]
+
+[[BuildOutput.File]]
+path = "dist/$APPNAME.tar"
+
+[BuildOutput.File.FileCopy]
+path = "/tmp/$APPNAME-$OS.tar"
+`), filepath.Join(tempDir, "include.toml"))
+
+	appDir := filepath.Join(tempDir, "calc")
+	createTestApp(t, appDir, "calc", "$ROOT/include.toml")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := repo.AppByDir(appDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appCfg, err := app.MergedConfig(false)
+	if err != nil {
+		t.Fatal("MergedConfig failed:", err)
+	}
+
+	if len(appCfg.Build.Includes) != 0 {
+		t.Errorf("merged config contains includes: %v", appCfg.Build.Includes)
+	}
+
+	expectedPaths := []string{"main.c", "$ROOT/Makefile"}
+	if len(appCfg.Build.Input.Files.Paths) != 2 ||
+		appCfg.Build.Input.Files.Paths[0] != expectedPaths[0] ||
+		appCfg.Build.Input.Files.Paths[1] != expectedPaths[1] {
+		t.Errorf("merged file inputs are %v, expected %v", appCfg.Build.Input.Files.Paths, expectedPaths)
+	}
+
+	if len(appCfg.Build.Output.File) != 1 || appCfg.Build.Output.File[0].Path != "dist/$APPNAME.tar" {
+		t.Fatalf("merged file outputs are unexpected: %+v", appCfg.Build.Output.File)
+	}
+
+	resolvedCfg, err := app.MergedConfig(true)
+	if err != nil {
+		t.Fatal("MergedConfig with resolving variables failed:", err)
+	}
+
+	if p := resolvedCfg.Build.Input.Files.Paths[1]; p != filepath.Join(tempDir, "Makefile") {
+		t.Errorf("$ROOT was not replaced in input path: %q", p)
+	}
+
+	if p := resolvedCfg.Build.Output.File[0].Path; p != "dist/calc.tar" {
+		t.Errorf("$APPNAME was not replaced in output path: %q", p)
+	}
+
+	// the outputs of the cached include must not be modified
+	unresolvedCfg, err := app.MergedConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := unresolvedCfg.Build.Output.File[0].FileCopy.Path; p != "/tmp/$APPNAME-$OS.tar" {
+		t.Errorf("include was modified when resolving variables, FileCopy path is %q", p)
+	}
+}
@@ -0,0 +1,278 @@
+package baur
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/fs"
+	"github.com/simplesurance/baur/log"
+)
+
+// appLoader discovers application config files in the search directories of
+// a repository and loads them.
+// Loaded applications are cached, each application config file is only read
+// and parsed once.
+type appLoader struct {
+	repository  *Repository
+	searchDirs  []string
+	searchDepth int
+	// searchDepthOverrides contains absolute paths of directories that
+	// are searched with a different depth than searchDepth
+	searchDepthOverrides map[string]int
+	symlinks             fs.SymlinkMode
+	// ignore matches directories that are not searched for application
+	// configs, it is nil if no directories are ignored
+	ignore *fs.IgnoreFileMatcher
+	// parallelism is the max. number of application configs that are
+	// loaded concurrently by All()
+	parallelism int
+
+	lock sync.Mutex
+	// appConfigs contains the absolute paths of all discovered application
+	// config files, the value is nil until the config file was loaded
+	appConfigs map[string]*App
+}
+
+// newAppLoader returns a new appLoader that searches for application configs in
+// searchDirs. searchDirs must be absolute paths to existing directories.
+// searchDepthOverrides maps absolute directory paths to search depths that
+// are used instead of searchDepth when searching in the directory. The
+// directories are searched in addition to searchDirs.
+// symlinks defines if symlinks to directories are followed.
+// Directories matched by ignore are not descended into, ignore can be nil.
+func newAppLoader(repository *Repository, searchDirs []string, searchDepth int, searchDepthOverrides map[string]int, symlinks fs.SymlinkMode, ignore *fs.IgnoreFileMatcher) (*appLoader, error) {
+	for _, dir := range searchDirs {
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("search directory '%s' is not an absolute path", dir)
+		}
+	}
+
+	if err := fs.DirsExist(searchDirs...); err != nil {
+		return nil, err
+	}
+
+	for dir := range searchDepthOverrides {
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("search depth override directory '%s' is not an absolute path", dir)
+		}
+
+		if err := fs.DirsExist(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &appLoader{
+		repository:           repository,
+		searchDirs:           searchDirs,
+		searchDepth:          searchDepth,
+		searchDepthOverrides: searchDepthOverrides,
+		symlinks:             symlinks,
+		ignore:               ignore,
+		parallelism:          runtime.NumCPU(),
+	}, nil
+}
+
+// discover searches for application config files in the search directories
+// and stores their paths in appConfigs.
+// The search is only done on the first call.
+func (l *appLoader) discover() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.appConfigs != nil {
+		return nil
+	}
+
+	appConfigs := map[string]*App{}
+
+	for searchDir, depth := range l.searchDepths() {
+		log.Debugf("searching for application configs in '%s', max. depth: %d", searchDir, depth)
+
+		appsCfgPaths, err := l.findAppConfigs(searchDir, depth)
+		if err != nil {
+			return errors.Wrap(err, "finding application configs failed")
+		}
+
+		for _, appCfgPath := range appsCfgPaths {
+			appCfgPath = filepath.Clean(appCfgPath)
+			if _, exist := appConfigs[appCfgPath]; exist {
+				continue
+			}
+
+			log.Debugf("discovered application config '%s'", appCfgPath)
+			appConfigs[appCfgPath] = nil
+		}
+	}
+
+	l.appConfigs = appConfigs
+
+	return nil
+}
+
+// findAppConfigs returns the paths of application config files in searchDir,
+// directories matched by l.ignore are skipped
+func (l *appLoader) findAppConfigs(searchDir string, depth int) ([]string, error) {
+	if l.ignore == nil {
+		return fs.FindFilesInSubDir(searchDir, AppCfgFile, depth, l.symlinks)
+	}
+
+	return fs.FindFilesInSubDirFiltered(searchDir, AppCfgFile, depth, l.symlinks, l.ignore.MatchDir)
+}
+
+// searchDepths returns the directories that are searched for application
+// configs with their search depth
+func (l *appLoader) searchDepths() map[string]int {
+	res := make(map[string]int, len(l.searchDirs)+len(l.searchDepthOverrides))
+
+	for _, dir := range l.searchDirs {
+		res[dir] = l.searchDepth
+	}
+
+	for dir, depth := range l.searchDepthOverrides {
+		res[dir] = depth
+	}
+
+	return res
+}
+
+// load returns the App for the discovered config file, if it was not loaded
+// before, it is read and stored in appConfigs.
+// It is safe to call load concurrently.
+func (l *appLoader) load(cfgPath string) (*App, error) {
+	l.lock.Lock()
+	app := l.appConfigs[cfgPath]
+	l.lock.Unlock()
+
+	if app != nil {
+		return app, nil
+	}
+
+	app, err := NewApp(l.repository, cfgPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading %s failed", cfgPath)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// the config might have been loaded concurrently, return the stored
+	// App to ensure the same object is always returned for a config
+	if cached := l.appConfigs[cfgPath]; cached != nil {
+		return cached, nil
+	}
+
+	l.appConfigs[cfgPath] = app
+
+	return app, nil
+}
+
+// AppConfig returns the application for the config file at cfgPath.
+// The config file must have been discovered in the search directories,
+// otherwise os.ErrNotExist is returned.
+func (l *appLoader) AppConfig(cfgPath string) (*App, error) {
+	if err := l.discover(); err != nil {
+		return nil, err
+	}
+
+	absPath, err := filepath.Abs(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	absPath = filepath.Clean(absPath)
+
+	l.lock.Lock()
+	_, exist := l.appConfigs[absPath]
+	l.lock.Unlock()
+
+	if !exist {
+		return nil, os.ErrNotExist
+	}
+
+	return l.load(absPath)
+}
+
+// ByName loads the discovered application configs until it finds an
+// application with the given name.
+// If none is found os.ErrNotExist is returned. If multiple applications with
+// the name exist, an error is returned.
+func (l *appLoader) ByName(name string) (*App, error) {
+	apps, err := l.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var result *App
+	for _, app := range apps {
+		if app.Name != name {
+			continue
+		}
+
+		if result != nil {
+			return nil, fmt.Errorf("application name %q is ambiguous, it is used in %s and %s",
+				name, result.Path, app.Path)
+		}
+
+		result = app
+	}
+
+	if result == nil {
+		return nil, os.ErrNotExist
+	}
+
+	return result, nil
+}
+
+// ConfigPaths returns the paths of all discovered application config files
+// sorted by path.
+func (l *appLoader) ConfigPaths() ([]string, error) {
+	if err := l.discover(); err != nil {
+		return nil, err
+	}
+
+	l.lock.Lock()
+	cfgPaths := make([]string, 0, len(l.appConfigs))
+	for cfgPath := range l.appConfigs {
+		cfgPaths = append(cfgPaths, cfgPath)
+	}
+	l.lock.Unlock()
+
+	sort.Strings(cfgPaths)
+
+	return cfgPaths, nil
+}
+
+// All loads all discovered applications and returns them sorted by the
+// path of their config file.
+// Up to parallelism application configs are loaded concurrently, if loading
+// fails for one of them the first error that happened is returned.
+func (l *appLoader) All() ([]*App, error) {
+	cfgPaths, err := l.ConfigPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*App, len(cfgPaths))
+
+	err = forEachParallel(context.Background(), len(cfgPaths), l.parallelism, func(_ context.Context, idx int) error {
+		app, err := l.load(cfgPaths[idx])
+		if err != nil {
+			return err
+		}
+
+		result[idx] = app
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,571 @@
+package baur
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/cfg"
+	"github.com/simplesurance/baur/exec"
+	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+// createTestRepository creates a repository config in dir and returns the
+// path to it.
+func createTestRepository(t testing.TB, dir string) string {
+	t.Helper()
+
+	repoCfg := cfg.ExampleRepository()
+	repoCfg.Discover.Dirs = []string{"."}
+	repoCfg.Discover.SearchDepth = 2
+
+	cfgPath := filepath.Join(dir, RepositoryCfgFile)
+	if err := repoCfg.ToFile(cfgPath, false); err != nil {
+		t.Fatal("writing repository config failed:", err)
+	}
+
+	return cfgPath
+}
+
+// createTestApp creates an application config with the given name in dir,
+// the app includes the passed include files.
+func createTestApp(t testing.TB, dir, name string, includes ...string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fstest.WriteToFile(t, []byte("content"), filepath.Join(dir, "main.c"))
+
+	var includesStr string
+	for _, inc := range includes {
+		includesStr += fmt.Sprintf("%q,", inc)
+	}
+
+	appCfg := fmt.Sprintf(`
+name = %q
+
+[Build]
+command = "make"
+includes = [%s]
+
+[Build.Input.Files]
+paths = ["main.c"]
+`, name, includesStr)
+
+	fstest.WriteToFile(t, []byte(appCfg), filepath.Join(dir, AppCfgFile))
+}
+
+func TestAppLoaderAll(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+
+	includePath := filepath.Join(tempDir, "include.toml")
+	fstest.WriteToFile(t, []byte("content"), filepath.Join(tempDir, "Makefile"))
+	fstest.WriteToFile(t, []byte(`
+[BuildInput.Files]
+paths = ["$ROOT/Makefile"]
+`), includePath)
+
+	createTestApp(t, filepath.Join(tempDir, "b"), "appB", "$ROOT/include.toml")
+	createTestApp(t, filepath.Join(tempDir, "a"), "appA")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps, err := repo.appLoader.All()
+	if err != nil {
+		t.Fatal("All() failed:", err)
+	}
+
+	if len(apps) != 2 {
+		t.Fatalf("All() returned %d apps, expected 2", len(apps))
+	}
+
+	if apps[0].Name != "appA" || apps[1].Name != "appB" {
+		t.Errorf("All() returned apps in unexpected order: %s, %s", apps[0], apps[1])
+	}
+
+	inputs, err := apps[1].BuildInputs()
+	if err != nil {
+		t.Fatal("resolving build inputs failed:", err)
+	}
+
+	var found bool
+	for _, in := range inputs {
+		if in.RepoRelPath() == "Makefile" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("build input from include file is missing in %v", inputs)
+	}
+
+	apps2, err := repo.appLoader.All()
+	if err != nil {
+		t.Fatal("second All() call failed:", err)
+	}
+
+	if apps2[0] != apps[0] || apps2[1] != apps[1] {
+		t.Error("second All() call returned different App objects, expected cached ones")
+	}
+}
+
+func TestAppLoaderAllWrapsErrorWithPath(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+
+	appDir := filepath.Join(tempDir, "broken")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fstest.WriteToFile(t, []byte("name = "), filepath.Join(appDir, AppCfgFile))
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.appLoader.All()
+	if err == nil {
+		t.Fatal("All() succeeded for an invalid app config")
+	}
+
+	if !strings.Contains(err.Error(), filepath.Join(appDir, AppCfgFile)) {
+		t.Errorf("error message %q does not contain the path of the invalid config", err)
+	}
+}
+
+// TestAppLoaderAllConcurrentGitCommit loads applications that reference
+// $GITCOMMIT concurrently, the Git commit ID is cached in the Repository.
+// Data races are only detected when the test is run with -race.
+func TestAppLoaderAllConcurrentGitCommit(t *testing.T) {
+	const appCnt = 16
+
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+
+	for i := 0; i < appCnt; i++ {
+		appDir := filepath.Join(tempDir, fmt.Sprintf("app%d", i))
+		createTestApp(t, appDir, fmt.Sprintf("app%d", i))
+
+		fstest.WriteToFile(t, []byte(fmt.Sprintf(`
+name = "app%d"
+
+[Build]
+command = "make"
+
+[Build.Input.Files]
+paths = ["main.c"]
+
+[[Build.Output.File]]
+path = "dist/app.tar"
+
+[Build.Output.File.FileCopy]
+path = "/mnt/$APPNAME-$GITCOMMIT.tar"
+`, i)), filepath.Join(appDir, AppCfgFile))
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q", "."},
+		{"add", "."},
+		{"-c", "user.name=baur", "-c", "user.email=baur@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if _, err := exec.Command("git", args...).Directory(tempDir).ExpectSuccess().Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps, err := repo.WithAppLoadParallelism(4).FindApps()
+	if err != nil {
+		t.Fatal("FindApps() failed:", err)
+	}
+
+	if len(apps) != appCnt {
+		t.Fatalf("FindApps() returned %d apps, expected %d", len(apps), appCnt)
+	}
+
+	commitID, err := repo.GitCommitID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, app := range apps {
+		if !strings.Contains(app.Outputs[0].UploadDestination(), commitID) {
+			t.Errorf("%s: upload destination %s does not contain the commit ID %s", app, app.Outputs[0].UploadDestination(), commitID)
+		}
+	}
+}
+
+func BenchmarkAppLoaderAll(b *testing.B) {
+	tempDir, cleanupFn := fstest.CreateTempDir(b)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(b, tempDir)
+
+	for i := 0; i < 200; i++ {
+		createTestApp(b, filepath.Join(tempDir, fmt.Sprintf("app%d", i)), fmt.Sprintf("app%d", i))
+	}
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, parallelism := range []int{1, runtime.NumCPU()} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// a new loader is created on each iteration, to not
+				// benchmark returning cached apps
+				loader, err := newAppLoader(repo, repo.appLoader.searchDirs, repo.appLoader.searchDepth, repo.appLoader.searchDepthOverrides, repo.appLoader.symlinks, repo.appLoader.ignore)
+				if err != nil {
+					b.Fatal(err)
+				}
+				loader.parallelism = parallelism
+
+				if _, err := loader.All(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAppLoaderUsesSearchDepthOverrides(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfg := cfg.ExampleRepository()
+	repoCfg.Discover.Dirs = []string{"."}
+	repoCfg.Discover.SearchDepth = 1
+	repoCfg.Discover.SearchDepthOverrides = []*cfg.SearchDepthOverride{
+		{Dir: "vendor", Depth: 4},
+	}
+
+	repoCfgPath := filepath.Join(tempDir, RepositoryCfgFile)
+	if err := repoCfg.ToFile(repoCfgPath, false); err != nil {
+		t.Fatal("writing repository config failed:", err)
+	}
+
+	createTestApp(t, filepath.Join(tempDir, "a"), "appA")
+	createTestApp(t, filepath.Join(tempDir, "services", "x", "b"), "appB")
+	createTestApp(t, filepath.Join(tempDir, "vendor", "x", "y", "z", "c"), "appC")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps, err := repo.appLoader.All()
+	if err != nil {
+		t.Fatal("All() failed:", err)
+	}
+
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+
+	expected := []string{"appA", "appC"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("All() returned apps %v, expected %v", names, expected)
+	}
+}
+
+func TestAppLoaderSkipsDirsInIgnoreFile(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfg := cfg.ExampleRepository()
+	repoCfg.Discover.Dirs = []string{"."}
+	repoCfg.Discover.SearchDepth = 3
+
+	repoCfgPath := filepath.Join(tempDir, RepositoryCfgFile)
+	if err := repoCfg.ToFile(repoCfgPath, false); err != nil {
+		t.Fatal("writing repository config failed:", err)
+	}
+
+	fstest.WriteToFile(t, []byte("# dependencies\nnode_modules/\n/build\n"), filepath.Join(tempDir, IgnoreFile))
+
+	createTestApp(t, filepath.Join(tempDir, "a"), "appA")
+	createTestApp(t, filepath.Join(tempDir, "node_modules", "b"), "appB")
+	createTestApp(t, filepath.Join(tempDir, "web", "node_modules", "c"), "appC")
+	createTestApp(t, filepath.Join(tempDir, "build"), "appD")
+	createTestApp(t, filepath.Join(tempDir, "web", "build"), "appE")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps, err := repo.appLoader.All()
+	if err != nil {
+		t.Fatal("All() failed:", err)
+	}
+
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+
+	expected := []string{"appA", "appE"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("All() returned apps %v, expected %v", names, expected)
+	}
+}
+
+func TestAppLoaderFollowsSymlinksWhenEnabled(t *testing.T) {
+	for _, follow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("follow-%t", follow), func(t *testing.T) {
+			tempDir, cleanupFn := fstest.CreateTempDir(t)
+			defer cleanupFn()
+
+			repoDir := filepath.Join(tempDir, "repo")
+			if err := os.Mkdir(repoDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			repoCfg := cfg.ExampleRepository()
+			repoCfg.Discover.Dirs = []string{"."}
+			repoCfg.Discover.SearchDepth = 2
+			repoCfg.Discover.FollowSymlinks = &follow
+
+			repoCfgPath := filepath.Join(repoDir, RepositoryCfgFile)
+			if err := repoCfg.ToFile(repoCfgPath, false); err != nil {
+				t.Fatal("writing repository config failed:", err)
+			}
+
+			createTestApp(t, filepath.Join(repoDir, "a"), "appA")
+			createTestApp(t, filepath.Join(tempDir, "shared", "b"), "appB")
+
+			if err := os.Symlink(filepath.Join(tempDir, "shared"), filepath.Join(repoDir, "shared")); err != nil {
+				t.Fatal(err)
+			}
+
+			// a symlink loop must not cause an endless search
+			if err := os.Symlink(repoDir, filepath.Join(repoDir, "a", "root")); err != nil {
+				t.Fatal(err)
+			}
+
+			repo, err := NewRepository(repoCfgPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			apps, err := repo.appLoader.All()
+			if err != nil {
+				t.Fatal("All() failed:", err)
+			}
+
+			var names []string
+			for _, app := range apps {
+				names = append(names, app.Name)
+			}
+
+			expected := []string{"appA"}
+			if follow {
+				expected = append(expected, "appB")
+			}
+
+			if !reflect.DeepEqual(names, expected) {
+				t.Errorf("All() returned apps %v, expected %v", names, expected)
+			}
+		})
+	}
+}
+
+func TestAppLoaderLogsDiscoveryInVerboseMode(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+
+	includePath := filepath.Join(tempDir, "include.toml")
+	fstest.WriteToFile(t, []byte("content"), filepath.Join(tempDir, "Makefile"))
+	fstest.WriteToFile(t, []byte(`
+[BuildInput.Files]
+paths = ["$ROOT/Makefile"]
+`), includePath)
+
+	createTestApp(t, filepath.Join(tempDir, "a"), "appA", "$ROOT/include.toml")
+	createTestApp(t, filepath.Join(tempDir, "b"), "appB")
+
+	var logBuf bytes.Buffer
+	log.StdLogger.SetOutput(&logBuf)
+	defer log.StdLogger.SetOutput(os.Stderr)
+
+	for _, verbose := range []bool{false, true} {
+		logBuf.Reset()
+		log.StdLogger.EnableDebug(verbose)
+
+		repo, err := NewRepository(repoCfgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		apps, err := repo.FindApps()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, app := range apps {
+			if _, err := app.BuildInputs(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if !verbose {
+			if logBuf.Len() != 0 {
+				t.Errorf("messages were logged when verbose mode is disabled: %q", logBuf.String())
+			}
+
+			continue
+		}
+
+		expectedLines := []string{
+			fmt.Sprintf("discovered application config '%s'", filepath.Join(tempDir, "a", AppCfgFile)),
+			fmt.Sprintf("discovered application config '%s'", filepath.Join(tempDir, "b", AppCfgFile)),
+			fmt.Sprintf("appA: merging include '%s'", includePath),
+			fmt.Sprintf("appA: Files input '%s' matched 1 files", filepath.Join(tempDir, "Makefile")),
+		}
+
+		for _, line := range expectedLines {
+			if !strings.Contains(logBuf.String(), line) {
+				t.Errorf("log output does not contain %q:\n%s", line, logBuf.String())
+			}
+		}
+	}
+
+	log.StdLogger.EnableDebug(false)
+}
+
+func TestAppLoaderSetsAppPaths(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "sub", "app")
+	createTestApp(t, appDir, "app")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps, err := repo.appLoader.All()
+	if err != nil {
+		t.Fatal("All() failed:", err)
+	}
+
+	if len(apps) != 1 {
+		t.Fatalf("All() returned %d apps, expected 1", len(apps))
+	}
+
+	if apps[0].Name != "app" {
+		t.Errorf("app name is %q, expected %q", apps[0].Name, "app")
+	}
+
+	if apps[0].Path != appDir {
+		t.Errorf("app path is %q, expected the directory containing the %s file %q",
+			apps[0].Path, AppCfgFile, appDir)
+	}
+
+	if !filepath.IsAbs(apps[0].Path) {
+		t.Errorf("app path %q is not absolute", apps[0].Path)
+	}
+
+	expectedRelPath := filepath.Join("sub", "app")
+	if apps[0].RelPath != expectedRelPath {
+		t.Errorf("app relative path is %q, expected %q", apps[0].RelPath, expectedRelPath)
+	}
+}
+
+// BenchmarkResolveBuildInputs compares discovering all applications of a
+// repository and resolving their build inputs with and without caching stat
+// results. All applications share the same input files.
+func BenchmarkResolveBuildInputs(b *testing.B) {
+	const (
+		appCnt  = 50
+		dirCnt  = 20
+		fileCnt = 20
+	)
+
+	tempDir, cleanupFn := fstest.CreateTempDir(b)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(b, tempDir)
+
+	for d := 0; d < dirCnt; d++ {
+		dir := filepath.Join(tempDir, "shared", fmt.Sprintf("dir%d", d))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+
+		for f := 0; f < fileCnt; f++ {
+			fstest.WriteToFile(b, []byte("content"), filepath.Join(dir, fmt.Sprintf("file%d", f)))
+		}
+	}
+
+	fstest.WriteToFile(b, []byte(`
+[BuildInput.Files]
+paths = ["$ROOT/shared/**"]
+`), filepath.Join(tempDir, "include.toml"))
+
+	for i := 0; i < appCnt; i++ {
+		createTestApp(b, filepath.Join(tempDir, fmt.Sprintf("app%d", i)), fmt.Sprintf("app%d", i), "$ROOT/include.toml")
+	}
+
+	resolveAll := func(b *testing.B, cached bool) {
+		repo, err := NewRepository(repoCfgPath)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if !cached {
+			repo.statCache = nil
+		}
+
+		apps, err := repo.appLoader.All()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, app := range apps {
+			if _, err := app.BuildInputs(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			resolveAll(b, false)
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			resolveAll(b, true)
+		}
+	})
+}
@@ -5,3 +5,7 @@ const AppCfgFile = ".app.toml"
 
 // RepositoryCfgFile contains the name of the repository configuration file.
 const RepositoryCfgFile = ".baur.toml"
+
+// IgnoreFile contains the name of the file in the repository root directory
+// that lists directories that are not searched for application configs.
+const IgnoreFile = ".baurignore"
@@ -1,11 +1,13 @@
 package baur
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/pkg/errors"
 
 	"github.com/simplesurance/baur/storage"
+	"github.com/simplesurance/baur/upload"
 )
 
 // BuildStatus indicates if build for a current application version exist
@@ -21,6 +23,9 @@ const (
 	BuildStatusExist
 	// BuildStatusPending no build exist
 	BuildStatusPending
+	// BuildStatusOutputsMissing a build exist but not all of it's uploaded
+	// outputs exist anymore
+	BuildStatusOutputsMissing
 )
 
 func (b BuildStatus) String() string {
@@ -33,6 +38,8 @@ func (b BuildStatus) String() string {
 		return "Pending"
 	case BuildStatusBuildCommandUndefined:
 		return "Build Command Undefined"
+	case BuildStatusOutputsMissing:
+		return "Outputs Missing"
 	default:
 		panic(fmt.Sprintf("incompatible BuildStatus value: %d", b))
 	}
@@ -42,7 +49,7 @@ func (b BuildStatus) String() string {
 // storage if a build for this input digest already exist.
 // If the function returns BuildStatusExist the returned build pointer is valid
 // otherwise it is nil.
-func GetBuildStatus(storer storage.Storer, app *App) (BuildStatus, *storage.BuildWithDuration, error) {
+func GetBuildStatus(ctx context.Context, storer storage.Storer, app *App) (BuildStatus, *storage.BuildWithDuration, error) {
 	if len(app.BuildCmd) == 0 {
 		return BuildStatusBuildCommandUndefined, nil, nil
 	}
@@ -56,7 +63,7 @@ func GetBuildStatus(storer storage.Storer, app *App) (BuildStatus, *storage.Buil
 		return -1, nil, errors.Wrap(err, "calculating total input digest failed")
 	}
 
-	build, err := storer.GetLatestBuildByDigest(app.Name, d.String())
+	build, err := storer.GetLatestBuildByDigest(ctx, app.Name, d.String())
 	if err != nil {
 		if err == storage.ErrNotExist {
 			return BuildStatusPending, nil, nil
@@ -67,3 +74,29 @@ func GetBuildStatus(storer storage.Storer, app *App) (BuildStatus, *storage.Buil
 
 	return BuildStatusExist, build, nil
 }
+
+// MissingOutputs checks if the uploaded outputs of the build still exist. The
+// checker for an output is chosen by it's upload method, outputs with upload
+// methods that have no checker in checkers are not verified.
+// The outputs that do not exist are returned.
+func MissingOutputs(build *storage.BuildWithDuration, checkers map[storage.UploadMethod]upload.ExistChecker) ([]*storage.Output, error) {
+	var res []*storage.Output
+
+	for _, out := range build.Outputs {
+		checker, exist := checkers[out.Upload.Method]
+		if !exist {
+			continue
+		}
+
+		exists, err := checker.Exists(out.Upload.URI)
+		if err != nil {
+			return nil, errors.Wrapf(err, "checking if output %s exists at %s failed", out.Name, out.Upload.URI)
+		}
+
+		if !exists {
+			res = append(res, out)
+		}
+	}
+
+	return res, nil
+}
@@ -1,28 +1,178 @@
 package build
 
 import (
+	"io"
 	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/exec"
 )
 
+// MaxOutputSize is the max. number of bytes of the command output that are
+// kept in Result.Output, if the output is longer the last MaxOutputSize
+// bytes are kept.
+const MaxOutputSize = 1024 * 1024
+
 // Result result of a build job
 type Result struct {
 	Job   *Job
 	Error error
+	// Skipped is true if the command was not run because the
+	// PreCommand of the job failed, Error describes the failure
+	Skipped bool
 
 	StartTs  time.Time
 	StopTs   time.Time
 	ExitCode int
-	Output   string
+	// Output contains the combined STDOUT and STDERR output of Command,
+	// it is truncated to MaxOutputSize bytes.
+	Output string
 }
 
-// Job describes abuild job
+// Job describes abuild job.
+// If Args is empty, Command is executed in a shell. Otherwise Command is the
+// program that is executed with Args as arguments.
 type Job struct {
 	Application string
 	Directory   string
 	Command     string
+	Args        []string
+	// PreCommand is run in a shell before Command, if it fails Command
+	// is not run.
+	PreCommand string
+	// PostCommand is run in a shell by RunPostCommand.
+	PostCommand string
+	// Timeout is the duration after which Command and all processes it
+	// started are killed and the job fails. 0 disables the timeout.
+	Timeout time.Duration
+	// Environment contains additional environment variables in the
+	// format KEY=VALUE that are set when the command is run.
+	Environment []string
+	// OutputStream, if set, receives the output of the commands
+	// while they run. Each line is prefixed with the application name.
+	OutputStream io.Writer
+	// OutputPaths are the paths of the outputs that are created by the
+	// job. Builders must not run jobs with a common output path
+	// concurrently.
+	OutputPaths []string
 	UserData    interface{}
 }
 
+// Failed returns true if the job could not be executed or the command
+// exited with a non-zero exit code. Skipped jobs did not fail.
+func (r *Result) Failed() bool {
+	return !r.Skipped && (r.Error != nil || r.ExitCode != 0)
+}
+
+// Run executes the command of the job in it's directory and returns the
+// result.
+// If the job has a PreCommand, it is run first. If it fails, Command is not
+// run and a Result with Skipped set to true is returned.
+func Run(j *Job) *Result {
+	startTime := time.Now()
+
+	if len(j.PreCommand) != 0 {
+		if err := runHook(j, j.PreCommand); err != nil {
+			return &Result{
+				Job:     j,
+				Error:   errors.Wrap(err, "pre_command failed"),
+				Skipped: true,
+				StartTs: startTime,
+				StopTs:  time.Now(),
+			}
+		}
+	}
+
+	var cmd *exec.Cmd
+	if len(j.Args) == 0 {
+		cmd = exec.ShellCommand(j.Command)
+	} else {
+		cmd = exec.Command(j.Command, j.Args...)
+	}
+
+	cmdRes, err := cmd.
+		Directory(j.Directory).
+		Env(j.Environment).
+		Timeout(j.Timeout).
+		OutputWriter(outputWriter(j)).
+		OutputLimit(MaxOutputSize).
+		DebugfPrefix(color.YellowString(j.Application + ": ")).
+		Run()
+	res := Result{
+		Job:     j,
+		Error:   err,
+		StartTs: startTime,
+		StopTs:  time.Now(),
+	}
+
+	if cmdRes != nil {
+		res.ExitCode = cmdRes.ExitCode
+		res.Output = cmdRes.StrOutput()
+	}
+
+	return &res
+}
+
+// RunPostCommand runs the PostCommand of the job in a shell, in the same
+// directory and with the same environment as Command. If the job has no
+// PostCommand, nothing is done.
+func RunPostCommand(j *Job) error {
+	if len(j.PostCommand) == 0 {
+		return nil
+	}
+
+	if err := runHook(j, j.PostCommand); err != nil {
+		return errors.Wrap(err, "post_command failed")
+	}
+
+	return nil
+}
+
+func runHook(j *Job, command string) error {
+	_, err := exec.ShellCommand(command).
+		Directory(j.Directory).
+		Env(j.Environment).
+		OutputWriter(outputWriter(j)).
+		OutputLimit(MaxOutputSize).
+		DebugfPrefix(color.YellowString(j.Application + ": ")).
+		ExpectSuccess().
+		Run()
+
+	return err
+}
+
+// prefixWriter prepends a prefix to the data of every Write call
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	buf := make([]byte, 0, len(p.prefix)+len(b))
+	buf = append(append(buf, p.prefix...), b...)
+
+	if _, err := p.w.Write(buf); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// outputWriter returns the writer that the command output of the job is
+// streamed to, nil is returned if the job has no OutputStream.
+func outputWriter(j *Job) io.Writer {
+	if j.OutputStream == nil {
+		return nil
+	}
+
+	return &prefixWriter{
+		w:      j.OutputStream,
+		prefix: color.YellowString(j.Application + ": "),
+	}
+}
+
 // Builder is an interface for builders
 type Builder interface {
 	Start()
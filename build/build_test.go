@@ -0,0 +1,146 @@
+package build
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/exec"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func readOrderFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestHooksAreRunInOrder(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	job := Job{
+		Application: "calc",
+		Directory:   tempDir,
+		Command:     "echo build >> order",
+		PreCommand:  "echo pre-$HOOK_VAR >> order",
+		PostCommand: "echo post-$HOOK_VAR >> order",
+		Environment: []string{"HOOK_VAR=set"},
+	}
+
+	res := Run(&job)
+	if res.Failed() || res.Skipped {
+		t.Fatalf("build failed: %+v", res)
+	}
+
+	if err := RunPostCommand(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	order := readOrderFile(t, filepath.Join(tempDir, "order"))
+	if order != "pre-set\nbuild\npost-set\n" {
+		t.Errorf("commands were run in order %q, expected pre_command, command, post_command", order)
+	}
+}
+
+func TestFailingPreCommandSkipsBuild(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	job := Job{
+		Application: "calc",
+		Directory:   tempDir,
+		Command:     "echo build >> order",
+		PreCommand:  "echo pre >> order; false",
+	}
+
+	res := Run(&job)
+	if !res.Skipped {
+		t.Errorf("build was not skipped: %+v", res)
+	}
+
+	if res.Error == nil {
+		t.Error("result of skipped build has no error")
+	}
+
+	if res.Failed() {
+		t.Error("skipped build is reported as failed")
+	}
+
+	if order := readOrderFile(t, filepath.Join(tempDir, "order")); order != "pre\n" {
+		t.Errorf("commands were run in order %q, expected only pre_command", order)
+	}
+}
+
+func TestFailingPostCommandReturnsError(t *testing.T) {
+	job := Job{
+		Application: "calc",
+		Directory:   ".",
+		PostCommand: "exit 1",
+	}
+
+	if err := RunPostCommand(&job); err == nil {
+		t.Error("RunPostCommand succeeded for a failing post_command")
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	job := Job{
+		Application: "calc",
+		Directory:   tempDir,
+		Command:     "sleep",
+		Args:        []string{"30"},
+		Timeout:     100 * time.Millisecond,
+	}
+
+	res := Run(&job)
+	if !res.Failed() {
+		t.Fatalf("build that timed out is not reported as failed: %+v", res)
+	}
+
+	if _, ok := errors.Cause(res.Error).(exec.TimeoutError); !ok {
+		t.Errorf("result error is %v, expected a timeout error", res.Error)
+	}
+}
+
+func TestOutputIsStreamedWithPrefix(t *testing.T) {
+	oldNoColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = oldNoColor }()
+
+	var stream bytes.Buffer
+
+	job := Job{
+		Application:  "calc",
+		Directory:    ".",
+		Command:      "echo pre",
+		PreCommand:   "echo hook",
+		OutputStream: &stream,
+	}
+
+	res := Run(&job)
+	if res.Failed() {
+		t.Fatalf("build failed: %+v", res)
+	}
+
+	if stream.String() != "calc: hook\ncalc: pre\n" {
+		t.Errorf("streamed output is %q, expected the output of the pre_command and command prefixed with the application name", stream.String())
+	}
+
+	if res.Output != "pre" {
+		t.Errorf("result output is %q, expected %q", res.Output, "pre")
+	}
+}
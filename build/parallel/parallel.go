@@ -0,0 +1,134 @@
+// Package parallel provides a builder that runs multiple build jobs
+// concurrently.
+package parallel
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/simplesurance/baur/build"
+)
+
+// Builder runs build jobs concurrently with a limited number of workers.
+// Jobs that have a common output path are not run at the same time.
+type Builder struct {
+	jobs       []*build.Job
+	statusChan chan<- *build.Result
+	workers    int
+	failFast   bool
+
+	lock        sync.Mutex
+	outputLocks map[string]*sync.Mutex
+	failed      bool
+}
+
+// New returns a new builder that runs the jobs with up to workers jobs in
+// parallel. If failFast is true, no further jobs are started after a job
+// failed.
+// Results are sent to the status channel, the channel is closed when all
+// jobs finished. Jobs that were not started because of failFast are not
+// reported.
+func New(jobs []*build.Job, status chan<- *build.Result, workers int, failFast bool) build.Builder {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Builder{
+		jobs:        jobs,
+		statusChan:  status,
+		workers:     workers,
+		failFast:    failFast,
+		outputLocks: map[string]*sync.Mutex{},
+	}
+}
+
+// Start starts building applications
+func (b *Builder) Start() {
+	var wg sync.WaitGroup
+
+	jobChan := make(chan *build.Job)
+
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range jobChan {
+				b.run(j)
+			}
+		}()
+	}
+
+	for _, j := range b.jobs {
+		jobChan <- j
+	}
+
+	close(jobChan)
+	wg.Wait()
+
+	close(b.statusChan)
+}
+
+func (b *Builder) run(j *build.Job) {
+	locks := b.lockOutputs(j)
+	defer unlock(locks)
+
+	if b.failFast && b.hasFailed() {
+		return
+	}
+
+	res := build.Run(j)
+	if res.Failed() {
+		b.lock.Lock()
+		b.failed = true
+		b.lock.Unlock()
+	}
+
+	b.statusChan <- res
+}
+
+func (b *Builder) hasFailed() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.failed
+}
+
+// lockOutputs acquires the locks for all output paths of the job.
+// The locks are always acquired in the same order to prevent deadlocks.
+func (b *Builder) lockOutputs(j *build.Job) []*sync.Mutex {
+	paths := make([]string, len(j.OutputPaths))
+	copy(paths, j.OutputPaths)
+	sort.Strings(paths)
+
+	locks := make([]*sync.Mutex, 0, len(paths))
+
+	b.lock.Lock()
+	for i, p := range paths {
+		if i > 0 && paths[i-1] == p {
+			continue
+		}
+
+		l, exist := b.outputLocks[p]
+		if !exist {
+			l = &sync.Mutex{}
+			b.outputLocks[p] = l
+		}
+
+		locks = append(locks, l)
+	}
+	b.lock.Unlock()
+
+	for _, l := range locks {
+		l.Lock()
+	}
+
+	return locks
+}
+
+func unlock(locks []*sync.Mutex) {
+	for _, l := range locks {
+		l.Unlock()
+	}
+}
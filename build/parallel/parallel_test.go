@@ -0,0 +1,115 @@
+package parallel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/build"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func runJobs(jobs []*build.Job, workers int, failFast bool) []*build.Result {
+	var results []*build.Result
+
+	statusChan := make(chan *build.Result, len(jobs))
+	go New(jobs, statusChan, workers, failFast).Start()
+
+	for res := range statusChan {
+		results = append(results, res)
+	}
+
+	return results
+}
+
+func TestAllJobsAreRun(t *testing.T) {
+	var jobs []*build.Job
+
+	for i := 0; i < 10; i++ {
+		jobs = append(jobs, &build.Job{
+			Application: fmt.Sprintf("app%d", i),
+			Command:     "true",
+		})
+	}
+
+	jobs = append(jobs, &build.Job{Application: "failing", Command: "false"})
+
+	results := runJobs(jobs, 4, false)
+	if len(results) != len(jobs) {
+		t.Fatalf("got %d results, expected %d", len(results), len(jobs))
+	}
+
+	var failedCnt int
+	for _, res := range results {
+		if res.Failed() {
+			failedCnt++
+
+			if res.Job.Application != "failing" {
+				t.Errorf("job of %s failed unexpectedly: %+v", res.Job.Application, res)
+			}
+		}
+	}
+
+	if failedCnt != 1 {
+		t.Errorf("%d jobs failed, expected 1", failedCnt)
+	}
+}
+
+func TestFailFastSkipsRemainingJobs(t *testing.T) {
+	jobs := []*build.Job{
+		{Application: "failing", Command: "false"},
+		{Application: "app1", Command: "true"},
+		{Application: "app2", Command: "true"},
+	}
+
+	results := runJobs(jobs, 1, true)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, expected 1", len(results))
+	}
+
+	if !results[0].Failed() {
+		t.Errorf("job did not fail: %+v", results[0])
+	}
+}
+
+func TestJobsWithCommonOutputDoNotRunConcurrently(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	logPath := filepath.Join(tempDir, "log")
+	cmd := fmt.Sprintf("echo start >> %s; sleep 0.1; echo end >> %s", logPath, logPath)
+
+	var jobs []*build.Job
+	for i := 0; i < 4; i++ {
+		jobs = append(jobs, &build.Job{
+			Application: fmt.Sprintf("app%d", i),
+			Command:     cmd,
+			OutputPaths: []string{"dist/app.tar", fmt.Sprintf("dist/%d", i)},
+		})
+	}
+
+	results := runJobs(jobs, 4, false)
+	for _, res := range results {
+		if res.Failed() {
+			t.Fatalf("job of %s failed: %+v", res.Job.Application, res)
+		}
+	}
+
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Fields(string(content))
+	if len(lines) != 2*len(jobs) {
+		t.Fatalf("log contains %d lines, expected %d", len(lines), 2*len(jobs))
+	}
+
+	for i := 0; i < len(lines); i += 2 {
+		if lines[i] != "start" || lines[i+1] != "end" {
+			t.Fatalf("jobs with a common output ran concurrently, log: %v", lines)
+		}
+	}
+}
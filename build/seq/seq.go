@@ -3,12 +3,7 @@
 package seq
 
 import (
-	"time"
-
-	"github.com/fatih/color"
-
 	"github.com/simplesurance/baur/build"
-	"github.com/simplesurance/baur/exec"
 )
 
 // Builder represents a sequential builder
@@ -28,22 +23,7 @@ func New(jobs []*build.Job, status chan<- *build.Result) build.Builder {
 // Start starts building applications
 func (b *Builder) Start() {
 	for _, j := range b.jobs {
-		startTime := time.Now()
-
-		cmdRes, err := exec.ShellCommand(j.Command).
-			Directory(j.Directory).
-			DebugfPrefix(color.YellowString(j.Application + ": ")).
-			Run()
-		res := build.Result{
-			Job:      j,
-			Error:    err,
-			StartTs:  startTime,
-			StopTs:   time.Now(),
-			ExitCode: cmdRes.ExitCode,
-			Output:   cmdRes.StrOutput(),
-		}
-
-		b.statusChan <- &res
+		b.statusChan <- build.Run(j)
 	}
 
 	close(b.statusChan)
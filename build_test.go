@@ -0,0 +1,174 @@
+package baur
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/baur/storage"
+	"github.com/simplesurance/baur/storage/memory"
+	"github.com/simplesurance/baur/testutils/fstest"
+	"github.com/simplesurance/baur/upload"
+)
+
+// fakeExistChecker reports that the URIs in existing exist, if err is set it
+// is returned for all URIs
+type fakeExistChecker struct {
+	existing map[string]bool
+	err      error
+}
+
+func (f *fakeExistChecker) Exists(uri string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+
+	return f.existing[uri], nil
+}
+
+func newOutput(name string, method storage.UploadMethod, uri string) *storage.Output {
+	return &storage.Output{
+		Name:   name,
+		Upload: storage.Upload{Method: method, URI: uri},
+	}
+}
+
+func TestMissingOutputs(t *testing.T) {
+	build := storage.BuildWithDuration{
+		Build: storage.Build{
+			Outputs: []*storage.Output{
+				newOutput("binary", storage.S3, "s3://bucket/binary"),
+				newOutput("archive", storage.S3, "s3://bucket/archive"),
+				newOutput("image", storage.DockerRegistry, "simplesurance/calc:1"),
+				newOutput("copy", storage.FileCopy, "/tmp/copy"),
+			},
+		},
+	}
+
+	checkers := map[storage.UploadMethod]upload.ExistChecker{
+		storage.S3: &fakeExistChecker{
+			existing: map[string]bool{"s3://bucket/binary": true},
+		},
+		storage.DockerRegistry: &fakeExistChecker{},
+	}
+
+	missing, err := MissingOutputs(&build, checkers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(missing) != 2 {
+		t.Fatalf("MissingOutputs returned %d outputs, expected 2", len(missing))
+	}
+
+	if missing[0].Name != "archive" {
+		t.Errorf("first missing output is %q, expected %q", missing[0].Name, "archive")
+	}
+
+	if missing[1].Name != "image" {
+		t.Errorf("second missing output is %q, expected %q", missing[1].Name, "image")
+	}
+}
+
+func TestMissingOutputsFailsWhenCheckFails(t *testing.T) {
+	build := storage.BuildWithDuration{
+		Build: storage.Build{
+			Outputs: []*storage.Output{
+				newOutput("binary", storage.S3, "s3://bucket/binary"),
+			},
+		},
+	}
+
+	checkers := map[storage.UploadMethod]upload.ExistChecker{
+		storage.S3: &fakeExistChecker{err: errors.New("connection refused")},
+	}
+
+	if _, err := MissingOutputs(&build, checkers); err == nil {
+		t.Error("MissingOutputs did not return an error when the check failed")
+	}
+}
+
+func TestBuildStatusString(t *testing.T) {
+	tests := []struct {
+		status   BuildStatus
+		expected string
+	}{
+		{BuildStatusInputsUndefined, "Inputs Undefined"},
+		{BuildStatusBuildCommandUndefined, "Build Command Undefined"},
+		{BuildStatusExist, "Exist"},
+		{BuildStatusPending, "Pending"},
+		{BuildStatusOutputsMissing, "Outputs Missing"},
+	}
+
+	for _, tt := range tests {
+		if s := tt.status.String(); s != tt.expected {
+			t.Errorf("String() of BuildStatus %d returned %q, expected %q", tt.status, s, tt.expected)
+		}
+	}
+}
+
+func TestGetBuildStatus(t *testing.T) {
+	ctx := context.Background()
+
+	if err := os.Unsetenv("BAUR_TEST_TOTAL_INPUT_DIGEST_UNSET"); err != nil {
+		t.Fatal(err)
+	}
+
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	var paths []string
+	for p := range totalInputDigestFixture {
+		paths = append(paths, p)
+	}
+
+	app := createTotalInputDigestFixture(t, tempDir, paths)
+	storer := memory.New()
+
+	status, build, err := GetBuildStatus(ctx, storer, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != BuildStatusPending {
+		t.Errorf("build status is %q, expected %q", status, BuildStatusPending)
+	}
+
+	if build != nil {
+		t.Errorf("build is %+v, expected nil", build)
+	}
+
+	d, err := app.TotalInputDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startTs := time.Now()
+	for i := 0; i < 2; i++ {
+		b := storage.Build{
+			Application:      storage.Application{Name: app.Name},
+			StartTimeStamp:   startTs,
+			StopTimeStamp:    startTs.Add(time.Duration(i+1) * time.Second),
+			TotalInputDigest: d.String(),
+		}
+
+		if _, err := storer.SaveBuild(ctx, &b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, build, err = GetBuildStatus(ctx, storer, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != BuildStatusExist {
+		t.Errorf("build status is %q, expected %q", status, BuildStatusExist)
+	}
+
+	if build == nil || build.ID != 2 {
+		t.Errorf("build status returned build %+v, expected the latest build with id 2", build)
+	}
+}
@@ -2,43 +2,84 @@ package cfg
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
-	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/resolve/glob"
 )
 
 // App stores an application configuration.
 type App struct {
-	Name  string `toml:"name" comment:"Name of the application"`
-	Build Build  `toml:"Build"`
+	SchemaVersion int    `toml:"schema_version" comment:"Version of the configuration file format"`
+	Name          string `toml:"name" comment:"Name of the application"`
+	Build         Build  `toml:"Build"`
 }
 
 // Build the build section
 type Build struct {
-	Command  string      `toml:"command" commented:"false" comment:"Command to build the application"`
-	Includes []string    `toml:"includes" comment:"Repository relative paths to baur include files that the build inherits.\n Valid variables: $ROOT"`
-	Input    BuildInput  `comment:"Specification of build inputs like source files, Makefiles, etc"`
-	Output   BuildOutput `comment:"Specification of build outputs produced by the [Build.command]"`
+	Command     string      `toml:"command" commented:"false" comment:"Command to build the application.\n If args is empty, the command is run in a shell (sh -c)."`
+	Args        []string    `toml:"args" commented:"true" comment:"Arguments that are passed to the command.\n If set, the command is the name of the program and is executed without a shell."`
+	PreCommand  string      `toml:"pre_command" commented:"true" comment:"Command that is run in a shell (sh -c) before the build command.\n If it fails, the build is skipped."`
+	PostCommand string      `toml:"post_command" commented:"true" comment:"Command that is run in a shell (sh -c) after the outputs were uploaded.\n If it fails, the build fails, the uploaded outputs are still recorded."`
+	Timeout     string      `toml:"timeout" commented:"true" comment:"Duration after which the command and all processes it started are killed\n and the build fails, e.g. \"30m\". Default: no timeout"`
+	WorkingDir  string      `toml:"working_dir" commented:"true" comment:"Directory the command, pre_command and post_command are run in.\n Relative paths are relative to the application directory, it must be part of the repository.\n Default: application directory. Valid variables: $ROOT, $APPNAME"`
+	Includes    []string    `toml:"includes" comment:"Repository relative paths to baur include files that the build inherits.\n Valid variables: $ROOT"`
+	Input       BuildInput  `comment:"Specification of build inputs like source files, Makefiles, etc"`
+	Output      BuildOutput `comment:"Specification of build outputs produced by the [Build.command]"`
 }
 
 // BuildInput contains information about build inputs
 type BuildInput struct {
-	Files         FileInputs    `comment:"Inputs specified by file glob paths"`
-	GitFiles      GitFileInputs `comment:"Inputs specified by path, matching only Git tracked files"`
-	GolangSources GolangSources `comment:"Inputs specified by directories containing Golang applications"`
+	Files         FileInputs        `comment:"Inputs specified by file glob paths"`
+	Directories   DirInputs         `comment:"Inputs specified by directories, all files in the directories are inputs"`
+	GitFiles      GitFileInputs     `comment:"Inputs specified by path, matching only Git tracked files"`
+	GolangSources GolangSources     `comment:"Inputs specified by directories containing Golang applications"`
+	Environment   EnvironmentInputs `comment:"Inputs specified by environment variables"`
+	URLs          URLInputs         `comment:"Inputs specified by HTTP(S) URLs of files with known sha256 digests"`
+}
+
+// URLInputs specifies files that are downloaded via HTTP(S) and are inputs
+type URLInputs struct {
+	File []*URLInput `comment:"Files that are downloaded before the build,\n the URL and the sha256 digest are part of the total input digest.\n The paths of the downloaded files are passed to the build command in the\n environment variables BAUR_URL_INPUT_0, BAUR_URL_INPUT_1, ... in the order of the files."`
+}
+
+// URLInput is a file that is downloaded via HTTP(S)
+type URLInput struct {
+	URL    string `toml:"url" comment:"HTTP or HTTPS URL of the file"`
+	SHA256 string `toml:"sha256" comment:"Hex encoded sha256 digest of the file, the download fails if it does not match"`
+}
+
+// EnvironmentInputs specifies environment variables whose values are inputs
+type EnvironmentInputs struct {
+	Names []string `toml:"names" commented:"true" comment:"Names of environment variables, their values are part of the total input digest.\n An unset variable is distinguished from a variable that is set to an empty value."`
 }
 
 // GolangSources specifies inputs for Golang Applications
 type GolangSources struct {
-	Environment []string `toml:"environment" comment:"Environment to use when discovering Golang source files\n This can be environment variables understood by the Golang tools, like GOPATH, GOFLAGS, etc.\n If empty the default Go environment is used.\n Valid variables: $ROOT " commented:"true"`
+	Environment []string `toml:"environment" comment:"Environment to use when discovering Golang source files\n This can be environment variables understood by the Golang tools, like GOPATH, GOFLAGS, etc.\n Files are selected for the build context defined by the environment (GOOS, GOARCH, GOFLAGS=-tags=...).\n If empty the default Go environment is used.\n Valid variables: $ROOT " commented:"true"`
 	Paths       []string `toml:"paths" comment:"Paths to directories containing Golang source files.\n All source files including imported packages are discovered,\n files from Go's stdlib package and testfiles are ignored." commented:"true"`
+	Excludes    []string `toml:"excludes" comment:"Glob patterns of discovered Go source files that are not inputs, e.g. *_gen.go.\n Patterns are matched against the file path relative to its package directory.\n They are applied in addition to ignoring stdlib and test files." commented:"true"`
 }
 
 // FileInputs describes a file source
 type FileInputs struct {
-	Paths []string `toml:"paths" commented:"true" comment:"Relative path to source files,\n supports Golang's Glob syntax (https://golang.org/pkg/path/filepath/#Match) and\n ** to match files recursively\n Valid variables: $ROOT"`
+	Paths     []string `toml:"paths" commented:"true" comment:"Relative path to source files,\n supports Golang's Glob syntax (https://golang.org/pkg/path/filepath/#Match),\n ** to match files recursively and {a,b} to match alternatives\n Valid variables: $ROOT"`
+	Excludes  []string `toml:"excludes" commented:"true" comment:"Relative paths to files that are removed from the files matched by paths,\n the same syntax as for paths is supported.\n If a file is matched by paths and excludes, it is excluded.\n Valid variables: $ROOT"`
+	GitIgnore bool     `toml:"gitignore" commented:"true" comment:"If true, files that are ignored by .gitignore files in the repository are removed from the matched files"`
+	Optional  bool     `toml:"optional" commented:"true" comment:"If false, it is an error when a path matches no files"`
+}
+
+// DirInputs describes directories whose files are inputs
+type DirInputs struct {
+	Paths []string `toml:"paths" commented:"true" comment:"Relative paths to directories, all files in the directories and\n their subdirectories are inputs.\n Symlinks to files are followed, symlinks to directories are ignored.\n Valid variables: $ROOT"`
 }
 
 // GitFileInputs describes source files that are in the git repository by git
@@ -55,32 +96,64 @@ type BuildOutput struct {
 
 // FileOutput describes where a file artifact should be uploaded to
 type FileOutput struct {
-	Path     string   `toml:"path" comment:"Path relative to the application directory, valid variables: $APPNAME" commented:"true"`
-	FileCopy FileCopy `comment:"Copy the file to a local directory"`
-	S3Upload S3Upload `comment:"Upload the file to S3"`
+	Path            string          `toml:"path" comment:"Path relative to the application directory, valid variables: $APPNAME, $OS, $ARCH" commented:"true"`
+	FileCopy        FileCopy        `comment:"Copy the file to a local directory"`
+	S3Upload        S3Upload        `comment:"Upload the file to S3"`
+	GCSUpload       GCSUpload       `comment:"Upload the file to Google Cloud Storage"`
+	AzureBlobUpload AzureBlobUpload `comment:"Upload the file to Azure Blob Storage"`
+	CommandUpload   CommandUpload   `comment:"Upload the file by running a command"`
 }
 
 // FileCopy describes where a file artifact should be copied to
 type FileCopy struct {
-	Path string `toml:"path" comment:"Destination directory" commented:"true"`
+	Path               string `toml:"path" comment:"Destination directory, valid variables: $APPNAME, $UUID, $GITCOMMIT, $OS, $ARCH" commented:"true"`
+	PreserveMode       bool   `toml:"preserve_mode" comment:"Set the permissions of the copy to the ones of the source file" commented:"true"`
+	PreserveTimestamps bool   `toml:"preserve_timestamps" comment:"Set the modification time of the copy to the one of the source file" commented:"true"`
+	Atomic             *bool  `toml:"atomic" comment:"Write the copy to a temporary file and rename it to the destination,\n readers never see a partially written file, default: true.\n The copy fails if the destination is a file on a different device than its directory,\n e.g. a bind-mounted file, set it to false for such destinations." commented:"true"`
 }
 
 // DockerImageRegistryUpload holds information about where the docker image
 // should be uploaded to
 type DockerImageRegistryUpload struct {
-	Repository string `toml:"repository" comment:"Repository path, format: [<server[:port]>/]<owner>/<repository>:<tag>, valid variables: $APPNAME" commented:"true"`
-	Tag        string `toml:"tag" comment:"Tag that is applied to the image, valid variables: $APPNAME, $UUID, $GITCOMMIT" commented:"true"`
+	Repository string `toml:"repository" comment:"Repository path, format: [<server[:port]>/]<path-component>[/<path-component>...]:<tag>, valid variables: $APPNAME" commented:"true"`
+	Tag        string `toml:"tag" comment:"Tag that is applied to the image, valid variables: $APPNAME, $UUID, $GITCOMMIT, $OS, $ARCH" commented:"true"`
 }
 
 // S3Upload contains S3 upload information
 type S3Upload struct {
+	Bucket         string `toml:"bucket" comment:"Bucket name, valid variables: $APPNAME" commented:"true"`
+	DestFile       string `toml:"dest_file" comment:"Remote File Name, valid variables: $APPNAME, $UUID, $GITCOMMIT, $OS, $ARCH" commented:"true"`
+	MaxRetries     int    `toml:"max_retries" comment:"Number of times a failed upload is retried, only temporary errors are retried.\n Defaults to 3 when unset or 0, -1 disables retries." commented:"true"`
+	RetryBaseDelay string `toml:"retry_base_delay" comment:"Delay before the first retry, the delay doubles with every retry.\n Format: <number><unit>, e.g. 500ms, 2s. Defaults to 1s when unset." commented:"true"`
+	Endpoint       string `toml:"endpoint" comment:"URL of an S3 compatible service (e.g. MinIO) that is used instead of AWS S3.\n The AWS_REGION environment variable must still be set." commented:"true"`
+	ForcePathStyle bool   `toml:"force_path_style" comment:"Use path-style addressing (<endpoint>/<bucket>/<key>), required by MinIO.\n Only applies when endpoint is set." commented:"true"`
+}
+
+// GCSUpload contains Google Cloud Storage upload information
+type GCSUpload struct {
 	Bucket   string `toml:"bucket" comment:"Bucket name, valid variables: $APPNAME" commented:"true"`
-	DestFile string `toml:"dest_file" comment:"Remote File Name, valid variables: $APPNAME, $UUID, $GITCOMMIT" commented:"true"`
+	DestFile string `toml:"dest_file" comment:"Remote File Name, valid variables: $APPNAME, $UUID, $GITCOMMIT, $OS, $ARCH" commented:"true"`
+}
+
+// AzureBlobUpload contains Azure Blob Storage upload information
+type AzureBlobUpload struct {
+	Container  string `toml:"container" comment:"Container name, valid variables: $APPNAME" commented:"true"`
+	DestBlob   string `toml:"dest_blob" comment:"Remote blob name, valid variables: $APPNAME, $UUID, $GITCOMMIT, $OS, $ARCH" commented:"true"`
+	AccountURL string `toml:"account_url" comment:"URL of the Blob Storage service, defaults to https://<AZURE_STORAGE_ACCOUNT>.blob.core.windows.net" commented:"true"`
+}
+
+// CommandUpload describes a command that uploads a file
+type CommandUpload struct {
+	Command string `toml:"command" comment:"Command that uploads the file, it is run in a shell in the application directory.\n The path of the file is passed as first argument and in the BAUR_OUTPUT_PATH environment variable,\n BAUR_APPNAME and BAUR_GITCOMMIT are also set. baur variables are not replaced in the command.\n The command must print the URI of the uploaded file to stdout, a non-zero exit code fails the upload." commented:"true"`
 }
 
 // DockerImageOutput describes where a docker container is uploaded to
 type DockerImageOutput struct {
-	IDFile         string                    `toml:"idfile" comment:"Path to a file that is created by [Build.Command] and contains the image ID of the produced image (docker build --iidfile), valid variables: $APPNAME" commented:"true"`
+	IDFile string `toml:"idfile" comment:"Path to a file that is created by [Build.Command] and contains the image ID of the produced image (docker build --iidfile), valid variables: $APPNAME" commented:"true"`
+	// Platforms and IDFiles describe images for multiple platforms that
+	// are uploaded with a manifest list, they are used instead of IDFile
+	Platforms      []string                  `toml:"platforms" comment:"Platforms of the images in idfiles, format: <os>/<arch>[/<variant>], e.g. linux/arm64.\n If set, the images are uploaded with a manifest list that is tagged with [RegistryUpload.tag] instead of a single image" commented:"true"`
+	IDFiles        []string                  `toml:"idfiles" comment:"Paths to image ID files, one for each entry in platforms in the same order, valid variables: $APPNAME" commented:"true"`
 	RegistryUpload DockerImageRegistryUpload `comment:"Registry repository the image is uploaded to"`
 }
 
@@ -95,6 +168,7 @@ func exampleBuildInput() BuildInput {
 		GolangSources: GolangSources{
 			Paths:       []string{"."},
 			Environment: []string{"GOFLAGS=-mod=vendor", "GO111MODULE=on"},
+			Excludes:    []string{"*_gen.go"},
 		},
 	}
 }
@@ -129,7 +203,8 @@ func exampleBuildOutput() BuildOutput {
 // ExampleApp returns an exemplary app cfg struct with the name set to the given value
 func ExampleApp(name string) *App {
 	return &App{
-		Name: name,
+		SchemaVersion: SchemaVersion,
+		Name:          name,
 
 		Build: Build{
 			Command: "make dist",
@@ -140,17 +215,26 @@ func ExampleApp(name string) *App {
 }
 
 // AppFromFile reads a application configuration file and returns it.
-// If the buildCmd is not set in the App configuration it's set to
-// defaultBuild.Command
 func AppFromFile(path string) (*App, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return AppFromReader(f)
+}
+
+// AppFromReader reads an application configuration from r and returns it.
+func AppFromReader(r io.Reader) (*App, error) {
 	config := App{}
 
-	content, err := ioutil.ReadFile(path)
+	content, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	err = toml.Unmarshal(content, &config)
+	err = unmarshalVersioned(content, &config)
 	if err != nil {
 		return nil, err
 	}
@@ -189,14 +273,24 @@ func removeEmptySections(buildOutput *BuildOutput) {
 	buildOutput.DockerImage = dockerImageOutputs
 }
 
-// ToFile writes an exemplary Application configuration file to
-// filepath. The name setting is set to appName
-func (a *App) ToFile(filepath string) error {
-	return toFile(a, filepath, false)
+// ToFile writes the Application configuration to filepath.
+// If overwrite is true an existent file will be overwriten. If it's false the
+// function returns an error if the file exist.
+func (a *App) ToFile(filepath string, overwrite bool) error {
+	return toFile(a, filepath, overwrite)
+}
+
+// Encode writes the configuration in TOML format without comments to w.
+func (a *App) Encode(w io.Writer) error {
+	return encode(a, w, false)
 }
 
 // Validate validates a App configuration
 func (a *App) Validate() error {
+	if err := validateSchemaVersion(a.SchemaVersion); err != nil {
+		return err
+	}
+
 	if len(a.Name) == 0 {
 		return errors.New("name parameter can not be empty")
 	}
@@ -207,9 +301,42 @@ func (a *App) Validate() error {
 // Validate validates the build section
 func (b *Build) Validate() error {
 	if len(b.Command) == 0 {
+		if len(b.Args) != 0 {
+			return errors.New("command parameter can not be empty if args is set")
+		}
+
+		if len(b.PreCommand) != 0 || len(b.PostCommand) != 0 {
+			return errors.New("command parameter can not be empty if pre_command or post_command is set")
+		}
+
 		return nil
 	}
 
+	if len(b.Args) != 0 && strings.ContainsAny(strings.TrimSpace(b.Command), " \t\n") {
+		return errors.New("command parameter must only contain the program name if args is set, " +
+			"it contains whitespace characters")
+	}
+
+	if len(b.PreCommand) != 0 && len(strings.TrimSpace(b.PreCommand)) == 0 {
+		return NewValidationError("pre_command", "can not be empty")
+	}
+
+	if len(b.PostCommand) != 0 && len(strings.TrimSpace(b.PostCommand)) == 0 {
+		return NewValidationError("post_command", "can not be empty")
+	}
+
+	if err := validateVarsSlice("includes", b.Includes, rootVars); err != nil {
+		return err
+	}
+
+	if err := validateVars("working_dir", b.WorkingDir, workingDirVars); err != nil {
+		return err
+	}
+
+	if _, err := b.TimeoutDuration(); err != nil {
+		return NewValidationError("timeout", "%s", err)
+	}
+
 	if err := b.Input.Validate(); err != nil {
 		return errors.Wrap(err, "[Build.Input] section contains errors")
 	}
@@ -227,15 +354,126 @@ func (b *BuildInput) Validate() error {
 		return errors.Wrap(err, "Files")
 	}
 
+	if err := b.Directories.Validate(); err != nil {
+		return errors.Wrap(err, "Directories")
+	}
+
 	if err := b.GolangSources.Validate(); err != nil {
 		return errors.Wrap(err, "GolangSources")
 	}
 
-	// TODO: add validation for gitfiles section
+	if err := b.Environment.Validate(); err != nil {
+		return errors.Wrap(err, "Environment")
+	}
+
+	if err := b.GitFiles.Validate(); err != nil {
+		return errors.Wrap(err, "GitFiles")
+	}
+
+	if err := b.URLs.Validate(); err != nil {
+		return errors.Wrap(err, "URLs")
+	}
+
+	return nil
+}
+
+// sha256HexRe matches a hex encoded sha256 digest
+var sha256HexRe = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// Validate validates the URLs section.
+// A URL that is specified multiple times with different sha256 digests is
+// invalid.
+func (u *URLInputs) Validate() error {
+	for _, f := range u.File {
+		if err := f.Validate(); err != nil {
+			return errors.Wrap(err, "File")
+		}
+	}
+
+	if err := URLInputConflict(u.File); err != nil {
+		return errors.Wrap(err, "File")
+	}
+
+	return nil
+}
+
+// URLInputConflict returns a ValidationError if inputs contains the same URL
+// multiple times with different sha256 digests.
+func URLInputConflict(inputs []*URLInput) error {
+	digests := make(map[string]string, len(inputs))
+
+	for _, in := range inputs {
+		digest, exist := digests[in.URL]
+		if !exist {
+			digests[in.URL] = in.SHA256
+			continue
+		}
+
+		if !strings.EqualFold(digest, in.SHA256) {
+			return NewValidationError("url", "%q is specified multiple times with different sha256 digests (%s, %s)",
+				in.URL, digest, in.SHA256)
+		}
+	}
 
 	return nil
 }
 
+// Validate validates a [[Build.Input.URLs.File]] section
+func (u *URLInput) Validate() error {
+	if len(u.URL) == 0 {
+		return NewValidationError("url", "can not be empty")
+	}
+
+	parsedURL, err := url.Parse(u.URL)
+	if err != nil {
+		return NewValidationError("url", "%q is not a valid URL: %s", u.URL, err)
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" || len(parsedURL.Host) == 0 {
+		return NewValidationError("url", "%q is not a valid http or https URL", u.URL)
+	}
+
+	if !sha256HexRe.MatchString(u.SHA256) {
+		return NewValidationError("sha256", "%q is not a hex encoded sha256 digest, it must consist of 64 hex characters", u.SHA256)
+	}
+
+	return nil
+}
+
+// Validate validates the GitFiles section.
+// The paths are passed as patterns to git ls-files, they must be relative and
+// can not reference parent directories.
+func (g *GitFileInputs) Validate() error {
+	for _, p := range g.Paths {
+		if len(p) == 0 {
+			return NewValidationError("paths", "a path can not be empty")
+		}
+
+		if strings.HasPrefix(p, "/") {
+			return NewValidationError("paths", "%q is an absolute path, only relative paths are supported", p)
+		}
+
+		for _, elem := range strings.Split(p, "/") {
+			if elem == ".." {
+				return NewValidationError("paths", "%q references a parent directory, this is not supported", p)
+			}
+		}
+	}
+
+	return validateVarsSlice("paths", g.Paths, rootVars)
+}
+
+// Validate validates the Directories section
+func (d *DirInputs) Validate() error {
+	for _, p := range d.Paths {
+		if len(p) == 0 {
+			return NewValidationError("paths", "a path can not be empty")
+		}
+	}
+
+	return validateVarsSlice("paths", d.Paths, rootVars)
+}
+
 // Validate validates the GolangSources section
 func (g *GolangSources) Validate() error {
 	if len(g.Environment) != 0 && len(g.Paths) == 0 {
@@ -248,9 +486,86 @@ func (g *GolangSources) Validate() error {
 		}
 	}
 
+	if len(g.Excludes) != 0 && len(g.Paths) == 0 {
+		return errors.New("path must be set if excludes is set")
+	}
+
+	for _, pattern := range g.Excludes {
+		if len(pattern) == 0 {
+			return errors.New("an exclude pattern can not be empty")
+		}
+
+		if strings.Contains(pattern, "/") {
+			return fmt.Errorf("exclude pattern %q can not contain '/', patterns are matched against file names in package directories", pattern)
+		}
+
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "exclude pattern %q is invalid", pattern)
+		}
+	}
+
+	if err := validateVarsSlice("paths", g.Paths, rootVars); err != nil {
+		return err
+	}
+
+	return validateVarsSlice("environment", g.Environment, rootVars)
+}
+
+// Validate validates the Environment section
+func (e *EnvironmentInputs) Validate() error {
+	for _, name := range e.Names {
+		if len(name) == 0 {
+			return errors.New("a name can not be empty")
+		}
+
+		if strings.Contains(name, "=") {
+			return fmt.Errorf("name %q can not contain '='", name)
+		}
+	}
+
 	return nil
 }
 
+// Merge appends the inputs of other to the inputs of b.
+// Files.GitIgnore and Files.Optional are enabled if they are enabled in one
+// of both.
+// The result is lossy: when inputs are resolved the Files settings only
+// apply to the paths of the section they are defined in, in the merged
+// BuildInput they appear to apply to all paths. Merge is therefore only
+// suitable to display a configuration, not to resolve inputs.
+func (b *BuildInput) Merge(other *BuildInput) {
+	b.Files.Paths = append(b.Files.Paths, other.Files.Paths...)
+	b.Files.Excludes = append(b.Files.Excludes, other.Files.Excludes...)
+	b.Files.GitIgnore = b.Files.GitIgnore || other.Files.GitIgnore
+	b.Files.Optional = b.Files.Optional || other.Files.Optional
+	b.Directories.Paths = append(b.Directories.Paths, other.Directories.Paths...)
+	b.GitFiles.Paths = append(b.GitFiles.Paths, other.GitFiles.Paths...)
+	b.GolangSources.Paths = append(b.GolangSources.Paths, other.GolangSources.Paths...)
+	b.GolangSources.Environment = append(b.GolangSources.Environment, other.GolangSources.Environment...)
+	b.GolangSources.Excludes = append(b.GolangSources.Excludes, other.GolangSources.Excludes...)
+	b.Environment.Names = append(b.Environment.Names, other.Environment.Names...)
+
+	for _, f := range other.URLs.File {
+		fCopy := *f
+		b.URLs.File = append(b.URLs.File, &fCopy)
+	}
+}
+
+// Merge appends copies of the outputs of other to the outputs of b.
+func (b *BuildOutput) Merge(other *BuildOutput) {
+	for _, f := range other.File {
+		fCopy := *f
+		b.File = append(b.File, &fCopy)
+	}
+
+	for _, d := range other.DockerImage {
+		dCopy := *d
+		dCopy.Platforms = append([]string(nil), d.Platforms...)
+		dCopy.IDFiles = append([]string(nil), d.IDFiles...)
+		b.DockerImage = append(b.DockerImage, &dCopy)
+	}
+}
+
 // Validate validates the BuildOutput section
 func (b *BuildOutput) Validate() error {
 	for _, f := range b.File {
@@ -268,14 +583,22 @@ func (b *BuildOutput) Validate() error {
 	return nil
 }
 
-// IsEmpty returns true if FileCopy is empty
+// IsEmpty returns true if FileCopy is empty, only the Path field is
+// considered
 func (f *FileCopy) IsEmpty() bool {
 	return len(f.Path) == 0
 }
 
+// IsAtomic returns true if the file should be copied atomically, this is the
+// default if Atomic is unset
+func (f *FileCopy) IsAtomic() bool {
+	return f.Atomic == nil || *f.Atomic
+}
+
 // IsEmpty returns true if FileOutput is empty
 func (f *FileOutput) IsEmpty() bool {
-	return f.FileCopy.IsEmpty() && f.S3Upload.IsEmpty()
+	return f.FileCopy.IsEmpty() && f.S3Upload.IsEmpty() && f.GCSUpload.IsEmpty() && f.AzureBlobUpload.IsEmpty() &&
+		f.CommandUpload.IsEmpty()
 }
 
 // IsEmpty returns true if S3Upload is empty
@@ -283,13 +606,56 @@ func (s *S3Upload) IsEmpty() bool {
 	return len(s.Bucket) == 0 && len(s.DestFile) == 0
 }
 
+// IsEmpty returns true if GCSUpload is empty
+func (g *GCSUpload) IsEmpty() bool {
+	return len(g.Bucket) == 0 && len(g.DestFile) == 0
+}
+
+// IsEmpty returns true if AzureBlobUpload is empty
+func (a *AzureBlobUpload) IsEmpty() bool {
+	return len(a.Container) == 0 && len(a.DestBlob) == 0 && len(a.AccountURL) == 0
+}
+
+// IsEmpty returns true if CommandUpload is empty
+func (c *CommandUpload) IsEmpty() bool {
+	return len(c.Command) == 0
+}
+
 // Validate validates a [[Build.Output.File]] section
 func (f *FileOutput) Validate() error {
 	if len(f.Path) == 0 {
 		return errors.New("path parameter can not be unset or empty")
 	}
 
-	return f.S3Upload.Validate()
+	if err := validateVars("path", f.Path, outputPathVars); err != nil {
+		return err
+	}
+
+	if err := validateRelPathInDir("path", f.Path); err != nil {
+		return err
+	}
+
+	if err := validateVars("path", f.FileCopy.Path, uploadDestVars); err != nil {
+		return errors.Wrap(err, "FileCopy")
+	}
+
+	if err := f.S3Upload.Validate(); err != nil {
+		return errors.Wrap(err, "S3Upload")
+	}
+
+	if err := f.GCSUpload.Validate(); err != nil {
+		return errors.Wrap(err, "GCSUpload")
+	}
+
+	if err := f.AzureBlobUpload.Validate(); err != nil {
+		return errors.Wrap(err, "AzureBlobUpload")
+	}
+
+	if err := f.CommandUpload.Validate(); err != nil {
+		return errors.Wrap(err, "CommandUpload")
+	}
+
+	return nil
 }
 
 //IsEmpty returns true if the struct is empty
@@ -299,7 +665,7 @@ func (d *DockerImageRegistryUpload) IsEmpty() bool {
 
 // IsEmpty returns true if DockerImageOutput is empty
 func (d *DockerImageOutput) IsEmpty() bool {
-	return len(d.IDFile) == 0 && d.RegistryUpload.IsEmpty()
+	return len(d.IDFile) == 0 && len(d.Platforms) == 0 && len(d.IDFiles) == 0 && d.RegistryUpload.IsEmpty()
 
 }
 
@@ -317,13 +683,168 @@ func (s *S3Upload) Validate() error {
 		return errors.New("bucket parameter can not be unset or empty")
 	}
 
+	if err := validateVars("bucket", s.Bucket, appNameVars); err != nil {
+		return err
+	}
+
+	if err := validateVars("dest_file", s.DestFile, uploadDestVars); err != nil {
+		return err
+	}
+
+	if s.MaxRetries < -1 {
+		return errors.New("max_retries parameter must be -1 or >= 0")
+	}
+
+	if len(s.Endpoint) != 0 {
+		u, err := url.Parse(s.Endpoint)
+		if err != nil {
+			return errors.Wrap(err, "endpoint parameter is invalid")
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" || len(u.Host) == 0 {
+			return fmt.Errorf("endpoint parameter %q is invalid, must be an http or https URL", s.Endpoint)
+		}
+	}
+
+	if _, err := s.RetryBaseDelayDuration(); err != nil {
+		return errors.Wrap(err, "retry_base_delay parameter is invalid")
+	}
+
 	return nil
 }
 
+// RetryBaseDelayDuration returns RetryBaseDelay as time.Duration.
+// If RetryBaseDelay is empty, 0 is returned.
+func (s *S3Upload) RetryBaseDelayDuration() (time.Duration, error) {
+	if len(s.RetryBaseDelay) == 0 {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s.RetryBaseDelay)
+	if err != nil {
+		return 0, err
+	}
+
+	if d < 0 {
+		return 0, errors.New("duration can not be negative")
+	}
+
+	return d, nil
+}
+
+// TimeoutDuration returns Timeout as time.Duration.
+// If Timeout is empty, 0 is returned.
+func (b *Build) TimeoutDuration() (time.Duration, error) {
+	if len(b.Timeout) == 0 {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(b.Timeout)
+	if err != nil {
+		return 0, err
+	}
+
+	if d <= 0 {
+		return 0, errors.New("duration must be positive")
+	}
+
+	return d, nil
+}
+
+// Validate validates a [Build.Output.File.GCSUpload] section
+func (g *GCSUpload) Validate() error {
+	if g.IsEmpty() {
+		return nil
+	}
+
+	if len(g.DestFile) == 0 {
+		return errors.New("destfile parameter can not be unset or empty")
+	}
+
+	if len(g.Bucket) == 0 {
+		return errors.New("bucket parameter can not be unset or empty")
+	}
+
+	if err := validateVars("bucket", g.Bucket, appNameVars); err != nil {
+		return err
+	}
+
+	return validateVars("dest_file", g.DestFile, uploadDestVars)
+}
+
+// Validate validates a [Build.Output.File.AzureBlobUpload] section
+func (a *AzureBlobUpload) Validate() error {
+	if a.IsEmpty() {
+		return nil
+	}
+
+	if len(a.DestBlob) == 0 {
+		return errors.New("dest_blob parameter can not be unset or empty")
+	}
+
+	if len(a.Container) == 0 {
+		return errors.New("container parameter can not be unset or empty")
+	}
+
+	if err := validateVars("container", a.Container, appNameVars); err != nil {
+		return err
+	}
+
+	if err := validateVars("dest_blob", a.DestBlob, uploadDestVars); err != nil {
+		return err
+	}
+
+	if len(a.AccountURL) != 0 {
+		u, err := url.Parse(a.AccountURL)
+		if err != nil {
+			return errors.Wrap(err, "account_url parameter is invalid")
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" || len(u.Host) == 0 {
+			return fmt.Errorf("account_url parameter %q is invalid, must be an http or https URL", a.AccountURL)
+		}
+	}
+
+	return nil
+}
+
+// Validate validates a [Build.Output.File.CommandUpload] section
+func (c *CommandUpload) Validate() error {
+	if c.IsEmpty() {
+		return nil
+	}
+
+	if len(strings.TrimSpace(c.Command)) == 0 {
+		return NewValidationError("command", "can not be empty")
+	}
+
+	return nil
+}
+
+// IsMultiPlatform returns true if the output describes images for multiple
+// platforms that are uploaded with a manifest list
+func (d *DockerImageOutput) IsMultiPlatform() bool {
+	return len(d.Platforms) != 0
+}
+
 // Validate validates its content
 func (d *DockerImageOutput) Validate() error {
-	if len(d.IDFile) == 0 {
-		return errors.New("idfile parameter can not be unset or empty")
+	if d.IsMultiPlatform() {
+		if err := d.validatePlatforms(); err != nil {
+			return err
+		}
+	} else {
+		if len(d.IDFiles) != 0 {
+			return errors.New("idfiles parameter can only be set together with platforms")
+		}
+
+		if len(d.IDFile) == 0 {
+			return errors.New("idfile parameter can not be unset or empty")
+		}
+
+		if err := validateVars("idfile", d.IDFile, appNameVars); err != nil {
+			return err
+		}
 	}
 
 	if err := d.RegistryUpload.Validate(); err != nil {
@@ -333,6 +854,44 @@ func (d *DockerImageOutput) Validate() error {
 	return nil
 }
 
+// platformRe matches platforms in the format <os>/<arch>[/<variant>]
+var platformRe = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+func (d *DockerImageOutput) validatePlatforms() error {
+	if len(d.IDFile) != 0 {
+		return errors.New("idfile parameter can not be set together with platforms, use idfiles")
+	}
+
+	if len(d.IDFiles) != len(d.Platforms) {
+		return fmt.Errorf("idfiles parameter must contain one element for each platform, has %d elements, expected %d",
+			len(d.IDFiles), len(d.Platforms))
+	}
+
+	seen := make(map[string]struct{}, len(d.Platforms))
+	for _, p := range d.Platforms {
+		if !platformRe.MatchString(p) {
+			return fmt.Errorf("platforms parameter contains invalid platform %q, must be in the format <os>/<arch>[/<variant>]", p)
+		}
+
+		if _, exist := seen[p]; exist {
+			return fmt.Errorf("platforms parameter contains %q multiple times", p)
+		}
+		seen[p] = struct{}{}
+	}
+
+	for _, idFile := range d.IDFiles {
+		if len(idFile) == 0 {
+			return errors.New("idfiles parameter can not contain empty elements")
+		}
+
+		if err := validateVars("idfiles", idFile, appNameVars); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Validate validates its content
 func (d *DockerImageRegistryUpload) Validate() error {
 	if len(d.Repository) == 0 {
@@ -343,6 +902,40 @@ func (d *DockerImageRegistryUpload) Validate() error {
 		return errors.New("tag parameter can not be unset or empty")
 	}
 
+	if err := validateVars("tag", d.Tag, uploadDestVars); err != nil {
+		return err
+	}
+
+	if err := validateVars("repository", d.Repository, appNameVars); err != nil {
+		return err
+	}
+
+	if err := validateDockerRepository(d.Repository); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// dockerRepositoryRe matches repository paths in the format
+// [<server[:port]>/]<path-component>[/<path-component>...]
+var dockerRepositoryRe = regexp.MustCompile(
+	`^([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*(:[0-9]+)?/)?` +
+		`[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+
+// validateDockerRepository validates that repository is in the format
+// [<server[:port]>/]<path-component>[/<path-component>...], e.g.
+// gcr.io/project/team/app or localhost:5000/app.
+// Variables in the repository string are replaced by a valid placeholder
+// before it is matched.
+func validateDockerRepository(repository string) error {
+	r := strings.Replace(repository, "$APPNAME", "appname", -1)
+
+	if !dockerRepositoryRe.MatchString(r) {
+		return NewValidationError("repository",
+			"%q is not in the format [<server[:port]>/]<path-component>[/<path-component>...]", repository)
+	}
+
 	return nil
 }
 
@@ -352,8 +945,40 @@ func (f *FileInputs) Validate() error {
 		if len(path) == 0 {
 			return errors.New("path can not be empty")
 		}
-		if strings.Count(path, "**") > 1 {
-			return errors.New("'**' can only appear one time in a path")
+
+		if err := validateGlobPath(path); err != nil {
+			return errors.Wrap(err, "paths")
+		}
+	}
+
+	for _, path := range f.Excludes {
+		if len(path) == 0 {
+			return errors.New("exclude path can not be empty")
+		}
+
+		if err := validateGlobPath(path); err != nil {
+			return errors.Wrap(err, "excludes")
+		}
+	}
+
+	if err := validateVarsSlice("paths", f.Paths, rootVars); err != nil {
+		return err
+	}
+
+	return validateVarsSlice("excludes", f.Excludes, rootVars)
+}
+
+// validateGlobPath returns an error if path contains unbalanced braces or if
+// '**' appears more than one time in one of the patterns it expands to
+func validateGlobPath(path string) error {
+	patterns, err := glob.ExpandBraces(path)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range patterns {
+		if strings.Count(p, "**") > 1 {
+			return fmt.Errorf("%q: '**' can only appear one time in a path", path)
 		}
 	}
 
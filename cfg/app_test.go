@@ -3,7 +3,10 @@ package cfg
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 func Test_ExampleApp_IsValid(t *testing.T) {
@@ -28,7 +31,7 @@ func Test_ExampleApp_WrittenAndReadCfgIsValid(t *testing.T) {
 		t.Error("example conf fails validation: ", err)
 	}
 
-	if err := a.ToFile(tmpfileName); err != nil {
+	if err := a.ToFile(tmpfileName, false); err != nil {
 		t.Fatal("writing conf to file failed: ", err)
 	}
 
@@ -41,3 +44,648 @@ func Test_ExampleApp_WrittenAndReadCfgIsValid(t *testing.T) {
 		t.Error("validating conf from file failed: ", err)
 	}
 }
+
+func Test_FileInputs_ValidateExcludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		excludes []string
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			excludes: []string{"gen/*.sql", "gen/**/*.tmp"},
+		},
+		{
+			name:     "empty",
+			excludes: []string{""},
+			wantErr:  true,
+		},
+		{
+			name:     "multipleDoubleStars",
+			excludes: []string{"gen/**/x/**"},
+			wantErr:  true,
+		},
+		{
+			name:     "braces",
+			excludes: []string{"gen/{a,b}/*.tmp", "{gen,tmp}/**"},
+		},
+		{
+			name:     "unbalancedBraces",
+			excludes: []string{"gen/{a,b/*.tmp"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := FileInputs{
+				Paths:    []string{"gen/*"},
+				Excludes: tt.excludes,
+			}
+
+			err := f.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_GolangSources_ValidateExcludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		excludes []string
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			excludes: []string{"*_gen.go", "zz_generated.*.go"},
+		},
+		{
+			name:     "empty",
+			excludes: []string{""},
+			wantErr:  true,
+		},
+		{
+			name:     "containsSlash",
+			excludes: []string{"gen/*.go"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalidPattern",
+			excludes: []string{"[*_gen.go"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := GolangSources{
+				Paths:    []string{"."},
+				Excludes: tt.excludes,
+			}
+
+			err := g.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_FileOutput_ValidatePathInAppDir(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "dist/app.tar"},
+		{path: "./dist/../app.tar"},
+		{path: "..app.tar"},
+		{path: "../app.tar", wantErr: true},
+		{path: "dist/../../app.tar", wantErr: true},
+		{path: "../../etc/passwd", wantErr: true},
+		{path: "..", wantErr: true},
+		{path: "/etc/passwd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			f := FileOutput{
+				Path:     tt.path,
+				FileCopy: FileCopy{Path: "/mnt/"},
+			}
+
+			err := f.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				return
+			}
+
+			vErr, ok := errors.Cause(err).(*ValidationError)
+			if !ok || vErr.Path != "path" {
+				t.Errorf("Validate() returned %v, expected a ValidationError for path", err)
+			}
+		})
+	}
+}
+
+func Test_validateDockerRepository(t *testing.T) {
+	tests := []struct {
+		repository string
+		wantErr    bool
+	}{
+		{repository: "my-company/$APPNAME"},
+		{repository: "simplesurance/calculator"},
+		{repository: "localhost/simplesurance/calculator"},
+		{repository: "localhost:5000/simplesurance/calculator"},
+		{repository: "registry.example.com/owner/$APPNAME-service"},
+		{repository: "owner/app_name.v2"},
+		{repository: "calculator"},
+		{repository: "a/b/c/d"},
+		{repository: "gcr.io/project/team/app"},
+		{repository: "registry.gitlab.com/group/sub/app"},
+		{repository: "localhost:5000/app"},
+
+		{repository: "localhost:5000/calculator:latest", wantErr: true},
+		{repository: "owner//calculator", wantErr: true},
+		{repository: "owner/calculator/", wantErr: true},
+		{repository: "gcr.io/project//app", wantErr: true},
+		{repository: "registry.example.com:/app", wantErr: true},
+		{repository: "owner/Calculator", wantErr: true},
+		{repository: "owner/calc ulator", wantErr: true},
+		{repository: "localhost:port/owner/calculator", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repository, func(t *testing.T) {
+			err := validateDockerRepository(tt.repository)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateDockerRepository() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				return
+			}
+
+			vErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("returned error is of type %T, expected *ValidationError", err)
+			}
+
+			if vErr.Path != "repository" {
+				t.Errorf("ValidationError path is %q, expected \"repository\"", vErr.Path)
+			}
+		})
+	}
+}
+
+func Test_GitFileInputs_Validate(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "Makefile"},
+		{path: "src/*.go"},
+		{path: "**/*.c"},
+		{path: "$ROOT/Makefile"},
+		{path: "dir/..file"},
+		{path: "[abc].txt"},
+
+		{path: "", wantErr: true},
+		{path: "/etc/passwd", wantErr: true},
+		{path: "..", wantErr: true},
+		{path: "../Makefile", wantErr: true},
+		{path: "src/../../Makefile", wantErr: true},
+		{path: "$ROOT/../Makefile", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			g := GitFileInputs{Paths: []string{tt.path}}
+
+			err := g.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				return
+			}
+
+			vErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("returned error is of type %T, expected *ValidationError", err)
+			}
+
+			if vErr.Path != "paths" {
+				t.Errorf("ValidationError path is %q, expected \"paths\"", vErr.Path)
+			}
+		})
+	}
+}
+
+func Test_ValidateUnknownVars(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func() error
+		wantErr  bool
+		errPath  string
+	}{
+		{
+			name: "valid_output_path",
+			validate: (&FileOutput{
+				Path:     "dist/$APPNAME-$OS-$ARCH.tar.xz",
+				FileCopy: FileCopy{Path: "/mnt/$APPNAME-$GITCOMMIT-$UUID"},
+			}).Validate,
+		},
+		{
+			name:     "typo_in_output_path",
+			validate: (&FileOutput{Path: "dist/$APPNANE.tar.xz"}).Validate,
+			wantErr:  true,
+			errPath:  "path",
+		},
+		{
+			name:     "uuid_in_output_path",
+			validate: (&FileOutput{Path: "dist/$UUID.tar.xz"}).Validate,
+			wantErr:  true,
+			errPath:  "path",
+		},
+		{
+			name:     "valid_s3_upload",
+			validate: (&S3Upload{Bucket: "$APPNAME", DestFile: "$APPNAME-$GITCOMMIT-$OS"}).Validate,
+		},
+		{
+			name:     "suffixed_var_in_output_path",
+			validate: (&FileOutput{Path: "dist/$APPNAME_bin.tar", FileCopy: FileCopy{Path: "/mnt/$APPNAME_"}}).Validate,
+		},
+		{
+			name:     "underscore_separated_vars_in_s3_dest_file",
+			validate: (&S3Upload{Bucket: "bucket", DestFile: "$APPNAME_$GITCOMMIT.tar"}).Validate,
+		},
+		{
+			name:     "gitcommit_after_underscore_in_output_path",
+			validate: (&FileOutput{Path: "dist/$APPNAME_$GITCOMMIT.tar"}).Validate,
+			wantErr:  true,
+			errPath:  "path",
+		},
+		{
+			name:     "gitcommit_in_bucket",
+			validate: (&S3Upload{Bucket: "$GITCOMMIT", DestFile: "file"}).Validate,
+			wantErr:  true,
+			errPath:  "bucket",
+		},
+		{
+			name:     "unknown_var_in_gcs_dest_file",
+			validate: (&GCSUpload{Bucket: "bucket", DestFile: "$COMMIT"}).Validate,
+			wantErr:  true,
+			errPath:  "dest_file",
+		},
+		{
+			name:     "valid_azure_blob_upload",
+			validate: (&AzureBlobUpload{Container: "$APPNAME", DestBlob: "$APPNAME-$GITCOMMIT", AccountURL: "http://127.0.0.1:10000/devstoreaccount1"}).Validate,
+		},
+		{
+			name:     "uuid_in_azure_container",
+			validate: (&AzureBlobUpload{Container: "$UUID", DestBlob: "file"}).Validate,
+			wantErr:  true,
+			errPath:  "container",
+		},
+		{
+			name:     "valid_build_hooks",
+			validate: (&Build{Command: "make", PreCommand: "make check-migrations", PostCommand: "notify"}).Validate,
+		},
+		{
+			name:     "whitespace_pre_command",
+			validate: (&Build{Command: "make", PreCommand: " "}).Validate,
+			wantErr:  true,
+			errPath:  "pre_command",
+		},
+		{
+			name:     "whitespace_post_command",
+			validate: (&Build{Command: "make", PostCommand: "\n"}).Validate,
+			wantErr:  true,
+			errPath:  "post_command",
+		},
+		{
+			name:     "valid_working_dir",
+			validate: (&Build{Command: "make", WorkingDir: "$ROOT/build/$APPNAME"}).Validate,
+		},
+		{
+			name:     "unknown_var_in_working_dir",
+			validate: (&Build{Command: "make", WorkingDir: "$HOME/build"}).Validate,
+			wantErr:  true,
+			errPath:  "working_dir",
+		},
+		{
+			name:     "valid_timeout",
+			validate: (&Build{Command: "make", Timeout: "1h30m"}).Validate,
+		},
+		{
+			name:     "invalid_timeout",
+			validate: (&Build{Command: "make", Timeout: "30"}).Validate,
+			wantErr:  true,
+			errPath:  "timeout",
+		},
+		{
+			name:     "negative_timeout",
+			validate: (&Build{Command: "make", Timeout: "-1s"}).Validate,
+			wantErr:  true,
+			errPath:  "timeout",
+		},
+		{
+			name:     "valid_command_upload",
+			validate: (&CommandUpload{Command: `my-store upload "$1"`}).Validate,
+		},
+		{
+			name:     "whitespace_command_upload",
+			validate: (&CommandUpload{Command: " \t"}).Validate,
+			wantErr:  true,
+			errPath:  "command",
+		},
+		{
+			name:     "unknown_var_in_docker_tag",
+			validate: (&DockerImageRegistryUpload{Repository: "owner/$APPNAME", Tag: "$GIT_COMMIT"}).Validate,
+			wantErr:  true,
+			errPath:  "tag",
+		},
+		{
+			name:     "uuid_in_docker_repository",
+			validate: (&DockerImageRegistryUpload{Repository: "owner/$UUID", Tag: "latest"}).Validate,
+			wantErr:  true,
+			errPath:  "repository",
+		},
+		{
+			name:     "valid_file_inputs",
+			validate: (&FileInputs{Paths: []string{"$ROOT/Makefile"}, Excludes: []string{"$ROOT/x"}}).Validate,
+		},
+		{
+			name:     "appname_in_file_inputs",
+			validate: (&FileInputs{Paths: []string{"$APPNAME/*.go"}}).Validate,
+			wantErr:  true,
+			errPath:  "paths",
+		},
+		{
+			name:     "unknown_var_in_golang_environment",
+			validate: (&GolangSources{Paths: []string{"."}, Environment: []string{"GOPATH=$HOME/go"}}).Validate,
+			wantErr:  true,
+			errPath:  "environment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				return
+			}
+
+			vErr, ok := errors.Cause(err).(*ValidationError)
+			if !ok {
+				t.Fatalf("returned error is of type %T, expected *ValidationError", errors.Cause(err))
+			}
+
+			if vErr.Path != tt.errPath {
+				t.Errorf("ValidationError path is %q, expected %q", vErr.Path, tt.errPath)
+			}
+		})
+	}
+}
+
+func Test_Build_ValidateArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		wantErr bool
+	}{
+		{name: "shellcommand", command: "make dist"},
+		{name: "programwithargs", command: "make", args: []string{"dist", "-j", "4"}},
+		{name: "programwithpath", command: "./build.sh", args: []string{"--release"}},
+		{name: "argswithwhitespace", command: "go", args: []string{"build", "-ldflags", "-s -w"}},
+		{name: "programsurroundedbywhitespace", command: " make ", args: []string{"dist"}},
+		{name: "emptyargs", command: "make dist", args: []string{}},
+		{name: "nocommandnoargs"},
+
+		{name: "argswithoutcommand", args: []string{"dist"}, wantErr: true},
+		{name: "argswithshellcommand", command: "make dist", args: []string{"-j4"}, wantErr: true},
+		{name: "argswithtabincommand", command: "make\tdist", args: []string{"-j4"}, wantErr: true},
+		{name: "argswithnewlineincommand", command: "make\ndist", args: []string{"-j4"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Build{Command: tt.command, Args: tt.args}
+
+			err := b.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_S3Upload_ValidateRetrySettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxRetries     int
+		retryBaseDelay string
+		wantErr        bool
+	}{
+		{name: "unset"},
+		{name: "valid", maxRetries: 5, retryBaseDelay: "500ms"},
+		{name: "disabledretries", maxRetries: -1},
+		{name: "negativeretries", maxRetries: -2, wantErr: true},
+		{name: "invaliddelay", retryBaseDelay: "5", wantErr: true},
+		{name: "negativedelay", retryBaseDelay: "-1s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := S3Upload{
+				Bucket:         "bucket",
+				DestFile:       "$APPNAME.tar.xz",
+				MaxRetries:     tt.maxRetries,
+				RetryBaseDelay: tt.retryBaseDelay,
+			}
+
+			err := s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_S3Upload_ValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  bool
+	}{
+		{endpoint: ""},
+		{endpoint: "http://localhost:9000"},
+		{endpoint: "https://minio.example.com"},
+		{endpoint: "localhost:9000", wantErr: true},
+		{endpoint: "ftp://minio.example.com", wantErr: true},
+		{endpoint: "https://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			s := S3Upload{
+				Bucket:         "bucket",
+				DestFile:       "$APPNAME.tar.xz",
+				Endpoint:       tt.endpoint,
+				ForcePathStyle: true,
+			}
+
+			err := s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_DockerImageOutput_ValidatePlatforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		idFile    string
+		platforms []string
+		idFiles   []string
+		wantErr   bool
+	}{
+		{name: "singleimage", idFile: "image.id"},
+		{name: "multiplatform", platforms: []string{"linux/amd64", "linux/arm/v7"}, idFiles: []string{"amd64.id", "$APPNAME-arm.id"}},
+		{name: "idfileandplatforms", idFile: "image.id", platforms: []string{"linux/amd64"}, idFiles: []string{"amd64.id"}, wantErr: true},
+		{name: "idfileswithoutplatforms", idFiles: []string{"amd64.id"}, wantErr: true},
+		{name: "missingidfile", platforms: []string{"linux/amd64", "linux/arm64"}, idFiles: []string{"amd64.id"}, wantErr: true},
+		{name: "emptyidfile", platforms: []string{"linux/amd64"}, idFiles: []string{""}, wantErr: true},
+		{name: "noarch", platforms: []string{"linux"}, idFiles: []string{"amd64.id"}, wantErr: true},
+		{name: "toomanyelements", platforms: []string{"linux/arm/v7/x"}, idFiles: []string{"arm.id"}, wantErr: true},
+		{name: "duplicateplatform", platforms: []string{"linux/amd64", "linux/amd64"}, idFiles: []string{"a.id", "b.id"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DockerImageOutput{
+				IDFile:    tt.idFile,
+				Platforms: tt.platforms,
+				IDFiles:   tt.idFiles,
+				RegistryUpload: DockerImageRegistryUpload{
+					Repository: "simplesurance/$APPNAME",
+					Tag:        "$GITCOMMIT",
+				},
+			}
+
+			err := d.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_URLInputs_Validate(t *testing.T) {
+	const sha256A = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	const sha256B = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	tests := []struct {
+		name    string
+		files   []*URLInput
+		wantErr bool
+	}{
+		{
+			name: "differenturls",
+			files: []*URLInput{
+				{URL: "https://example.com/a.tar.gz", SHA256: sha256A},
+				{URL: "https://example.com/b.tar.gz", SHA256: sha256B},
+			},
+		},
+		{
+			name: "identicalduplicates",
+			files: []*URLInput{
+				{URL: "https://example.com/a.tar.gz", SHA256: sha256A},
+				{URL: "https://example.com/a.tar.gz", SHA256: strings.ToUpper(sha256A)},
+			},
+		},
+		{
+			name: "conflictingduplicates",
+			files: []*URLInput{
+				{URL: "https://example.com/a.tar.gz", SHA256: sha256A},
+				{URL: "https://example.com/a.tar.gz", SHA256: sha256B},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := URLInputs{File: tt.files}
+
+			err := u.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_URLInput_Validate(t *testing.T) {
+	const validSHA256 = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+	tests := []struct {
+		name    string
+		url     string
+		sha256  string
+		errPath string
+	}{
+		{name: "https", url: "https://example.com/go.tar.gz", sha256: validSHA256},
+		{name: "http", url: "http://example.com:8080/go.tar.gz", sha256: validSHA256},
+		{name: "uppercasedigest", url: "https://example.com/go.tar.gz", sha256: strings.ToUpper(validSHA256)},
+
+		{name: "emptyurl", sha256: validSHA256, errPath: "url"},
+		{name: "relativeurl", url: "go.tar.gz", sha256: validSHA256, errPath: "url"},
+		{name: "ftpurl", url: "ftp://example.com/go.tar.gz", sha256: validSHA256, errPath: "url"},
+		{name: "emptydigest", url: "https://example.com/go.tar.gz", errPath: "sha256"},
+		{name: "shortdigest", url: "https://example.com/go.tar.gz", sha256: validSHA256[1:], errPath: "sha256"},
+		{name: "nonhexdigest", url: "https://example.com/go.tar.gz", sha256: "x" + validSHA256[1:], errPath: "sha256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := URLInput{URL: tt.url, SHA256: tt.sha256}
+
+			err := u.Validate()
+			if (err != nil) != (tt.errPath != "") {
+				t.Fatalf("Validate() error = %v, expected error: %v", err, tt.errPath != "")
+			}
+
+			if err == nil {
+				return
+			}
+
+			vErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("returned error is of type %T, expected *ValidationError", err)
+			}
+
+			if vErr.Path != tt.errPath {
+				t.Errorf("ValidationError path is %q, expected %q", vErr.Path, tt.errPath)
+			}
+		})
+	}
+}
+
+func Test_BuildInput_MergeOptional(t *testing.T) {
+	tests := []struct {
+		name     string
+		optional bool
+		other    bool
+		expected bool
+	}{
+		{name: "none", expected: false},
+		{name: "own", optional: true, expected: true},
+		{name: "other", other: true, expected: true},
+		{name: "both", optional: true, other: true, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := BuildInput{Files: FileInputs{Paths: []string{"*.c"}, Optional: tt.optional}}
+			other := BuildInput{Files: FileInputs{Paths: []string{"*.h"}, Optional: tt.other}}
+
+			in.Merge(&other)
+
+			if in.Files.Optional != tt.expected {
+				t.Errorf("Files.Optional is %t after merge, expected %t", in.Files.Optional, tt.expected)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cfg
 
 import (
+	"io"
 	"os"
 
 	"github.com/pelletier/go-toml"
@@ -22,9 +23,7 @@ func toFile(data interface{}, filepath string, overwrite bool) error {
 		return err
 	}
 
-	encoder := toml.NewEncoder(f)
-	encoder.Order(toml.OrderPreserve)
-	err = encoder.Encode(data)
+	err = encode(data, f, true)
 	if err != nil {
 		f.Close()
 		return err
@@ -37,3 +36,65 @@ func toFile(data interface{}, filepath string, overwrite bool) error {
 
 	return err
 }
+
+// encode serializes a struct to TOML format and writes it to w.
+// If withComments is false, no comments are written and fields that are
+// tagged as commented are written as normal values.
+func encode(data interface{}, w io.Writer, withComments bool) error {
+	encoder := toml.NewEncoder(w)
+	encoder.Order(toml.OrderPreserve)
+
+	if !withComments {
+		// the tags can not be disabled, setting tag names that are not
+		// used has the same effect
+		encoder.SetTagComment("-")
+		encoder.SetTagCommented("-")
+	}
+
+	return encoder.Encode(data)
+}
+
+// SchemaVersion is the newest version of the application and include
+// configuration file format that is supported
+const SchemaVersion = 1
+
+// schemaVersionHeader is used to read only the schema version of a config
+// file, before it is deserialized completely
+type schemaVersionHeader struct {
+	SchemaVersion int `toml:"schema_version"`
+}
+
+// validateSchemaVersion returns an error if the version is not supported.
+// Config files without a version (0) were written before the version was
+// introduced and are compatible with version 1.
+func validateSchemaVersion(version int) error {
+	if version < 0 {
+		return NewValidationError("schema_version", "%d is not a valid version", version)
+	}
+
+	if version > SchemaVersion {
+		return NewValidationError("schema_version",
+			"version %d is not supported, the newest supported version is %d, please update baur",
+			version, SchemaVersion)
+	}
+
+	return nil
+}
+
+// unmarshalVersioned checks the schema version in content and then
+// deserializes content into config.
+// The version is checked first, to report an incompatible version instead of
+// errors from deserializing changed fields.
+func unmarshalVersioned(content []byte, config interface{}) error {
+	var header schemaVersionHeader
+
+	if err := toml.Unmarshal(content, &header); err != nil {
+		return err
+	}
+
+	if err := validateSchemaVersion(header.SchemaVersion); err != nil {
+		return err
+	}
+
+	return toml.Unmarshal(content, config)
+}
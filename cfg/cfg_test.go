@@ -0,0 +1,150 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func writeTmpFile(t *testing.T, content string) string {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "baur")
+	if err != nil {
+		t.Fatal("opening tmpfile failed: ", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal("writing tmpfile failed: ", err)
+	}
+
+	return f.Name()
+}
+
+func Test_NewerSchemaVersionIsRejected(t *testing.T) {
+	// the name field has an incompatible type, the version error must be
+	// returned instead of an unmarshal error
+	const content = `
+schema_version = 2
+name = ["calc"]
+
+[BuildInput]
+Files = "x"
+`
+	path := writeTmpFile(t, content)
+	defer os.Remove(path)
+
+	loaders := map[string]func() error{
+		"app": func() error {
+			_, err := AppFromFile(path)
+			return err
+		},
+		"include": func() error {
+			_, err := IncludeFromFile(path)
+			return err
+		},
+	}
+
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			err := load()
+			if err == nil {
+				t.Fatal("loading config with newer schema version succeeded")
+			}
+
+			vErr, ok := errors.Cause(err).(*ValidationError)
+			if !ok {
+				t.Fatalf("returned error is of type %T, expected *ValidationError: %s", err, err)
+			}
+
+			if vErr.Path != "schema_version" {
+				t.Errorf("ValidationError path is %q, expected \"schema_version\"", vErr.Path)
+			}
+
+			if !strings.Contains(err.Error(), "2") {
+				t.Errorf("error message %q does not contain the unsupported version", err)
+			}
+		})
+	}
+}
+
+func Test_ConfigWithoutSchemaVersionIsValid(t *testing.T) {
+	const content = `
+name = "calc"
+
+[Build]
+command = "make"
+`
+	path := writeTmpFile(t, content)
+	defer os.Remove(path)
+
+	app, err := AppFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.Validate(); err != nil {
+		t.Error("validating app config without schema version failed:", err)
+	}
+}
+
+func Test_ValidateSchemaVersion(t *testing.T) {
+	a := ExampleApp("calc")
+	a.SchemaVersion = SchemaVersion + 1
+
+	if err := a.Validate(); err == nil {
+		t.Error("validating app config with newer schema version succeeded")
+	}
+
+	in := ExampleInclude()
+	in.SchemaVersion = -1
+
+	if err := in.Validate(); err == nil {
+		t.Error("validating include config with negative schema version succeeded")
+	}
+}
+
+func Test_AppFromReader(t *testing.T) {
+	app, err := AppFromReader(strings.NewReader(`
+name = "calc"
+
+[Build]
+command = "make"
+
+[Build.Input.Files]
+paths = ["*.c"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if app.Name != "calc" || app.Build.Command != "make" {
+		t.Errorf("unexpected app config read: %+v", app)
+	}
+
+	if len(app.Build.Input.Files.Paths) != 1 || app.Build.Input.Files.Paths[0] != "*.c" {
+		t.Errorf("unexpected file inputs read: %+v", app.Build.Input.Files)
+	}
+}
+
+func Test_IncludeFromReader(t *testing.T) {
+	include, err := IncludeFromReader(strings.NewReader(`
+[BuildInput.Files]
+paths = ["$ROOT/Makefile"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := include.Validate(); err != nil {
+		t.Error("validating include failed:", err)
+	}
+
+	if len(include.BuildInput.Files.Paths) != 1 {
+		t.Errorf("unexpected file inputs read: %+v", include.BuildInput.Files)
+	}
+}
@@ -1,23 +1,26 @@
 package cfg
 
 import (
+	"io"
 	"io/ioutil"
+	"os"
 
-	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 )
 
 // Include represents an include configuration file.
 type Include struct {
-	BuildInput  BuildInput
-	BuildOutput BuildOutput
+	SchemaVersion int `toml:"schema_version" comment:"Version of the configuration file format"`
+	BuildInput    BuildInput
+	BuildOutput   BuildOutput
 }
 
 // ExampleInclude returns an Include struct with exemplary values.
 func ExampleInclude() *Include {
 	return &Include{
-		BuildInput:  exampleBuildInput(),
-		BuildOutput: exampleBuildOutput(),
+		SchemaVersion: SchemaVersion,
+		BuildInput:    exampleBuildInput(),
+		BuildOutput:   exampleBuildOutput(),
 	}
 }
 
@@ -28,14 +31,25 @@ func (in *Include) IncludeToFile(filepath string) error {
 
 // IncludeFromFile deserializes an Include struct from a file.
 func IncludeFromFile(path string) (*Include, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return IncludeFromReader(f)
+}
+
+// IncludeFromReader deserializes an Include struct from r.
+func IncludeFromReader(r io.Reader) (*Include, error) {
 	config := Include{}
 
-	content, err := ioutil.ReadFile(path)
+	content, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	err = toml.Unmarshal(content, &config)
+	err = unmarshalVersioned(content, &config)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +61,10 @@ func IncludeFromFile(path string) (*Include, error) {
 
 // Validate validates an Include configuration struct.
 func (in *Include) Validate() error {
+	if err := validateSchemaVersion(in.SchemaVersion); err != nil {
+		return err
+	}
+
 	if err := in.BuildInput.Validate(); err != nil {
 		return errors.Wrap(err, "[BuildInput] section contains errors")
 	}
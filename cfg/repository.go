@@ -2,10 +2,18 @@ package cfg
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/git"
 )
 
 const (
@@ -17,29 +25,109 @@ const (
 	configVersion int = 2
 )
 
+// DefaultDigestAlgorithm is the algorithm that is used to calculate input
+// digests when no DigestAlgorithm is configured. It is the algorithm that
+// was used before the algorithm became configurable, to keep the digests of
+// existing repositories.
+const DefaultDigestAlgorithm = digest.SHA384
+
 // Repository contains the repository configuration.
 type Repository struct {
-	ConfigVersion int      `toml:"config_version" comment:"Version of baur configuration format"`
-	Database      Database `toml:"Database"`
-	Discover      Discover `comment:"Application discovery settings"`
+	ConfigVersion   int      `toml:"config_version" comment:"Version of baur configuration format"`
+	DigestAlgorithm string   `toml:"digest_algorithm" comment:"Algorithm that is used to calculate the digests of build inputs.\n Valid values: sha256, sha384. Defaults to sha384 when unset."`
+	GitBackend      string   `toml:"git_backend" comment:"Implementation that is used to list the files tracked in git for GitFiles inputs.\n Valid values: binary, builtin.\n binary runs the git executable, builtin reads the git index and does not require git to be installed.\n Defaults to binary if git is found in $PATH and to builtin otherwise." commented:"true"`
+	Environment     []string `toml:"environment" comment:"Environment variables in the format KEY=VALUE that are set when running build commands\n and discovering Golang source files of all applications.\n Variables with the same name in the GolangSources environment of an application override them.\n Valid variables: $ROOT"`
+	Database        Database `toml:"Database"`
+	Discover        Discover `comment:"Application discovery settings"`
+
+	filePath string
+}
+
+// DigestAlgorithmOrDefault returns the configured DigestAlgorithm, if it is
+// unset DefaultDigestAlgorithm is returned.
+func (r *Repository) DigestAlgorithmOrDefault() (digest.Algorithm, error) {
+	if r.DigestAlgorithm == "" {
+		return DefaultDigestAlgorithm, nil
+	}
+
+	return digest.AlgorithmFromString(r.DigestAlgorithm)
+}
+
+// GitBackendOrDefault returns the configured GitBackend. If it is unset,
+// git.BackendBinary is returned when the git executable is found in $PATH and
+// git.BackendBuiltin otherwise.
+func (r *Repository) GitBackendOrDefault() (git.Backend, error) {
+	if r.GitBackend != "" {
+		return git.BackendFromString(r.GitBackend)
+	}
+
+	if git.BinaryAvailable() {
+		return git.BackendBinary, nil
+	}
+
+	return git.BackendBuiltin, nil
 }
 
 // Database contains database configuration
 type Database struct {
 	PGSQLURL string `toml:"postgresql_url" comment:"Connection string to the PostgreSQL database, see https://www.postgresql.org/docs/current/static/libpq-connect.html#LIBPQ-CONNSTRING"`
+	// SSLRootCert, SSLCert and SSLKey are added to the connection string
+	// as sslrootcert, sslcert and sslkey parameters
+	SSLRootCert string `toml:"ssl_root_cert" comment:"Path to the CA certificate file the server certificate is verified with, relative paths are relative to the repository root"`
+	SSLCert     string `toml:"ssl_cert" comment:"Path to the client certificate file, relative paths are relative to the repository root"`
+	SSLKey      string `toml:"ssl_key" comment:"Path to the private key file of the client certificate, relative paths are relative to the repository root"`
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure the
+	// connection pool, unset values are replaced by defaults
+	MaxOpenConns    int    `toml:"max_open_conns" comment:"Max. number of open connections to the database, 0 uses the number of parallel jobs + 2"`
+	MaxIdleConns    int    `toml:"max_idle_conns" comment:"Max. number of idle connections to the database, 0 uses the number of parallel jobs + 2"`
+	ConnMaxLifetime string `toml:"conn_max_lifetime" comment:"Max. duration a connection to the database is reused, e.g. '10m', empty uses 5m"`
 }
 
 // Discover stores the [Discover] section of the repository configuration.
 type Discover struct {
-	Dirs        []string `toml:"application_dirs" comment:"List of directories containing applications, example: ['go/code', 'shop/']"`
-	SearchDepth int      `toml:"search_depth" comment:"Descend at most SearchDepth levels to find application configs"`
+	Dirs           []string `toml:"application_dirs" comment:"List of directories containing applications, example: ['go/code', 'shop/']"`
+	SearchDepth    int      `toml:"search_depth" comment:"Descend at most SearchDepth levels to find application configs"`
+	FollowSymlinks *bool    `toml:"follow_symlinks" comment:"If true, symlinks to directories are followed when searching for application configs, default: true"`
+	// SearchDepthOverrides are searched with their own search depth
+	// instead of SearchDepth
+	SearchDepthOverrides []*SearchDepthOverride `toml:"SearchDepthOverride" comment:"Search depths for specific directories"`
+}
+
+// FollowsSymlinks returns true if symlinks to directories should be
+// followed, this is the default if FollowSymlinks is unset
+func (d *Discover) FollowsSymlinks() bool {
+	return d.FollowSymlinks == nil || *d.FollowSymlinks
+}
+
+// SearchDepthOverride specifies the search depth for a directory.
+type SearchDepthOverride struct {
+	Dir   string `toml:"dir" comment:"Directory relative to the repository root, must be one of application_dirs or a subdirectory of one"`
+	Depth int    `toml:"depth" comment:"Descend at most depth levels below dir to find application configs"`
 }
 
 // RepositoryFromFile reads the repository config from a file and returns it.
 func RepositoryFromFile(cfgPath string) (*Repository, error) {
+	f, err := os.Open(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, err := RepositoryFromReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	config.filePath = cfgPath
+
+	return config, nil
+}
+
+// RepositoryFromReader reads the repository config from r and returns it.
+func RepositoryFromReader(r io.Reader) (*Repository, error) {
 	config := Repository{}
 
-	content, err := ioutil.ReadFile(cfgPath)
+	content, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
@@ -49,17 +137,27 @@ func RepositoryFromFile(cfgPath string) (*Repository, error) {
 		return nil, err
 	}
 
-	return &config, err
+	return &config, nil
+}
+
+// FilePath returns the path of the file the config was read from.
+// If the config was not read from a file an empty string is returned.
+func (r *Repository) FilePath() string {
+	return r.filePath
 }
 
 // ExampleRepository returns an exemplary Repository config
 func ExampleRepository() *Repository {
+	followSymlinks := true
+
 	return &Repository{
-		ConfigVersion: configVersion,
+		ConfigVersion:   configVersion,
+		DigestAlgorithm: digest.SHA256.String(),
 
 		Discover: Discover{
-			Dirs:        []string{"."},
-			SearchDepth: 1,
+			Dirs:           []string{"."},
+			SearchDepth:    1,
+			FollowSymlinks: &followSymlinks,
 		},
 
 		Database: Database{
@@ -86,14 +184,71 @@ func (r *Repository) Validate() error {
 			"Update your baur configuration files or downgrade baur.", r.ConfigVersion, configVersion)
 	}
 
+	if r.DigestAlgorithm != "" {
+		if _, err := digest.AlgorithmFromString(r.DigestAlgorithm); err != nil {
+			return errors.Wrap(err, "digest_algorithm parameter is invalid")
+		}
+	}
+
+	if r.GitBackend != "" {
+		if _, err := git.BackendFromString(r.GitBackend); err != nil {
+			return errors.Wrap(err, "git_backend parameter is invalid")
+		}
+	}
+
+	if err := validateEnvironment("environment", r.Environment, rootVars); err != nil {
+		return err
+	}
+
 	err := r.Discover.Validate()
 	if err != nil {
 		return errors.Wrap(err, "[Discover] section contains errors")
 	}
 
+	err = r.Database.Validate()
+	if err != nil {
+		return errors.Wrap(err, "[Database] section contains errors")
+	}
+
+	return nil
+}
+
+// Validate validates the Database section
+func (d *Database) Validate() error {
+	if d.MaxOpenConns < 0 {
+		return errors.New("max_open_conns parameter must not be negative")
+	}
+
+	if d.MaxIdleConns < 0 {
+		return errors.New("max_idle_conns parameter must not be negative")
+	}
+
+	if _, err := d.ConnMaxLifetimeDuration(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// ConnMaxLifetimeDuration returns the parsed conn_max_lifetime parameter, if
+// it is unset 0 is returned
+func (d *Database) ConnMaxLifetimeDuration() (time.Duration, error) {
+	if d.ConnMaxLifetime == "" {
+		return 0, nil
+	}
+
+	lifetime, err := time.ParseDuration(d.ConnMaxLifetime)
+	if err != nil {
+		return 0, errors.Wrap(err, "conn_max_lifetime parameter is invalid")
+	}
+
+	if lifetime <= 0 {
+		return 0, errors.New("conn_max_lifetime parameter must be positive")
+	}
+
+	return lifetime, nil
+}
+
 // Validate validates the Discover section and sets defaults.
 func (d *Discover) Validate() error {
 	if len(d.Dirs) == 0 {
@@ -105,5 +260,53 @@ func (d *Discover) Validate() error {
 			minSearchDepth, maxSearchDepth)
 	}
 
+	seen := make(map[string]struct{}, len(d.SearchDepthOverrides))
+
+	for _, o := range d.SearchDepthOverrides {
+		if err := o.validate(d.Dirs); err != nil {
+			return errors.Wrapf(err, "SearchDepthOverride %q", o.Dir)
+		}
+
+		dir := filepath.Clean(o.Dir)
+		if _, exist := seen[dir]; exist {
+			return fmt.Errorf("SearchDepthOverride %q is specified multiple times", o.Dir)
+		}
+
+		seen[dir] = struct{}{}
+	}
+
 	return nil
 }
+
+func (o *SearchDepthOverride) validate(discoverDirs []string) error {
+	if len(o.Dir) == 0 {
+		return errors.New("dir parameter can not be empty")
+	}
+
+	if filepath.IsAbs(o.Dir) {
+		return errors.New("dir parameter must be a relative path")
+	}
+
+	if o.Depth < minSearchDepth || o.Depth > maxSearchDepth {
+		return fmt.Errorf("depth parameter must be in range (%d, %d]",
+			minSearchDepth, maxSearchDepth)
+	}
+
+	for _, d := range discoverDirs {
+		if isSubDir(filepath.Clean(d), filepath.Clean(o.Dir)) {
+			return nil
+		}
+	}
+
+	return errors.New("dir parameter must be one of the application_dirs or a subdirectory of one")
+}
+
+// isSubDir returns true if dir is parent or a subdirectory of it, both paths
+// must be relative and cleaned
+func isSubDir(parent, dir string) bool {
+	if parent == "." {
+		return dir != ".." && !strings.HasPrefix(dir, ".."+string(filepath.Separator))
+	}
+
+	return dir == parent || strings.HasPrefix(dir, parent+string(filepath.Separator))
+}
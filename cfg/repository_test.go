@@ -3,7 +3,12 @@ package cfg
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/simplesurance/baur/digest"
 )
 
 func Test_ExampleRepository_IsValid(t *testing.T) {
@@ -40,4 +45,277 @@ func Test_ExampleRepository_WrittenAndReadCfgIsValid(t *testing.T) {
 	if err := rRead.Validate(); err != nil {
 		t.Error("validating conf from file failed: ", err)
 	}
+
+	if rRead.FilePath() != tmpfileName {
+		t.Errorf("FilePath() returned %q, expected %q", rRead.FilePath(), tmpfileName)
+	}
+}
+
+func Test_RepositoryFromReader(t *testing.T) {
+	const content = `
+config_version = 2
+
+[Database]
+  postgresql_url = "postgres://localhost:5432/baur"
+
+[Discover]
+  application_dirs = ["apps", "services"]
+  search_depth = 2
+`
+
+	r, err := RepositoryFromReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatal("RepositoryFromReader failed:", err)
+	}
+
+	if err := r.Validate(); err != nil {
+		t.Error("validating conf failed:", err)
+	}
+
+	if !reflect.DeepEqual(r.Discover.Dirs, []string{"apps", "services"}) {
+		t.Errorf("application_dirs is %v, expected [apps services]", r.Discover.Dirs)
+	}
+
+	if r.Discover.SearchDepth != 2 {
+		t.Errorf("search_depth is %d, expected 2", r.Discover.SearchDepth)
+	}
+
+	algorithm, err := r.DigestAlgorithmOrDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if algorithm != digest.SHA384 {
+		t.Errorf("digest algorithm is %s when digest_algorithm is unset, expected %s", algorithm, digest.SHA384)
+	}
+
+	if !r.Discover.FollowsSymlinks() {
+		t.Error("symlinks are not followed when follow_symlinks is unset")
+	}
+
+	if r.Database.PGSQLURL != "postgres://localhost:5432/baur" {
+		t.Errorf("postgresql_url is %q, expected %q", r.Database.PGSQLURL, "postgres://localhost:5432/baur")
+	}
+
+	if r.FilePath() != "" {
+		t.Errorf("FilePath() returned %q for a config that was not read from a file", r.FilePath())
+	}
+}
+
+func Test_RepositoryValidateDiscover(t *testing.T) {
+	r := ExampleRepository()
+	r.Discover.SearchDepth = -1
+	if err := r.Validate(); err == nil {
+		t.Error("validation succeeded for a negative search_depth")
+	}
+
+	r = ExampleRepository()
+	r.Discover.Dirs = nil
+	if err := r.Validate(); err == nil {
+		t.Error("validation succeeded for empty application_dirs")
+	}
+}
+
+func Test_RepositoryValidateDigestAlgorithm(t *testing.T) {
+	testcases := []struct {
+		algorithm string
+		valid     bool
+	}{
+		{algorithm: "", valid: true},
+		{algorithm: "sha256", valid: true},
+		{algorithm: "sha384", valid: true},
+		{algorithm: "SHA256", valid: true},
+		{algorithm: "md5", valid: false},
+		{algorithm: "blake3", valid: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			r := ExampleRepository()
+			r.DigestAlgorithm = tc.algorithm
+
+			err := r.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("validation failed for digest_algorithm %q: %s", tc.algorithm, err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Errorf("validation succeeded for invalid digest_algorithm %q", tc.algorithm)
+			}
+		})
+	}
+}
+
+func Test_RepositoryValidateGitBackend(t *testing.T) {
+	testcases := []struct {
+		backend string
+		valid   bool
+	}{
+		{backend: "", valid: true},
+		{backend: "binary", valid: true},
+		{backend: "builtin", valid: true},
+		{backend: "Builtin", valid: true},
+		{backend: "go-git", valid: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.backend, func(t *testing.T) {
+			r := ExampleRepository()
+			r.GitBackend = tc.backend
+
+			err := r.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("validation failed for git_backend %q: %s", tc.backend, err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Errorf("validation succeeded for invalid git_backend %q", tc.backend)
+			}
+		})
+	}
+}
+
+func Test_RepositoryValidateEnvironment(t *testing.T) {
+	testcases := []struct {
+		env   string
+		valid bool
+	}{
+		{env: "GOFLAGS=-mod=vendor", valid: true},
+		{env: "GOPATH=$ROOT/go", valid: true},
+		{env: "EMPTY=", valid: true},
+		{env: "_UNDERSCORE_1=x", valid: true},
+		{env: "NOVALUE", valid: false},
+		{env: "=value", valid: false},
+		{env: "1KEY=value", valid: false},
+		{env: "MY-KEY=value", valid: false},
+		{env: "APP=$APPNAME", valid: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.env, func(t *testing.T) {
+			r := ExampleRepository()
+			r.Environment = []string{tc.env}
+
+			err := r.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("validation failed for environment %q: %s", tc.env, err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Errorf("validation succeeded for invalid environment %q", tc.env)
+			}
+		})
+	}
+}
+
+func Test_RepositoryValidateSearchDepthOverrides(t *testing.T) {
+	testcases := []struct {
+		name      string
+		dirs      []string
+		overrides []*SearchDepthOverride
+		valid     bool
+	}{
+		{
+			name:      "subdir",
+			dirs:      []string{"apps"},
+			overrides: []*SearchDepthOverride{{Dir: "apps/vendor", Depth: 5}},
+			valid:     true,
+		},
+		{
+			name:      "samedir",
+			dirs:      []string{"apps/"},
+			overrides: []*SearchDepthOverride{{Dir: "apps", Depth: 5}},
+			valid:     true,
+		},
+		{
+			name:      "repositoryroot",
+			dirs:      []string{"."},
+			overrides: []*SearchDepthOverride{{Dir: "vendor", Depth: 5}},
+			valid:     true,
+		},
+		{
+			name:      "outsidediscoverdirs",
+			dirs:      []string{"apps"},
+			overrides: []*SearchDepthOverride{{Dir: "appsx", Depth: 5}},
+		},
+		{
+			name:      "outsiderepository",
+			dirs:      []string{"."},
+			overrides: []*SearchDepthOverride{{Dir: "../vendor", Depth: 5}},
+		},
+		{
+			name:      "absolute",
+			dirs:      []string{"."},
+			overrides: []*SearchDepthOverride{{Dir: "/vendor", Depth: 5}},
+		},
+		{
+			name:      "invaliddepth",
+			dirs:      []string{"."},
+			overrides: []*SearchDepthOverride{{Dir: "vendor", Depth: maxSearchDepth + 1}},
+		},
+		{
+			name: "duplicate",
+			dirs: []string{"."},
+			overrides: []*SearchDepthOverride{
+				{Dir: "vendor", Depth: 5},
+				{Dir: "vendor/", Depth: 3},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := ExampleRepository()
+			r.Discover.Dirs = tc.dirs
+			r.Discover.SearchDepthOverrides = tc.overrides
+
+			err := r.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("validation failed: %s", err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Error("validation succeeded for an invalid SearchDepthOverride")
+			}
+		})
+	}
+}
+
+func Test_RepositoryValidateDatabase(t *testing.T) {
+	r := ExampleRepository()
+	r.Database.MaxOpenConns = 10
+	r.Database.MaxIdleConns = 5
+	r.Database.ConnMaxLifetime = "10m"
+	if err := r.Validate(); err != nil {
+		t.Error("validation failed for valid pool parameters:", err)
+	}
+
+	lifetime, err := r.Database.ConnMaxLifetimeDuration()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lifetime != 10*time.Minute {
+		t.Errorf("ConnMaxLifetimeDuration returned %s, expected 10m", lifetime)
+	}
+
+	r = ExampleRepository()
+	r.Database.MaxOpenConns = -1
+	if err := r.Validate(); err == nil {
+		t.Error("validation succeeded for a negative max_open_conns")
+	}
+
+	r = ExampleRepository()
+	r.Database.MaxIdleConns = -1
+	if err := r.Validate(); err == nil {
+		t.Error("validation succeeded for a negative max_idle_conns")
+	}
+
+	for _, lifetime := range []string{"10", "-5m", "0s"} {
+		r = ExampleRepository()
+		r.Database.ConnMaxLifetime = lifetime
+		if err := r.Validate(); err == nil {
+			t.Errorf("validation succeeded for conn_max_lifetime %q", lifetime)
+		}
+	}
 }
@@ -0,0 +1,174 @@
+package cfg
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ValidationError is returned when a configuration element contains an
+// invalid value
+type ValidationError struct {
+	// Path is the name of the configuration element that is invalid
+	Path    string
+	Message string
+}
+
+// NewValidationError returns a ValidationError for the element path.
+func NewValidationError(path, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the error description
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// Variables that can be used in the configuration fields, each field supports
+// one of the sets.
+var (
+	rootVars       = []string{"$ROOT"}
+	workingDirVars = []string{"$ROOT", "$APPNAME"}
+	appNameVars    = []string{"$APPNAME"}
+	outputPathVars = []string{"$APPNAME", "$OS", "$ARCH"}
+	uploadDestVars = []string{"$APPNAME", "$UUID", "$GITCOMMIT", "$OS", "$ARCH"}
+)
+
+// Vars are the names of all variables that are supported in configuration
+// values. They are ordered by length, longest first, to match the longest
+// name when one name is the prefix of another.
+var Vars = []string{"$GITCOMMIT", "$APPNAME", "$ROOT", "$UUID", "$ARCH", "$OS"}
+
+// unknownVarRe matches a variable reference like $APPNANE that does not start
+// with the name of a supported variable
+var unknownVarRe = regexp.MustCompile(`^\$[A-Za-z_][A-Za-z0-9_]*`)
+
+// ReplaceVarRefs replaces all variable references in value with the result of
+// calling replaceFn for them.
+// Variable names are matched as prefix, $APPNAME_bin references the variable
+// $APPNAME followed by "_bin". If a $ is not followed by the name of a
+// supported variable, the longest $WORD token is passed to replaceFn as
+// reference of an unknown variable.
+func ReplaceVarRefs(value string, replaceFn func(ref string) string) string {
+	var res strings.Builder
+
+	for {
+		idx := strings.IndexByte(value, '$')
+		if idx == -1 {
+			res.WriteString(value)
+			return res.String()
+		}
+
+		res.WriteString(value[:idx])
+		value = value[idx:]
+
+		ref := varRef(value)
+		if ref == "" {
+			res.WriteByte('$')
+			value = value[1:]
+			continue
+		}
+
+		res.WriteString(replaceFn(ref))
+		value = value[len(ref):]
+	}
+}
+
+// varRef returns the variable reference that s starts with. If s does not
+// start with a variable reference an empty string is returned.
+func varRef(s string) string {
+	for _, v := range Vars {
+		if strings.HasPrefix(s, v) {
+			return v
+		}
+	}
+
+	return unknownVarRe.FindString(s)
+}
+
+// validateVars returns a ValidationError for path if value references a
+// variable that is not in allowedVars.
+func validateVars(path, value string, allowedVars []string) error {
+	var unknownVar string
+
+	ReplaceVarRefs(value, func(ref string) string {
+		if unknownVar == "" && !containsString(allowedVars, ref) {
+			unknownVar = ref
+		}
+
+		return ref
+	})
+
+	if unknownVar != "" {
+		return NewValidationError(path, "unknown variable %s in %q, valid variables: %s",
+			unknownVar, value, strings.Join(allowedVars, ", "))
+	}
+
+	return nil
+}
+
+// validateVarsSlice runs validateVars for all values.
+func validateVarsSlice(path string, values []string, allowedVars []string) error {
+	for _, v := range values {
+		if err := validateVars(path, v, allowedVars); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateRelPathInDir returns a ValidationError for path if value is an
+// absolute path or if it refers to a location outside of the directory it is
+// relative to.
+func validateRelPathInDir(path, value string) error {
+	if filepath.IsAbs(value) {
+		return NewValidationError(path, "%q must be a relative path", value)
+	}
+
+	cleaned := filepath.Clean(value)
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return NewValidationError(path, "%q refers to a location outside of the application directory", value)
+	}
+
+	return nil
+}
+
+// envKeyRe matches valid environment variable names
+var envKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnvironment returns a ValidationError for path if an element of env
+// is not in the format KEY=VALUE or references a variable that is not in
+// allowedVars.
+func validateEnvironment(path string, env []string, allowedVars []string) error {
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			return NewValidationError(path, "%q is not in the format KEY=VALUE", e)
+		}
+
+		if !envKeyRe.MatchString(kv[0]) {
+			return NewValidationError(path, "%q is not a valid environment variable name", kv[0])
+		}
+
+		if err := validateVars(path, e, allowedVars); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,8 +1,11 @@
 package command
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -11,15 +14,16 @@ import (
 
 	"github.com/simplesurance/baur"
 	"github.com/simplesurance/baur/build"
-	"github.com/simplesurance/baur/build/seq"
-	"github.com/simplesurance/baur/digest"
-	"github.com/simplesurance/baur/digest/sha384"
+	"github.com/simplesurance/baur/build/parallel"
 	"github.com/simplesurance/baur/log"
 	"github.com/simplesurance/baur/prettyprint"
 	"github.com/simplesurance/baur/storage"
 	"github.com/simplesurance/baur/term"
+	"github.com/simplesurance/baur/upload/azure"
+	"github.com/simplesurance/baur/upload/cmdupload"
 	"github.com/simplesurance/baur/upload/docker"
 	"github.com/simplesurance/baur/upload/filecopy"
+	"github.com/simplesurance/baur/upload/gcs"
 	"github.com/simplesurance/baur/upload/s3"
 	"github.com/simplesurance/baur/upload/scheduler"
 	sequploader "github.com/simplesurance/baur/upload/scheduler/seq"
@@ -29,6 +33,13 @@ const (
 	dockerEnvUsernameVar = "BAUR_DOCKER_USERNAME"
 	dockerEnvPasswordVar = "BAUR_DOCKER_PASSWORD"
 
+	gcsEnvAccessKeyIDVar = "BAUR_GCS_ACCESS_KEY_ID"
+	gcsEnvSecretVar      = "BAUR_GCS_SECRET_ACCESS_KEY"
+
+	azureEnvAccountVar  = "AZURE_STORAGE_ACCOUNT"
+	azureEnvKeyVar      = "AZURE_STORAGE_KEY"
+	azureEnvSASTokenVar = "AZURE_STORAGE_SAS_TOKEN"
+
 	appColSep = " => "
 	sepLen    = len(appColSep)
 )
@@ -37,6 +48,8 @@ var buildLongHelp = fmt.Sprintf(`
 Build applications.
 If no path or application name is passed, all applications in the repository are build.
 By default only applications with status %s and %s are build.
+Applications are built in parallel, by default as many as the machine has
+CPUs (--jobs). The output of their build commands is interleaved line by line.
 
 The following Environment Variables are supported:
     %s
@@ -46,6 +59,15 @@ The following Environment Variables are supported:
     %s
     %s
 
+  Google Cloud Storage Upload:
+    %s
+    %s
+
+  Azure Blob Storage Upload:
+    %s
+    %s
+    %s (used if %s is not set)
+
   Docker Registry Upload:
     %s
     %s
@@ -63,6 +85,13 @@ The following Environment Variables are supported:
 	highlight("AWS_ACCESS_KEY_ID"),
 	highlight("AWS_SECRET_ACCESS_KEY"),
 
+	highlight(gcsEnvAccessKeyIDVar),
+	highlight(gcsEnvSecretVar),
+
+	highlight(azureEnvAccountVar),
+	highlight(azureEnvKeyVar),
+	highlight(azureEnvSASTokenVar), azureEnvKeyVar,
+
 	highlight(dockerEnvUsernameVar),
 	highlight(dockerEnvPasswordVar),
 	highlight("DOCKER_HOST"),
@@ -74,7 +103,11 @@ const buildExampleHelp = `
 build payment-service		build and upload the application with the name payment-service
 build --verbose --force		rebuild and upload all applications, enable verbose output
 build --skip-upload shop-ui	build the application with the name shop-ui, skip uploading it's build ouputs
+build --skip-record shop-ui	build the application with the name shop-ui and upload it's build outputs,
+				don't record the build in the database
 build ui/shop			build and upload the application in the directory ui/shop
+build -j 2 --fail-fast		build and upload all applications, run max. 2 builds in parallel,
+				don't start further builds after a build failed
 `
 
 var buildCmd = &cobra.Command{
@@ -88,10 +121,17 @@ var buildCmd = &cobra.Command{
 
 var (
 	buildSkipUpload bool
+	buildSkipRecord bool
 	buildForce      bool
+	buildJobCnt     int
+	buildFailFast   bool
+	buildQuiet      bool
 
 	result     = map[string]*storage.Build{}
 	resultLock = sync.Mutex{}
+	// postCommandFailed is true if the post_command of an application
+	// failed, it is protected by resultLock
+	postCommandFailed bool
 
 	store          storage.Storer
 	outputBackends baur.BuildOutputBackends
@@ -99,6 +139,7 @@ var (
 
 type uploadUserData struct {
 	App    *baur.App
+	Job    *build.Job
 	Output baur.BuildOutput
 }
 
@@ -111,8 +152,18 @@ type buildUserData struct {
 func init() {
 	buildCmd.Flags().BoolVarP(&buildSkipUpload, "skip-upload", "s", false,
 		"skip uploading build outputs and recording the build")
+	buildCmd.Flags().BoolVar(&buildSkipRecord, "skip-record", false,
+		"upload build outputs but skip recording the build in the database")
 	buildCmd.Flags().BoolVarP(&buildForce, "force", "f", false,
 		"force rebuilding of all applications")
+	buildCmd.Flags().IntVarP(&buildJobCnt, "jobs", "j", runtime.NumCPU(),
+		"max. number of applications that are built in parallel,\n"+
+			"the output of parallel builds is interleaved line by line,\n"+
+			"use -j 1 to build one application after another")
+	buildCmd.Flags().BoolVar(&buildFailFast, "fail-fast", false,
+		"don't start further builds after a build failed")
+	buildCmd.Flags().BoolVarP(&buildQuiet, "quiet", "q", false,
+		"don't print the output of build commands while they run")
 	rootCmd.AddCommand(buildCmd)
 }
 
@@ -149,7 +200,7 @@ func resultAddUploadResult(appName string, ar baur.BuildOutput, r *scheduler.Res
 	}
 
 	switch r.Job.Type() {
-	case scheduler.JobDocker:
+	case scheduler.JobDocker, scheduler.JobDockerManifest:
 		arType = storage.DockerArtifact
 		uploadMethod = storage.DockerRegistry
 	case scheduler.JobFileCopy:
@@ -158,6 +209,15 @@ func resultAddUploadResult(appName string, ar baur.BuildOutput, r *scheduler.Res
 	case scheduler.JobS3:
 		arType = storage.FileArtifact
 		uploadMethod = storage.S3
+	case scheduler.JobGCS:
+		arType = storage.FileArtifact
+		uploadMethod = storage.GCS
+	case scheduler.JobAzureBlob:
+		arType = storage.FileArtifact
+		uploadMethod = storage.AzureBlob
+	case scheduler.JobCommand:
+		arType = storage.FileArtifact
+		uploadMethod = storage.Command
 	default:
 		panic(fmt.Sprintf("unknown job type %v", r.Job.Type()))
 	}
@@ -180,6 +240,7 @@ func resultAddUploadResult(appName string, ar baur.BuildOutput, r *scheduler.Res
 			URI:            r.URL,
 			Method:         uploadMethod,
 			UploadDuration: r.Duration,
+			RegistryDigest: r.RegistryDigest,
 		},
 		Digest: artDigest.String(),
 	})
@@ -219,7 +280,6 @@ func dockerAuthFromEnv() (string, string) {
 func calcDigests(app *baur.App) ([]*storage.Input, string) {
 	var totalDigest string
 	var storageInputs []*storage.Input
-	inputDigests := []*digest.Digest{}
 
 	// TODO: refactor this functions, most is obsolete and can be replaced
 	// by App.TotalInputDigests()
@@ -242,12 +302,34 @@ func calcDigests(app *baur.App) ([]*storage.Input, string) {
 			Digest: d.String(),
 			URI:    s.RepoRelPath(),
 		})
+	}
+
+	for _, e := range app.EnvironmentInputs() {
+		d, err := e.Digest()
+		if err != nil {
+			log.Fatalf("%s: calculating environment input digest failed: %s", app, err)
+		}
+
+		storageInputs = append(storageInputs, &storage.Input{
+			Digest: d.String(),
+			URI:    e.URI(),
+		})
+	}
+
+	for _, u := range app.URLInputs() {
+		d, err := u.Digest()
+		if err != nil {
+			log.Fatalf("%s: calculating URL input digest failed: %s", app, err)
+		}
 
-		inputDigests = append(inputDigests, &d)
+		storageInputs = append(storageInputs, &storage.Input{
+			Digest: d.String(),
+			URI:    u.URI(),
+		})
 	}
 
-	if len(inputDigests) > 0 {
-		td, err := sha384.Sum(inputDigests)
+	if len(storageInputs) > 0 {
+		td, err := app.TotalInputDigest()
 		if err != nil {
 			log.Fatalf("%s: calculating total input digest failed: %s", app, err)
 		}
@@ -258,17 +340,40 @@ func calcDigests(app *baur.App) ([]*storage.Input, string) {
 	return storageInputs, totalDigest
 }
 
-func createBuildJobs(apps []*baur.App) []*build.Job {
+// createBuildJobs creates the build jobs for the apps, the environment
+// variables in urlInputEnv are set for the build commands of the apps.
+func createBuildJobs(apps []*baur.App, urlInputEnv map[*baur.App][]string) []*build.Job {
 	buildJobs := make([]*build.Job, 0, len(apps))
 
+	var outputStream io.Writer
+	if !buildQuiet {
+		outputStream = os.Stdout
+	}
+
 	for _, app := range apps {
 		buildInputs, totalDigest := calcDigests(app)
 		log.Debugf("%s: total input digest: %s\n", app, totalDigest)
 
+		env := make([]string, 0, len(app.Repository.Environment)+len(urlInputEnv[app]))
+		env = append(env, app.Repository.Environment...)
+		env = append(env, urlInputEnv[app]...)
+
+		outputPaths := make([]string, 0, len(app.Outputs))
+		for _, o := range app.Outputs {
+			outputPaths = append(outputPaths, o.LocalPath())
+		}
+
 		buildJobs = append(buildJobs, &build.Job{
-			Application: app.Name,
-			Directory:   app.Path,
-			Command:     app.BuildCmd,
+			Application:  app.Name,
+			Directory:    app.BuildDir,
+			Command:      app.BuildCmd,
+			Args:         app.BuildArgs,
+			PreCommand:   app.PreBuildCmd,
+			PostCommand:  app.PostBuildCmd,
+			Timeout:      app.BuildTimeout,
+			OutputStream: outputStream,
+			Environment:  env,
+			OutputPaths:  outputPaths,
 			UserData: &buildUserData{
 				App:              app,
 				Inputs:           buildInputs,
@@ -280,29 +385,49 @@ func createBuildJobs(apps []*baur.App) []*build.Job {
 	return buildJobs
 }
 
-func startBGUploader(outputCnt int, uploadChan chan *scheduler.Result) scheduler.Manager {
-	var dockerUploader *docker.Client
-	s3Uploader, err := s3.NewClient(log.StdLogger)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
+// mustNewDockerClient returns a docker client that authenticates with the
+// credentials from the environment variables if they are set, otherwise with
+// the ones from the user's docker config.json
+func mustNewDockerClient() *docker.Client {
+	var clt *docker.Client
+	var err error
 
 	dockerUser, dockerPass := dockerAuthFromEnv()
 	if len(dockerUser) != 0 {
 		log.Debugf("using docker authentication data from %s, %s Environment variables, authenticating as '%s'",
 			dockerEnvUsernameVar, dockerEnvPasswordVar, dockerUser)
-		dockerUploader, err = docker.NewClientwAuth(log.StdLogger.Debugf, dockerUser, dockerPass)
+		clt, err = docker.NewClientwAuth(log.StdLogger.Debugf, dockerUser, dockerPass)
 	} else {
 		log.Debugf("environment variable %s not set", dockerEnvUsernameVar)
-		dockerUploader, err = docker.NewClient(log.StdLogger.Debugf)
+		clt, err = docker.NewClient(log.StdLogger.Debugf)
 	}
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	return clt
+}
+
+func startBGUploader(outputCnt int, uploadChan chan *scheduler.Result) scheduler.Manager {
+	s3Uploader, err := s3.NewClient(log.StdLogger)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	dockerUploader := mustNewDockerClient()
+
+	gcsUploader, err := gcs.NewClient(log.StdLogger, os.Getenv(gcsEnvAccessKeyIDVar), os.Getenv(gcsEnvSecretVar))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	azureBlobUploader := azure.NewClient(log.StdLogger, os.Getenv(azureEnvAccountVar), os.Getenv(azureEnvKeyVar), os.Getenv(azureEnvSASTokenVar))
+
 	filecopyUploader := filecopy.New(log.Debugf)
 
-	uploader := sequploader.New(log.StdLogger, filecopyUploader, s3Uploader, dockerUploader, uploadChan)
+	commandUploader := cmdupload.New(log.Debugf)
+
+	uploader := sequploader.New(log.StdLogger, filecopyUploader, s3Uploader, gcsUploader, azureBlobUploader, commandUploader, dockerUploader, uploadChan)
 
 	outputBackends.DockerClt = dockerUploader
 
@@ -311,10 +436,21 @@ func startBGUploader(outputCnt int, uploadChan chan *scheduler.Result) scheduler
 	return uploader
 }
 
-func waitPrintUploadStatus(uploader scheduler.Manager, uploadChan chan *scheduler.Result, finished chan struct{}, outputCnt int) {
+// waitPrintUploadStatus processes upload results until as many results as
+// received via uploadCntChan were processed.
+func waitPrintUploadStatus(uploader scheduler.Manager, uploadChan chan *scheduler.Result, finished chan struct{}, uploadCntChan <-chan int) {
 	var resultCnt int
+	uploadCnt := -1
+
+	for uploadCnt != resultCnt {
+		var res *scheduler.Result
+
+		select {
+		case uploadCnt = <-uploadCntChan:
+			continue
+		case res = <-uploadChan:
+		}
 
-	for res := range uploadChan {
 		ud, ok := res.Job.GetUserData().(*uploadUserData)
 		if !ok {
 			log.Fatalln("upload result user data has unexpected type")
@@ -330,20 +466,24 @@ func waitPrintUploadStatus(uploader scheduler.Manager, uploadChan chan *schedule
 		resultAddUploadResult(ud.App.Name, ud.Output, res)
 
 		complete, build := recordResultIsComplete(ud.App)
-		if complete {
+		if complete && !buildSkipRecord {
 			log.Debugf("%s: storing build information in database\n", ud.App)
-			if err := store.Save(build); err != nil {
+			ctx, cancel := newStorageCtx()
+			id, err := store.SaveBuild(ctx, build)
+			cancel()
+			if err != nil {
 				log.Fatalf("storing build information about %q failed: %s", ud.App.Name, err)
 			}
-			fmt.Printf("%s: build %d stored in database\n", ud.App.Name, build.ID)
+			fmt.Printf("%s: build %d stored in database\n", ud.App.Name, id)
 
 			log.Debugf("stored the following build information: %s\n", prettyprint.AsString(build))
 		}
 
-		resultCnt++
-		if resultCnt == outputCnt {
-			break
+		if complete {
+			runPostCommand(ud.Job)
 		}
+
+		resultCnt++
 	}
 
 	uploader.Stop()
@@ -351,6 +491,18 @@ func waitPrintUploadStatus(uploader scheduler.Manager, uploadChan chan *schedule
 	close(finished)
 }
 
+// runPostCommand runs the post_command of the application of the job.
+// If it fails, the error is logged and postCommandFailed is set.
+func runPostCommand(j *build.Job) {
+	if err := build.RunPostCommand(j); err != nil {
+		log.Errorf("%s: %s", j.Application, err)
+
+		resultLock.Lock()
+		postCommandFailed = true
+		resultLock.Unlock()
+	}
+}
+
 func maxAppNameLen(apps []*baur.App) int {
 	var maxLen int
 
@@ -386,10 +538,13 @@ func appsWithBuildCommand(apps []*baur.App) []*baur.App {
 func pendingBuilds(storage storage.Storer, apps []*baur.App) []*baur.App {
 	var res []*baur.App
 
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
 	appNameColLen := maxAppNameLen(apps) + sepLen
 
 	for _, app := range apps {
-		buildStatus, build, _ := mustGetBuildStatus(app, storage)
+		buildStatus, build, _ := mustGetBuildStatus(ctx, app, storage)
 
 		if buildStatus == baur.BuildStatusExist {
 			fmt.Printf("%-*s%s%s (%s)\n",
@@ -418,8 +573,8 @@ func buildRun(cmd *cobra.Command, args []string) {
 
 	repo := MustFindRepository()
 
-	if !buildSkipUpload || !buildForce {
-		store = MustGetPostgresClt(repo)
+	if !buildForce || (!buildSkipUpload && !buildSkipRecord) {
+		store = mustGetStorageCltForJobs(repo, buildJobCnt)
 	}
 
 	startTs := time.Now()
@@ -440,6 +595,8 @@ func buildRun(cmd *cobra.Command, args []string) {
 
 	if buildSkipUpload {
 		fmt.Println("Outputs are not uploaded.")
+	} else if buildSkipRecord {
+		fmt.Println("Builds are not recorded in the database.")
 	}
 
 	if len(apps) == 0 {
@@ -452,43 +609,82 @@ func buildRun(cmd *cobra.Command, args []string) {
 		os.Exit(0)
 	}
 
-	buildJobs := createBuildJobs(apps)
+	if buildJobCnt < 1 {
+		log.Fatalln("--jobs must be greater than 0")
+	}
+
+	urlInputEnv := mustFetchURLInputs(apps)
+
+	jobs := createBuildJobs(apps, urlInputEnv)
 	buildChan := make(chan *build.Result, len(apps))
-	builder := seq.New(buildJobs, buildChan)
+	builder := parallel.New(jobs, buildChan, buildJobCnt, buildFailFast)
 	outputCnt := outputCount(apps)
 
+	var uploadCntChan chan int
+	var uploadCnt int
+
 	if !buildSkipUpload {
 		uploadChan := make(chan *scheduler.Result, outputCnt)
+		uploadCntChan = make(chan int, 1)
 		uploader = startBGUploader(outputCnt, uploadChan)
 		uploadWatchFin = make(chan struct{}, 1)
-		go waitPrintUploadStatus(uploader, uploadChan, uploadWatchFin, outputCnt)
+		go waitPrintUploadStatus(uploader, uploadChan, uploadWatchFin, uploadCntChan)
 	}
 
 	term.PrintSep()
 
 	go builder.Start()
 
+	var succeededCnt, failedCnt int
+
 	for status := range buildChan {
 		bud := status.Job.UserData.(*buildUserData)
 		app := bud.App
 
+		if status.Skipped {
+			log.Warnf("%s: build skipped: %s\n", app.Name, status.Error)
+			continue
+		}
+
 		if status.Error != nil {
-			log.Fatalf("%s: build failed: %s", app.Name, status.Error)
+			log.Errorf("%s: build failed: %s", app.Name, status.Error)
+			failedCnt++
+			continue
 		}
 
 		if status.ExitCode != 0 {
-			log.Fatalf("%s: build failed: command (%q) exited with code %d "+
-				"Output: %s",
-				app.Name, status.Job.Command, status.ExitCode, status.Output)
+			// without --quiet the output was already streamed
+			if buildQuiet {
+				log.Errorf("%s: build failed: command (%q) exited with code %d "+
+					"Output: %s",
+					app.Name, status.Job.Command, status.ExitCode, status.Output)
+			} else {
+				log.Errorf("%s: build failed: command (%q) exited with code %d",
+					app.Name, status.Job.Command, status.ExitCode)
+			}
+
+			failedCnt++
+			continue
+		}
+
+		if missing := missingOutputs(app); len(missing) != 0 {
+			log.Errorf("%s: build outputs %s did not exist after build",
+				app, strings.Join(missing, ", "))
+			failedCnt++
+			continue
 		}
 
 		fmt.Printf("%s: build successful (%.3fs)\n", app.Name, status.StopTs.Sub(status.StartTs).Seconds())
+		succeededCnt++
 		resultAddBuildResult(bud, status)
 
 		for _, ar := range app.Outputs {
-			if !ar.Exists() {
-				log.Fatalf("%s: build output %q did not exist after build",
-					app, ar)
+			// the digest is calculated before the upload starts, it
+			// is recorded when the upload finished
+			d, err := ar.Digest()
+			if err != nil {
+				log.Fatalf("%s: calculating digest of output %s failed: %s",
+					app.Name, ar, err)
 			}
 
 			if !buildSkipUpload {
@@ -500,36 +696,101 @@ func buildRun(cmd *cobra.Command, args []string) {
 
 				uj.SetUserData(&uploadUserData{
 					App:    app,
+					Job:    status.Job,
 					Output: ar,
 				})
 
 				uploader.Add(uj)
-
-			}
-			d, err := ar.Digest()
-			if err != nil {
-				log.Fatalf("%s: calculating input digest of %s failed: %s",
-					app.Name, ar, err)
+				uploadCnt++
 			}
 
 			fmt.Printf("%s: created %s (%s)\n", app.Name, ar, d)
 		}
 
+		// the post_command of applications with uploaded outputs is
+		// run when all uploads finished
+		if buildSkipUpload || len(app.Outputs) == 0 {
+			runPostCommand(status.Job)
+		}
 	}
 
-	if !buildSkipUpload && outputCnt > 0 {
-		fmt.Println("waiting for uploads to finish...")
+	if !buildSkipUpload {
+		uploadCntChan <- uploadCnt
+
+		if uploadCnt > 0 {
+			fmt.Println("waiting for uploads to finish...")
+		}
+
 		<-uploadWatchFin
 	}
 
 	term.PrintSep()
+	fmt.Printf("%d builds succeeded, %d failed, %d skipped\n",
+		succeededCnt, failedCnt, len(jobs)-succeededCnt-failedCnt)
 	fmt.Printf("finished in %ss\n", durationToStrSeconds(time.Since(startTs)))
+
+	resultLock.Lock()
+	failed := failedCnt > 0 || postCommandFailed
+	resultLock.Unlock()
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// mustFetchURLInputs downloads the URLInputs of the apps that are not in
+// the download cache and verifies their digests.
+// It returns the environment variables that contain the paths of the
+// downloaded files per app, apps without URLInputs are not in the map.
+func mustFetchURLInputs(apps []*baur.App) map[*baur.App][]string {
+	var cacheDir string
+
+	res := map[*baur.App][]string{}
+
+	for _, app := range apps {
+		if len(app.URLInputs()) == 0 {
+			continue
+		}
+
+		if cacheDir == "" {
+			var err error
+
+			cacheDir, err = baur.URLInputCacheDir()
+			if err != nil {
+				log.Fatalln("determining download cache directory failed:", err)
+			}
+		}
+
+		paths, err := app.FetchURLInputs(cacheDir)
+		if err != nil {
+			log.Fatalf("%s: %s\n", app, err)
+		}
+
+		res[app] = baur.URLInputEnv(paths)
+		log.Debugf("%s: URL inputs are stored in %s\n", app, strings.Join(paths, ", "))
+	}
+
+	return res
+}
+
+// missingOutputs returns the names of the outputs of the app that do not
+// exist
+func missingOutputs(app *baur.App) []string {
+	var res []string
+
+	for _, ar := range app.Outputs {
+		if !ar.Exists() {
+			res = append(res, ar.String())
+		}
+	}
+
+	return res
 }
 
-func mustGetBuildStatus(app *baur.App, storage storage.Storer) (baur.BuildStatus, *storage.BuildWithDuration, string) {
+func mustGetBuildStatus(ctx context.Context, app *baur.App, storage storage.Storer) (baur.BuildStatus, *storage.BuildWithDuration, string) {
 	var strBuildID string
 
-	status, build, err := baur.GetBuildStatus(storage, app)
+	status, build, err := baur.GetBuildStatus(ctx, storage, app)
 	if err != nil {
 		log.Fatalf("%s: %s", app.Name, err)
 	}
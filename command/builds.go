@@ -0,0 +1,14 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var buildsCmd = &cobra.Command{
+	Use:   "builds",
+	Short: "browse recorded builds",
+}
+
+func init() {
+	rootCmd.AddCommand(buildsCmd)
+}
@@ -0,0 +1,160 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur/command/flag"
+	"github.com/simplesurance/baur/format"
+	"github.com/simplesurance/baur/log"
+)
+
+const buildsDuplicatesLongHelp = `
+List total input digests that were recorded for multiple builds of the same
+application.
+Builds with the same total input digest had the same inputs, all but the
+first of them were rerun unnecessarily. The report shows how often that
+happened per application.
+`
+
+const buildsDuplicatesExample = `
+baur builds duplicates                  list duplicate input digests of all builds
+baur builds duplicates --app calc --since=2018.09.27-11:30
+                                        list duplicate input digests of builds of
+                                        the calc application that started after
+                                        2018.09.27 11:30
+baur builds duplicates --format json    list duplicate input digests in JSON format`
+
+var buildsDuplicatesCmd = &cobra.Command{
+	Use:     "duplicates",
+	Short:   "list builds that had the same inputs",
+	Long:    strings.TrimSpace(buildsDuplicatesLongHelp),
+	Example: strings.TrimSpace(buildsDuplicatesExample),
+	Args:    cobra.NoArgs,
+	Run:     buildsDuplicates,
+}
+
+type buildsDuplicatesConf struct {
+	app    string
+	csv    bool
+	format *flag.Format
+	since  flag.DateTimeFlagValue
+}
+
+var buildsDuplicatesConfig = buildsDuplicatesConf{format: flag.NewFormatFlag()}
+
+func init() {
+	buildsDuplicatesCmd.Flags().StringVar(&buildsDuplicatesConfig.app, "app", "",
+		"Only show builds of the application with this name")
+
+	buildsDuplicatesCmd.Flags().BoolVar(&buildsDuplicatesConfig.csv, "csv", false,
+		"List builds in RFC4180 CSV format, alias for --format csv")
+
+	buildsDuplicatesCmd.Flags().Var(buildsDuplicatesConfig.format, "format",
+		buildsDuplicatesConfig.format.Usage(highlight))
+
+	buildsDuplicatesCmd.Flags().Var(&buildsDuplicatesConfig.since, "since",
+		fmt.Sprintf("Only consider builds that were started after this datetime.\nFormat: %s", highlight(flag.DateTimeFormatDescr)))
+
+	buildsCmd.AddCommand(buildsDuplicatesCmd)
+}
+
+func buildsDuplicates(cmd *cobra.Command, args []string) {
+	var appNames []string
+	var headers []string
+
+	if buildsDuplicatesConfig.csv {
+		buildsDuplicatesConfig.format.Val = flag.FormatCSV
+	}
+
+	repo := MustFindRepository()
+	clt := mustGetStorageClt(repo)
+	defer clt.Close()
+
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	if buildsDuplicatesConfig.app != "" {
+		appNames = []string{buildsDuplicatesConfig.app}
+	} else {
+		apps, err := clt.GetApps(ctx)
+		if err != nil {
+			log.Fatalln("retrieving applications from storage failed:", err)
+		}
+
+		for _, app := range apps {
+			appNames = append(appNames, app.Name)
+		}
+	}
+
+	isPlainFormat := buildsDuplicatesConfig.format.Val == flag.FormatPlain
+	keys := []string{"app", "total_input_digest", "count", "build_ids"}
+
+	if isPlainFormat {
+		headers = []string{"App", "Input Digest", "Count", "Build Ids"}
+	}
+
+	formatter := newFormatter(buildsDuplicatesConfig.format.Val, headers, keys)
+	startTs := buildsDuplicatesConfig.since.Time
+
+	var rerunCnt int
+
+	for _, appName := range appNames {
+		digests, err := clt.GetSameTotalInputDigestsForAppBuilds(ctx, appName, startTs)
+		if err != nil {
+			log.Fatalf("retrieving builds of %s with same total input digests failed: %s", appName, err)
+		}
+
+		rerunCnt += buildsDuplicatesWriteRows(formatter, appName, digests)
+	}
+
+	if err := formatter.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+
+	if isPlainFormat {
+		fmt.Printf("\n%d builds had the same inputs as a previous build\n", rerunCnt)
+	}
+}
+
+// buildsDuplicatesWriteRows writes a row per digest, sorted by the digests.
+// It returns the number of builds that had the same digest as a previous
+// build.
+func buildsDuplicatesWriteRows(formatter format.Formatter, appName string, digests map[string][]int) int {
+	var rerunCnt int
+
+	sortedDigests := make([]string, 0, len(digests))
+	for digest := range digests {
+		sortedDigests = append(sortedDigests, digest)
+	}
+	sort.Strings(sortedDigests)
+
+	for _, digest := range sortedDigests {
+		buildIDs := digests[digest]
+		sort.Ints(buildIDs)
+
+		rerunCnt += len(buildIDs) - 1
+
+		var ids interface{} = buildIDs
+		if buildsDuplicatesConfig.format.Val != flag.FormatJSON {
+			ids = intsToStr(buildIDs, ", ")
+		}
+
+		mustWriteRow(formatter, []interface{}{appName, digest, len(buildIDs), ids})
+	}
+
+	return rerunCnt
+}
+
+func intsToStr(ints []int, sep string) string {
+	strs := make([]string, 0, len(ints))
+	for _, i := range ints {
+		strs = append(strs, strconv.Itoa(i))
+	}
+
+	return strings.Join(strs, sep)
+}
@@ -0,0 +1,169 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur/command/flag"
+	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/storage"
+)
+
+const buildsLsExample = `
+baur builds ls                                  list the 20 most recent builds
+baur builds ls --app calc --sort duration:desc  list builds of the calc
+                                                application, sorted by
+                                                build duration
+baur builds ls --format json --after=2018.09.27-11:30 --limit 0
+                                                list all builds that happened
+                                                after 2018.09.27 11:30 in JSON
+                                                format`
+
+const buildsLsDefaultLimit = 20
+
+var buildsLsCmd = &cobra.Command{
+	Use:     "ls",
+	Short:   "list recorded builds",
+	Example: strings.TrimSpace(buildsLsExample),
+	Args:    cobra.NoArgs,
+	Run:     buildsLs,
+}
+
+type buildsLsConf struct {
+	app    string
+	csv    bool
+	format *flag.Format
+	after  flag.DateTimeFlagValue
+	before flag.DateTimeFlagValue
+	sort   *flag.Sort
+	limit  int
+	quiet  bool
+}
+
+var buildsLsConfig = buildsLsConf{format: flag.NewFormatFlag()}
+
+func init() {
+	buildsLsConfig.sort = flag.NewSort(map[string]storage.Field{
+		"time":     storage.FieldBuildStartTime,
+		"duration": storage.FieldBuildDuration,
+		"app":      storage.FieldApplicationName,
+		"id":       storage.FieldBuildID,
+	})
+
+	buildsLsCmd.Flags().StringVar(&buildsLsConfig.app, "app", "",
+		"Only show builds of the application with this name")
+
+	buildsLsCmd.Flags().BoolVar(&buildsLsConfig.csv, "csv", false,
+		"List builds in RFC4180 CSV format, alias for --format csv")
+
+	buildsLsCmd.Flags().Var(buildsLsConfig.format, "format",
+		buildsLsConfig.format.Usage(highlight))
+
+	buildsLsCmd.Flags().BoolVarP(&buildsLsConfig.quiet, "quiet", "q", false,
+		"Only print build IDs")
+
+	buildsLsCmd.Flags().VarP(buildsLsConfig.sort, "sort", "s",
+		buildsLsConfig.sort.Usage(highlight))
+
+	buildsLsCmd.Flags().VarP(&buildsLsConfig.after, "after", "a",
+		fmt.Sprintf("Only show builds that were build after this datetime.\nFormat: %s", highlight(flag.DateTimeFormatDescr)))
+
+	buildsLsCmd.Flags().VarP(&buildsLsConfig.before, "before", "b",
+		fmt.Sprintf("Only show builds that were build before this datetime.\nFormat: %s", highlight(flag.DateTimeFormatDescr)))
+
+	buildsLsCmd.Flags().IntVarP(&buildsLsConfig.limit, "limit", "l", buildsLsDefaultLimit,
+		"Show at most this number of builds, 0 shows all builds")
+
+	buildsCmd.AddCommand(buildsLsCmd)
+}
+
+func buildsLs(cmd *cobra.Command, args []string) {
+	var sorters []*storage.Sorter
+
+	if buildsLsConfig.csv {
+		buildsLsConfig.format.Val = flag.FormatCSV
+	}
+
+	if buildsLsConfig.limit < 0 {
+		log.Fatalln("--limit must be >=0")
+	}
+
+	repo := MustFindRepository()
+	clt := mustGetStorageClt(repo)
+
+	if buildsLsConfig.sort.Value != (storage.Sorter{}) {
+		sorters = append(sorters, &buildsLsConfig.sort.Value)
+	}
+
+	// the default sorter is always appended, it ensures that the most
+	// recent builds are returned when the result is limited
+	sorters = append(sorters, &storage.Sorter{
+		Field: storage.FieldBuildStartTime,
+		Order: storage.OrderDesc,
+	})
+
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	builds, err := clt.GetBuildsWithoutInputsOutputs(ctx, buildsLsConfig.getFilters(), sorters, buildsLsConfig.limit)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	buildsLsPrint(builds)
+}
+
+func buildsLsPrint(builds []*storage.BuildWithDuration) {
+	var headers, keys []string
+
+	isPlainFormat := buildsLsConfig.format.Val == flag.FormatPlain
+
+	if buildsLsConfig.quiet {
+		keys = []string{"id"}
+	} else {
+		keys = []string{"id", "app", "start_time", "stop_time", "total_input_digest"}
+
+		if isPlainFormat {
+			headers = []string{"Id", "App", "Start Time", "Stop Time", "Input Digest"}
+		}
+	}
+
+	formatter := newFormatter(buildsLsConfig.format.Val, headers, keys)
+
+	for _, build := range builds {
+		if buildsLsConfig.quiet {
+			mustWriteRow(formatter, []interface{}{build.ID})
+			continue
+		}
+
+		mustWriteRow(formatter, []interface{}{
+			build.ID,
+			build.Application.Name,
+			build.StartTimeStamp.Format(flag.DateTimeFormatTz),
+			build.StopTimeStamp.Format(flag.DateTimeFormatTz),
+			build.TotalInputDigest,
+		})
+	}
+
+	if err := formatter.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func (conf *buildsLsConf) getFilters() (filters []*storage.Filter) {
+	if conf.app != "" {
+		filters = append(filters, storage.FilterByApp(conf.app))
+	}
+
+	if conf.before != (flag.DateTimeFlagValue{}) {
+		filters = append(filters, storage.FilterStartedBefore(conf.before.Time))
+	}
+
+	if conf.after != (flag.DateTimeFlagValue{}) {
+		filters = append(filters, storage.FilterStartedAfter(conf.after.Time))
+	}
+
+	return
+}
@@ -0,0 +1,155 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur"
+	"github.com/simplesurance/baur/fs"
+	"github.com/simplesurance/baur/log"
+)
+
+const cleanLongHelp = `
+Delete local build outputs of applications.
+
+The files that are deleted are the paths of File outputs, the destinations
+of FileCopy uploads and the docker image ID files of the applications.
+Only the configured files are deleted, directories are never removed.
+Paths that are outside of the repository directory are skipped.
+FileCopy destinations containing the $UUID variable are not deleted, their
+paths differ on every run.
+
+If no application is passed, the outputs of all applications are deleted.
+Before files are deleted the command asks for confirmation.
+`
+
+const cleanExamples = `
+baur clean			delete the local outputs of all applications
+baur clean --dry-run calc	show which local outputs of the calc application
+				would be deleted
+baur clean -y ui/shop		delete the local outputs of the application in
+				the ui/shop directory without confirmation
+`
+
+var cleanCmd = &cobra.Command{
+	Use:     "clean [<APP-NAME>|<PATH>|<APP-NAME>.<TASK>]...",
+	Short:   "delete local build outputs",
+	Long:    strings.TrimSpace(cleanLongHelp),
+	Example: strings.TrimSpace(cleanExamples),
+	Run:     clean,
+}
+
+type cleanConf struct {
+	dryRun bool
+	yes    bool
+}
+
+var cleanConfig cleanConf
+
+func init() {
+	cleanCmd.Flags().BoolVar(&cleanConfig.dryRun, "dry-run", false,
+		"only print the files that would be deleted")
+	cleanCmd.Flags().BoolVarP(&cleanConfig.yes, "yes", "y", false,
+		"delete the files without asking for confirmation")
+
+	rootCmd.AddCommand(cleanCmd)
+}
+
+func clean(cmd *cobra.Command, args []string) {
+	repo := MustFindRepository()
+	apps := statusArgsToApps(repo, args)
+
+	baur.SortAppsByName(apps)
+
+	var paths []string
+	for _, app := range apps {
+		paths = append(paths, cleanablePaths(repo, app)...)
+	}
+
+	if len(paths) == 0 {
+		fmt.Println("no local outputs exist")
+		return
+	}
+
+	for _, p := range paths {
+		fmt.Println(p)
+	}
+
+	if cleanConfig.dryRun {
+		return
+	}
+
+	if !cleanConfig.yes && !confirm(fmt.Sprintf("Delete %d files?", len(paths))) {
+		fmt.Println("aborted")
+		return
+	}
+
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			log.Fatalln(err)
+		}
+	}
+
+	fmt.Printf("deleted %s files\n", highlight(len(paths)))
+}
+
+// cleanablePaths returns the local output paths of app that exist, are not
+// directories and are located in the repository directory.
+func cleanablePaths(repo *baur.Repository, app *baur.App) []string {
+	var res []string
+
+	for _, p := range app.LocalOutputPaths() {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		inRepo, err := fs.IsInDir(abs, repo.Path)
+		if err != nil {
+			log.Fatalf("%s: %s\n", app, err)
+		}
+
+		if !inRepo {
+			log.Warnf("%s: skipping %s, it is outside of the repository directory\n", app, abs)
+			continue
+		}
+
+		fi, err := os.Lstat(abs)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			log.Fatalln(err)
+		}
+
+		if fi.IsDir() {
+			log.Warnf("%s: skipping %s, it is a directory\n", app, abs)
+			continue
+		}
+
+		res = append(res, abs)
+	}
+
+	return res
+}
+
+// confirm prints question and reads the answer from stdin, it returns true if
+// the answer is "y" or "yes".
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
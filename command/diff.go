@@ -0,0 +1,114 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur"
+	"github.com/simplesurance/baur/format/table"
+	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/storage"
+)
+
+const diffLongHelp = `
+Show the differences between the current inputs of an application and the
+inputs of its last recorded build.
+
+Inputs are compared by their paths and digests, added, removed and changed
+inputs are listed.
+`
+
+const diffExample = `
+baur diff calc		show the changed inputs of the calc application
+baur diff calc.build	same as above, in task specifier format
+`
+
+var diffCmd = &cobra.Command{
+	Use:     "diff <APP-NAME>|<PATH>|<APP-NAME>.<TASK>",
+	Short:   "show changed inputs since the last recorded build",
+	Long:    strings.TrimSpace(diffLongHelp),
+	Example: strings.TrimSpace(diffExample),
+	Run:     diff,
+	Args:    cobra.ExactArgs(1),
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+func mustGetLatestBuild(ctx context.Context, clt storage.Client, app *baur.App) *storage.BuildWithDuration {
+	filters := []*storage.Filter{
+		{
+			Field:    storage.FieldApplicationName,
+			Operator: storage.OpEQ,
+			Value:    app.Name,
+		},
+	}
+
+	sorters := []*storage.Sorter{
+		{
+			Field: storage.FieldBuildStartTime,
+			Order: storage.OrderDesc,
+		},
+	}
+
+	builds, err := clt.GetBuildsWithoutInputsOutputs(ctx, filters, sorters, 1)
+	if err != nil {
+		log.Fatalf("%s: fetching builds failed: %s", app, err)
+	}
+
+	if len(builds) == 0 {
+		log.Fatalf("%s: no build has been recorded for the application", app)
+	}
+
+	return builds[0]
+}
+
+func diff(cmd *cobra.Command, args []string) {
+	appArg := taskSpecToAppArg(args[0])
+	if appArg == "" {
+		log.Fatalln("the argument must specify a single application")
+	}
+
+	repo := MustFindRepository()
+	app := mustArgToApp(repo, appArg)
+	storageClt := mustGetStorageClt(repo)
+
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	build := mustGetLatestBuild(ctx, storageClt, app)
+
+	inputs, totalInputDigest := calcDigests(app)
+	if totalInputDigest == build.TotalInputDigest {
+		fmt.Println("no changes")
+		return
+	}
+
+	recordedInputs, err := storageClt.GetBuildInputs(ctx, build.ID)
+	if err != nil {
+		log.Fatalf("%s: fetching inputs of build %d failed: %s", app, build.ID, err)
+	}
+
+	diffs := baur.DiffInputs(inputs, recordedInputs)
+	if len(diffs) == 0 {
+		fmt.Printf("inputs are the same as in build %d but the total input digest differs\n", build.ID)
+		return
+	}
+
+	fmt.Printf("Comparing inputs with build %d:\n\n", build.ID)
+
+	formatter := table.New([]string{"Change", "Input"}, os.Stdout)
+
+	for _, d := range diffs {
+		mustWriteRow(formatter, []interface{}{d.Change, highlight(d.URI)})
+	}
+
+	if err := formatter.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+}
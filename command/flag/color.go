@@ -0,0 +1,69 @@
+package flag
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/simplesurance/baur/term"
+)
+
+// Color is a commandline flag to specify when output is colored
+type Color struct {
+	Val term.ColorMode
+}
+
+// NewColorFlag returns a Color flag with the default value term.ColorModeAuto
+func NewColorFlag() *Color {
+	return &Color{Val: term.ColorModeAuto}
+}
+
+// String returns the default value in the usage output
+func (c *Color) String() string {
+	return string(c.Val)
+}
+
+// Set parses the passed string and sets the Color
+func (c *Color) Set(val string) error {
+	mode := term.ColorMode(strings.ToLower(val))
+
+	for _, m := range term.ColorModes {
+		if mode == m {
+			c.Val = mode
+			return nil
+		}
+	}
+
+	return errors.New("color must be one of " + c.choices())
+}
+
+func (c *Color) choices() string {
+	res := make([]string, 0, len(term.ColorModes))
+	for _, m := range term.ColorModes {
+		res = append(res, string(m))
+	}
+
+	return strings.Join(res, ", ")
+}
+
+// Type returns the format description
+func (c *Color) Type() string {
+	return "<WHEN>"
+}
+
+// Usage returns a usage description, important parts are passed through
+// highlightFn
+func (c *Color) Usage(highlightFn func(a ...interface{}) string) string {
+	return strings.TrimSpace(fmt.Sprintf(`
+When to color output
+Format: %s
+where %s is one of: %s, %s, %s
+%s colors output if stdout is a terminal and NO_COLOR is not set`,
+		highlightFn(c.Type()),
+		highlightFn("WHEN"),
+		highlightFn(term.ColorModeAuto),
+		highlightFn(term.ColorModeAlways),
+		highlightFn(term.ColorModeNever),
+		highlightFn(term.ColorModeAuto),
+	))
+}
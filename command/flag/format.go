@@ -0,0 +1,61 @@
+package flag
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Supported output formats
+const (
+	FormatPlain = "plain"
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+)
+
+// Format is a commandline flag to specify the output format
+type Format struct {
+	Val string
+}
+
+// NewFormatFlag returns a Format flag with the default value FormatPlain
+func NewFormatFlag() *Format {
+	return &Format{Val: FormatPlain}
+}
+
+// String returns the default value in the usage output
+func (f *Format) String() string {
+	return f.Val
+}
+
+// Set parses the passed string and sets the Format
+func (f *Format) Set(val string) error {
+	switch v := strings.ToLower(val); v {
+	case FormatPlain, FormatCSV, FormatJSON:
+		f.Val = v
+	default:
+		return errors.New("format must be one of " + strings.Join([]string{FormatPlain, FormatCSV, FormatJSON}, ", "))
+	}
+
+	return nil
+}
+
+// Type returns the format description
+func (f *Format) Type() string {
+	return "<FORMAT>"
+}
+
+// Usage returns a usage description, important parts are passed through
+// highlightFn
+func (f *Format) Usage(highlightFn func(a ...interface{}) string) string {
+	return strings.TrimSpace(fmt.Sprintf(`
+Output format
+Format: %s
+where %s is one of: %s, %s, %s`,
+		highlightFn(f.Type()),
+		highlightFn("FORMAT"),
+		highlightFn(FormatPlain),
+		highlightFn(FormatCSV),
+		highlightFn(FormatJSON),
+	))
+}
@@ -8,6 +8,13 @@ import (
 	"github.com/simplesurance/baur/storage"
 )
 
+// sortSeparator separates the field and the order in a Sort flag value,
+// sortSeparatorAlt is accepted as alternative
+const (
+	sortSeparator    = '-'
+	sortSeparatorAlt = ':'
+)
+
 // Sort is a commandline flag to specify via which field and in which order
 // output should be sorted
 type Sort struct {
@@ -33,7 +40,9 @@ func (s *Sort) String() string {
 func (s *Sort) Set(sortStr string) error {
 	var err error
 
-	pieces := strings.Split(sortStr, "-")
+	pieces := strings.FieldsFunc(sortStr, func(r rune) bool {
+		return r == sortSeparator || r == sortSeparatorAlt
+	})
 	if len(pieces) != 2 {
 		return fmt.Errorf("format must be %s", s.Type())
 	}
@@ -48,8 +57,12 @@ func (s *Sort) Set(sortStr string) error {
 		return err
 	}
 
-	s.Value.Field = field
-	s.Value.Order = order
+	sorter, err := storage.SortBy(field, order)
+	if err != nil {
+		return err
+	}
+
+	s.Value = *sorter
 
 	return nil
 }
@@ -1,18 +1,23 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
 	"time"
 
-	"github.com/fatih/color"
-
 	"github.com/simplesurance/baur"
+	"github.com/simplesurance/baur/command/flag"
 	"github.com/simplesurance/baur/format"
+	"github.com/simplesurance/baur/format/csv"
+	"github.com/simplesurance/baur/format/json"
+	"github.com/simplesurance/baur/format/table"
+	"github.com/simplesurance/baur/fs"
 	"github.com/simplesurance/baur/log"
 	"github.com/simplesurance/baur/storage"
 	"github.com/simplesurance/baur/storage/postgres"
+	"github.com/simplesurance/baur/term"
 )
 
 // envVarPSQLURL contains the name of an environment variable in that the
@@ -20,12 +25,15 @@ import (
 const envVarPSQLURL = "BAUR_POSTGRESQL_URL"
 
 var (
-	greenHighlight  = color.New(color.FgGreen).SprintFunc()
-	redHighlight    = color.New(color.FgRed).SprintFunc()
-	yellowHighlight = color.New(color.FgYellow).SprintFunc()
-	underline       = color.New(color.Underline).SprintFunc()
+	greenHighlight  = term.GreenHighlight
+	redHighlight    = term.RedHighlight
+	yellowHighlight = term.YellowHighlight
+	underline       = term.Underline
 	// highlight is a function that highlights parts of strings in the cli output
-	highlight = greenHighlight
+	highlight = term.Highlight
+	// coloredBuildStatus returns the colored string representation of a
+	// build status
+	coloredBuildStatus = term.ColoredBuildStatus
 )
 
 func findRepository() (*baur.Repository, error) {
@@ -38,6 +46,10 @@ func findRepository() (*baur.Repository, error) {
 
 	log.Debugf("repository root found: %s", repo.Path)
 
+	if noCacheFlag {
+		repo.GoSourceCacheDir = ""
+	}
+
 	return repo, nil
 }
 
@@ -45,10 +57,10 @@ func findRepository() (*baur.Repository, error) {
 func MustFindRepository() *baur.Repository {
 	repo, err := findRepository()
 	if err != nil {
-		if os.IsNotExist(err) {
-			log.Fatalf("could not find repository root config file "+
-				"ensure the file '%s' exist in the root",
-				baur.RepositoryCfgFile)
+		if baur.IsRepositoryNotFound(err) {
+			log.Fatalf("%s\n"+
+				"Run '%s' in the root directory of your repository to create it.\n",
+				err, highlight(cmdInitRepo))
 		}
 
 		log.Fatalln(err)
@@ -68,6 +80,10 @@ func mustArgToApp(repo *baur.Repository, arg string) *baur.App {
 	if isAppDir(arg) {
 		app, err := repo.AppByDir(arg)
 		if err != nil {
+			if os.IsNotExist(err) {
+				log.Fatalf("application in dir '%s' is not in an application_dirs directory of the repository", arg)
+			}
+
 			log.Fatalf("could not find application in dir '%s': %s", arg, err)
 		}
 
@@ -85,22 +101,29 @@ func mustArgToApp(repo *baur.Repository, arg string) *baur.App {
 	return app
 }
 
-// getPostgresCltWithEnv returns a new postresql storage client,
-// if the environment variable BAUR_PSQL_URI is set, this uri is used instead of
-// the configuration specified in the baur.Repository object
-func getPostgresCltWithEnv(psqlURI string) (*postgres.Client, error) {
-	uri := psqlURI
-
+// storageURIWithEnv returns the value of the environment variable
+// BAUR_PSQL_URI if it is set, otherwise uri is returned
+func storageURIWithEnv(uri string) string {
 	if envURI := os.Getenv(envVarPSQLURL); len(envURI) != 0 {
 		log.Debugf("using postgresql connection URL from $%s environment variable",
 			envVarPSQLURL)
 
-		uri = envURI
-	} else {
-		log.Debugf("environment variable $%s not set", envVarPSQLURL)
+		return envURI
 	}
 
-	return postgres.New(uri)
+	log.Debugf("environment variable $%s not set", envVarPSQLURL)
+
+	return uri
+}
+
+// repoStorageURI returns the database URI from the environment or the
+// repository config, with the TLS files from the repository config added
+func repoStorageURI(r *baur.Repository) (string, error) {
+	return postgres.ConnectionString(storageURIWithEnv(r.PSQLURL), postgres.TLSFiles{
+		RootCert: r.PSQLSSLRootCert,
+		Cert:     r.PSQLSSLCert,
+		Key:      r.PSQLSSLKey,
+	})
 }
 
 //mustHavePSQLURI calls log.Fatalf if neither envVarPSQLURL nor the postgres_url
@@ -117,18 +140,70 @@ func mustHavePSQLURI(r *baur.Repository) {
 	}
 }
 
-// MustGetPostgresClt must return the PG client
-func MustGetPostgresClt(r *baur.Repository) *postgres.Client {
+// mustGetStorageClt returns a storage client for the database URI in the
+// repository config or the environment
+func mustGetStorageClt(r *baur.Repository) storage.Storer {
+	return mustGetStorageCltForJobs(r, 1)
+}
+
+// postgresPoolConfig returns the connection pool configuration for jobs
+// parallel operations, values that are set in the repository config
+// replace the defaults
+func postgresPoolConfig(r *baur.Repository, jobs int) postgres.PoolConfig {
+	cfg := postgres.DefaultPoolConfig(jobs)
+
+	if r.PSQLMaxOpenConns > 0 {
+		cfg.MaxOpenConns = r.PSQLMaxOpenConns
+	}
+
+	if r.PSQLMaxIdleConns > 0 {
+		cfg.MaxIdleConns = r.PSQLMaxIdleConns
+	}
+
+	if r.PSQLConnMaxLifetime > 0 {
+		cfg.ConnMaxLifetime = r.PSQLConnMaxLifetime
+	}
+
+	return cfg
+}
+
+// mustGetStorageCltForJobs returns a storage client like mustGetStorageClt,
+// the connection pool of a postgres client is sized for jobs parallel
+// operations
+func mustGetStorageCltForJobs(r *baur.Repository, jobs int) storage.Storer {
 	mustHavePSQLURI(r)
 
-	clt, err := getPostgresCltWithEnv(r.PSQLURL)
+	uri, err := repoStorageURI(r)
 	if err != nil {
-		log.Fatalf("could not establish connection to postgreSQL db: %s", err)
+		log.Fatalln(err)
+	}
+
+	clt, err := storage.NewClient(uri)
+	if err != nil {
+		if _, ok := err.(*storage.SchemaVersionError); ok {
+			log.Fatalln(err)
+		}
+
+		log.Fatalf("could not establish connection to the database: %s", err)
+	}
+
+	if pgClt, ok := clt.(*postgres.Client); ok {
+		pgClt.WithPoolConfig(postgresPoolConfig(r, jobs))
 	}
 
 	return clt
 }
 
+// newStorageCtx returns a context for storage operations. If storageTimeout
+// is not 0, the context expires after it.
+func newStorageCtx() (context.Context, context.CancelFunc) {
+	if storageTimeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), storageTimeout)
+}
+
 func mustGetCommitID(r *baur.Repository) string {
 	commitID, err := r.GitCommitID()
 	if err != nil {
@@ -192,6 +267,42 @@ func mustArgToApps(repo *baur.Repository, args []string) []*baur.App {
 	return apps
 }
 
+// newFormatter returns a Formatter for the passed output format.
+// headers is written as first row for the plain and csv format if it's not
+// empty, keys are the field names of the JSON objects.
+func newFormatter(outputFormat string, headers, keys []string) format.Formatter {
+	switch outputFormat {
+	case flag.FormatCSV:
+		return csv.New(headers, os.Stdout)
+	case flag.FormatJSON:
+		return json.New(keys, os.Stdout)
+	default:
+		return table.New(headers, os.Stdout)
+	}
+}
+
+// newTruncatingFormatter returns a Formatter like newFormatter. Values in the
+// plain output format are truncated to maxColumnWidth characters, 0 disables
+// truncation.
+func newTruncatingFormatter(outputFormat string, headers, keys []string, maxColumnWidth int) format.Formatter {
+	if outputFormat == flag.FormatPlain {
+		return table.New(headers, os.Stdout).WithMaxColumnWidth(maxColumnWidth)
+	}
+
+	return newFormatter(outputFormat, headers, keys)
+}
+
+// displayPath returns how path is shown in the output of commands. Paths in
+// the repository are shown relative to the repository root, other paths
+// and all paths if absPath is true are shown as absolute paths.
+func displayPath(repo *baur.Repository, path string, absPath bool) string {
+	if absPath {
+		return path
+	}
+
+	return fs.RelPathOrAbs(repo.Path, path)
+}
+
 func mustWriteRow(fmt format.Formatter, row []interface{}) {
 	err := fmt.WriteRow(row)
 	if err != nil {
@@ -199,21 +310,6 @@ func mustWriteRow(fmt format.Formatter, row []interface{}) {
 	}
 }
 
-func coloredBuildStatus(status baur.BuildStatus) string {
-	switch status {
-	case baur.BuildStatusInputsUndefined:
-		return yellowHighlight(status.String())
-	case baur.BuildStatusBuildCommandUndefined:
-		return yellowHighlight(status.String())
-	case baur.BuildStatusExist:
-		return greenHighlight(status.String())
-	case baur.BuildStatusPending:
-		return redHighlight(status.String())
-	default:
-		return status.String()
-	}
-}
-
 func bytesToMib(bytes int) string {
 	return fmt.Sprintf("%.3f", float64(bytes)/1024/1024)
 }
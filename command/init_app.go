@@ -3,7 +3,7 @@ package command
 import (
 	"fmt"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -13,16 +13,31 @@ import (
 	"github.com/simplesurance/baur/log"
 )
 
+type initAppConf struct {
+	dir   string
+	force bool
+}
+
+var initAppConfig initAppConf
+
 func init() {
+	initAppCmd.Flags().StringVar(&initAppConfig.dir, "dir", "",
+		"directory in that the application config is created (default: current directory)")
+	initAppCmd.Flags().BoolVarP(&initAppConfig.force, "force", "f", false,
+		"overwrite an existing application config file")
+
 	initCmd.AddCommand(initAppCmd)
 }
 
 const initAppLongHelp = `
-Create an application config file in the current directory.
-If no name is passed, the application name will be the name of the current directory.`
+Create an application config file in the current directory or in the directory
+passed with --dir.
+If no name is passed, the application name will be the name of the directory.`
 
 const initAppExample = `
-baur init app shop-ui	create an application config with the app name set to shop-ui`
+baur init app shop-ui			create an application config with the app name set to shop-ui
+baur init app --dir services/payment	create an application config in services/payment with
+					the app name set to payment`
 
 var initAppCmd = &cobra.Command{
 	Use:     "app [APP-NAME]",
@@ -37,7 +52,7 @@ func initApp(cmd *cobra.Command, args []string) {
 	var appName string
 	MustFindRepository()
 
-	cwd, err := os.Getwd()
+	appDir, err := filepath.Abs(initAppConfig.dir)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -45,20 +60,30 @@ func initApp(cmd *cobra.Command, args []string) {
 	if len(args) > 0 {
 		appName = args[0]
 	} else {
-		appName = path.Base(cwd)
+		appName = filepath.Base(appDir)
 	}
 
 	appCfg := cfg.ExampleApp(appName)
+	appCfgPath := filepath.Join(appDir, baur.AppCfgFile)
 
-	err = appCfg.ToFile(path.Join(cwd, baur.AppCfgFile))
+	err = appCfg.ToFile(appCfgPath, initAppConfig.force)
 	if err != nil {
 		if os.IsExist(err) {
-			log.Fatalf("%s already exist\n", baur.AppCfgFile)
+			log.Fatalf("%s already exist, pass --force to overwrite it\n", appCfgPath)
 		}
 
 		log.Fatalln(err)
 	}
 
+	writtenCfg, err := cfg.AppFromFile(appCfgPath)
+	if err != nil {
+		log.Fatalf("reading written application config %s failed: %s\n", appCfgPath, err)
+	}
+
+	if err := writtenCfg.Validate(); err != nil {
+		log.Fatalf("written application config %s is invalid: %s\n", appCfgPath, err)
+	}
+
 	fmt.Printf("Application configuration file was written to %s\n",
-		highlight(baur.AppCfgFile))
+		highlight(appCfgPath))
 }
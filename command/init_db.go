@@ -2,21 +2,27 @@ package command
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/simplesurance/baur"
 	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/storage"
 )
 
 const initDbExample = `
 baur init db postgres://postgres@localhost:5432/baur?sslmode=disable
+baur init db --migrate
 `
 
 var initDbLongHelp = fmt.Sprintf(`
 Creates the baur tables in a PostgreSQL database.
+The storage backend is selected by the scheme of the URL.
+
+If the tables already exist, the command fails unless '--migrate' is passed.
+With '--migrate' the tables are updated to the schema of the baur version,
+pending migrations are applied, data is preserved.
 
 The Postgres URL is read from the repository configuration file.
 Alternatively the URL can be passed as argument or
@@ -32,7 +38,11 @@ var initDbCmd = &cobra.Command{
 	Args:    cobra.MaximumNArgs(1),
 }
 
+var initDbMigrate bool
+
 func init() {
+	initDbCmd.Flags().BoolVar(&initDbMigrate, "migrate", false,
+		"update the tables of an already initialized database to the current schema")
 	initCmd.AddCommand(initDbCmd)
 }
 
@@ -42,7 +52,7 @@ func initDb(cmd *cobra.Command, args []string) {
 	if len(args) == 0 {
 		repo, err := findRepository()
 		if err != nil {
-			if os.IsNotExist(err) {
+			if baur.IsRepositoryNotFound(err) {
 				log.Fatalf("could not find '%s' repository config file.\n"+
 					"Run '%s' first or pass the Postgres URL as argument.",
 					highlight(baur.RepositoryCfgFile), highlight(cmdInitRepo))
@@ -50,20 +60,59 @@ func initDb(cmd *cobra.Command, args []string) {
 			log.Fatalln(err)
 		}
 
-		dbURL = repo.PSQLURL
+		dbURL, err = repoStorageURI(repo)
+		if err != nil {
+			log.Fatalln(err)
+		}
 	} else {
-		dbURL = args[0]
+		dbURL = storageURIWithEnv(args[0])
 	}
 
-	storageClt, err := getPostgresCltWithEnv(dbURL)
+	storageClt, err := storage.NewUncheckedClient(dbURL)
 	if err != nil {
 		log.Fatalln("establishing connection failed:", err.Error())
 	}
 
-	err = storageClt.Init()
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	migrator, isMigrator := storageClt.(storage.Migrator)
+	if !isMigrator {
+		if initDbMigrate {
+			log.Fatalln("the storage backend does not support migrations")
+		}
+
+		err = storageClt.Init(ctx)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		fmt.Println("database tables created successfully")
+		return
+	}
+
+	prevVersion, err := migrator.SchemaVersion(ctx)
+	if err != nil {
+		log.Fatalln("querying schema version failed:", err)
+	}
+
+	if prevVersion != 0 && !initDbMigrate {
+		log.Fatalf("database is already initialized (schema version %d), "+
+			"pass '%s' to apply pending migrations", prevVersion, highlight("--migrate"))
+	}
+
+	err = storageClt.Init(ctx)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	fmt.Println("database tables created successfully")
+	switch {
+	case prevVersion == 0:
+		fmt.Println("database tables created successfully")
+	case prevVersion == migrator.LatestSchemaVersion():
+		fmt.Printf("database schema is up to date (version %d)\n", prevVersion)
+	default:
+		fmt.Printf("database schema migrated from version %d to %d\n",
+			prevVersion, migrator.LatestSchemaVersion())
+	}
 }
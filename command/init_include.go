@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -11,15 +12,28 @@ import (
 	"github.com/simplesurance/baur/log"
 )
 
+type initIncludeConf struct {
+	dir string
+}
+
+var initIncludeConfig initIncludeConf
+
 func init() {
+	initIncludeCmd.Flags().StringVar(&initIncludeConfig.dir, "dir", "",
+		"directory in that the include config is created (default: current directory)")
+
 	initCmd.AddCommand(initIncludeCmd)
 }
 
-const defIncludeFilename = "includes.toml"
+const (
+	defIncludeFilename = "includes.toml"
+	includeFileExt     = ".toml"
+)
 
 const initIncludeLongHelp = `
 Create an include config file.
-If no FILENAME argument is passed, the filename will be '` + defIncludeFilename + `'.`
+If no FILENAME argument is passed, the filename will be '` + defIncludeFilename + `'.
+The '` + includeFileExt + `' extension is appended to FILENAME if it is missing.`
 
 var initIncludeCmd = &cobra.Command{
 	Use:   "include [<FILENAME>]",
@@ -38,8 +52,14 @@ func initInclude(cmd *cobra.Command, args []string) {
 		filename = defIncludeFilename
 	}
 
-	cfg := cfg.ExampleInclude()
-	err := cfg.IncludeToFile(filename)
+	if filepath.Ext(filename) != includeFileExt {
+		filename += includeFileExt
+	}
+
+	filename = filepath.Join(initIncludeConfig.dir, filename)
+
+	includeCfg := cfg.ExampleInclude()
+	err := includeCfg.IncludeToFile(filename)
 	if err != nil {
 		if os.IsExist(err) {
 			log.Fatalf("%s already exist\n", filename)
@@ -48,6 +68,15 @@ func initInclude(cmd *cobra.Command, args []string) {
 		log.Fatalln(err)
 	}
 
+	writtenCfg, err := cfg.IncludeFromFile(filename)
+	if err != nil {
+		log.Fatalf("reading written include config %s failed: %s\n", filename, err)
+	}
+
+	if err := writtenCfg.Validate(); err != nil {
+		log.Fatalf("written include config %s is invalid: %s\n", filename, err)
+	}
+
 	fmt.Printf("Include configuration file was written to %s\n",
 		highlight(filename))
 }
@@ -2,15 +2,11 @@ package command
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/simplesurance/baur"
 	"github.com/simplesurance/baur/command/flag"
-	"github.com/simplesurance/baur/format"
-	"github.com/simplesurance/baur/format/csv"
-	"github.com/simplesurance/baur/format/table"
 	"github.com/simplesurance/baur/log"
 	"github.com/simplesurance/baur/storage"
 )
@@ -28,12 +24,23 @@ const (
 	lsAppGitCommitParam    = "git-commit"
 )
 
+// lsAppJSONKeys maps field parameters to the keys used in JSON output
+var lsAppJSONKeys = map[string]string{
+	lsAppNameParam:        "name",
+	lsAppPathParam:        "directory",
+	lsAppBuildStatusParam: "build_status",
+	lsAppBuildIDParam:     "build_id",
+	lsAppGitCommitParam:   "git_commit",
+}
+
 type lsAppsConf struct {
 	csv         bool
+	format      *flag.Format
 	quiet       bool
 	absPaths    bool
 	buildStatus flag.BuildStatus
 	fields      *flag.Fields
+	maxColWidth int
 }
 
 var lsAppsCmd = &cobra.Command{
@@ -43,11 +50,14 @@ var lsAppsCmd = &cobra.Command{
 	Args:  cobra.ArbitraryArgs,
 }
 
-var lsAppsConfig lsAppsConf
+var lsAppsConfig = lsAppsConf{format: flag.NewFormatFlag()}
 
 func init() {
 	lsAppsCmd.Flags().BoolVar(&lsAppsConfig.csv, "csv", false,
-		"List applications in RFC4180 CSV format")
+		"List applications in RFC4180 CSV format, alias for --format csv")
+
+	lsAppsCmd.Flags().Var(lsAppsConfig.format, "format",
+		lsAppsConfig.format.Usage(highlight))
 
 	lsAppsCmd.Flags().BoolVarP(&lsAppsConfig.quiet, "quiet", "q", false,
 		"Suppress printing a header and progress dots")
@@ -55,6 +65,9 @@ func init() {
 	lsAppsCmd.Flags().BoolVar(&lsAppsConfig.absPaths, "abs-path", false,
 		"Show absolute instead of relative paths")
 
+	lsAppsCmd.Flags().IntVar(&lsAppsConfig.maxColWidth, "max-col-width", 0,
+		"Truncate values in the plain format to this number of characters, 0 disables truncation")
+
 	lsAppsCmd.Flags().VarP(&lsAppsConfig.buildStatus, "build-status", "s",
 		lsAppsConfig.buildStatus.Usage(highlight))
 
@@ -95,31 +108,44 @@ func createHeader() []string {
 	return headers
 }
 
+func createJSONKeys() []string {
+	keys := make([]string, 0, len(lsAppsConfig.fields.Fields))
+
+	for _, f := range lsAppsConfig.fields.Fields {
+		keys = append(keys, lsAppJSONKeys[f])
+	}
+
+	return keys
+}
+
 func ls(cmd *cobra.Command, args []string) {
 	var headers []string
-	var formatter format.Formatter
 	var storageClt storage.Storer
 
+	if lsAppsConfig.csv {
+		lsAppsConfig.format.Val = flag.FormatCSV
+	}
+
 	repo := MustFindRepository()
 	apps := mustArgToApps(repo, args)
-	writeHeaders := !lsAppsConfig.quiet && !lsAppsConfig.csv
+	isPlainFormat := lsAppsConfig.format.Val == flag.FormatPlain
+	writeHeaders := !lsAppsConfig.quiet && isPlainFormat
 	storageQueryNeeded := storageQueryIsNeeded()
 
 	if storageQueryNeeded {
-		storageClt = MustGetPostgresClt(repo)
+		storageClt = mustGetStorageClt(repo)
 	}
 
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
 	if writeHeaders {
 		headers = createHeader()
 	}
 
-	if lsAppsConfig.csv {
-		formatter = csv.New(headers, os.Stdout)
-	} else {
-		formatter = table.New(headers, os.Stdout)
-	}
+	formatter := newTruncatingFormatter(lsAppsConfig.format.Val, headers, createJSONKeys(), lsAppsConfig.maxColWidth)
 
-	showProgress := len(apps) >= 5 && !lsAppsConfig.quiet && !lsAppsConfig.csv
+	showProgress := len(apps) >= 5 && !lsAppsConfig.quiet && isPlainFormat
 
 	baur.SortAppsByName(apps)
 
@@ -131,7 +157,7 @@ func ls(cmd *cobra.Command, args []string) {
 		if storageQueryNeeded {
 			var err error
 
-			buildStatus, build, err = baur.GetBuildStatus(storageClt, app)
+			buildStatus, build, err = baur.GetBuildStatus(ctx, storageClt, app)
 			if err != nil {
 				log.Fatalf("gathering informations for %s failed: %s", app, err)
 			}
@@ -188,11 +214,7 @@ func assembleRow(app *baur.App, build *storage.BuildWithDuration, buildStatus ba
 			row = append(row, app.Name)
 
 		case lsAppPathParam:
-			if lsAppsConfig.absPaths {
-				row = append(row, app.Path)
-			} else {
-				row = append(row, app.RelPath)
-			}
+			row = append(row, displayPath(app.Repository, app.Path, lsAppsConfig.absPaths))
 
 		case lsAppBuildStatusParam:
 			row = append(row, buildStatus)
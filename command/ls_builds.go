@@ -78,7 +78,7 @@ func runBuildLs(cmd *cobra.Command, args []string) {
 	lsBuildsConfig.app = args[0]
 
 	repo := MustFindRepository()
-	psql := MustGetPostgresClt(repo)
+	psql := mustGetStorageClt(repo)
 
 	filters := lsBuildsConfig.getFilters()
 	if lsBuildsConfig.sort.Value != (storage.Sorter{}) {
@@ -87,7 +87,10 @@ func runBuildLs(cmd *cobra.Command, args []string) {
 
 	sorters = append(sorters, &defaultSorter)
 
-	builds, err := psql.GetBuildsWithoutInputsOutputs(filters, sorters)
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	builds, err := psql.GetBuildsWithoutInputsOutputs(ctx, filters, sorters, 0)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -148,27 +151,15 @@ func printBuilds(repo *baur.Repository, builds []*storage.BuildWithDuration) {
 
 func (conf lsBuildsConf) getFilters() (filters []*storage.Filter) {
 	if conf.app != "all" {
-		filters = append(filters, &storage.Filter{
-			Field:    storage.FieldApplicationName,
-			Operator: storage.OpEQ,
-			Value:    conf.app,
-		})
+		filters = append(filters, storage.FilterByApp(conf.app))
 	}
 
 	if conf.before != (flag.DateTimeFlagValue{}) {
-		filters = append(filters, &storage.Filter{
-			Field:    storage.FieldBuildStartTime,
-			Operator: storage.OpLT,
-			Value:    conf.before.Time,
-		})
+		filters = append(filters, storage.FilterStartedBefore(conf.before.Time))
 	}
 
 	if conf.after != (flag.DateTimeFlagValue{}) {
-		filters = append(filters, &storage.Filter{
-			Field:    storage.FieldBuildStartTime,
-			Operator: storage.OpGT,
-			Value:    conf.after.Time,
-		})
+		filters = append(filters, storage.FilterStartedAfter(conf.after.Time))
 	}
 
 	return
@@ -15,9 +15,11 @@ import (
 )
 
 type lsInputsConf struct {
-	quiet      bool
-	showDigest bool
-	csv        bool
+	quiet       bool
+	showDigest  bool
+	csv         bool
+	absPaths    bool
+	maxColWidth int
 }
 
 var lsInputsCmd = &cobra.Command{
@@ -39,6 +41,12 @@ func init() {
 	lsInputsCmd.Flags().BoolVar(&lsInputsConfig.showDigest, "digests", false,
 		"show digests")
 
+	lsInputsCmd.Flags().BoolVar(&lsInputsConfig.absPaths, "abs-path", false,
+		"Show absolute instead of relative paths")
+
+	lsInputsCmd.Flags().IntVar(&lsInputsConfig.maxColWidth, "max-col-width", 0,
+		"Truncate values to this number of characters, 0 disables truncation, not applied to CSV output")
+
 	lsCmd.AddCommand(lsInputsCmd)
 }
 func lsInputs(cmd *cobra.Command, args []string) {
@@ -64,7 +72,7 @@ func lsInputs(cmd *cobra.Command, args []string) {
 	if lsInputsConfig.csv {
 		formatter = csv.New(headers, os.Stdout)
 	} else {
-		formatter = table.New(headers, os.Stdout)
+		formatter = table.New(headers, os.Stdout).WithMaxColumnWidth(lsInputsConfig.maxColWidth)
 	}
 
 	inputs, err := app.BuildInputs()
@@ -77,8 +85,10 @@ func lsInputs(cmd *cobra.Command, args []string) {
 	})
 
 	for _, input := range inputs {
+		path := displayPath(rep, input.Path(), lsInputsConfig.absPaths)
+
 		if !lsInputsConfig.showDigest || lsInputsConfig.quiet {
-			mustWriteRow(formatter, []interface{}{input})
+			mustWriteRow(formatter, []interface{}{path})
 			continue
 		}
 
@@ -87,7 +97,7 @@ func lsInputs(cmd *cobra.Command, args []string) {
 			log.Fatalln("calculating digest failed:", err)
 		}
 
-		mustWriteRow(formatter, []interface{}{input, digest.String()})
+		mustWriteRow(formatter, []interface{}{path, digest.String()})
 	}
 
 	if err := formatter.Flush(); err != nil {
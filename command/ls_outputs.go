@@ -1,15 +1,19 @@
 package command
 
 import (
+	"context"
 	"os"
 	"strconv"
 
 	"github.com/spf13/cobra"
 
+	"github.com/simplesurance/baur"
+	"github.com/simplesurance/baur/command/flag"
 	"github.com/simplesurance/baur/format"
 	"github.com/simplesurance/baur/format/csv"
 	"github.com/simplesurance/baur/format/table"
 	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/storage"
 )
 
 var lsOutputsCmd = &cobra.Command{
@@ -20,43 +24,55 @@ var lsOutputsCmd = &cobra.Command{
 }
 
 type lsOutputsConfig struct {
-	quiet bool
-	csv   bool
+	quiet  bool
+	csv    bool
+	verify bool
 }
 
 var lsOutputsConf lsOutputsConfig
 
 func init() {
-	lsOutputsCmd.Flags().BoolVar(&lsOutputsConf.csv, "csv", false,
+	addLsOutputsFlags(lsOutputsCmd)
+
+	lsCmd.AddCommand(lsOutputsCmd)
+}
+
+func addLsOutputsFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&lsOutputsConf.csv, "csv", false,
 		"Show output in RFC4180 CSV format")
 
-	lsOutputsCmd.Flags().BoolVarP(&lsOutputsConf.quiet, "quiet", "q", false,
+	cmd.Flags().BoolVarP(&lsOutputsConf.quiet, "quiet", "q", false,
 		"Only show URIs")
 
-	lsCmd.AddCommand(lsOutputsCmd)
+	cmd.Flags().BoolVar(&lsOutputsConf.verify, "verify", false,
+		"Recalculate the digests of the local outputs and compare them\n"+
+			"with the recorded ones, exits with code 2 if they differ")
 }
 
 func lsOutputs(cmd *cobra.Command, args []string) {
 	repo := MustFindRepository()
-	pgClient := MustGetPostgresClt(repo)
+	pgClient := mustGetStorageClt(repo)
 
 	buildID, err := strconv.Atoi(args[0])
 	if err != nil {
 		log.Fatalf("'%s' is not a numeric build ID", args[0])
 	}
 
-	exist, err := pgClient.BuildExist(buildID)
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	outputs, err := pgClient.GetBuildOutputs(ctx, buildID)
 	if err != nil {
-		log.Fatalln(err)
-	}
+		if err == storage.ErrNotExist {
+			log.Fatalf("build with ID %d does not exist", buildID)
+		}
 
-	if !exist {
-		log.Fatalf("build with ID %d does not exist", buildID)
+		log.Fatalln(err)
 	}
 
-	outputs, err := pgClient.GetBuildOutputs(buildID)
-	if err != nil {
-		log.Fatalln(err)
+	if lsOutputsConf.verify {
+		verifyOutputs(ctx, repo, pgClient, buildID, outputs)
+		return
 	}
 
 	formatter := getLsOutputsFormatter(lsOutputsConf.quiet, lsOutputsConf.csv)
@@ -107,3 +123,80 @@ func getLsOutputsFormatter(isQuiet, isCsv bool) format.Formatter {
 
 	return table.New(headers, os.Stdout)
 }
+
+const (
+	outputVerifyOK       = "ok"
+	outputVerifyModified = "modified"
+	outputVerifyMissing  = "missing"
+)
+
+// verifyOutputs compares the recorded digests of the outputs with the digests
+// of the outputs that currently exist in the local filesystem.
+// It exits with verifyExitCodeIssuesFound if an output is missing or differs.
+func verifyOutputs(ctx context.Context, repo *baur.Repository, clt storage.Client, buildID int, outputs []*storage.Output) {
+	build, err := clt.GetBuildWithoutInputsOutputs(ctx, buildID)
+	if err != nil {
+		log.Fatalf("fetching build %d failed: %s\n", buildID, err)
+	}
+
+	app, err := repo.AppByName(build.Application.Name)
+	if err != nil {
+		log.Fatalf("loading application %q failed: %s\n", build.Application.Name, err)
+	}
+
+	localOutputs := make(map[string]baur.BuildOutput, len(app.Outputs))
+	for _, o := range app.Outputs {
+		localOutputs[o.Name()] = o
+	}
+
+	var headers []string
+	if !lsOutputsConf.csv {
+		headers = []string{"Name", "Recorded Digest", "Local Digest", "Status"}
+	}
+
+	outputFormat := ""
+	if lsOutputsConf.csv {
+		outputFormat = flag.FormatCSV
+	}
+
+	formatter := newFormatter(outputFormat, headers, nil)
+
+	var issuesFound bool
+
+	for _, o := range outputs {
+		status := outputVerifyOK
+		var localDigest string
+
+		local, exist := localOutputs[o.Name]
+		if !exist || !local.Exists() {
+			status = outputVerifyMissing
+		} else {
+			d, err := local.Digest()
+			if err != nil {
+				log.Fatalf("%s: calculating digest of output %s failed: %s\n", app, local, err)
+			}
+
+			localDigest = d.String()
+			if localDigest != o.Digest {
+				status = outputVerifyModified
+			}
+		}
+
+		if status != outputVerifyOK {
+			issuesFound = true
+			if !lsOutputsConf.csv {
+				status = redHighlight(status)
+			}
+		}
+
+		mustWriteRow(formatter, []interface{}{o.Name, o.Digest, localDigest, status})
+	}
+
+	if err = formatter.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+
+	if issuesFound {
+		os.Exit(verifyExitCodeIssuesFound)
+	}
+}
@@ -0,0 +1,20 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var outputsCmd = &cobra.Command{
+	Use:   "outputs <BUILD-ID>",
+	Short: "list outputs of a build, same as 'ls outputs'",
+	Long: `Lists the outputs of a build with their digests and the destinations
+they were uploaded to (S3 URI, docker repository:tag or local path).`,
+	Run:  lsOutputs,
+	Args: cobra.ExactArgs(1),
+}
+
+func init() {
+	addLsOutputsFlags(outputsCmd)
+
+	rootCmd.AddCommand(outputsCmd)
+}
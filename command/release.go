@@ -0,0 +1,90 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur"
+	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/storage"
+)
+
+const releaseLongHelp = `
+Record a release.
+
+A release is a named set of builds, it references the builds of the
+applications that exist for their current inputs. If no application is passed,
+all applications in the repository are part of the release.
+The command fails if no build exists for one of the applications or if a
+release with the name already exists.
+Releases can not be changed after they were created.
+`
+
+const releaseExamples = `
+baur release v1.2.0			record a release of all applications
+baur release v1.2.0 shop-api ui/shop	record a release of the shop-api
+					application and the application in
+					the ui/shop directory
+baur release show v1.2.0		show the builds of release v1.2.0
+`
+
+var releaseCmd = &cobra.Command{
+	Use:     "release NAME [<APP-NAME>|<PATH>]...",
+	Short:   "record a release of the current builds",
+	Long:    strings.TrimSpace(releaseLongHelp),
+	Example: strings.TrimSpace(releaseExamples),
+	Args:    cobra.MinimumNArgs(1),
+	Run:     release,
+}
+
+func init() {
+	rootCmd.AddCommand(releaseCmd)
+}
+
+func release(cmd *cobra.Command, args []string) {
+	name := args[0]
+
+	repo := MustFindRepository()
+	apps := mustArgToApps(repo, args[1:])
+	storageClt := mustGetStorageClt(repo)
+
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	baur.SortAppsByName(apps)
+
+	var missing []string
+	buildIDs := make([]int, 0, len(apps))
+
+	for _, app := range apps {
+		buildStatus, build, err := baur.GetBuildStatus(ctx, storageClt, app)
+		if err != nil {
+			log.Fatalf("%s: evaluating build status failed: %s", app, err)
+		}
+
+		if buildStatus != baur.BuildStatusExist {
+			missing = append(missing, fmt.Sprintf("%s (%s)", app.Name, buildStatus))
+			continue
+		}
+
+		log.Debugf("%s: using build %d", app, build.ID)
+		buildIDs = append(buildIDs, build.ID)
+	}
+
+	if len(missing) != 0 {
+		log.Fatalf("no build exists for the current inputs of: %s\n", strings.Join(missing, ", "))
+	}
+
+	rel, err := storageClt.CreateRelease(ctx, name, buildIDs)
+	if err != nil {
+		if err == storage.ErrExist {
+			log.Fatalf("release %q already exists\n", name)
+		}
+
+		log.Fatalf("storing release failed: %s\n", err)
+	}
+
+	fmt.Printf("release %s with %d builds recorded\n", highlight(rel.Name), len(rel.BuildIDs))
+}
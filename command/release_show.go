@@ -0,0 +1,80 @@
+package command
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur/format/table"
+	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/storage"
+)
+
+var releaseShowCmd = &cobra.Command{
+	Use:   "show NAME",
+	Short: "show the builds of a release",
+	Args:  cobra.ExactArgs(1),
+	Run:   releaseShow,
+}
+
+func init() {
+	releaseCmd.AddCommand(releaseShowCmd)
+}
+
+func releaseShow(cmd *cobra.Command, args []string) {
+	name := args[0]
+
+	repo := MustFindRepository()
+	storageClt := mustGetStorageClt(repo)
+
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	rel, err := storageClt.GetRelease(ctx, name)
+	if err != nil {
+		if err == storage.ErrNotExist {
+			log.Fatalf("release %q does not exist\n", name)
+		}
+
+		log.Fatalln(err)
+	}
+
+	var builds []*storage.BuildWithDuration
+	if len(rel.BuildIDs) != 0 {
+		builds, err = storageClt.GetBuildsWithoutInputsOutputs(
+			ctx,
+			[]*storage.Filter{{Field: storage.FieldBuildID, Operator: storage.OpIN, Value: rel.BuildIDs}},
+			[]*storage.Sorter{{Field: storage.FieldApplicationName, Order: storage.OrderAsc}},
+			0,
+		)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	formatter := table.New(nil, os.Stdout)
+
+	mustWriteRow(formatter, []interface{}{underline("General:")})
+	mustWriteRow(formatter, []interface{}{"", "Name:", highlight(rel.Name)})
+	mustWriteRow(formatter, []interface{}{"", "ID:", highlight(rel.ID)})
+	mustWriteRow(formatter, []interface{}{"", "Created At:", highlight(rel.CreatedAt)})
+
+	if len(builds) > 0 {
+		mustWriteRow(formatter, []interface{}{})
+		mustWriteRow(formatter, []interface{}{underline("Builds:")})
+	}
+
+	for i, b := range builds {
+		mustWriteRow(formatter, []interface{}{"", "Application:", highlight(b.Application.Name)})
+		mustWriteRow(formatter, []interface{}{"", "Build ID:", highlight(b.ID)})
+		mustWriteRow(formatter, []interface{}{"", "Git Commit:", highlight(vcsStr(&b.VCSState))})
+
+		if i+1 < len(builds) {
+			mustWriteRow(formatter, []interface{}{})
+		}
+	}
+
+	if err := formatter.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -3,13 +3,18 @@ package command
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime/pprof"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/simplesurance/baur/command/flag"
 	"github.com/simplesurance/baur/exec"
 	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/term"
 	"github.com/simplesurance/baur/version"
 )
 
@@ -19,17 +24,43 @@ var rootCmd = &cobra.Command{
 	PersistentPreRun: initSb,
 }
 
-var verboseFlag bool
+// verbosity is the number of times the verbose flag was passed, 1 enables
+// debug messages, 2 or more also trace messages
+var verbosity int
 var cpuProfilingFlag bool
+var noCacheFlag bool
+var noColorFlag bool
+var colorFlag = flag.NewColorFlag()
+
+// storageTimeout is the max. duration of a database operation, 0 disables
+// the timeout
+var storageTimeout time.Duration
 
 var defCPUProfFile = filepath.Join(os.TempDir(), "baur-cpu.prof")
 
-func initSb(_ *cobra.Command, _ []string) {
-	if verboseFlag {
-		log.StdLogger.EnableDebug(verboseFlag)
+func initSb(cmd *cobra.Command, _ []string) {
+	if verbosity > 0 {
+		log.StdLogger.EnableDebug(true)
 		exec.DefaultDebugfFn = log.StdLogger.Debugf
 	}
 
+	if verbosity > 1 {
+		log.StdLogger.EnableTrace(true)
+	}
+
+	colorMode := colorFlag.Val
+	if noColorFlag {
+		if cmd.Flags().Changed("color") && colorMode != term.ColorModeNever {
+			log.Fatalf("--no-color and --color=%s can not be passed together", colorMode)
+		}
+
+		colorMode = term.ColorModeNever
+	}
+
+	if err := term.SetColorMode(colorMode); err != nil {
+		log.Fatalln(err)
+	}
+
 	if cpuProfilingFlag {
 		cpuProfFile, err := os.Create(defCPUProfFile)
 		if err != nil {
@@ -42,16 +73,46 @@ func initSb(_ *cobra.Command, _ []string) {
 	}
 }
 
+// forwardTermSignals forwards SIGINT and SIGTERM to the build commands that
+// run in their own process group and terminates baur afterwards. Without it
+// they would continue to run after baur terminated.
+func forwardTermSignals() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigCh
+
+		exec.SignalProcessGroups(sig)
+
+		exitCode := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			exitCode = 128 + int(s)
+		}
+
+		os.Exit(exitCode)
+	}()
+}
+
 // Execute parses commandline flags and execute their actions
 func Execute() {
+	forwardTermSignals()
+
 	if err := version.LoadPackageVars(); err != nil {
 		log.Errorln("setting version failed", err)
 	}
 	rootCmd.Version = version.CurSemVer.String()
 
-	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "verbose output, pass it twice (-vv) for more details")
 	rootCmd.PersistentFlags().BoolVar(&cpuProfilingFlag, "cpu-prof", false,
 		fmt.Sprintf("enable cpu profiling, result is written to %q", defCPUProfFile))
+	rootCmd.PersistentFlags().DurationVar(&storageTimeout, "timeout", 0,
+		"max. duration of a database operation, 0 disables the timeout")
+	rootCmd.PersistentFlags().BoolVar(&noCacheFlag, "no-cache", false,
+		"do not use and store cached results of resolving GolangSources inputs")
+	rootCmd.PersistentFlags().Var(colorFlag, "color", colorFlag.Usage(highlight))
+	rootCmd.PersistentFlags().BoolVar(&noColorFlag, "no-color", false,
+		"disable colored output, alias for --color=never")
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalln(err)
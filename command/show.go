@@ -20,12 +20,19 @@ Show information about an application or a build.
 If the name or the path to an application directory is passed,
 application information are shown.
 If a numeric build ID is passed, information about the build are shown.
+
+With the --config flag the configuration of the application is printed in
+TOML format, the sections of its include files are merged into it.
+The optional, gitignore and excludes settings of merged [Build.Input.Files]
+sections are combined. They only apply to the paths of the section they are
+defined in, in the printed configuration they appear to apply to all paths.
 `
 
 const showExamples = `
 baur show calc		show information about the calc application
 baur show ui/shop	show information about the app in the ui/shop directory
 baur show 512		show information about build 512
+baur show --config calc	show the configuration of the calc application
 `
 
 var showCmd = &cobra.Command{
@@ -37,11 +44,32 @@ var showCmd = &cobra.Command{
 	Example: strings.TrimSpace(showExamples),
 }
 
+type showConf struct {
+	config      bool
+	resolveVars bool
+}
+
+var showConfig showConf
+
 func init() {
+	showCmd.Flags().BoolVar(&showConfig.config, "config", false,
+		"Print the application configuration with merged includes in TOML format")
+	showCmd.Flags().BoolVar(&showConfig.resolveVars, "resolve-vars", false,
+		"Replace variables in the printed configuration with their values, requires --config")
+
 	rootCmd.AddCommand(showCmd)
 }
 
 func show(cmd *cobra.Command, args []string) {
+	if showConfig.resolveVars && !showConfig.config {
+		log.Fatalln("--resolve-vars can only be used together with --config")
+	}
+
+	if showConfig.config {
+		showAppConfig(args[0])
+		return
+	}
+
 	buildID, err := strconv.Atoi(args[0])
 	if err == nil {
 		showBuild(buildID)
@@ -50,6 +78,20 @@ func show(cmd *cobra.Command, args []string) {
 	}
 }
 
+func showAppConfig(arg string) {
+	repo := MustFindRepository()
+	app := mustArgToApp(repo, arg)
+
+	appCfg, err := app.MergedConfig(showConfig.resolveVars)
+	if err != nil {
+		log.Fatalf("%s: %s", app, err)
+	}
+
+	if err := appCfg.Encode(os.Stdout); err != nil {
+		log.Fatalln(err)
+	}
+}
+
 func showApp(arg string) {
 	var formatter format.Formatter
 
@@ -61,7 +103,7 @@ func showApp(arg string) {
 	mustWriteRow(formatter, []interface{}{underline("General:")})
 	mustWriteRow(formatter, []interface{}{"", "Name:", highlight(app.Name)})
 	mustWriteRow(formatter, []interface{}{"", "Path:", highlight(app.RelPath)})
-	mustWriteRow(formatter, []interface{}{"", "Build Command:", highlight(app.BuildCmd)})
+	mustWriteRow(formatter, []interface{}{"", "Build Command:", highlight(app.BuildCmdString())})
 
 	if len(app.Outputs) != 0 {
 		mustWriteRow(formatter, []interface{}{})
@@ -95,6 +137,24 @@ func showApp(arg string) {
 					"Paths:", highlight(strings.Join(bi.Files.Paths, ", ")),
 				})
 
+				if len(bi.Files.Excludes) > 0 {
+					mustWriteRow(formatter, []interface{}{"",
+						"Excludes:", highlight(strings.Join(bi.Files.Excludes, ", ")),
+					})
+				}
+
+				printNewLine = true
+			}
+
+			if len(bi.Directories.Paths) > 0 {
+				if printNewLine {
+					mustWriteRow(formatter, []interface{}{})
+				}
+
+				mustWriteRow(formatter, []interface{}{"", "Type:", highlight("Directory")})
+				mustWriteRow(formatter, []interface{}{"",
+					"Paths:", highlight(strings.Join(bi.Directories.Paths, ", "))})
+
 				printNewLine = true
 			}
 
@@ -123,6 +183,30 @@ func showApp(arg string) {
 
 				printNewLine = true
 			}
+
+			if len(bi.Environment.Names) > 0 {
+				if printNewLine {
+					mustWriteRow(formatter, []interface{}{})
+				}
+
+				mustWriteRow(formatter, []interface{}{"", "Type:", highlight("Environment")})
+				mustWriteRow(formatter, []interface{}{"",
+					"Names:", highlight(strings.Join(bi.Environment.Names, ", "))})
+
+				printNewLine = true
+			}
+
+			for _, f := range bi.URLs.File {
+				if printNewLine {
+					mustWriteRow(formatter, []interface{}{})
+				}
+
+				mustWriteRow(formatter, []interface{}{"", "Type:", highlight("URL")})
+				mustWriteRow(formatter, []interface{}{"", "URL:", highlight(f.URL)})
+				mustWriteRow(formatter, []interface{}{"", "SHA256:", highlight(f.SHA256)})
+
+				printNewLine = true
+			}
 		}
 	}
 
@@ -135,9 +219,12 @@ func showBuild(buildID int) {
 	var formatter format.Formatter
 
 	repo := MustFindRepository()
-	storageClt := MustGetPostgresClt(repo)
+	storageClt := mustGetStorageClt(repo)
+
+	ctx, cancel := newStorageCtx()
+	defer cancel()
 
-	build, err := storageClt.GetBuildWithoutInputsOutputs(buildID)
+	build, err := storageClt.GetBuildWithoutInputsOutputs(ctx, buildID)
 	if err != nil {
 		if err == storage.ErrNotExist {
 			log.Fatalf("build with id %d does not exist\n", buildID)
@@ -146,7 +233,7 @@ func showBuild(buildID int) {
 		log.Fatalln(err)
 	}
 
-	build.Outputs, err = storageClt.GetBuildOutputs(build.ID)
+	build.Outputs, err = storageClt.GetBuildOutputs(ctx, build.ID)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -187,6 +274,9 @@ func showBuild(buildID int) {
 		})
 		mustWriteRow(formatter, []interface{}{"", "Type:", highlight(o.Type)})
 		mustWriteRow(formatter, []interface{}{"", "Upload Method:", highlight(o.Upload.Method)})
+		if len(o.Upload.RegistryDigest) != 0 {
+			mustWriteRow(formatter, []interface{}{"", "Registry Digest:", highlight(o.Upload.RegistryDigest)})
+		}
 
 		if i+1 < len(build.Outputs) {
 			mustWriteRow(formatter, []interface{}{})
@@ -0,0 +1,257 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur"
+	"github.com/simplesurance/baur/command/flag"
+	"github.com/simplesurance/baur/log"
+	"github.com/simplesurance/baur/storage"
+	"github.com/simplesurance/baur/upload"
+	"github.com/simplesurance/baur/upload/azure"
+	"github.com/simplesurance/baur/upload/filecopy"
+	"github.com/simplesurance/baur/upload/gcs"
+	"github.com/simplesurance/baur/upload/s3"
+)
+
+const statusLongHelp = `
+Show the build status of application tasks.
+
+The total input digest of each task is calculated and it is checked if a
+build for it was recorded.
+
+Tasks can be selected by passing application names, application directories
+or task specifiers in the format <APP-NAME>.<TASK>. Both parts of a task
+specifier can contain '*' wildcards.
+If no argument is passed, the status of all tasks in the repository is shown.
+
+When --verify-outputs is passed, it is additionally checked for tasks with a
+recorded build if the uploaded outputs still exist at their upload
+locations. Tasks with missing outputs are shown with the status
+'Outputs Missing'. The environment variables for authentication that the
+build command supports are respected.
+`
+
+const statusExample = `
+baur status			show the status of all tasks
+baur status '*.build'		show the status of the build tasks of all applications
+baur status 'calc.*'		show the status of all tasks of the calc application
+baur status --csv calc		show the status of the tasks of the calc application in csv format
+baur status --verify-outputs	show the status of all tasks, check if uploaded outputs exist
+`
+
+var statusCmd = &cobra.Command{
+	Use:     "status [<APP-NAME>|<PATH>|<APP-NAME>.<TASK>]...",
+	Short:   "show the build status of tasks",
+	Long:    strings.TrimSpace(statusLongHelp),
+	Example: strings.TrimSpace(statusExample),
+	Run:     status,
+	Args:    cobra.ArbitraryArgs,
+}
+
+type statusConf struct {
+	csv           bool
+	format        *flag.Format
+	quiet         bool
+	verifyOutputs bool
+}
+
+var statusConfig = statusConf{format: flag.NewFormatFlag()}
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusConfig.csv, "csv", false,
+		"List tasks in RFC4180 CSV format, alias for --format csv")
+
+	statusCmd.Flags().Var(statusConfig.format, "format",
+		statusConfig.format.Usage(highlight))
+
+	statusCmd.Flags().BoolVarP(&statusConfig.quiet, "quiet", "q", false,
+		"Suppress printing a header")
+
+	statusCmd.Flags().BoolVar(&statusConfig.verifyOutputs, "verify-outputs", false,
+		"Check if the uploaded outputs of recorded builds still exist")
+
+	rootCmd.AddCommand(statusCmd)
+}
+
+// taskSpecToAppArg converts an argument in the format <APP-NAME>.<TASK> to
+// the application name. If the argument is not a task specifier it is
+// returned unchanged. An empty string is returned if the argument matches
+// all applications.
+func taskSpecToAppArg(arg string) string {
+	if isAppDir(arg) {
+		return arg
+	}
+
+	suffix := "." + baur.BuildTaskName
+	if !strings.HasSuffix(arg, suffix) {
+		return arg
+	}
+
+	appName := strings.TrimSuffix(arg, suffix)
+	if appName == "*" {
+		return ""
+	}
+
+	return appName
+}
+
+// statusArgsToApps returns the applications that are selected by args.
+// Arguments can be application names, directories or task specifiers with
+// wildcards.
+func statusArgsToApps(repo *baur.Repository, args []string) []*baur.App {
+	var allApps []*baur.App
+	var res []*baur.App
+
+	if len(args) == 0 {
+		return mustArgToApps(repo, nil)
+	}
+
+	appArgs := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if isAppDir(arg) || !baur.IsTaskSpecifier(arg) {
+			appArgs = append(appArgs, arg)
+			continue
+		}
+
+		appPattern, taskPattern, err := baur.ParseTaskSpecifier(arg)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if allApps == nil {
+			allApps = mustArgToApps(repo, nil)
+		}
+
+		tasks, err := baur.MatchTasks(allApps, appPattern, taskPattern)
+		if err != nil {
+			log.Fatalf("matching tasks against %q failed: %s", arg, err)
+		}
+
+		if len(tasks) == 0 {
+			log.Fatalf("task specifier %q does not match any tasks", arg)
+		}
+
+		for _, task := range tasks {
+			res = append(res, task.App)
+		}
+	}
+
+	if len(appArgs) > 0 {
+		res = append(res, mustArgToApps(repo, appArgs)...)
+	}
+
+	return uniqApps(res)
+}
+
+func uniqApps(apps []*baur.App) []*baur.App {
+	seen := make(map[string]struct{}, len(apps))
+	res := make([]*baur.App, 0, len(apps))
+
+	for _, app := range apps {
+		if _, exist := seen[app.Path]; exist {
+			continue
+		}
+
+		seen[app.Path] = struct{}{}
+		res = append(res, app)
+	}
+
+	return res
+}
+
+// mustNewExistCheckers returns clients to check if uploaded outputs exist,
+// for each supported upload method
+func mustNewExistCheckers() map[storage.UploadMethod]upload.ExistChecker {
+	s3Clt, err := s3.NewClient(log.StdLogger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	gcsClt, err := gcs.NewClient(log.StdLogger, os.Getenv(gcsEnvAccessKeyIDVar), os.Getenv(gcsEnvSecretVar))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return map[storage.UploadMethod]upload.ExistChecker{
+		storage.S3:             s3Clt,
+		storage.GCS:            gcsClt,
+		storage.AzureBlob:      azure.NewClient(log.StdLogger, os.Getenv(azureEnvAccountVar), os.Getenv(azureEnvKeyVar), os.Getenv(azureEnvSASTokenVar)),
+		storage.DockerRegistry: mustNewDockerClient(),
+		storage.FileCopy:       filecopy.New(log.Debugf),
+	}
+}
+
+func status(cmd *cobra.Command, args []string) {
+	var existCheckers map[storage.UploadMethod]upload.ExistChecker
+
+	var headers []string
+
+	if statusConfig.csv {
+		statusConfig.format.Val = flag.FormatCSV
+	}
+
+	repo := MustFindRepository()
+	apps := statusArgsToApps(repo, args)
+	storageClt := mustGetStorageClt(repo)
+
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	baur.SortAppsByName(apps)
+
+	if statusConfig.verifyOutputs {
+		existCheckers = mustNewExistCheckers()
+	}
+
+	if !statusConfig.quiet && statusConfig.format.Val == flag.FormatPlain {
+		headers = []string{"Name", "Task", "Status", "Build ID"}
+	}
+
+	formatter := newFormatter(statusConfig.format.Val, headers,
+		[]string{"name", "task", "status", "build_id"})
+
+	for _, app := range apps {
+		var buildID string
+
+		buildStatus, build, err := baur.GetBuildStatus(ctx, storageClt, app)
+		if err != nil {
+			log.Fatalf("%s: evaluating build status failed: %s", app, err)
+		}
+
+		if buildStatus == baur.BuildStatusExist {
+			buildID = fmt.Sprint(build.ID)
+
+			if statusConfig.verifyOutputs {
+				missing, err := baur.MissingOutputs(build, existCheckers)
+				if err != nil {
+					log.Fatalf("%s: verifying outputs failed: %s", app, err)
+				}
+
+				for _, out := range missing {
+					log.Debugf("%s: output %s does not exist at %s", app, out.Name, out.Upload.URI)
+				}
+
+				if len(missing) > 0 {
+					buildStatus = baur.BuildStatusOutputsMissing
+				}
+			}
+		}
+
+		mustWriteRow(formatter, []interface{}{
+			app.Name,
+			baur.BuildTaskName,
+			buildStatus,
+			buildID,
+		})
+	}
+
+	if err := formatter.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+}
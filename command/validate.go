@@ -0,0 +1,221 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur"
+	"github.com/simplesurance/baur/cfg"
+	"github.com/simplesurance/baur/fs"
+	"github.com/simplesurance/baur/log"
+)
+
+const validateLongHelp = `
+Validate baur configuration files.
+
+If no argument is passed, all application configuration files in the
+repository and the include files they reference are validated.
+Files named .app.toml are validated as application configuration files,
+other files as include files. If a directory is passed, the application
+configuration file in it is validated.
+If '-' is passed as argument, an application configuration is read from
+stdin and validated. With the --include flag it is validated as include file.
+
+All errors are reported. The command exits with a non-zero exit code if a
+configuration file is invalid.
+`
+
+const validateExample = `
+baur validate					validate all configs in the repository
+baur validate calc/.app.toml include.toml	validate an application and an include config
+baur validate - <.app.toml			validate an application config
+baur validate --include - <include.toml		validate an include config
+`
+
+var validateCmd = &cobra.Command{
+	Use:     "validate [-|<PATH>...]",
+	Short:   "validate configuration files",
+	Long:    strings.TrimSpace(validateLongHelp),
+	Example: strings.TrimSpace(validateExample),
+	Run:     validate,
+	Args:    cobra.ArbitraryArgs,
+}
+
+type validateConf struct {
+	include bool
+}
+
+var validateConfig validateConf
+
+func init() {
+	validateCmd.Flags().BoolVar(&validateConfig.include, "include", false,
+		"Validate the configuration from stdin as include file")
+
+	rootCmd.AddCommand(validateCmd)
+}
+
+// validateStdin reads a configuration from stdin and validates it
+func validateStdin() error {
+	if validateConfig.include {
+		include, err := cfg.IncludeFromReader(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		return include.Validate()
+	}
+
+	app, err := cfg.AppFromReader(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	return app.Validate()
+}
+
+func validateAppConfig(path string) (*cfg.App, error) {
+	app, err := cfg.AppFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return app, app.Validate()
+}
+
+func validateIncludeConfig(path string) error {
+	include, err := cfg.IncludeFromFile(path)
+	if err != nil {
+		return err
+	}
+
+	return include.Validate()
+}
+
+// cfgValidator validates config files and records the results
+type cfgValidator struct {
+	validated map[string]struct{}
+	failed    int
+}
+
+func newCfgValidator() *cfgValidator {
+	return &cfgValidator{validated: map[string]struct{}{}}
+}
+
+// done returns true if path was already validated, otherwise it is marked as
+// validated and false is returned
+func (v *cfgValidator) done(path string) bool {
+	if _, exist := v.validated[path]; exist {
+		return true
+	}
+
+	v.validated[path] = struct{}{}
+
+	return false
+}
+
+func (v *cfgValidator) report(path string, err error) {
+	if err == nil {
+		return
+	}
+
+	v.failed++
+	log.Errorf("%s: %s", path, err)
+}
+
+// app validates an application config file and returns the parsed config.
+// If the file can not be parsed, nil is returned.
+func (v *cfgValidator) app(path string) *cfg.App {
+	if v.done(path) {
+		return nil
+	}
+
+	appCfg, err := validateAppConfig(path)
+	v.report(path, err)
+
+	return appCfg
+}
+
+func (v *cfgValidator) include(path string) {
+	if v.done(path) {
+		return
+	}
+
+	v.report(path, validateIncludeConfig(path))
+}
+
+func (v *cfgValidator) repository(repo *baur.Repository) {
+	cfgPaths, err := repo.AppConfigPaths()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, cfgPath := range cfgPaths {
+		appCfg := v.app(cfgPath)
+		if appCfg == nil {
+			continue
+		}
+
+		for _, includePath := range appCfg.Build.Includes {
+			v.include(repo.IncludeAbsPath(filepath.Dir(cfgPath), includePath))
+		}
+	}
+}
+
+func (v *cfgValidator) path(path string) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	isDir, err := fs.IsDir(absPath)
+	if err != nil {
+		v.report(path, err)
+		return
+	}
+
+	if isDir {
+		absPath = filepath.Join(absPath, baur.AppCfgFile)
+	}
+
+	if filepath.Base(absPath) == baur.AppCfgFile {
+		v.app(absPath)
+		return
+	}
+
+	v.include(absPath)
+}
+
+func validate(cmd *cobra.Command, args []string) {
+	if len(args) == 1 && args[0] == "-" {
+		if err := validateStdin(); err != nil {
+			log.Fatalf("<stdin>: %s", err)
+		}
+
+		fmt.Println("configuration is valid")
+		return
+	}
+
+	v := newCfgValidator()
+
+	if len(args) == 0 {
+		v.repository(MustFindRepository())
+	} else {
+		for _, arg := range args {
+			if arg == "-" {
+				log.Fatalln("'-' can not be combined with other arguments")
+			}
+
+			v.path(arg)
+		}
+	}
+
+	if v.failed > 0 {
+		log.Fatalf("%d of %d configuration files are invalid", v.failed, len(v.validated))
+	}
+
+	fmt.Printf("%d configuration files are valid\n", len(v.validated))
+}
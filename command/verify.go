@@ -66,10 +66,13 @@ func verify(cmd *cobra.Command, args []string) {
 	}
 	repo := MustFindRepository()
 
-	clt := MustGetPostgresClt(repo)
+	clt := mustGetStorageClt(repo)
 	defer clt.Close()
 
-	storedApps, err := clt.GetApps()
+	ctx, cancel := newStorageCtx()
+	defer cancel()
+
+	storedApps, err := clt.GetApps(ctx)
 	if err != nil {
 		if err == storage.ErrNotExist {
 			log.Fatalln("database doesn't contain any build informations, run some builds first")
@@ -82,7 +85,7 @@ func verify(cmd *cobra.Command, args []string) {
 
 	var issuesFound bool
 	for _, app := range storedApps {
-		issues, err := storage.VerifySameInputDigestSameOutputs(clt, app.Name, startTs)
+		issues, err := storage.VerifySameInputDigestSameOutputs(ctx, clt, app.Name, startTs)
 		if err != nil && err != storage.ErrNotExist {
 			log.Fatalln("verifiying if builds with same input digests have the same outputs failed:", err)
 		}
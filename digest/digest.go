@@ -32,6 +32,19 @@ func (t Algorithm) String() string {
 	}
 }
 
+// AlgorithmFromString returns the Algorithm with the given name, the name is
+// case-insensitive.
+func AlgorithmFromString(name string) (Algorithm, error) {
+	switch strings.ToLower(name) {
+	case "sha256":
+		return SHA256, nil
+	case "sha384":
+		return SHA384, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm %q", name)
+	}
+}
+
 // Digest contains a checksum
 type Digest struct {
 	Sum       big.Int
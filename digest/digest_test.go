@@ -21,3 +21,20 @@ func TestFromString(t *testing.T) {
 	}
 
 }
+
+func TestAlgorithmFromString(t *testing.T) {
+	for _, a := range []Algorithm{SHA256, SHA384} {
+		res, err := AlgorithmFromString(a.String())
+		if err != nil {
+			t.Fatalf("AlgorithmFromString(%q) failed: %s", a, err)
+		}
+
+		if res != a {
+			t.Errorf("AlgorithmFromString(%q) returned %q, expected %q", a, res, a)
+		}
+	}
+
+	if _, err := AlgorithmFromString("md5"); err == nil {
+		t.Error("AlgorithmFromString succeeded for an unsupported algorithm")
+	}
+}
@@ -0,0 +1,116 @@
+package sha256_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/digest/sha256"
+)
+
+func TestDigestOnEmptyHashErrors(t *testing.T) {
+	const emptySHA256Digest = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	sha := sha256.New()
+	d := sha.Digest()
+
+	if d.Sum.Text(16) != emptySHA256Digest {
+		t.Errorf("hash of nothing is %q expected %q", d.Sum.Text(16), emptySHA256Digest)
+	}
+
+	if d.Algorithm != digest.SHA256 {
+		t.Errorf("Algorithm of Digest is set to %q expected %q", d.Algorithm, digest.SHA256)
+	}
+}
+
+func TestAddBytes(t *testing.T) {
+	const (
+		helloSha256    = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+		hellobyeSha256 = "a94432573249e9556c9a9b20ee7524c2c10ccecb3ef139cf82e944901a8c27da"
+		helloStr       = "hello"
+		byeStr         = "bye"
+	)
+
+	sha := sha256.New()
+	err := sha.AddBytes([]byte(helloStr))
+	if err != nil {
+		t.Fatalf("AddBytes(%q) failed: %s", helloStr, err.Error())
+	}
+
+	d1 := sha.Digest()
+	if d1.Algorithm != digest.SHA256 {
+		t.Errorf("Algorithm of Digest is set to %q expected %q", d1.Algorithm, digest.SHA256)
+	}
+
+	if d1.Sum.Text(16) != helloSha256 {
+		t.Errorf("calculated hash of %q is %q, expected %q", helloStr, d1.Sum.Text(16), helloSha256)
+	}
+
+	expectedStrRepr := "sha256:" + helloSha256
+	if d1.String() != expectedStrRepr {
+		t.Errorf("string representation of digest is %q, expected %q", d1.String(), expectedStrRepr)
+	}
+
+	err = sha.AddBytes([]byte(byeStr))
+	if err != nil {
+		t.Fatalf("AddBytes(%q) failed: %s", byeStr, err)
+	}
+
+	d2 := sha.Digest()
+	if d1.Sum.Cmp(&d2.Sum) == 0 {
+		t.Fatalf("adding %q to hash didn't change digest", byeStr)
+	}
+
+	if d2.Sum.Text(16) != hellobyeSha256 {
+		t.Errorf("calculated hash of 'hellobye' is %q, expected %q", d1.Sum.Text(16), hellobyeSha256)
+	}
+}
+
+func TestAddFile(t *testing.T) {
+	const (
+		testStr       = "this is a baur sha256 test file"
+		testStrSHA256 = "90c256ac7050738b57f44b54d67b09d44edaa0fb4fc19ecf0c54791e271690a4"
+	)
+
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal("creating tempfile failed:", err.Error())
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write([]byte(testStr))
+	if err != nil {
+		file.Close()
+		t.Fatal("writing to file failed:", err.Error())
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal("closing file failed:", err.Error())
+	}
+
+	sha := sha256.New()
+
+	err = sha.AddFile(file.Name())
+	if err != nil {
+		t.Fatal("hashing file failed:", err.Error())
+	}
+	d := sha.Digest()
+
+	if d.Sum.Text(16) != testStrSHA256 {
+		t.Errorf("hash of file is %q expeted %q", d.Sum.Text(16), testStrSHA256)
+	}
+}
+
+func TestHashingNonExistingFileFails(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal("creating tempfile failed:", err.Error())
+	}
+	os.Remove(file.Name())
+
+	sha := sha256.New()
+	err = sha.AddFile(file.Name())
+	if err == nil {
+		t.Errorf("hashing non existing file was successful")
+	}
+}
@@ -0,0 +1,144 @@
+package baur
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/digest/sha256"
+	"github.com/simplesurance/baur/fs"
+	"github.com/simplesurance/baur/upload/docker"
+	"github.com/simplesurance/baur/upload/scheduler"
+)
+
+// DockerPlatformImage is a docker image for a specific platform
+type DockerPlatformImage struct {
+	// Platform in the format <os>/<arch>[/<variant>]
+	Platform    string
+	ImageIDFile string
+}
+
+// DockerManifestArtifact consists of docker images for multiple platforms
+// that are uploaded with a manifest list referencing them
+type DockerManifestArtifact struct {
+	Images     []*DockerPlatformImage
+	Tag        string
+	Repository string
+}
+
+// Exists returns true if the ImageIDFiles of all images exist
+func (d *DockerManifestArtifact) Exists() bool {
+	for _, img := range d.Images {
+		if !fs.FileExists(img.ImageIDFile) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *DockerManifestArtifact) manifestImages() ([]*docker.ManifestImage, error) {
+	res := make([]*docker.ManifestImage, 0, len(d.Images))
+
+	for _, img := range d.Images {
+		id, err := fs.FileReadLine(img.ImageIDFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading imageID from %s failed", img.ImageIDFile)
+		}
+
+		if len(id) == 0 {
+			return nil, fmt.Errorf("%s is empty", img.ImageIDFile)
+		}
+
+		res = append(res, &docker.ManifestImage{
+			ImageID:  id,
+			Platform: img.Platform,
+		})
+	}
+
+	return res, nil
+}
+
+// UploadJob returns a scheduler.DockerManifestJob for the artifact
+func (d *DockerManifestArtifact) UploadJob() (scheduler.Job, error) {
+	images, err := d.manifestImages()
+	if err != nil {
+		return nil, err
+	}
+
+	return &scheduler.DockerManifestJob{
+		Images:     images,
+		Repository: d.Repository,
+		Tag:        d.Tag,
+	}, nil
+}
+
+// String returns the paths of the ImageID files
+func (d *DockerManifestArtifact) String() string {
+	return d.LocalPath()
+}
+
+// LocalPath returns the paths of the ImageID files, separated by ", "
+func (d *DockerManifestArtifact) LocalPath() string {
+	paths := make([]string, 0, len(d.Images))
+	for _, img := range d.Images {
+		paths = append(paths, img.ImageIDFile)
+	}
+
+	return strings.Join(paths, ", ")
+}
+
+// Name returns the docker repository name
+func (d *DockerManifestArtifact) Name() string {
+	return d.Repository
+}
+
+// UploadDestination returns the upload destination of the manifest list
+func (d *DockerManifestArtifact) UploadDestination() string {
+	return fmt.Sprintf("%s:%s", d.Repository, d.Tag)
+}
+
+// Digest returns a sha256 digest of the platforms and image IDs
+func (d *DockerManifestArtifact) Digest() (*digest.Digest, error) {
+	images, err := d.manifestImages()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	for _, img := range images {
+		if err := h.AddBytes([]byte(img.Platform + " " + img.ImageID + "\n")); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.Digest(), nil
+}
+
+// Size returns the sum of the sizes of the images in bytes
+func (d *DockerManifestArtifact) Size(b *BuildOutputBackends) (int64, error) {
+	var size int64
+
+	images, err := d.manifestImages()
+	if err != nil {
+		return -1, err
+	}
+
+	for _, img := range images {
+		imgSize, err := b.DockerClt.Size(img.ImageID)
+		if err != nil {
+			return -1, errors.Wrapf(err, "getting size of image %s failed", img.ImageID)
+		}
+
+		size += imgSize
+	}
+
+	return size, nil
+}
+
+// Type returns "docker"
+func (d *DockerManifestArtifact) Type() string {
+	return "docker"
+}
@@ -0,0 +1,66 @@
+package baur
+
+import (
+	"os"
+
+	"github.com/simplesurance/baur/digest"
+)
+
+// EnvVar represents an environment variable that is a build input
+type EnvVar struct {
+	name      string
+	algorithm digest.Algorithm
+	digest    *digest.Digest
+}
+
+// NewEnvVar returns a new EnvVar, it's digest is calculated with the passed
+// algorithm
+func NewEnvVar(name string, algorithm digest.Algorithm) *EnvVar {
+	return &EnvVar{name: name, algorithm: algorithm}
+}
+
+// Digest returns a digest of the name and value of the environment variable.
+// The value is read from the environment on the first call.
+// An unset variable results in a different digest than a variable that is set
+// to an empty string.
+func (e *EnvVar) Digest() (digest.Digest, error) {
+	if e.digest != nil {
+		return *e.digest, nil
+	}
+
+	sha, err := newInputHash(e.algorithm)
+	if err != nil {
+		return digest.Digest{}, err
+	}
+
+	// names can not contain '=', an unset variable is hashed only by it's
+	// name, a set variable as NAME=VALUE, both can not collide
+	data := e.name
+	if val, exist := os.LookupEnv(e.name); exist {
+		data += "=" + val
+	}
+
+	err = sha.AddBytes([]byte(data))
+	if err != nil {
+		return digest.Digest{}, err
+	}
+
+	e.digest = sha.Digest()
+
+	return *e.digest, nil
+}
+
+// Name returns the name of the environment variable
+func (e *EnvVar) Name() string {
+	return e.name
+}
+
+// URI returns the name of the environment variable prefixed with "$"
+func (e *EnvVar) URI() string {
+	return "$" + e.name
+}
+
+// String returns it's string representation
+func (e *EnvVar) String() string {
+	return e.URI()
+}
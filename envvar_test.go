@@ -0,0 +1,69 @@
+package baur
+
+import (
+	"os"
+	"testing"
+
+	"github.com/simplesurance/baur/digest"
+)
+
+const envVarTestName = "BAUR_TEST_ENV_VAR_INPUT"
+
+// envVarDigest sets the environment variable envVarTestName to val or unsets
+// it if set is false and returns the digest of an EnvVar for it
+func envVarDigest(t *testing.T, val string, set bool) string {
+	t.Helper()
+
+	old, exists := os.LookupEnv(envVarTestName)
+	defer func() {
+		if exists {
+			os.Setenv(envVarTestName, old)
+			return
+		}
+
+		os.Unsetenv(envVarTestName)
+	}()
+
+	var err error
+	if set {
+		err = os.Setenv(envVarTestName, val)
+	} else {
+		err = os.Unsetenv(envVarTestName)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewEnvVar(envVarTestName, digest.SHA256).Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d.String()
+}
+
+func TestEnvVarUnsetAndEmptyDigestsDiffer(t *testing.T) {
+	unset := envVarDigest(t, "", false)
+	empty := envVarDigest(t, "", true)
+	value := envVarDigest(t, "value", true)
+
+	if unset == empty {
+		t.Errorf("digest of unset variable and variable set to an empty value are equal: %s", unset)
+	}
+
+	if empty == value {
+		t.Errorf("digest of variable set to an empty value and to \"value\" are equal: %s", empty)
+	}
+
+	if unset == value {
+		t.Errorf("digest of unset variable and variable set to \"value\" are equal: %s", unset)
+	}
+
+	if d := envVarDigest(t, "", false); d != unset {
+		t.Errorf("digests of unset variable differ: %s, %s", unset, d)
+	}
+
+	if d := envVarDigest(t, "", true); d != empty {
+		t.Errorf("digests of variable set to an empty value differ: %s, %s", empty, d)
+	}
+}
@@ -4,10 +4,16 @@ package exec
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 var (
@@ -28,15 +34,65 @@ func (e ExitCodeError) Error() string {
 		e.Command, e.Dir, e.ExitCode, e.Output)
 }
 
+// TimeoutError is returned from Run() when a command was killed because it
+// did not terminate within its timeout.
+type TimeoutError struct {
+	*Result
+	Timeout time.Duration
+}
+
+// Error returns the error description.
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("exec: running '%s' in directory '%s' timed out after %s, output: '%s'",
+		e.Command, e.Dir, e.Timeout, e.Output)
+}
+
+var (
+	procGroupsLock sync.Mutex
+	// procGroups contains the running commands that were started in their
+	// own process group
+	procGroups = map[*exec.Cmd]struct{}{}
+)
+
+// SignalProcessGroups sends sig to the process groups of all running
+// commands that were started in their own process group, these are commands
+// with a Timeout. Signals that are sent to baur, e.g. when pressing Ctrl-C,
+// do not reach them.
+// On Windows the command processes are killed instead.
+func SignalProcessGroups(sig os.Signal) {
+	procGroupsLock.Lock()
+	defer procGroupsLock.Unlock()
+
+	for cmd := range procGroups {
+		_ = signalProcessGroup(cmd, sig)
+	}
+}
+
+func addProcGroup(cmd *exec.Cmd) {
+	procGroupsLock.Lock()
+	procGroups[cmd] = struct{}{}
+	procGroupsLock.Unlock()
+}
+
+func removeProcGroup(cmd *exec.Cmd) {
+	procGroupsLock.Lock()
+	delete(procGroups, cmd)
+	procGroupsLock.Unlock()
+}
+
 // Cmd represents a command that can be run.
 type Cmd struct {
 	path string
 	args []string
 
 	dir           string
+	env           []string
 	debugfFn      func(format string, v ...interface{})
 	debugfPrefix  string
 	expectSuccess bool
+	timeout       time.Duration
+	outputWriter  io.Writer
+	outputLimit   int
 }
 
 // Command returns a new Cmd struct.
@@ -65,6 +121,14 @@ func (c *Cmd) Directory(dir string) *Cmd {
 	return c
 }
 
+// Env sets additional environment variables in the format KEY=VALUE for the
+// command. They are appended to the environment of the baur process, if a
+// variable is defined multiple times the last one is used.
+func (c *Cmd) Env(env []string) *Cmd {
+	c.env = env
+	return c
+}
+
 // DebugfFunc sets the debug function for the command. It accepts a
 // printf-style printf function and call it for every line that the command
 // prints to STDOUT and STDERR when it's run.
@@ -86,6 +150,33 @@ func (c *Cmd) ExpectSuccess() *Cmd {
 	return c
 }
 
+// Timeout sets the duration after which the command and all processes that
+// it started are killed. Run() returns a TimeoutError when it happens.
+// The command is run in its own process group, SignalProcessGroups must be
+// used to forward signals to it.
+// On Windows only the command process is killed.
+// A timeout of 0 disables it, it is the default.
+func (c *Cmd) Timeout(d time.Duration) *Cmd {
+	c.timeout = d
+	return c
+}
+
+// OutputWriter sets a writer to that every line that the command prints to
+// STDOUT and STDERR is written when it is read. Lines are written with a
+// single Write call. The lines are not passed to the debug function.
+func (c *Cmd) OutputWriter(w io.Writer) *Cmd {
+	c.outputWriter = w
+	return c
+}
+
+// OutputLimit sets the max. number of bytes of the output that are kept in
+// the Result. If the output is longer, only its last n bytes are kept.
+// A limit of 0 disables it, it is the default.
+func (c *Cmd) OutputLimit(n int) *Cmd {
+	c.outputLimit = n
+	return c
+}
+
 func cmdString(cmd *exec.Cmd) string {
 	// cmd.Args[0] contains the command name, cmd.Path the absolute command path,
 	// omit cmd.Args[0] from the string
@@ -138,6 +229,9 @@ func exitCodeFromErr(err error) (int, error) {
 func (c *Cmd) Run() (*Result, error) {
 	cmd := exec.Command(c.path, c.args...)
 	cmd.Dir = c.dir
+	if len(c.env) != 0 {
+		cmd.Env = append(os.Environ(), c.env...)
+	}
 
 	outReader, err := cmd.StdoutPipe()
 	if err != nil {
@@ -145,12 +239,38 @@ func (c *Cmd) Run() (*Result, error) {
 	}
 	cmd.Stderr = cmd.Stdout
 
+	if c.timeout > 0 {
+		// the command is run in it's own process group, to be able
+		// to kill the processes it started on timeout
+		setProcessGroup(cmd)
+	}
+
 	c.debugfFn(c.debugfPrefix+"running '%s' in directory '%s'", cmdString(cmd), cmd.Dir)
 	err = cmd.Start()
 	if err != nil {
 		return nil, err
 	}
 
+	var timedOut int32
+	if c.timeout > 0 {
+		addProcGroup(cmd)
+		defer removeProcGroup(cmd)
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), c.timeout)
+		defer cancelFn()
+
+		go func() {
+			<-ctx.Done()
+
+			if ctx.Err() != context.DeadlineExceeded {
+				return
+			}
+
+			atomic.StoreInt32(&timedOut, 1)
+			_ = killProcessGroup(cmd)
+		}()
+	}
+
 	var outBuf bytes.Buffer
 	firstline := true
 	in := bufio.NewScanner(outReader)
@@ -161,9 +281,21 @@ func (c *Cmd) Run() (*Result, error) {
 			outBuf.WriteRune('\n')
 		}
 
-		c.debugfFn(c.debugfPrefix + in.Text())
+		if c.outputWriter != nil {
+			line := make([]byte, 0, len(in.Bytes())+1)
+			line = append(append(line, in.Bytes()...), '\n')
+
+			// errors are ignored, the output is still captured
+			_, _ = c.outputWriter.Write(line)
+		} else {
+			c.debugfFn(c.debugfPrefix + in.Text())
+		}
 
 		outBuf.Write(in.Bytes())
+
+		if c.outputLimit > 0 && outBuf.Len() > c.outputLimit {
+			outBuf.Next(outBuf.Len() - c.outputLimit)
+		}
 	}
 
 	if err := in.Err(); err != nil {
@@ -174,6 +306,21 @@ func (c *Cmd) Run() (*Result, error) {
 
 	var exitCode int
 	waitErr := cmd.Wait()
+
+	if atomic.LoadInt32(&timedOut) == 1 {
+		c.debugfFn(c.debugfPrefix+"command was killed, it timed out after %s", c.timeout)
+
+		return nil, TimeoutError{
+			Result: &Result{
+				Command:  cmdString(cmd),
+				Dir:      cmd.Dir,
+				ExitCode: -1,
+				Output:   outBuf.Bytes(),
+			},
+			Timeout: c.timeout,
+		}
+	}
+
 	if exitCode, err = exitCodeFromErr(waitErr); err != nil {
 		return nil, err
 	}
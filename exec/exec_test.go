@@ -1,9 +1,13 @@
 package exec
 
 import (
+	"bytes"
 	"fmt"
+	"os"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestEchoStdout(t *testing.T) {
@@ -81,3 +85,155 @@ func TestShellLsGlob(t *testing.T) {
 		t.Errorf("expected >=2 lines of output")
 	}
 }
+
+func TestEnvOverridesProcessEnv(t *testing.T) {
+	const val = "from cmd env"
+
+	if err := os.Setenv("BAUR_TEST_VAR", "from process env"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("BAUR_TEST_VAR")
+
+	res, err := ShellCommand("echo -n \"$BAUR_TEST_VAR\"").
+		Env([]string{"BAUR_TEST_VAR=" + val}).
+		ExpectSuccess().
+		Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StrOutput() != val {
+		t.Errorf("expected output '%s', got '%s'", val, res.StrOutput())
+	}
+}
+
+func TestTimeoutKillsProcessGroup(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+
+	start := time.Now()
+
+	// the backgrounded sleep inherits stdout, Run() only returns when it
+	// was killed too
+	res, err := ShellCommand("echo started; sleep 30 & sleep 30").
+		Timeout(timeout).
+		Run()
+	if err == nil {
+		t.Fatalf("Run() did not return an error, result: %+v", res)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Run() returned after %s, expected the processes to be killed after %s", elapsed, timeout)
+	}
+
+	timeoutErr, ok := err.(TimeoutError)
+	if !ok {
+		t.Fatalf("Run() returned error of type %T, expected TimeoutError: %s", err, err)
+	}
+
+	if timeoutErr.StrOutput() != "started" {
+		t.Errorf("expected output 'started', got '%s'", timeoutErr.StrOutput())
+	}
+}
+
+func TestSignalProcessGroups(t *testing.T) {
+	type result struct {
+		res *Result
+		err error
+	}
+
+	resultCh := make(chan result, 1)
+
+	go func() {
+		res, err := ShellCommand("sleep 30 & sleep 30").Timeout(time.Minute).Run()
+		resultCh <- result{res: res, err: err}
+	}()
+
+	// wait until the command was started
+	for i := 0; ; i++ {
+		procGroupsLock.Lock()
+		cnt := len(procGroups)
+		procGroupsLock.Unlock()
+
+		if cnt == 1 {
+			break
+		}
+
+		if i == 1000 {
+			t.Fatal("command was not started within 10s")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	SignalProcessGroups(syscall.SIGTERM)
+
+	select {
+	case r := <-resultCh:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+
+		if r.res.ExitCode == 0 {
+			t.Error("command exited with code 0, expected it to be terminated")
+		}
+
+	case <-time.After(10 * time.Second):
+		t.Fatal("command did not terminate within 10s after sending SIGTERM to its process group")
+	}
+
+	procGroupsLock.Lock()
+	defer procGroupsLock.Unlock()
+
+	if len(procGroups) != 0 {
+		t.Errorf("%d process groups are still registered after the command terminated, expected 0", len(procGroups))
+	}
+}
+
+func TestCommandFinishesBeforeTimeout(t *testing.T) {
+	res, err := Command("true").Timeout(time.Minute).ExpectSuccess().Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ExitCode != 0 {
+		t.Errorf("cmd exited with code %d, expected 0", res.ExitCode)
+	}
+}
+
+func TestOutputWriterReceivesInterleavedOutput(t *testing.T) {
+	const expected = "out1\nerr1\nout2\nerr2\n"
+
+	var streamed bytes.Buffer
+
+	res, err := ShellCommand("echo out1; echo err1 >&2; echo out2; echo err2 >&2").
+		OutputWriter(&streamed).
+		ExpectSuccess().
+		Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if streamed.String() != expected {
+		t.Errorf("expected streamed output '%s', got '%s'", expected, streamed.String())
+	}
+
+	if res.StrOutput() != strings.TrimSuffix(expected, "\n") {
+		t.Errorf("expected output '%s', got '%s'", strings.TrimSuffix(expected, "\n"), res.StrOutput())
+	}
+}
+
+func TestOutputLimitKeepsTail(t *testing.T) {
+	res, err := ShellCommand("echo first; echo second; echo third").
+		OutputLimit(10).
+		ExpectSuccess().
+		Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = "cond\nthird"
+
+	if res.StrOutput() != expected {
+		t.Errorf("expected output %q, got %q", expected, res.StrOutput())
+	}
+}
@@ -0,0 +1,22 @@
+package exec
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing, process groups are not supported on
+// Windows
+func setProcessGroup(*exec.Cmd) {}
+
+// killProcessGroup kills the process of the started cmd, processes that it
+// started are not killed
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// signalProcessGroup kills the process of the started cmd, sending other
+// signals is not supported on Windows
+func signalProcessGroup(cmd *exec.Cmd, _ os.Signal) error {
+	return cmd.Process.Kill()
+}
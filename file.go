@@ -1,10 +1,12 @@
 package baur
 
 import (
+	"context"
 	"path/filepath"
 
+	"github.com/pkg/errors"
+
 	"github.com/simplesurance/baur/digest"
-	"github.com/simplesurance/baur/digest/sha384"
 )
 
 // File represent a file
@@ -12,15 +14,18 @@ type File struct {
 	repoRootPath string
 	relPath      string
 	absPath      string
+	algorithm    digest.Algorithm
 	digest       *digest.Digest
 }
 
-// NewFile returns a new file
-func NewFile(repoRootPath, relPath string) *File {
+// NewFile returns a new file, it's digest is calculated with the passed
+// algorithm
+func NewFile(repoRootPath, relPath string, algorithm digest.Algorithm) *File {
 	return &File{
 		repoRootPath: repoRootPath,
 		relPath:      relPath,
 		absPath:      filepath.Join(repoRootPath, relPath),
+		algorithm:    algorithm,
 	}
 }
 
@@ -30,9 +35,12 @@ func (f *File) Digest() (digest.Digest, error) {
 		return *f.digest, nil
 	}
 
-	sha := sha384.New()
+	sha, err := newInputHash(f.algorithm)
+	if err != nil {
+		return digest.Digest{}, err
+	}
 
-	err := sha.AddBytes([]byte(f.relPath))
+	err = sha.AddBytes([]byte(f.relPath))
 	if err != nil {
 		return digest.Digest{}, err
 	}
@@ -61,3 +69,17 @@ func (f *File) RepoRelPath() string {
 func (f *File) String() string {
 	return f.RepoRelPath()
 }
+
+// digestFiles calculates the digests of files with up to workers goroutines.
+// The digests are stored in the files and returned by following Digest()
+// calls. If calculating a digest fails or ctx is cancelled, the remaining
+// files are not processed and the first error is returned.
+func digestFiles(ctx context.Context, files []*File, workers int) error {
+	return forEachParallel(ctx, len(files), workers, func(_ context.Context, idx int) error {
+		if _, err := files[idx].Digest(); err != nil {
+			return errors.Wrapf(err, "calculating input digest of %q failed", files[idx])
+		}
+
+		return nil
+	})
+}
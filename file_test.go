@@ -0,0 +1,89 @@
+package baur
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+// createFiles creates cnt small files in dir and returns them
+func createFiles(t testing.TB, dir string, cnt int) []*File {
+	t.Helper()
+
+	files := make([]*File, 0, cnt)
+
+	for i := 0; i < cnt; i++ {
+		relPath := fmt.Sprintf("file%d", i)
+		fstest.WriteToFile(t, []byte(relPath), filepath.Join(dir, relPath))
+
+		files = append(files, NewFile(dir, relPath, digest.SHA256))
+	}
+
+	return files
+}
+
+func TestDigestFiles(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	files := createFiles(t, tempDir, 100)
+
+	if err := digestFiles(context.Background(), files, 4); err != nil {
+		t.Fatal("digestFiles failed:", err)
+	}
+
+	for _, f := range files {
+		if f.digest == nil {
+			t.Fatalf("digest of %s was not calculated", f)
+		}
+
+		expected, err := NewFile(tempDir, f.RepoRelPath(), digest.SHA256).Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f.digest.String() != expected.String() {
+			t.Errorf("digest of %s is %s, expected %s", f, f.digest, &expected)
+		}
+	}
+}
+
+func TestDigestFilesFailsForMissingFile(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	files := createFiles(t, tempDir, 10)
+	files = append(files, NewFile(tempDir, "missing", digest.SHA256))
+
+	if err := digestFiles(context.Background(), files, 4); err == nil {
+		t.Fatal("digestFiles succeeded for a non-existing file")
+	}
+}
+
+func BenchmarkDigestFiles(b *testing.B) {
+	const fileCnt = 5000
+
+	tempDir, cleanupFn := fstest.CreateTempDir(b)
+	defer cleanupFn()
+
+	createFiles(b, tempDir, fileCnt)
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				files := make([]*File, 0, fileCnt)
+				for j := 0; j < fileCnt; j++ {
+					files = append(files, NewFile(tempDir, fmt.Sprintf("file%d", j), digest.SHA256))
+				}
+
+				if err := digestFiles(context.Background(), files, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,9 @@
 package baur
 
 import (
+	"github.com/pkg/errors"
+
 	"github.com/simplesurance/baur/digest"
-	"github.com/simplesurance/baur/digest/sha384"
 	"github.com/simplesurance/baur/fs"
 	"github.com/simplesurance/baur/upload/scheduler"
 )
@@ -14,6 +15,12 @@ type FileArtifact struct {
 	DestFile  string
 	UploadURL string
 	uploadJob scheduler.Job
+	// destIsUnique is true if the upload destination contains a
+	// generated unique ID and differs for each App instance
+	destIsUnique bool
+
+	digest *digest.Digest
+	size   int64
 }
 
 // Exists returns true if the artifact exist
@@ -46,21 +53,46 @@ func (f *FileArtifact) UploadDestination() string {
 	return f.UploadURL
 }
 
-// Digest returns the file digest
+// Digest returns the digest of the file.
+// The digest and size are calculated on the first call of Digest() or Size()
+// and are cached. This ensures that the recorded values describe the file
+// as it was after the build, even if it is modified during the upload.
 func (f *FileArtifact) Digest() (*digest.Digest, error) {
-	sha := sha384.New()
-
-	err := sha.AddFile(f.LocalPath())
-	if err != nil {
+	if err := f.calcDigest(); err != nil {
 		return nil, err
 	}
 
-	return sha.Digest(), err
+	return f.digest, nil
 }
 
 // Size returns the size of the file in bytes
 func (f *FileArtifact) Size(_ *BuildOutputBackends) (int64, error) {
-	return fs.FileSize(f.LocalPath())
+	if err := f.calcDigest(); err != nil {
+		return -1, err
+	}
+
+	return f.size, nil
+}
+
+func (f *FileArtifact) calcDigest() error {
+	if f.digest != nil {
+		return nil
+	}
+
+	size, sum, err := fs.FileDigest(f.LocalPath(), digest.SHA384)
+	if err != nil {
+		return err
+	}
+
+	d, err := digest.FromString(digest.SHA384.String() + ":" + sum)
+	if err != nil {
+		return errors.Wrap(err, "converting checksum to digest failed")
+	}
+
+	f.digest = d
+	f.size = size
+
+	return nil
 }
 
 // Type returns "File"
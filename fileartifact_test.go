@@ -0,0 +1,55 @@
+package baur
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestFileArtifactDigestAndSizeAreCached(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	path := filepath.Join(tempDir, "app.tar")
+	fstest.WriteToFile(t, []byte("output"), path)
+
+	ar := &FileArtifact{RelPath: "app.tar", Path: path}
+
+	d1, err := ar.Digest()
+	if err != nil {
+		t.Fatal("calculating digest failed:", err)
+	}
+
+	size, err := ar.Size(nil)
+	if err != nil {
+		t.Fatal("getting size failed:", err)
+	}
+
+	if size != int64(len("output")) {
+		t.Errorf("size is %d, expected %d", size, len("output"))
+	}
+
+	if err := ioutil.WriteFile(path, []byte("modified output"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d2, err := ar.Digest()
+	if err != nil {
+		t.Fatal("calculating digest failed:", err)
+	}
+
+	if d1.String() != d2.String() {
+		t.Errorf("digest changed after file was modified, expected the cached digest %s, got %s", d1, d2)
+	}
+
+	d3, err := (&FileArtifact{RelPath: "app.tar", Path: path}).Digest()
+	if err != nil {
+		t.Fatal("calculating digest failed:", err)
+	}
+
+	if d3.String() == d1.String() {
+		t.Error("digest of the modified file is the same as the one of the original file")
+	}
+}
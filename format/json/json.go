@@ -0,0 +1,63 @@
+package json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Formatter converts Rows into a JSON array of objects. The columns of a row
+// are stored as object values with the key of the same index.
+type Formatter struct {
+	out  io.Writer
+	keys []string
+	rows []map[string]interface{}
+}
+
+// New returns a new JSON formatter, keys are the names of the object fields
+// for the row columns.
+func New(keys []string, out io.Writer) *Formatter {
+	return &Formatter{
+		out:  out,
+		keys: keys,
+		rows: []map[string]interface{}{},
+	}
+}
+
+// WriteRow adds a row to the buffer
+func (f *Formatter) WriteRow(row []interface{}) error {
+	if len(row) != len(f.keys) {
+		return fmt.Errorf("row has %d columns, expected %d", len(row), len(f.keys))
+	}
+
+	obj := make(map[string]interface{}, len(row))
+	for i, col := range row {
+		// types like baur.BuildStatus are represented by their string
+		// form instead of their underlying value
+		if s, ok := col.(fmt.Stringer); ok {
+			col = s.String()
+		}
+
+		obj[f.keys[i]] = col
+	}
+
+	f.rows = append(f.rows, obj)
+
+	return nil
+}
+
+// Flush writes all buffered rows as JSON array to the output
+func (f *Formatter) Flush() error {
+	if f.rows == nil {
+		return errors.New("formatter was already flushed")
+	}
+
+	enc := json.NewEncoder(f.out)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(f.rows)
+	f.rows = nil
+
+	return err
+}
@@ -7,11 +7,15 @@ import (
 	"text/tabwriter"
 )
 
+// truncationMark replaces the removed part of truncated values
+const truncationMark = "..."
+
 // Formatter converts Rows into an ASCII table format with space separated
 // columns
 type Formatter struct {
-	out       io.Writer
-	tabWriter *tabwriter.Writer
+	out            io.Writer
+	tabWriter      *tabwriter.Writer
+	maxColumnWidth int
 }
 
 // New returns a new tabwriter, if headers is not empty it's written as first
@@ -29,6 +33,32 @@ func New(headers []string, out io.Writer) *Formatter {
 	return &f
 }
 
+// WithMaxColumnWidth enables truncating values in rows that are longer than
+// width characters. The beginning of a truncated value is replaced by "...",
+// the end of paths is usually more meaningful. Values that contain ANSI
+// escape sequences are not truncated. A width of 0 disables truncation.
+func (f *Formatter) WithMaxColumnWidth(width int) *Formatter {
+	f.maxColumnWidth = width
+	return f
+}
+
+func (f *Formatter) truncate(s string) string {
+	if f.maxColumnWidth <= 0 || strings.Contains(s, "\x1b") {
+		return s
+	}
+
+	runes := []rune(s)
+	if len(runes) <= f.maxColumnWidth {
+		return s
+	}
+
+	if f.maxColumnWidth <= len(truncationMark) {
+		return string(runes[len(runes)-f.maxColumnWidth:])
+	}
+
+	return truncationMark + string(runes[len(runes)-f.maxColumnWidth+len(truncationMark):])
+}
+
 func (f *Formatter) writeHeader(headers []string) error {
 	header := strings.Join(headers, "\t")
 
@@ -42,7 +72,7 @@ func (f *Formatter) WriteRow(row []interface{}) error {
 	var rowStr string
 
 	for i, col := range row {
-		rowStr += fmt.Sprintf("%v", col)
+		rowStr += f.truncate(fmt.Sprintf("%v", col))
 
 		if i+1 < len(row) {
 			rowStr += "\t"
@@ -0,0 +1,19 @@
+// +build !windows
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIDOf returns the device and inode number of fi, ok is false if they
+// are not available.
+func fileIDOf(_ string, fi os.FileInfo) (id fileID, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
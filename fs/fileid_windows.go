@@ -0,0 +1,18 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileIDOf returns a fileID that contains path with all symlinks resolved,
+// Windows has no inode numbers. ok is false if the symlinks can not be
+// resolved.
+func fileIDOf(path string, _ os.FileInfo) (id fileID, ok bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, false
+	}
+
+	return fileID{path: resolved}, true
+}
@@ -2,13 +2,21 @@ package fs
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/digest"
 )
 
 // IsFile returns true if path is a file.
@@ -90,76 +98,286 @@ func SameFile(a, b string) (bool, error) {
 // If it reaches the root directory without finding the file it returns
 // os.ErrNotExist
 func FindFileInParentDirs(startPath, filename string) (string, error) {
-	searchDir := startPath
+	searchDir, err := filepath.Abs(startPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get absolute path of %v", startPath)
+	}
 
 	for {
-		p := path.Join(searchDir, filename)
+		p := filepath.Join(searchDir, filename)
 
 		_, err := os.Stat(p)
 		if err == nil {
-			abs, err := filepath.Abs(p)
-			if err != nil {
-				return "", errors.Wrapf(err,
-					"could not get absolute path of %v", p)
-			}
-
-			return abs, nil
+			return p, nil
 		}
 
 		if !os.IsNotExist(err) {
 			return "", err
 		}
 
-		// TODO: how to detect OS independent if reached the root dir
-		if searchDir == "/" {
+		parentDir := filepath.Dir(searchDir)
+		// the parent of the root directory is the root directory itself,
+		// this works for "/" and windows volume names
+		if parentDir == searchDir {
 			return "", os.ErrNotExist
 		}
 
-		searchDir = path.Join(searchDir, "..")
+		searchDir = parentDir
 	}
 }
 
+// SymlinkMode defines how symlinks to directories are handled when searching
+// in directories.
+type SymlinkMode int
+
+const (
+	// SymlinksSkip does not descend into directories that symlinks
+	// point to.
+	SymlinksSkip SymlinkMode = iota
+	// SymlinksFollow descends into directories that symlinks point to.
+	// Each directory is only searched once, this prevents endless loops
+	// and finding the same files via multiple paths.
+	SymlinksFollow
+)
+
 // FindFilesInSubDir returns all directories that contain filename that are in
 // searchDir. The function descends up to maxdepth levels of directories below
-// searchDir
-func FindFilesInSubDir(searchDir, filename string, maxdepth int) ([]string, error) {
-	var result []string
-	glob := ""
+// searchDir. symlinks defines if symlinks to directories are followed.
+func FindFilesInSubDir(searchDir, filename string, maxdepth int, symlinks SymlinkMode) ([]string, error) {
+	return FindFilesInSubDirFiltered(searchDir, filename, maxdepth, symlinks, nil)
+}
+
+// FindFilesInSubDirFiltered returns the same result as FindFilesInSubDir
+// but does not descend into directories for that skipDir returns true.
+// skipDir is called with the absolute paths of all directories below
+// searchDir, it can be nil. Directories that can not be read because of
+// missing permissions are skipped.
+func FindFilesInSubDirFiltered(searchDir, filename string, maxdepth int, symlinks SymlinkMode, skipDir func(dir string) (bool, error)) ([]string, error) {
+	absDir, err := filepath.Abs(searchDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get absolute path of %s", searchDir)
+	}
 
-	for i := 0; i <= maxdepth; i++ {
-		globPath := path.Join(searchDir, glob, filename)
+	fi, err := os.Stat(absDir)
+	if err != nil {
+		return nil, err
+	}
 
-		matches, err := filepath.Glob(globPath)
-		if err != nil {
-			return nil, err
+	f := fileFinder{
+		filename: filename,
+		symlinks: symlinks,
+		skipDir:  skipDir,
+		visited:  map[fileID]struct{}{},
+	}
+
+	f.markVisited(absDir, fi)
+
+	err = f.find(absDir, maxdepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.result, nil
+}
+
+// fileID identifies a file by it's device and inode number. On platforms
+// without inode numbers, path is the file path with symlinks resolved
+// instead.
+type fileID struct {
+	dev  uint64
+	ino  uint64
+	path string
+}
+
+type fileFinder struct {
+	filename string
+	symlinks SymlinkMode
+	skipDir  func(string) (bool, error)
+	// visited contains the directories that were searched, it's only
+	// used when symlinks are followed
+	visited map[fileID]struct{}
+	result  []string
+}
+
+// markVisited records the file at path in the visited set, it returns
+// false if it was already visited before.
+func (f *fileFinder) markVisited(path string, fi os.FileInfo) bool {
+	id, ok := fileIDOf(path, fi)
+	if !ok {
+		return true
+	}
+
+	if _, exist := f.visited[id]; exist {
+		return false
+	}
+
+	f.visited[id] = struct{}{}
+
+	return true
+}
+
+func (f *fileFinder) find(dir string, maxdepth int) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		if e.Name() == f.filename {
+			f.result = append(f.result, path)
+		}
+
+		if maxdepth == 0 {
+			continue
+		}
+
+		fi := e
+		if e.Mode()&os.ModeSymlink != 0 {
+			if f.symlinks != SymlinksFollow {
+				continue
+			}
+
+			fi, err = os.Stat(path)
+			if err != nil {
+				// dangling symlink
+				continue
+			}
+		}
+
+		if !fi.IsDir() {
+			continue
+		}
+
+		if f.symlinks == SymlinksFollow && !f.markVisited(path, fi) {
+			continue
 		}
 
-		for _, m := range matches {
-			abs, err := filepath.Abs(m)
+		if f.skipDir != nil {
+			skip, err := f.skipDir(path)
 			if err != nil {
-				return nil, errors.Wrapf(err, "could not get absolute path of %s", m)
+				return err
 			}
 
-			result = append(result, abs)
+			if skip {
+				continue
+			}
 		}
 
-		glob += "*/"
+		err = f.find(path, maxdepth-1)
+		if err != nil {
+			return err
+		}
 	}
 
-	return result, nil
+	return nil
 }
 
-// PathsJoin returns a list where all paths in relPaths are prefixed with
-// rootPath
-func PathsJoin(rootPath string, relPaths []string) []string {
+// AbsPaths returns a list where all paths in relPaths are joined with
+// rootPath and converted to absolute paths.
+func AbsPaths(rootPath string, relPaths []string) ([]string, error) {
 	absPaths := make([]string, 0, len(relPaths))
 
-	for _, d := range relPaths {
-		abs := path.Clean(path.Join(rootPath, d))
+	for _, p := range relPaths {
+		abs, err := filepath.Abs(filepath.Join(rootPath, p))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get absolute path of %s", p)
+		}
+
 		absPaths = append(absPaths, abs)
 	}
 
-	return absPaths
+	return absPaths, nil
+}
+
+// RelPathOrAbs returns path relative to baseDir if it is baseDir or is
+// located in one of it's subdirectories, otherwise the absolute path is
+// returned. Relative paths are interpreted as relative to baseDir.
+// The paths are compared lexically, symlinks are not resolved.
+func RelPathOrAbs(baseDir, path string) string {
+	baseDir = filepath.Clean(baseDir)
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	path = filepath.Clean(path)
+
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return rel
+}
+
+// IsInDir returns true if path is located in dir or one of its
+// subdirectories. Both paths are converted to absolute paths and symlinks in
+// the existing parent directories of path and in dir are resolved. The last
+// element of path is not resolved, a symlink in dir pointing outside of it
+// is in dir.
+func IsInDir(path, dir string) (bool, error) {
+	absDir, err := resolvePath(dir)
+	if err != nil {
+		return false, err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not get absolute path of %s", path)
+	}
+
+	parent, err := resolvePath(filepath.Dir(absPath))
+	if err != nil {
+		return false, err
+	}
+
+	absPath = filepath.Join(parent, filepath.Base(absPath))
+	if absPath == absDir {
+		return false, nil
+	}
+
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false, err
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// resolvePath returns the absolute path of p with all symlinks resolved. If p
+// does not exist, the symlinks of its longest existing parent directory are
+// resolved.
+func resolvePath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get absolute path of %s", p)
+	}
+
+	var missing []string
+
+	for {
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", err
+		}
+
+		missing = append([]string{filepath.Base(abs)}, missing...)
+		abs = parent
+	}
 }
 
 // FileReadLine reads the first line from a file
@@ -189,7 +407,144 @@ func FileSize(path string) (int64, error) {
 	return stat.Size(), nil
 }
 
+// FileSHA256 returns the lowercase hex encoded sha256 digest of the content
+// of the file at path.
+// The file is read in chunks, it is not read into memory at once.
+// If path is a symlink, the content of the file it points to is hashed.
+func FileSHA256(path string) (string, error) {
+	_, sum, err := FileDigest(path, digest.SHA256)
+	return sum, err
+}
+
+// FileDigest returns the size in bytes and the lowercase hex encoded digest,
+// calculated with the passed algorithm, of the file at path. Both are
+// determined while reading the file once.
+// If path is a symlink, the size and content of the file it points to are
+// returned.
+func FileDigest(path string, algorithm digest.Algorithm) (int64, string, error) {
+	var h hash.Hash
+
+	switch algorithm {
+	case digest.SHA256:
+		h = sha256.New()
+	case digest.SHA384:
+		h = sha512.New384()
+	default:
+		return -1, "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return -1, "", err
+	}
+	defer f.Close()
+
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return -1, "", errors.Wrapf(err, "reading %s failed", path)
+	}
+
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WalkFiles walks the directory tree rooted at root and calls walkFunc for
+// every file in it.
+// Symlinks to files are followed and passed to walkFunc with the path of the
+// symlink, symlinks to directories are not followed. walkFunc is not called
+// for directories.
+func WalkFiles(root string, walkFunc func(path string) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			isFile, err := IsFile(path)
+			if err != nil {
+				return errors.Wrapf(err, "resolving symlink %s failed", path)
+			}
+
+			if !isFile {
+				return nil
+			}
+
+			return walkFunc(path)
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		return walkFunc(path)
+	})
+}
+
 // Mkdir creates recursively directories
 func Mkdir(path string) error {
 	return os.MkdirAll(path, os.FileMode(0755))
 }
+
+// rename is os.Rename, it is replaced in tests
+var rename = os.Rename
+
+// WriteFileAtomic writes the content of r to dest.
+// The data is written to a temporary file in the directory of dest that is
+// renamed to dest afterwards. Readers of dest either see the previous or the
+// complete new file.
+// If dest is on a different device than its directory, e.g. because it is a
+// bind-mounted file, it can not be replaced atomically and an error is
+// returned.
+func WriteFileAtomic(dest string, r io.Reader, mode os.FileMode) (err error) {
+	dir, base := filepath.Split(dest)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file failed")
+	}
+
+	tmpPath := tmpFile.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if err = writeSync(tmpFile, r, mode); err != nil {
+		return errors.Wrapf(err, "writing %s failed", tmpPath)
+	}
+
+	err = rename(tmpPath, dest)
+	if err != nil {
+		if linkErr, ok := err.(*os.LinkError); ok && linkErr.Err == syscall.EXDEV {
+			return errors.Wrapf(err, "%s can not be replaced atomically, it is on a different device than its directory", dest)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// writeSync writes the content of r to f, sets the permissions of the file to
+// mode, syncs it and closes f
+func writeSync(f *os.File, r io.Reader, mode os.FileMode) error {
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,498 @@
+package fs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestFileDigest(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	const (
+		expectedDigest       = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+		expectedSHA384Digest = "fdbd8e75a67f29f701a4e040385e2e23986303ea10239211af907fcbb83578b3e417cb71ce646efd0819dd8c088de1bd"
+	)
+
+	path := filepath.Join(tempDir, "file")
+	fstest.WriteToFile(t, []byte("hello world"), path)
+
+	symlinkPath := filepath.Join(tempDir, "symlink")
+	if err := os.Symlink(path, symlinkPath); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{path, symlinkPath} {
+		size, sum, err := FileDigest(p, digest.SHA256)
+		if err != nil {
+			t.Fatalf("FileDigest(%q) failed: %s", p, err)
+		}
+
+		if size != 11 {
+			t.Errorf("FileDigest(%q) returned size %d, expected 11", p, size)
+		}
+
+		if sum != expectedDigest {
+			t.Errorf("FileDigest(%q) returned digest %q, expected %q", p, sum, expectedDigest)
+		}
+
+		_, sum, err = FileDigest(p, digest.SHA384)
+		if err != nil {
+			t.Fatalf("FileDigest(%q) failed: %s", p, err)
+		}
+
+		if sum != expectedSHA384Digest {
+			t.Errorf("FileDigest(%q) returned sha384 digest %q, expected %q", p, sum, expectedSHA384Digest)
+		}
+
+		sum, err = FileSHA256(p)
+		if err != nil {
+			t.Fatalf("FileSHA256(%q) failed: %s", p, err)
+		}
+
+		if sum != expectedDigest {
+			t.Errorf("FileSHA256(%q) returned digest %q, expected %q", p, sum, expectedDigest)
+		}
+	}
+}
+
+func TestFileDigestNonExistingFile(t *testing.T) {
+	_, _, err := FileDigest("/nonexisting/file", digest.SHA256)
+	if !os.IsNotExist(err) {
+		t.Errorf("FileDigest returned error %v, expected os.ErrNotExist", err)
+	}
+}
+
+func TestFindFileInParentDirs(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	startDir := filepath.Join(tempDir, "a", "b", "c")
+	if err := os.MkdirAll(startDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPath := filepath.Join(tempDir, "a", "marker")
+	fstest.WriteToFile(t, []byte("content"), expectedPath)
+
+	path, err := FindFileInParentDirs(startDir, "marker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != expectedPath {
+		t.Errorf("FindFileInParentDirs returned %q, expected %q", path, expectedPath)
+	}
+}
+
+func TestFindFileInParentDirsReturnsErrNotExistAtRoot(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	done := make(chan error)
+
+	go func() {
+		_, err := FindFileInParentDirs(tempDir, "baur-nonexisting-test-file-7a2d9f")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != os.ErrNotExist {
+			t.Errorf("FindFileInParentDirs returned %v, expected os.ErrNotExist", err)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("FindFileInParentDirs did not return after reaching the root directory")
+	}
+}
+
+func TestFindFileInParentDirsWithRelativeStartPath(t *testing.T) {
+	_, err := FindFileInParentDirs(".", "baur-nonexisting-test-file-7a2d9f")
+	if err != os.ErrNotExist {
+		t.Errorf("FindFileInParentDirs returned %v, expected os.ErrNotExist", err)
+	}
+}
+
+func TestFindFilesInSubDirFilteredSkipsDirs(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	for _, dir := range []string{"a", "a/b/c", "skip", "a/skip/c", "a/b/c/d"} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		fstest.WriteToFile(t, []byte(""), filepath.Join(tempDir, dir, "f"))
+	}
+
+	skipDir := func(dir string) (bool, error) {
+		return filepath.Base(dir) == "skip", nil
+	}
+
+	result, err := FindFilesInSubDirFiltered(tempDir, "f", 3, SymlinksSkip, skipDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(tempDir, "a", "b", "c", "f"),
+		filepath.Join(tempDir, "a", "f"),
+	}
+
+	sort.Strings(result)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("FindFilesInSubDirFiltered returned %v, expected %v", result, expected)
+	}
+}
+
+func TestFindFilesInSubDirSymlinks(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	searchDir := filepath.Join(tempDir, "search")
+	appDir := filepath.Join(tempDir, "outside", "app")
+
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(searchDir, "loop"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fstest.WriteToFile(t, []byte(""), filepath.Join(appDir, "f"))
+
+	if err := os.Symlink(appDir, filepath.Join(searchDir, "applink")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(searchDir, filepath.Join(searchDir, "loop", "parent")); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name     string
+		symlinks SymlinkMode
+		expected []string
+	}{
+		{
+			name:     "skip",
+			symlinks: SymlinksSkip,
+			expected: nil,
+		},
+		{
+			name:     "follow",
+			symlinks: SymlinksFollow,
+			expected: []string{filepath.Join(searchDir, "applink", "f")},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := FindFilesInSubDir(searchDir, "f", 100, tc.symlinks)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("FindFilesInSubDir returned %v, expected %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+// BenchmarkFindFilesInSubDir compares searching a tree containing a big
+// node_modules directory with and without pruning the directory.
+func BenchmarkFindFilesInSubDir(b *testing.B) {
+	tempDir, cleanupFn := fstest.CreateTempDir(b)
+	defer cleanupFn()
+
+	for i := 0; i < 10; i++ {
+		dir := filepath.Join(tempDir, fmt.Sprintf("app%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+
+		fstest.WriteToFile(b, []byte(""), filepath.Join(dir, ".app.toml"))
+	}
+
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			dir := filepath.Join(tempDir, "node_modules", fmt.Sprintf("pkg%d", i), fmt.Sprintf("lib%d", j), "dist")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	const depth = 4
+
+	b.Run("unfiltered", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := FindFilesInSubDir(tempDir, ".app.toml", depth, SymlinksSkip); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("filtered", func(b *testing.B) {
+		skipDir := func(dir string) (bool, error) {
+			return filepath.Base(dir) == "node_modules", nil
+		}
+
+		for i := 0; i < b.N; i++ {
+			if _, err := FindFilesInSubDirFiltered(tempDir, ".app.toml", depth, SymlinksSkip, skipDir); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestIsInDir(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	root := filepath.Join(tempDir, "repo")
+	outside := filepath.Join(tempDir, "outside")
+
+	for _, d := range []string{filepath.Join(root, "app"), outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink(outside, filepath.Join(root, "app", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{name: "file", path: filepath.Join(root, "app", "dist", "app.tar"), expected: true},
+		{name: "symlink_itself", path: filepath.Join(root, "app", "link"), expected: true},
+		{name: "dotdot_inside", path: filepath.Join(root, "app", "..", "other", "f"), expected: true},
+		{name: "root_itself", path: root, expected: false},
+		{name: "dotdot_traversal", path: root + "/app/../../outside/f", expected: false},
+		{name: "absolute_outside", path: "/etc/passwd", expected: false},
+		{name: "sibling_with_same_prefix", path: root + "2/f", expected: false},
+		{name: "through_symlinked_dir", path: filepath.Join(root, "app", "link", "f"), expected: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := IsInDir(tc.path, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res != tc.expected {
+				t.Errorf("IsInDir(%q, %q) returned %v, expected %v", tc.path, root, res, tc.expected)
+			}
+		})
+	}
+}
+
+func TestWalkFiles(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "sub", "subsub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fstest.WriteToFile(t, []byte("a"), filepath.Join(tempDir, "a"))
+	fstest.WriteToFile(t, []byte("b"), filepath.Join(tempDir, "sub", "b"))
+	fstest.WriteToFile(t, []byte("c"), filepath.Join(tempDir, "sub", "subsub", "c"))
+
+	if err := os.Symlink(filepath.Join(tempDir, "a"), filepath.Join(tempDir, "sub", "filelink")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(tempDir, "sub"), filepath.Join(tempDir, "dirlink")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(tempDir, "a"),
+		filepath.Join(tempDir, "sub", "b"),
+		filepath.Join(tempDir, "sub", "filelink"),
+		filepath.Join(tempDir, "sub", "subsub", "c"),
+	}
+
+	var result []string
+	err := WalkFiles(tempDir, func(path string) error {
+		result = append(result, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("WalkFiles returned %v, expected %v", result, expected)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	dest := filepath.Join(tempDir, "artifact")
+	fstest.WriteToFile(t, []byte("old"), dest)
+
+	err := WriteFileAtomic(dest, strings.NewReader("new"), 0640)
+	if err != nil {
+		t.Fatal("WriteFileAtomic failed:", err)
+	}
+
+	content, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "new" {
+		t.Errorf("file contains %q, expected %q", content, "new")
+	}
+
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("file has permissions %s, expected %s", fi.Mode().Perm(), os.FileMode(0640))
+	}
+
+	files, err := ioutil.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("directory contains %d files, expected 1, temporary file was not removed", len(files))
+	}
+}
+
+func TestWriteFileAtomicFailsForDestOnOtherDevice(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	dest := filepath.Join(tempDir, "artifact")
+	fstest.WriteToFile(t, []byte("old"), dest)
+
+	defer func() { rename = os.Rename }()
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+
+	err := WriteFileAtomic(dest, strings.NewReader("new"), 0640)
+	if err == nil {
+		t.Fatal("WriteFileAtomic succeeded, expected an error")
+	}
+
+	content, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "old" {
+		t.Errorf("file contains %q, expected it to be unchanged", content)
+	}
+
+	files, err := ioutil.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("directory contains %d files, expected 1, temporary file was not removed", len(files))
+	}
+}
+
+func TestStatCache(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	path := filepath.Join(tempDir, "file")
+	fstest.WriteToFile(t, []byte("hello world"), path)
+
+	cache := NewStatCache()
+	var uncached *StatCache
+
+	for _, c := range []*StatCache{cache, uncached} {
+		if !c.FileExists(path) {
+			t.Fatalf("FileExists(%q) returned false for an existing file", path)
+		}
+
+		if isDir, err := c.IsDir(tempDir); err != nil || !isDir {
+			t.Fatalf("IsDir(%q) returned %v, %v, expected true, nil", tempDir, isDir, err)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if !cache.FileExists(path) {
+		t.Error("FileExists() returned false for a removed file, expected the cached result")
+	}
+
+	if uncached.FileExists(path) {
+		t.Error("FileExists() of a nil StatCache returned true for a removed file")
+	}
+
+	missingPath := filepath.Join(tempDir, "missing")
+
+	if _, err := cache.Stat(missingPath); !os.IsNotExist(err) {
+		t.Fatalf("Stat(%q) returned %v, expected a not exist error", missingPath, err)
+	}
+
+	fstest.WriteToFile(t, []byte("hello world"), missingPath)
+
+	if _, err := cache.Stat(missingPath); !os.IsNotExist(err) {
+		t.Errorf("Stat(%q) returned %v, expected the cached not exist error", missingPath, err)
+	}
+}
+
+func TestRelPathOrAbs(t *testing.T) {
+	const root = "/home/user/repo"
+
+	testcases := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{name: "file_in_subdir", path: "/home/user/repo/app/main.go", expected: "app/main.go"},
+		{name: "root_itself", path: "/home/user/repo", expected: "."},
+		{name: "relative", path: "app/../lib/lib.go", expected: "lib/lib.go"},
+		{name: "dotdot_inside", path: "/home/user/repo/app/../lib", expected: "lib"},
+		{name: "outside", path: "/usr/lib/go/src/fmt/print.go", expected: "/usr/lib/go/src/fmt/print.go"},
+		{name: "parent", path: "/home/user", expected: "/home/user"},
+		{name: "relative_outside", path: "../other/f", expected: "/home/user/other/f"},
+		{name: "sibling_with_same_prefix", path: "/home/user/repo2/f", expected: "/home/user/repo2/f"},
+		{name: "dir_starting_with_dots", path: "/home/user/repo/..cache/f", expected: "..cache/f"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := RelPathOrAbs(root, tc.path)
+			if res != tc.expected {
+				t.Errorf("RelPathOrAbs(%q, %q) returned %q, expected %q", root, tc.path, res, tc.expected)
+			}
+		})
+	}
+}
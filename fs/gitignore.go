@@ -0,0 +1,290 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GitIgnoreFile is the name of the files containing gitignore patterns
+const GitIgnoreFile = ".gitignore"
+
+type gitIgnorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// GitIgnoreMatcher matches paths against the patterns in .gitignore files of
+// a directory tree.
+// The .gitignore files are read when they are needed to match a path and are
+// cached.
+// The syntax is described in https://git-scm.com/docs/gitignore, escaping
+// trailing spaces is not supported.
+type GitIgnoreMatcher struct {
+	root string
+	// patterns contains the parsed .gitignore files by absolute directory
+	// paths, a nil slice is stored for directories without a .gitignore file
+	patterns map[string][]*gitIgnorePattern
+}
+
+// NewGitIgnoreMatcher returns a matcher for paths in the root directory.
+func NewGitIgnoreMatcher(root string) (*GitIgnoreMatcher, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitIgnoreMatcher{
+		root:     absRoot,
+		patterns: map[string][]*gitIgnorePattern{},
+	}, nil
+}
+
+// Match returns true if path or one of it's parent directories is ignored by
+// the patterns in the .gitignore files in the root directory or the
+// directories between root and path.
+// path must be an absolute path or relative to root.
+func (m *GitIgnoreMatcher) Match(path string) (bool, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.root, path)
+	}
+
+	relPath, err := filepath.Rel(m.root, path)
+	if err != nil {
+		return false, err
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if strings.HasPrefix(relPath, "../") {
+		return false, fmt.Errorf("%s is not in %s", path, m.root)
+	}
+
+	elems := strings.Split(relPath, "/")
+
+	// a file can not be re-included if a parent directory is excluded,
+	// therefore the parent directories are checked first
+	for i := 1; i <= len(elems); i++ {
+		isDir := i < len(elems)
+
+		ignored, err := m.matchElem(elems[:i], isDir)
+		if err != nil {
+			return false, err
+		}
+
+		if ignored {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchElem checks if the path consisting of elems is ignored by the
+// patterns in the .gitignore files of the parent directories.
+// Patterns from .gitignore files in deeper directories and later lines have a
+// higher precedence.
+func (m *GitIgnoreMatcher) matchElem(elems []string, isDir bool) (bool, error) {
+	var ignored bool
+
+	dir := m.root
+	for i := 0; i < len(elems); i++ {
+		patterns, err := m.load(dir)
+		if err != nil {
+			return false, err
+		}
+
+		relPath := strings.Join(elems[i:], "/")
+		for _, p := range patterns {
+			if p.dirOnly && !isDir {
+				continue
+			}
+
+			if p.re.MatchString(relPath) {
+				ignored = !p.negate
+			}
+		}
+
+		dir = filepath.Join(dir, elems[i])
+	}
+
+	return ignored, nil
+}
+
+func (m *GitIgnoreMatcher) load(dir string) ([]*gitIgnorePattern, error) {
+	if patterns, exist := m.patterns[dir]; exist {
+		return patterns, nil
+	}
+
+	path := filepath.Join(dir, GitIgnoreFile)
+
+	patterns, err := parseGitIgnoreFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "reading %s failed", path)
+	}
+
+	m.patterns[dir] = patterns
+
+	return patterns, nil
+}
+
+func parseGitIgnoreFile(path string) ([]*gitIgnorePattern, error) {
+	var result []*gitIgnorePattern
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		p, err := parseGitIgnorePattern(sc.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		if p != nil {
+			result = append(result, p)
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// parseGitIgnorePattern parses a line of a .gitignore file. For blank and
+// comment lines nil is returned.
+func parseGitIgnorePattern(line string) (*gitIgnorePattern, error) {
+	var p gitIgnorePattern
+
+	line = trimTrailingSpaces(strings.TrimSuffix(line, "\r"))
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	// patterns containing a slash are relative to the directory of the
+	// .gitignore file, others can match in any subdirectory
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	reStr := gitIgnoreGlobToRegex(line)
+	if !anchored {
+		reStr = "(.*/)?" + reStr
+	}
+
+	re, err := regexp.Compile("^" + reStr + "$")
+	if err != nil {
+		return nil, errors.Wrapf(err, "converting pattern %q to regex failed", line)
+	}
+
+	p.re = re
+
+	return &p, nil
+}
+
+// trimTrailingSpaces removes trailing spaces from line that are not escaped
+// with a backslash, like git does. Other whitespace characters are kept.
+func trimTrailingSpaces(line string) string {
+	end := 0
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+		case '\\':
+			// the escaped character is kept, even if it is a space
+			i++
+			end = i + 1
+		default:
+			end = i + 1
+		}
+	}
+
+	if end > len(line) {
+		end = len(line)
+	}
+
+	return line[:end]
+}
+
+// gitIgnoreGlobToRegex converts a gitignore glob pattern to a regular
+// expression.
+// The pattern is processed byte-wise, literal bytes are written as slices of
+// glob to keep multi-byte UTF-8 characters intact.
+func gitIgnoreGlobToRegex(glob string) string {
+	var re strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/') {
+				re.WriteString("(.*/)?")
+				i += 2
+				continue
+			}
+
+			if strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/') {
+				re.WriteString(".*")
+				i++
+				continue
+			}
+
+			re.WriteString("[^/]*")
+
+		case '?':
+			re.WriteString("[^/]")
+
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			re.WriteString("[" + class + "]")
+			i += end + 1
+
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	return re.String()
+}
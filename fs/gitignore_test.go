@@ -0,0 +1,170 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestGitIgnoreMatcher(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	fstest.WriteToFile(t, []byte(`
+# comment
+*.log
+!important.log
+/build/
+tmp
+docs/**/*.html
+`), filepath.Join(tempDir, GitIgnoreFile))
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "sub", "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fstest.WriteToFile(t, []byte(`
+!debug.log
+*.o
+!keep.o
+`), filepath.Join(tempDir, "sub", GitIgnoreFile))
+
+	fstest.WriteToFile(t, []byte(`
+keep.o
+`), filepath.Join(tempDir, "sub", "nested", GitIgnoreFile))
+
+	testcases := []struct {
+		path    string
+		ignored bool
+	}{
+		{path: "main.c", ignored: false},
+		{path: "app.log", ignored: true},
+		{path: "important.log", ignored: false},
+		{path: "a/b/app.log", ignored: true},
+		{path: "build/out.bin", ignored: true},
+		{path: "sub/build/out.bin", ignored: false},
+		{path: "build", ignored: false},
+		{path: "tmp", ignored: true},
+		{path: "sub/tmp/file", ignored: true},
+		{path: "docs/index.html", ignored: true},
+		{path: "docs/a/b/index.html", ignored: true},
+		{path: "sub/docs/index.html", ignored: false},
+		{path: "sub/debug.log", ignored: false},
+		{path: "sub/other.log", ignored: true},
+		{path: "sub/main.o", ignored: true},
+		{path: "sub/keep.o", ignored: false},
+		{path: "sub/nested/main.o", ignored: true},
+		{path: "sub/nested/keep.o", ignored: true},
+		{path: filepath.Join(tempDir, "sub", "keep.o"), ignored: false},
+	}
+
+	m, err := NewGitIgnoreMatcher(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			ignored, err := m.Match(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ignored != tc.ignored {
+				t.Errorf("Match(%q) returned %v, expected %v", tc.path, ignored, tc.ignored)
+			}
+		})
+	}
+}
+
+func TestGitIgnoreMatcherNonASCIIAndWhitespace(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	fstest.WriteToFile(t, []byte(
+		"résumé.txt\n"+
+			"日本/\n"+
+			"*.größe\n"+
+			"[é]tude\n"+
+			"trailing   \n"+
+			"escaped\\ \n"+
+			"tab\t\n"+
+			"crlf\r\n",
+	), filepath.Join(tempDir, GitIgnoreFile))
+
+	testcases := []struct {
+		path    string
+		ignored bool
+	}{
+		{path: "résumé.txt", ignored: true},
+		{path: "sub/résumé.txt", ignored: true},
+		{path: "resume.txt", ignored: false},
+		{path: "日本/file", ignored: true},
+		{path: "日本", ignored: false},
+		{path: "file.größe", ignored: true},
+		{path: "file.grosse", ignored: false},
+		{path: "étude", ignored: true},
+		{path: "trailing", ignored: true},
+		{path: "trailing ", ignored: false},
+		{path: "escaped ", ignored: true},
+		{path: "escaped", ignored: false},
+		{path: "tab\t", ignored: true},
+		{path: "tab", ignored: false},
+		{path: "crlf", ignored: true},
+	}
+
+	m, err := NewGitIgnoreMatcher(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			ignored, err := m.Match(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ignored != tc.ignored {
+				t.Errorf("Match(%q) returned %v, expected %v", tc.path, ignored, tc.ignored)
+			}
+		})
+	}
+}
+
+func TestGitIgnoreMatcherFileInIgnoredDirCanNotBeReincluded(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	fstest.WriteToFile(t, []byte("vendor/\n!vendor/keep.go\n"), filepath.Join(tempDir, GitIgnoreFile))
+
+	m, err := NewGitIgnoreMatcher(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ignored, err := m.Match("vendor/keep.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ignored {
+		t.Error("file in ignored directory was re-included by negated pattern")
+	}
+}
+
+func TestGitIgnoreMatcherFailsForPathOutsideRoot(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	m, err := NewGitIgnoreMatcher(filepath.Join(tempDir, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Match(filepath.Join(tempDir, "file")); err == nil {
+		t.Error("Match() succeeded for a path outside of the root directory")
+	}
+}
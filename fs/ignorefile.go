@@ -0,0 +1,66 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// IgnoreFileMatcher matches directories against the gitignore patterns of a
+// single file. The patterns are relative to the directory containing the
+// file, patterns from .gitignore files in subdirectories are not considered.
+type IgnoreFileMatcher struct {
+	root     string
+	patterns []*gitIgnorePattern
+}
+
+// NewIgnoreFileMatcher reads the gitignore patterns from the file at path and
+// returns a matcher for them.
+func NewIgnoreFileMatcher(path string) (*IgnoreFileMatcher, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := parseGitIgnoreFile(absPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s failed", absPath)
+	}
+
+	return &IgnoreFileMatcher{
+		root:     filepath.Dir(absPath),
+		patterns: patterns,
+	}, nil
+}
+
+// MatchDir returns true if the directory is ignored by the patterns.
+// Only dir itself is matched, it's parent directories are not checked.
+// dir must be an absolute path or relative to the directory of the ignore
+// file.
+func (m *IgnoreFileMatcher) MatchDir(dir string) (bool, error) {
+	var ignored bool
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(m.root, dir)
+	}
+
+	relPath, err := filepath.Rel(m.root, dir)
+	if err != nil {
+		return false, err
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return false, fmt.Errorf("%s is not in %s", dir, m.root)
+	}
+
+	for _, p := range m.patterns {
+		if p.re.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+
+	return ignored, nil
+}
@@ -0,0 +1,54 @@
+package fs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestIgnoreFileMatcher(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	ignoreFile := filepath.Join(tempDir, ".ignore")
+	fstest.WriteToFile(t, []byte(`
+# comment
+node_modules/
+/vendor
+third_party/*/
+!third_party/keep
+`), ignoreFile)
+
+	testcases := []struct {
+		path    string
+		ignored bool
+	}{
+		{path: "src", ignored: false},
+		{path: "node_modules", ignored: true},
+		{path: "web/node_modules", ignored: true},
+		{path: "vendor", ignored: true},
+		{path: "sub/vendor", ignored: false},
+		{path: "third_party/lib", ignored: true},
+		{path: "third_party/keep", ignored: false},
+		{path: filepath.Join(tempDir, "node_modules"), ignored: true},
+	}
+
+	m, err := NewIgnoreFileMatcher(ignoreFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			ignored, err := m.MatchDir(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ignored != tc.ignored {
+				t.Errorf("MatchDir(%q) returned %v, expected %v", tc.path, ignored, tc.ignored)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"os"
+	"sync"
+)
+
+// StatCache memoizes the results of os.Stat calls.
+// A StatCache is meant to be used for the duration of a single baur
+// invocation. Changes to a path after it was stat'ed the first time are not
+// noticed, therefore the same StatCache must not be used to check paths that
+// are expected to change, like build outputs, or be reused across
+// invocations.
+// A nil *StatCache is valid and does not cache results.
+// StatCache is safe for concurrent use.
+type StatCache struct {
+	lock    sync.Mutex
+	entries map[string]*statResult
+}
+
+type statResult struct {
+	fi  os.FileInfo
+	err error
+}
+
+// NewStatCache returns an empty StatCache.
+func NewStatCache() *StatCache {
+	return &StatCache{entries: map[string]*statResult{}}
+}
+
+// Stat returns the result of os.Stat for path. The first result for a path,
+// including errors, is returned for all following calls.
+func (c *StatCache) Stat(path string) (os.FileInfo, error) {
+	if c == nil {
+		return os.Stat(path)
+	}
+
+	c.lock.Lock()
+	res, exist := c.entries[path]
+	c.lock.Unlock()
+
+	if exist {
+		return res.fi, res.err
+	}
+
+	fi, err := os.Stat(path)
+
+	c.lock.Lock()
+	c.entries[path] = &statResult{fi: fi, err: err}
+	c.lock.Unlock()
+
+	return fi, err
+}
+
+// IsDir returns true if the path is a directory.
+// If the directory does not exist, the error from os.Stat() is returned.
+func (c *StatCache) IsDir(path string) (bool, error) {
+	fi, err := c.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	return fi.IsDir(), nil
+}
+
+// IsFile returns true if path is a file.
+// If the path does not exist an error is returned
+func (c *StatCache) IsFile(path string) (bool, error) {
+	fi, err := c.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	return !fi.IsDir(), nil
+}
+
+// FileExists returns true if path exist and is a file
+func (c *StatCache) FileExists(path string) bool {
+	ret, _ := c.IsFile(path)
+
+	return ret
+}
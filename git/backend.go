@@ -0,0 +1,51 @@
+package git
+
+import (
+	"fmt"
+	osexec "os/exec"
+	"strings"
+)
+
+// Backend is the implementation that is used to list files tracked in a git
+// repository
+type Backend int
+
+const (
+	// BackendBinary runs the git executable
+	BackendBinary Backend = iota
+	// BackendBuiltin reads the git index directly, it does not require
+	// git to be installed
+	BackendBuiltin
+)
+
+// String returns the textual representation
+func (b Backend) String() string {
+	switch b {
+	case BackendBinary:
+		return "binary"
+	case BackendBuiltin:
+		return "builtin"
+	default:
+		return "undefined"
+	}
+}
+
+// BackendFromString returns the Backend with the given name, the name is
+// case-insensitive.
+func BackendFromString(name string) (Backend, error) {
+	switch strings.ToLower(name) {
+	case "binary":
+		return BackendBinary, nil
+	case "builtin":
+		return BackendBuiltin, nil
+	default:
+		return 0, fmt.Errorf("unsupported git backend %q", name)
+	}
+}
+
+// BinaryAvailable returns true if the git executable is found in one of the
+// directories in $PATH.
+func BinaryAvailable() bool {
+	_, err := osexec.LookPath("git")
+	return err == nil
+}
@@ -13,6 +13,8 @@ import (
 
 var gitLsPathSpecErrRe = regexp.MustCompile(`pathspec ('.+') did not match any file\(s\) known to git`)
 
+const gitNotARepositoryErrMsg = "not a git repository"
+
 // CommitID return the commit id of HEAD by running git rev-parse in the passed
 // directory
 func CommitID(dir string) (string, error) {
@@ -32,6 +34,7 @@ func CommitID(dir string) (string, error) {
 // LsFiles runs git ls-files in dir, passes args as argument and returns the
 // output
 // If no files match, ErrNotExist is returned
+// If dir is not part of a git repository, an error stating it is returned.
 func LsFiles(dir string, arg ...string) (string, error) {
 	args := append([]string{"-c", "core.quotepath=off", "ls-files", "error-unmatch"}, arg...)
 
@@ -41,6 +44,10 @@ func LsFiles(dir string, arg ...string) (string, error) {
 	}
 
 	if res.ExitCode != 0 {
+		if bytes.Contains(res.Output, []byte(gitNotARepositoryErrMsg)) {
+			return "", errors.Errorf("%s is not part of a git repository", dir)
+		}
+
 		var errMsgs []string
 
 		scanner := bufio.NewScanner(bytes.NewReader(res.Output))
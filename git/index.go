@@ -0,0 +1,317 @@
+package git
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	indexSignature = "DIRC"
+	indexHeaderLen = 12
+	// indexEntryStatLen is the length of the ctime, mtime, dev, ino,
+	// mode, uid, gid and size fields of an index entry
+	indexEntryStatLen   = 40
+	indexFlagExtended   = 0x4000
+	indexFlagNameMask   = 0xfff
+	sha1HashLen         = 20
+	sha256HashLen       = 32
+	indexExtSplitIndex  = "link"
+	indexExtSparseIndex = "sdir"
+)
+
+var objectFormatSHA256Re = regexp.MustCompile(`(?im)^\s*objectformat\s*=\s*sha256\s*$`)
+
+// IndexLsFiles returns the paths of the files in the index of the git
+// repository that match the pathspecs. It is an implementation of
+// git ls-files that does not require the git executable.
+// Pathspecs and the returned paths are relative to dir. If no pathspec is
+// passed, all files in dir and its subdirectories are returned.
+// Pathspecs support the same wildcards as git ls-files, pathspec magic
+// (e.g. ':(glob)') is not supported.
+func IndexLsFiles(dir string, pathspecs ...string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, gitDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := filepath.Rel(worktree, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := newPathspecs(filepath.ToSlash(prefix), pathspecs)
+	if err != nil {
+		return nil, err
+	}
+
+	hashLen, err := hashLength(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := readIndex(filepath.Join(gitDir, "index"), hashLen)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, e := range entries {
+		if !specs.match(e) {
+			continue
+		}
+
+		relPath, err := filepath.Rel(dir, filepath.Join(worktree, filepath.FromSlash(e)))
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, relPath)
+	}
+
+	return res, nil
+}
+
+// findGitDir searches for the git repository that dir is part of. It returns
+// the root directory of the worktree and the path of the git directory.
+// Like git, the search stops at directories listed in the
+// GIT_CEILING_DIRECTORIES environment variable.
+func findGitDir(dir string) (worktree, gitDir string, err error) {
+	ceilingDirs := map[string]struct{}{}
+	for _, d := range filepath.SplitList(os.Getenv("GIT_CEILING_DIRECTORIES")) {
+		if d != "" {
+			ceilingDirs[filepath.Clean(d)] = struct{}{}
+		}
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, isCeiling := ceilingDirs[d]; isCeiling {
+			break
+		}
+
+		gitPath := filepath.Join(d, ".git")
+
+		fi, err := os.Stat(gitPath)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", "", err
+			}
+
+			if d == filepath.Dir(d) {
+				break
+			}
+
+			continue
+		}
+
+		if fi.IsDir() {
+			return d, gitPath, nil
+		}
+
+		gitDir, err := readGitFile(gitPath)
+		if err != nil {
+			return "", "", err
+		}
+
+		return d, gitDir, nil
+	}
+
+	return "", "", errors.Errorf("%s is not part of a git repository", dir)
+}
+
+// readGitFile returns the path of the git directory that a .git file, as
+// created for worktrees and submodules, refers to.
+func readGitFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	const prefix = "gitdir:"
+
+	line := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(line, prefix) {
+		return "", fmt.Errorf("%s has an invalid format, expected it to start with %q", path, prefix)
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, prefix))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+
+	return gitDir, nil
+}
+
+// hashLength returns the length of object ids in the git repository
+func hashLength(gitDir string) (int, error) {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, "config"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sha1HashLen, nil
+		}
+
+		return 0, err
+	}
+
+	if objectFormatSHA256Re.Match(content) {
+		return sha256HashLen, nil
+	}
+
+	return sha1HashLen, nil
+}
+
+// readIndex returns the paths of the entries in the git index file, in the
+// order they are stored. Versions 2, 3 and 4 of the index format are
+// supported.
+// Paths of files with merge conflicts are returned once per stage.
+func readIndex(path string, hashLen int) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if len(data) < indexHeaderLen || string(data[:4]) != indexSignature {
+		return nil, fmt.Errorf("%s is not a git index file", path)
+	}
+
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("%s: unsupported git index version %d", path, version)
+	}
+
+	cnt := binary.BigEndian.Uint32(data[8:12])
+	entries := make([]string, 0, cnt)
+	off := indexHeaderLen
+	var prevName string
+
+	for i := uint32(0); i < cnt; i++ {
+		start := off
+
+		off += indexEntryStatLen + hashLen
+		if off+2 > len(data) {
+			return nil, fmt.Errorf("%s: entry %d is truncated", path, i)
+		}
+
+		flags := binary.BigEndian.Uint16(data[off : off+2])
+		off += 2
+
+		if version >= 3 && flags&indexFlagExtended != 0 {
+			off += 2
+		}
+
+		if off > len(data) {
+			return nil, fmt.Errorf("%s: entry %d is truncated", path, i)
+		}
+
+		var name string
+
+		if version == 4 {
+			stripLen, n := decodeIndexVarint(data[off:])
+			if n == 0 || stripLen > len(prevName) {
+				return nil, fmt.Errorf("%s: entry %d has an invalid path prefix length", path, i)
+			}
+			off += n
+
+			end := bytes.IndexByte(data[off:], 0)
+			if end == -1 {
+				return nil, fmt.Errorf("%s: entry %d is truncated", path, i)
+			}
+
+			name = prevName[:len(prevName)-stripLen] + string(data[off:off+end])
+			off += end + 1
+		} else {
+			nameLen := int(flags & indexFlagNameMask)
+			if nameLen == indexFlagNameMask {
+				nameLen = bytes.IndexByte(data[off:], 0)
+			}
+
+			if nameLen < 0 || off+nameLen > len(data) {
+				return nil, fmt.Errorf("%s: entry %d is truncated", path, i)
+			}
+
+			name = string(data[off : off+nameLen])
+			// entries are padded with 1-8 NUL bytes to a multiple of
+			// 8 bytes
+			off = start + ((off - start + nameLen + 8) &^ 7)
+		}
+
+		entries = append(entries, name)
+		prevName = name
+	}
+
+	if off > len(data) {
+		return nil, fmt.Errorf("%s: last entry is truncated", path)
+	}
+
+	if err := checkIndexExtensions(data[off:], hashLen); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	return entries, nil
+}
+
+// checkIndexExtensions returns an error if the index contains extensions
+// that change the meaning of the entries and are not supported.
+func checkIndexExtensions(data []byte, hashLen int) error {
+	for len(data) >= hashLen+8 {
+		sig := string(data[:4])
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+
+		switch sig {
+		case indexExtSplitIndex:
+			return errors.New("split index files are not supported")
+		case indexExtSparseIndex:
+			return errors.New("sparse index files are not supported")
+		}
+
+		if size > len(data)-8 {
+			return fmt.Errorf("extension %q is truncated", sig)
+		}
+
+		data = data[8+size:]
+	}
+
+	return nil
+}
+
+// decodeIndexVarint decodes the variable length integer that is used in
+// version 4 index files to store the length of the path prefix that is
+// removed from the previous entry. It returns the value and the number of
+// bytes read, if the data is incomplete 0 bytes are returned.
+func decodeIndexVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+
+	c := data[0]
+	val := int(c & 0x7f)
+	n := 1
+
+	for c&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+
+		c = data[n]
+		n++
+		val = ((val + 1) << 7) | int(c&0x7f)
+	}
+
+	return val, n
+}
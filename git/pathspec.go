@@ -0,0 +1,280 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// globSpecialChars are the characters that make a pathspec a wildcard
+// pattern
+const globSpecialChars = `*?[\`
+
+// pathspec is a pathspec that is relative to the root of the worktree
+type pathspec struct {
+	match string
+	// noWildcardLen is the length of the prefix of match that does
+	// not contain wildcards
+	noWildcardLen int
+}
+
+type pathspecs []*pathspec
+
+// newPathspecs converts specs that are relative to the directory prefix to
+// pathspecs relative to the root of the worktree.
+// If specs is empty, the returned pathspecs match all files in prefix.
+func newPathspecs(prefix string, specs []string) (pathspecs, error) {
+	if len(specs) == 0 {
+		specs = []string{"."}
+	}
+
+	res := make(pathspecs, 0, len(specs))
+
+	for _, s := range specs {
+		if s == "" {
+			return nil, errors.New("empty string is not a valid pathspec")
+		}
+
+		match := path.Join(prefix, s)
+
+		switch {
+		case match == ".":
+			match = ""
+		case match == ".." || strings.HasPrefix(match, "../"):
+			return nil, fmt.Errorf("pathspec %q is outside of the repository", s)
+		case strings.HasSuffix(s, "/"):
+			match += "/"
+		}
+
+		noWildcardLen := strings.IndexAny(match, globSpecialChars)
+		if noWildcardLen == -1 {
+			noWildcardLen = len(match)
+		}
+
+		res = append(res, &pathspec{match: match, noWildcardLen: noWildcardLen})
+	}
+
+	return res, nil
+}
+
+// match returns true if one of the pathspecs matches the path
+func (p pathspecs) match(path string) bool {
+	for _, s := range p {
+		if s.matches(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches returns true if the pathspec matches path literally, path is in the
+// directory the pathspec refers to or the pathspec is a wildcard pattern that
+// matches path.
+func (s *pathspec) matches(path string) bool {
+	if s.match == "" {
+		return true
+	}
+
+	l := len(s.match)
+	if l <= len(path) && path[:l] == s.match {
+		if l == len(path) || s.match[l-1] == '/' || path[l] == '/' {
+			return true
+		}
+	}
+
+	if s.noWildcardLen == l {
+		return false
+	}
+
+	if !strings.HasPrefix(path, s.match[:s.noWildcardLen]) {
+		return false
+	}
+
+	return wildmatch(s.match[s.noWildcardLen:], path[s.noWildcardLen:])
+}
+
+// wildmatch returns true if the pattern matches text.
+// It implements the matching of git pathspecs: '*' matches any sequence of
+// characters, including '/', '?' matches any single character, '[...]'
+// matches a character of a set and '\' escapes the following character.
+func wildmatch(pattern, text string) bool {
+	for len(pattern) > 0 {
+		switch c := pattern[0]; c {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+
+			for i := 0; i <= len(text); i++ {
+				if wildmatch(pattern, text[i:]) {
+					return true
+				}
+			}
+
+			return false
+
+		case '?':
+			if text == "" {
+				return false
+			}
+
+			pattern, text = pattern[1:], text[1:]
+
+		case '[':
+			if text == "" {
+				return false
+			}
+
+			matched, n, ok := matchCharClass(pattern, text[0])
+			if !ok || !matched {
+				return false
+			}
+
+			pattern, text = pattern[n:], text[1:]
+
+		default:
+			if c == '\\' {
+				if len(pattern) == 1 {
+					return false
+				}
+
+				pattern = pattern[1:]
+				c = pattern[0]
+			}
+
+			if text == "" || text[0] != c {
+				return false
+			}
+
+			pattern, text = pattern[1:], text[1:]
+		}
+	}
+
+	return text == ""
+}
+
+// matchCharClass matches ch against the bracket expression at the start of
+// pattern. It returns if ch matched and the length of the bracket
+// expression. If the expression is invalid, ok is false.
+func matchCharClass(pattern string, ch byte) (matched bool, n int, ok bool) {
+	i := 1
+	negate := false
+
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	for first := true; ; first = false {
+		if i >= len(pattern) {
+			return false, 0, false
+		}
+
+		c := pattern[i]
+
+		if c == ']' && !first {
+			i++
+			break
+		}
+
+		if c == '[' && i+1 < len(pattern) && pattern[i+1] == ':' {
+			end := strings.Index(pattern[i+2:], ":]")
+			if end == -1 {
+				return false, 0, false
+			}
+
+			inClass, known := posixCharClass(pattern[i+2:i+2+end], ch)
+			if !known {
+				return false, 0, false
+			}
+
+			if inClass {
+				matched = true
+			}
+
+			i += 2 + end + 2
+			continue
+		}
+
+		if c == '\\' {
+			i++
+			if i >= len(pattern) {
+				return false, 0, false
+			}
+
+			c = pattern[i]
+		}
+
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			hi := pattern[i+2]
+			adv := 3
+
+			if hi == '\\' {
+				if i+3 >= len(pattern) {
+					return false, 0, false
+				}
+
+				hi = pattern[i+3]
+				adv = 4
+			}
+
+			if c <= ch && ch <= hi {
+				matched = true
+			}
+
+			i += adv
+			continue
+		}
+
+		if c == ch {
+			matched = true
+		}
+
+		i++
+	}
+
+	return matched != negate, i, true
+}
+
+// posixCharClass returns true if ch is part of the POSIX character class with
+// the given name. If the class is unknown, known is false.
+func posixCharClass(name string, ch byte) (inClass, known bool) {
+	isLower := 'a' <= ch && ch <= 'z'
+	isUpper := 'A' <= ch && ch <= 'Z'
+	isDigit := '0' <= ch && ch <= '9'
+	isSpace := ch == ' ' || ('\t' <= ch && ch <= '\r')
+	isPrint := ' ' <= ch && ch <= '~'
+
+	switch name {
+	case "alnum":
+		return isLower || isUpper || isDigit, true
+	case "alpha":
+		return isLower || isUpper, true
+	case "blank":
+		return ch == ' ' || ch == '\t', true
+	case "cntrl":
+		return ch < ' ' || ch == 0x7f, true
+	case "digit":
+		return isDigit, true
+	case "graph":
+		return isPrint && ch != ' ', true
+	case "lower":
+		return isLower, true
+	case "print":
+		return isPrint, true
+	case "punct":
+		return isPrint && ch != ' ' && !isLower && !isUpper && !isDigit, true
+	case "space":
+		return isSpace, true
+	case "upper":
+		return isUpper, true
+	case "xdigit":
+		return isDigit || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F'), true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,41 @@
+package baur
+
+import (
+	"fmt"
+
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/digest/sha256"
+	"github.com/simplesurance/baur/digest/sha384"
+)
+
+// inputHash computes the digest of build inputs
+type inputHash interface {
+	AddFile(path string) error
+	AddBytes(b []byte) error
+	Digest() *digest.Digest
+}
+
+// newInputHash returns a hash for the passed algorithm
+func newInputHash(algorithm digest.Algorithm) (inputHash, error) {
+	switch algorithm {
+	case digest.SHA256:
+		return sha256.New(), nil
+	case digest.SHA384:
+		return sha384.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+}
+
+// sumDigests aggregates multiple digests to a single digest that is
+// calculated with the passed algorithm.
+func sumDigests(algorithm digest.Algorithm, digests []*digest.Digest) (*digest.Digest, error) {
+	switch algorithm {
+	case digest.SHA256:
+		return sha256.Sum(digests)
+	case digest.SHA384:
+		return sha384.Sum(digests)
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+}
@@ -1,12 +1,15 @@
 package baur
 
 import (
+	"fmt"
 	"path/filepath"
+	"sync"
 
 	"github.com/simplesurance/baur/cfg"
 )
 
 type includeCache struct {
+	lock  sync.Mutex
 	cache map[string]*cfg.Include
 }
 
@@ -17,13 +20,26 @@ func newIncludeCache() *includeCache {
 // load loads an cfg.Include from path.
 // If the the include file was already loaded in the past, cfg.Include is
 // returned from the cache and not read & parsed again.
+// Application and repository config files are not include files, an error is
+// returned if path refers to one of them.
+// It is safe to call load concurrently.
 func (im *includeCache) load(path string) (*cfg.Include, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if include, exist := im.cache[path]; exist {
+	switch filepath.Base(absPath) {
+	case AppCfgFile:
+		return nil, fmt.Errorf("%s is an application config file, not an include file", absPath)
+	case RepositoryCfgFile:
+		return nil, fmt.Errorf("%s is a repository config file, not an include file", absPath)
+	}
+
+	im.lock.Lock()
+	defer im.lock.Unlock()
+
+	if include, exist := im.cache[absPath]; exist {
 		return include, nil
 	}
 
@@ -0,0 +1,40 @@
+package baur
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestIncludeCacheSkipsAppConfigBesideInclude(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	includePath := filepath.Join(tempDir, "include.toml")
+	fstest.WriteToFile(t, []byte(`
+[BuildInput.Files]
+paths = ["Makefile"]
+`), includePath)
+
+	createTestApp(t, tempDir, "app")
+
+	c := newIncludeCache()
+
+	if _, err := c.load(includePath); err != nil {
+		t.Fatal("loading include file failed:", err)
+	}
+
+	for _, p := range []string{
+		filepath.Join(tempDir, AppCfgFile),
+		filepath.Join(tempDir, RepositoryCfgFile),
+	} {
+		if _, err := c.load(p); err == nil {
+			t.Errorf("loading %s as include file succeeded, expected an error", p)
+		}
+	}
+
+	if len(c.cache) != 1 {
+		t.Errorf("cache contains %d include files, expected 1", len(c.cache))
+	}
+}
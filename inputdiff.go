@@ -0,0 +1,77 @@
+package baur
+
+import (
+	"sort"
+
+	"github.com/simplesurance/baur/storage"
+)
+
+// InputChange describes how an input changed
+type InputChange int
+
+const (
+	// InputAdded describes an input that did not exist before
+	InputAdded InputChange = iota
+	// InputRemoved describes an input that does not exist anymore
+	InputRemoved
+	// InputChanged describes an input with a different digest
+	InputChanged
+)
+
+func (c InputChange) String() string {
+	switch c {
+	case InputAdded:
+		return "added"
+	case InputRemoved:
+		return "removed"
+	case InputChanged:
+		return "changed"
+	default:
+		return "undefined"
+	}
+}
+
+// InputDiff describes the difference of an input between 2 builds
+type InputDiff struct {
+	URI    string
+	Change InputChange
+}
+
+// DiffInputs compares the inputs by their URIs and digests and returns the
+// differences, sorted by URI. If the inputs are the same an empty slice is
+// returned.
+func DiffInputs(current, recorded []*storage.Input) []*InputDiff {
+	res := []*InputDiff{}
+
+	recordedMap := make(map[string]string, len(recorded))
+	for _, in := range recorded {
+		recordedMap[in.URI] = in.Digest
+	}
+
+	currentMap := make(map[string]struct{}, len(current))
+	for _, in := range current {
+		currentMap[in.URI] = struct{}{}
+
+		recordedDigest, exist := recordedMap[in.URI]
+		if !exist {
+			res = append(res, &InputDiff{URI: in.URI, Change: InputAdded})
+			continue
+		}
+
+		if recordedDigest != in.Digest {
+			res = append(res, &InputDiff{URI: in.URI, Change: InputChanged})
+		}
+	}
+
+	for _, in := range recorded {
+		if _, exist := currentMap[in.URI]; !exist {
+			res = append(res, &InputDiff{URI: in.URI, Change: InputRemoved})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].URI < res[j].URI
+	})
+
+	return res
+}
@@ -0,0 +1,39 @@
+package baur
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simplesurance/baur/storage"
+)
+
+func TestDiffInputs(t *testing.T) {
+	recorded := []*storage.Input{
+		{URI: "main.c", Digest: "sha384:1"},
+		{URI: "removed.c", Digest: "sha384:2"},
+		{URI: "Makefile", Digest: "sha384:3"},
+		{URI: "$CC", Digest: "sha384:4"},
+	}
+
+	current := []*storage.Input{
+		{URI: "Makefile", Digest: "sha384:3"},
+		{URI: "main.c", Digest: "sha384:5"},
+		{URI: "added.c", Digest: "sha384:6"},
+		{URI: "$CC", Digest: "sha384:4"},
+	}
+
+	expected := []*InputDiff{
+		{URI: "added.c", Change: InputAdded},
+		{URI: "main.c", Change: InputChanged},
+		{URI: "removed.c", Change: InputRemoved},
+	}
+
+	res := DiffInputs(current, recorded)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("DiffInputs returned %+v, expected %+v", res, expected)
+	}
+
+	if res := DiffInputs(current, current); len(res) != 0 {
+		t.Errorf("DiffInputs returned %+v for same inputs, expected an empty result", res)
+	}
+}
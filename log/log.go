@@ -2,18 +2,31 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"github.com/fatih/color"
 )
 
-var errorPrefix = color.New(color.FgRed).Sprint("ERROR: ")
-var warnPrefix = color.New(color.FgYellow).Sprint("WARN: ")
+var errorColor = color.New(color.FgRed)
+var warnColor = color.New(color.FgYellow)
+
+// errorPrefix returns the prefix for error messages, it's colored on each
+// call because colored output can be disabled after the program started
+func errorPrefix() string {
+	return errorColor.Sprint("ERROR: ")
+}
+
+// warnPrefix returns the prefix for warning messages
+func warnPrefix() string {
+	return warnColor.Sprint("WARN: ")
+}
 
 // Logger logs messages
 type Logger struct {
 	debugEnabled bool
+	traceEnabled bool
 	logger       *log.Logger
 }
 
@@ -34,11 +47,30 @@ func (l *Logger) EnableDebug(enabled bool) {
 	l.debugEnabled = enabled
 }
 
+// EnableTrace enables/disables logging trace messages, enabling trace
+// messages also enables debug messages
+func (l *Logger) EnableTrace(enabled bool) {
+	l.traceEnabled = enabled
+	if enabled {
+		l.debugEnabled = true
+	}
+}
+
+// SetOutput sets the destination of the log messages
+func (l *Logger) SetOutput(w io.Writer) {
+	l.logger.SetOutput(w)
+}
+
 // DebugEnabled returns true if logging debug messages is enabled
 func (l *Logger) DebugEnabled() bool {
 	return l.debugEnabled
 }
 
+// TraceEnabled returns true if logging trace messages is enabled
+func (l *Logger) TraceEnabled() bool {
+	return l.traceEnabled
+}
+
 // Debugln logs a debug message to stdout.
 // It's only shown if debugging is enabled.
 func (l *Logger) Debugln(v ...interface{}) {
@@ -59,10 +91,21 @@ func (l *Logger) Debugf(format string, v ...interface{}) {
 	l.logger.Printf(format, v...)
 }
 
+// Tracef logs a trace message, trace messages are more detailed than debug
+// messages.
+// It's only shown if tracing is enabled.
+func (l *Logger) Tracef(format string, v ...interface{}) {
+	if !l.traceEnabled {
+		return
+	}
+
+	l.logger.Printf(format, v...)
+}
+
 // Fatalln logs a message to stderr and terminates the application with an error
 func (l *Logger) Fatalln(v ...interface{}) {
 	if len(v) != 0 {
-		v[0] = fmt.Sprintf("%s %s", errorPrefix, v[0])
+		v[0] = fmt.Sprintf("%s %s", errorPrefix(), v[0])
 	}
 
 	l.logger.Fatalln(v...)
@@ -70,13 +113,13 @@ func (l *Logger) Fatalln(v ...interface{}) {
 
 // Fatalf logs a message to stderr and terminates the application with an error
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.logger.Fatalf(errorPrefix+format, v...)
+	l.logger.Fatalf(errorPrefix()+format, v...)
 }
 
 // Errorln logs a message to stderr
 func (l *Logger) Errorln(v ...interface{}) {
 	if len(v) != 0 {
-		v[0] = fmt.Sprintf("%s %s", errorPrefix, v[0])
+		v[0] = fmt.Sprintf("%s %s", errorPrefix(), v[0])
 	}
 
 	l.logger.Println(v...)
@@ -84,12 +127,12 @@ func (l *Logger) Errorln(v ...interface{}) {
 
 // Errorf logs a message to stderr
 func (l *Logger) Errorf(format string, v ...interface{}) {
-	l.logger.Printf(errorPrefix+" "+format, v...)
+	l.logger.Printf(errorPrefix()+" "+format, v...)
 }
 
 // Warnf logs a message to stderr
 func (l *Logger) Warnf(format string, v ...interface{}) {
-	l.logger.Printf(warnPrefix+format, v...)
+	l.logger.Printf(warnPrefix()+format, v...)
 }
 
 // Infoln logs a message to stdout
@@ -123,6 +166,12 @@ func Debugf(format string, v ...interface{}) {
 	StdLogger.Debugf(format, v...)
 }
 
+// Tracef logs a trace message.
+// It's only shown if tracing is enabled.
+func Tracef(format string, v ...interface{}) {
+	StdLogger.Tracef(format, v...)
+}
+
 // Fatalln logs a message to stderr and terminates the application with an error
 func Fatalln(v ...interface{}) {
 	StdLogger.Fatalln(v...)
@@ -0,0 +1,68 @@
+package baur
+
+import (
+	"context"
+	"sync"
+)
+
+// forEachParallel calls fn for every index in [0, n) with up to workers
+// goroutines.
+// When fn returns an error or ctx is cancelled, the context that is passed to
+// fn is cancelled and no further indexes are processed. The first error
+// that fn returned is returned, if ctx was cancelled before ctx.Err() is
+// returned.
+func forEachParallel(ctx context.Context, n, workers int, fn func(ctx context.Context, idx int) error) error {
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+
+	idxCh := make(chan int)
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for idx := range idxCh {
+				// the index can have been sent after the
+				// cancellation
+				if ctx.Err() != nil {
+					continue
+				}
+
+				if err := fn(ctx, idx); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancelFn()
+					})
+				}
+			}
+		}()
+	}
+
+sendLoop:
+	for i := 0; i < n; i++ {
+		select {
+		case idxCh <- i:
+		case <-ctx.Done():
+			break sendLoop
+		}
+	}
+
+	close(idxCh)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
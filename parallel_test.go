@@ -0,0 +1,71 @@
+package baur
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEachParallelProcessesAllIndexes(t *testing.T) {
+	const n = 1000
+
+	var processed [n]int32
+
+	err := forEachParallel(context.Background(), n, 8, func(_ context.Context, idx int) error {
+		atomic.AddInt32(&processed[idx], 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, cnt := range processed {
+		if cnt != 1 {
+			t.Errorf("index %d was processed %d times, expected 1", i, cnt)
+		}
+	}
+}
+
+func TestForEachParallelStopsOnFirstError(t *testing.T) {
+	const n = 1000
+	errTest := errors.New("test error")
+
+	var calls int32
+
+	err := forEachParallel(context.Background(), n, 1, func(ctx context.Context, idx int) error {
+		atomic.AddInt32(&calls, 1)
+
+		if idx == 10 {
+			return errTest
+		}
+
+		return nil
+	})
+	if err != errTest {
+		t.Fatalf("forEachParallel returned error %v, expected %v", err, errTest)
+	}
+
+	if cnt := atomic.LoadInt32(&calls); cnt != 11 {
+		t.Errorf("fn was called %d times, expected processing to stop after the error for index 10", cnt)
+	}
+}
+
+func TestForEachParallelStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+
+	var calls int32
+
+	err := forEachParallel(ctx, 1000, 4, func(context.Context, int) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("forEachParallel returned error %v, expected %v", err, context.Canceled)
+	}
+
+	if cnt := atomic.LoadInt32(&calls); cnt != 0 {
+		t.Errorf("fn was called %d times, expected no calls for a cancelled context", cnt)
+	}
+}
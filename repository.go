@@ -1,35 +1,108 @@
 package baur
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
-	"path"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/simplesurance/baur/cfg"
+	"github.com/simplesurance/baur/digest"
 	"github.com/simplesurance/baur/fs"
 	"github.com/simplesurance/baur/git"
+	"github.com/simplesurance/baur/log"
 )
 
 // Repository represents an repository containing applications
 type Repository struct {
-	Path               string
-	CfgPath            string
-	AppSearchDirs      []string
-	SearchDepth        int
+	Path          string
+	CfgPath       string
+	AppSearchDirs []string
+	SearchDepth   int
+	// SearchDepthOverrides contains search depths for specific
+	// directories, the keys are absolute paths
+	SearchDepthOverrides map[string]int
+	// FollowSymlinks is true if symlinks to directories are followed when
+	// searching for application configs
+	FollowSymlinks bool
+	// gitLock protects gitCommitID and gitWorktreeIsDirty, the methods
+	// returning them are called concurrently when applications are loaded
+	gitLock            sync.Mutex
 	gitCommitID        string
 	gitWorktreeIsDirty *bool
 	PSQLURL            string
-	includeCache       *includeCache
+	// PSQLSSLRootCert, PSQLSSLCert and PSQLSSLKey are the absolute paths
+	// of the TLS files that are used to connect to the database, they are
+	// empty if they are not configured
+	PSQLSSLRootCert string
+	PSQLSSLCert     string
+	PSQLSSLKey      string
+	// PSQLMaxOpenConns, PSQLMaxIdleConns and PSQLConnMaxLifetime configure
+	// the database connection pool, they are 0 if they are not configured
+	PSQLMaxOpenConns    int
+	PSQLMaxIdleConns    int
+	PSQLConnMaxLifetime time.Duration
+	// DigestAlgorithm is the algorithm that is used to calculate digests
+	// of build inputs
+	DigestAlgorithm digest.Algorithm
+	// GitBackend is the implementation that is used to resolve GitFiles
+	// inputs
+	GitBackend git.Backend
+	// Environment contains environment variables in the format KEY=VALUE
+	// that apply to all applications, $ROOT is already replaced
+	Environment []string
+	// GoSourceCacheDir is the directory in that results of resolving
+	// GolangSources inputs are cached, if it is empty results are not
+	// cached
+	GoSourceCacheDir string
+	includeCache     *includeCache
+	// statCache caches stat results of input files and directories
+	// during the lifetime of the Repository
+	statCache *fs.StatCache
+	appLoader *appLoader
+}
+
+// RepositoryNotFoundError is returned when no repository config file exists
+// in a directory and all its parent directories.
+type RepositoryNotFoundError struct {
+	// SearchDir is the directory in that the search started
+	SearchDir string
+}
+
+// Error returns the error description
+func (e *RepositoryNotFoundError) Error() string {
+	return fmt.Sprintf("could not find a %s file in %s or one of its parent directories", RepositoryCfgFile, e.SearchDir)
+}
+
+// IsRepositoryNotFound returns true if the cause of err is a
+// *RepositoryNotFoundError.
+func IsRepositoryNotFound(err error) bool {
+	_, ok := errors.Cause(err).(*RepositoryNotFoundError)
+	return ok
 }
 
 // FindRepository searches for a repository config file. The search starts in
 // the passed directory and traverses the parent directory down to '/'. The first found repository
 // configuration file is returned.
+// If no repository config file is found, a *RepositoryNotFoundError is
+// returned.
 func FindRepository(dir string) (*Repository, error) {
 	rootPath, err := fs.FindFileInParentDirs(dir, RepositoryCfgFile)
 	if err != nil {
+		if err == os.ErrNotExist {
+			searchDir, absErr := filepath.Abs(dir)
+			if absErr != nil {
+				searchDir = dir
+			}
+
+			return nil, &RepositoryNotFoundError{SearchDir: searchDir}
+		}
+
 		return nil, err
 	}
 
@@ -61,16 +134,77 @@ func NewRepository(cfgPath string) (*Repository, error) {
 			"validating repository config %q failed", cfgPath)
 	}
 
+	rootPath := filepath.Dir(cfgPath)
+
+	searchDirs, err := fs.AbsPaths(rootPath, cfg.Discover.Dirs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "validating repository config %q failed, "+
+			"application_dirs parameter is invalid", cfgPath)
+	}
+
+	searchDepthOverrides := make(map[string]int, len(cfg.Discover.SearchDepthOverrides))
+	for _, o := range cfg.Discover.SearchDepthOverrides {
+		searchDepthOverrides[filepath.Join(rootPath, o.Dir)] = o.Depth
+	}
+
+	digestAlgorithm, err := cfg.DigestAlgorithmOrDefault()
+	if err != nil {
+		return nil, errors.Wrapf(err, "validating repository config %q failed", cfgPath)
+	}
+
+	gitBackend, err := cfg.GitBackendOrDefault()
+	if err != nil {
+		return nil, errors.Wrapf(err, "validating repository config %q failed", cfgPath)
+	}
+
+	connMaxLifetime, err := cfg.Database.ConnMaxLifetimeDuration()
+	if err != nil {
+		return nil, errors.Wrapf(err, "validating repository config %q failed", cfgPath)
+	}
+
+	log.Debugf("using %s git backend to resolve GitFiles inputs", gitBackend)
+
 	r := Repository{
-		CfgPath:       cfgPath,
-		Path:          path.Dir(cfgPath),
-		AppSearchDirs: fs.PathsJoin(path.Dir(cfgPath), cfg.Discover.Dirs),
-		SearchDepth:   cfg.Discover.SearchDepth,
-		PSQLURL:       cfg.Database.PGSQLURL,
-		includeCache:  newIncludeCache(),
+		CfgPath:              cfgPath,
+		Path:                 rootPath,
+		AppSearchDirs:        searchDirs,
+		SearchDepth:          cfg.Discover.SearchDepth,
+		SearchDepthOverrides: searchDepthOverrides,
+		FollowSymlinks:       cfg.Discover.FollowsSymlinks(),
+		PSQLURL:              cfg.Database.PGSQLURL,
+		PSQLSSLRootCert:      absPathOrEmpty(rootPath, cfg.Database.SSLRootCert),
+		PSQLSSLCert:          absPathOrEmpty(rootPath, cfg.Database.SSLCert),
+		PSQLSSLKey:           absPathOrEmpty(rootPath, cfg.Database.SSLKey),
+		PSQLMaxOpenConns:     cfg.Database.MaxOpenConns,
+		PSQLMaxIdleConns:     cfg.Database.MaxIdleConns,
+		PSQLConnMaxLifetime:  connMaxLifetime,
+		DigestAlgorithm:      digestAlgorithm,
+		GitBackend:           gitBackend,
+		includeCache:         newIncludeCache(),
+		statCache:            fs.NewStatCache(),
+	}
+
+	r.GoSourceCacheDir, err = GoSourceCacheDir(rootPath)
+	if err != nil {
+		log.Debugf("caching GolangSources results is disabled, determining cache directory failed: %s", err)
+	}
+
+	r.Environment = make([]string, 0, len(cfg.Environment))
+	for _, e := range cfg.Environment {
+		r.Environment = append(r.Environment, replaceROOTvar(e, &r))
+	}
+
+	ignore, err := readIgnoreFile(rootPath)
+	if err != nil {
+		return nil, err
 	}
 
-	err = fs.DirsExist(r.AppSearchDirs...)
+	symlinks := fs.SymlinksSkip
+	if r.FollowSymlinks {
+		symlinks = fs.SymlinksFollow
+	}
+
+	r.appLoader, err = newAppLoader(&r, r.AppSearchDirs, r.SearchDepth, r.SearchDepthOverrides, symlinks, ignore)
 	if err != nil {
 		return nil, errors.Wrapf(err, "validating repository config %q failed, "+
 			"application_dirs parameter is invalid", cfgPath)
@@ -79,68 +213,94 @@ func NewRepository(cfgPath string) (*Repository, error) {
 	return &r, nil
 }
 
-// FindApps searches for application config files in the AppSearchDirs of the
-// repository and returns all found apps
-func (r *Repository) FindApps() ([]*App, error) {
-	var result []*App
+// readIgnoreFile returns a matcher for the patterns in the IgnoreFile in the
+// repository root directory, if the file does not exist nil is returned
+func readIgnoreFile(rootPath string) (*fs.IgnoreFileMatcher, error) {
+	path := filepath.Join(rootPath, IgnoreFile)
 
-	for _, searchDir := range r.AppSearchDirs {
-		appsCfgPaths, err := fs.FindFilesInSubDir(searchDir, AppCfgFile, r.SearchDepth)
-		if err != nil {
-			return nil, errors.Wrap(err, "finding application configs failed")
-		}
+	if !fs.FileExists(path) {
+		return nil, nil
+	}
 
-		for _, appCfgPath := range appsCfgPaths {
-			a, err := NewApp(r, appCfgPath)
-			if err != nil {
-				return nil, err
-			}
+	return fs.NewIgnoreFileMatcher(path)
+}
 
-			result = append(result, a)
-		}
+// absPathOrEmpty returns path joined with rootPath if it is relative,
+// an empty path is returned unchanged
+func absPathOrEmpty(rootPath, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
 	}
 
-	return result, nil
+	return filepath.Join(rootPath, path)
 }
 
-// AppByDir reads an application config file from the direcory and returns an
-// App
-func (r *Repository) AppByDir(appDir string) (*App, error) {
-	cfgPath := path.Join(appDir, AppCfgFile)
+// AppConfigPaths returns the absolute paths of all application config files
+// in the AppSearchDirs of the repository, sorted by path.
+// The config files are not loaded.
+func (r *Repository) AppConfigPaths() ([]string, error) {
+	return r.appLoader.ConfigPaths()
+}
 
-	cfgPath, err := filepath.Abs(cfgPath)
+// IncludeAbsPath returns the absolute path of an include file that is
+// referenced as includePath in the config of the application in appDir.
+func (r *Repository) IncludeAbsPath(appDir, includePath string) string {
+	path := replaceROOTvar(includePath, r)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(appDir, path)
+	}
+
+	return path
+}
+
+// GoSourceCacheDir returns the directory in that the results of resolving
+// GolangSources inputs of the repository at repoPath are cached.
+// The directory is in the user's cache directory, to not create untracked
+// files in the repository.
+func GoSourceCacheDir(repoPath string) (string, error) {
+	dir, err := os.UserCacheDir()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return NewApp(r, cfgPath)
+	h := sha256.Sum256([]byte(repoPath))
+
+	return filepath.Join(dir, "baur", "gosource", hex.EncodeToString(h[:8])), nil
+}
+
+// WithAppLoadParallelism sets the max. number of application configs that
+// are loaded concurrently by FindApps and AppByName.
+// The default is the number of CPUs, values < 1 are treated as 1.
+func (r *Repository) WithAppLoadParallelism(n int) *Repository {
+	r.appLoader.parallelism = n
+	return r
+}
+
+// FindApps searches for application config files in the AppSearchDirs of the
+// repository and returns all found apps sorted by their config file paths.
+func (r *Repository) FindApps() ([]*App, error) {
+	return r.appLoader.All()
+}
+
+// AppByDir reads an application config file from the direcory and returns an
+// App. If the directory does not contain an application config file that is
+// in the AppSearchDirs of the repository, os.ErrNotExist is returned.
+func (r *Repository) AppByDir(appDir string) (*App, error) {
+	return r.appLoader.AppConfig(filepath.Join(appDir, AppCfgFile))
 }
 
 // AppByName searches for an App with the given name in the repository and
 // returns it. If none is found os.ErrNotExist is returned.
+// If multiple applications with the name exist, an error is returned.
 func (r *Repository) AppByName(name string) (*App, error) {
-	for _, searchDir := range r.AppSearchDirs {
-		appsCfgPaths, err := fs.FindFilesInSubDir(searchDir, AppCfgFile, r.SearchDepth)
-		if err != nil {
-			return nil, errors.Wrap(err, "finding application failed")
-		}
-
-		for _, appCfgPath := range appsCfgPaths {
-			a, err := NewApp(r, appCfgPath)
-			if err != nil {
-				return nil, err
-			}
-			if a.Name == name {
-				return a, nil
-			}
-		}
-	}
-
-	return nil, os.ErrNotExist
+	return r.appLoader.ByName(name)
 }
 
 // GitCommitID returns the Git commit ID in the baur repository root
 func (r *Repository) GitCommitID() (string, error) {
+	r.gitLock.Lock()
+	defer r.gitLock.Unlock()
+
 	if len(r.gitCommitID) != 0 {
 		return r.gitCommitID, nil
 	}
@@ -160,6 +320,9 @@ func (r *Repository) GitCommitID() (string, error) {
 // GitWorkTreeIsDirty returns true if the git repository contains untracked
 // changes
 func (r *Repository) GitWorkTreeIsDirty() (bool, error) {
+	r.gitLock.Lock()
+	defer r.gitLock.Unlock()
+
 	if r.gitWorktreeIsDirty != nil {
 		return *r.gitWorktreeIsDirty, nil
 	}
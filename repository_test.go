@@ -0,0 +1,242 @@
+package baur
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/cfg"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestNewRepositoryResolvesSearchDirsInRepositoryDir(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfg := cfg.ExampleRepository()
+	repoCfg.Discover.Dirs = []string{".", "apps", "services/"}
+
+	for _, dir := range []string{"apps", "services"} {
+		if err := os.Mkdir(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfgPath := filepath.Join(tempDir, RepositoryCfgFile)
+	if err := repoCfg.ToFile(cfgPath, false); err != nil {
+		t.Fatal("writing repository config failed:", err)
+	}
+
+	repo, err := NewRepository(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.Path != tempDir {
+		t.Errorf("repository path is %q, expected %q", repo.Path, tempDir)
+	}
+
+	expected := []string{
+		tempDir,
+		filepath.Join(tempDir, "apps"),
+		filepath.Join(tempDir, "services"),
+	}
+
+	if !reflect.DeepEqual(repo.AppSearchDirs, expected) {
+		t.Errorf("AppSearchDirs are %v, expected %v", repo.AppSearchDirs, expected)
+	}
+}
+
+func TestNewRepositoryResolvesPSQLTLSFiles(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfg := cfg.ExampleRepository()
+	repoCfg.Database.SSLRootCert = "certs/ca.crt"
+	repoCfg.Database.SSLCert = "/etc/baur/client.crt"
+
+	cfgPath := filepath.Join(tempDir, RepositoryCfgFile)
+	if err := repoCfg.ToFile(cfgPath, false); err != nil {
+		t.Fatal("writing repository config failed:", err)
+	}
+
+	repo, err := NewRepository(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := filepath.Join(tempDir, "certs", "ca.crt"); repo.PSQLSSLRootCert != expected {
+		t.Errorf("PSQLSSLRootCert is %q, expected %q", repo.PSQLSSLRootCert, expected)
+	}
+
+	if expected := "/etc/baur/client.crt"; repo.PSQLSSLCert != expected {
+		t.Errorf("PSQLSSLCert is %q, expected %q", repo.PSQLSSLCert, expected)
+	}
+
+	if repo.PSQLSSLKey != "" {
+		t.Errorf("PSQLSSLKey is %q, expected it to be empty", repo.PSQLSSLKey)
+	}
+}
+
+func TestAppByName(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	createTestApp(t, filepath.Join(tempDir, "a"), "appA")
+	createTestApp(t, filepath.Join(tempDir, "b"), "appB")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := repo.AppByName("appB")
+	if err != nil {
+		t.Fatal("AppByName() failed:", err)
+	}
+
+	if app.Name != "appB" {
+		t.Errorf("AppByName() returned app %q, expected appB", app.Name)
+	}
+
+	_, err = repo.AppByName("doesnotexist")
+	if !os.IsNotExist(err) {
+		t.Errorf("AppByName() returned error %v for a non-existing app, expected os.ErrNotExist", err)
+	}
+}
+
+func TestAppByNameAmbiguous(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	createTestApp(t, filepath.Join(tempDir, "a"), "app")
+	createTestApp(t, filepath.Join(tempDir, "b"), "app")
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.AppByName("app")
+	if err == nil {
+		t.Fatal("AppByName() succeeded for an ambiguous name, expected an error")
+	}
+}
+
+func TestAppByDir(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "a")
+	createTestApp(t, appDir, "appA")
+
+	emptyDir := filepath.Join(tempDir, "empty")
+	if err := os.Mkdir(emptyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the path is passed in a not normalized form
+	app, err := repo.AppByDir(filepath.Join(appDir, "..", "a"))
+	if err != nil {
+		t.Fatal("AppByDir() failed:", err)
+	}
+
+	if app.Name != "appA" {
+		t.Errorf("AppByDir() returned app %q, expected appA", app.Name)
+	}
+
+	appByName, err := repo.AppByName("appA")
+	if err != nil {
+		t.Fatal("AppByName() failed:", err)
+	}
+
+	if appByName != app {
+		t.Error("AppByName() and AppByDir() returned different objects for the same app")
+	}
+
+	_, err = repo.AppByDir(emptyDir)
+	if !os.IsNotExist(err) {
+		t.Errorf("AppByDir() returned error %v for a dir without app config, expected os.ErrNotExist", err)
+	}
+}
+
+func TestNewRepositoryFailsOnNonExistingDiscoverDir(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	fstest.WriteToFile(t, []byte(`
+config_version = 2
+
+[Discover]
+application_dirs = [".", "doesnotexist"]
+search_depth = 1
+`), filepath.Join(tempDir, RepositoryCfgFile))
+
+	_, err := NewRepository(filepath.Join(tempDir, RepositoryCfgFile))
+	if err == nil {
+		t.Fatal("NewRepository() succeeded with a non-existing application_dirs entry, expected an error")
+	}
+}
+
+func TestFindRepositoryReturnsNotFoundError(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	_, err := FindRepository(tempDir)
+	if err == nil {
+		t.Fatal("FindRepository succeeded in a directory without repository config")
+	}
+
+	if !IsRepositoryNotFound(err) {
+		t.Fatalf("FindRepository returned %v (%T), expected a *RepositoryNotFoundError", err, err)
+	}
+
+	if searchDir := err.(*RepositoryNotFoundError).SearchDir; searchDir != tempDir {
+		t.Errorf("SearchDir is %q, expected %q", searchDir, tempDir)
+	}
+
+	if IsRepositoryNotFound(os.ErrNotExist) {
+		t.Error("IsRepositoryNotFound returned true for os.ErrNotExist")
+	}
+}
+
+func TestGoSourceCacheDirIsOutsideOfRepository(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	cacheHome, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip("user cache directory is not available:", err)
+	}
+
+	repoDir1 := filepath.Join(tempDir, "repo1")
+	repoDir2 := filepath.Join(tempDir, "repo2")
+
+	dir1, err := GoSourceCacheDir(repoDir1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir2, err := GoSourceCacheDir(repoDir2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(dir1, cacheHome+string(filepath.Separator)) {
+		t.Errorf("cache directory %s is not in the user cache directory %s", dir1, cacheHome)
+	}
+
+	if dir1 == dir2 {
+		t.Errorf("repositories %s and %s have the same cache directory %s", repoDir1, repoDir2, dir1)
+	}
+}
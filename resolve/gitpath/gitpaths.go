@@ -12,8 +12,10 @@ import (
 // git ls-files.
 // Glob path only resolve to files that are tracked in the repository.
 type Resolver struct {
-	path  string
-	globs []string
+	path      string
+	globs     []string
+	backend   git.Backend
+	statCache *fs.StatCache
 }
 
 // NewResolver returns a resolver that resolves the passed git glob paths to absolute
@@ -25,21 +27,34 @@ func NewResolver(path string, globs ...string) *Resolver {
 	}
 }
 
+// WithBackend sets the implementation that is used to list the files tracked
+// in the repository, the default is git.BackendBinary.
+func (r *Resolver) WithBackend(backend git.Backend) *Resolver {
+	r.backend = backend
+	return r
+}
+
+// WithStatCache sets the cache that is used to stat paths, by default paths
+// are stat'ed without caching.
+func (r *Resolver) WithStatCache(c *fs.StatCache) *Resolver {
+	r.statCache = c
+	return r
+}
+
 // Resolve the glob paths to absolute file paths by calling
 // git ls-files
 func (r *Resolver) Resolve() ([]string, error) {
-	out, err := git.LsFiles(r.path, r.globs...)
+	relPaths, err := r.lsFiles()
 	if err != nil {
 		return nil, err
 	}
 
-	relPaths := strings.Split(out, "\n")
 	res := make([]string, 0, len(relPaths))
 
 	for _, relPath := range relPaths {
 		absPath := filepath.Join(r.path, relPath)
 
-		isFile, err := fs.IsFile(absPath)
+		isFile, err := r.statCache.IsFile(absPath)
 		if err != nil {
 			return nil, err
 		}
@@ -53,3 +68,16 @@ func (r *Resolver) Resolve() ([]string, error) {
 
 	return res, nil
 }
+
+func (r *Resolver) lsFiles() ([]string, error) {
+	if r.backend == git.BackendBuiltin {
+		return git.IndexLsFiles(r.path, r.globs...)
+	}
+
+	out, err := git.LsFiles(r.path, r.globs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(out, "\n"), nil
+}
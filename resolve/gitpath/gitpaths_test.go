@@ -0,0 +1,258 @@
+package gitpath
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/exec"
+	"github.com/simplesurance/baur/git"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+var backends = []git.Backend{git.BackendBinary, git.BackendBuiltin}
+
+func runGit(t *testing.T, dir string, arg ...string) {
+	t.Helper()
+
+	args := append([]string{"-c", "user.name=baur", "-c", "user.email=baur@example.com"}, arg...)
+
+	if _, err := exec.Command("git", args...).Directory(dir).ExpectSuccess().Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, relPaths ...string) {
+	t.Helper()
+
+	for _, p := range relPaths {
+		absPath := filepath.Join(dir, p)
+
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		fstest.WriteToFile(t, []byte(p), absPath)
+	}
+}
+
+// createGitRepo creates a git repository in dir with committed, staged,
+// untracked and ignored files
+func createGitRepo(t *testing.T, dir string) {
+	t.Helper()
+
+	runGit(t, dir, "init", "-q", ".")
+
+	writeFiles(t, dir,
+		".gitignore",
+		"app/main.go",
+		"app/main_test.go",
+		"app/pkg/util.go",
+		"app/README.md",
+		"other/main.go",
+	)
+	fstest.WriteToFile(t, []byte("*.log\n"), filepath.Join(dir, ".gitignore"))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+
+	writeFiles(t, dir, "app/staged.go")
+	runGit(t, dir, "add", "app/staged.go")
+
+	writeFiles(t, dir, "app/untracked.go", "app/build.log")
+}
+
+func TestResolve(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	createGitRepo(t, tempDir)
+	appDir := filepath.Join(tempDir, "app")
+
+	testcases := []struct {
+		name     string
+		globs    []string
+		expected []string
+	}{
+		{
+			name:  "glob",
+			globs: []string{"*.go"},
+			expected: []string{
+				"app/main.go",
+				"app/main_test.go",
+				"app/pkg/util.go",
+				"app/staged.go",
+			},
+		},
+		{
+			name:     "file",
+			globs:    []string{"README.md"},
+			expected: []string{"app/README.md"},
+		},
+		{
+			name:  "multiple",
+			globs: []string{"pkg/*.go", "README.md"},
+			expected: []string{
+				"app/README.md",
+				"app/pkg/util.go",
+			},
+		},
+		{
+			name:     "ignored",
+			globs:    []string{"*.log"},
+			expected: []string{},
+		},
+	}
+
+	for _, backend := range backends {
+		for _, tc := range testcases {
+			t.Run(backend.String()+"/"+tc.name, func(t *testing.T) {
+				res, err := NewResolver(appDir, tc.globs...).WithBackend(backend).Resolve()
+				if err != nil {
+					t.Fatal("Resolve() failed:", err)
+				}
+
+				expected := make([]string, 0, len(tc.expected))
+				for _, p := range tc.expected {
+					expected = append(expected, filepath.Join(tempDir, p))
+				}
+
+				sort.Strings(res)
+
+				if !reflect.DeepEqual(res, expected) {
+					t.Errorf("Resolve() returned %v, expected %v", res, expected)
+				}
+			})
+		}
+	}
+}
+
+func TestBackendsReturnSameResults(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	createGitRepo(t, tempDir)
+	writeFiles(t, tempDir,
+		"app/pkg/sub/deep.go",
+		"app/pkg/sub/data[1].json",
+		"app/with space.txt",
+		"app/ünicode.c",
+		"app/a.go/file",
+		"Makefile",
+	)
+	runGit(t, tempDir, "add", ".")
+	runGit(t, tempDir, "commit", "-q", "-m", "more files")
+
+	pathspecs := [][]string{
+		{"."},
+		{"*"},
+		{"*.go"},
+		{"**/*.go"},
+		{"pkg"},
+		{"pkg/"},
+		{"pkg/*"},
+		{"pkg/sub/*.go"},
+		{"*/util.go"},
+		{"[mp]*"},
+		{"[!m]*.go"},
+		{"[[:upper:]]*"},
+		{"?ain.go"},
+		{"pkg/sub/data\\[1\\].json"},
+		{"pkg/sub/data[1].json"},
+		{"with space.txt"},
+		{"ünicode.c"},
+		{"a.go"},
+		{"../Makefile"},
+		{"../other/*.go"},
+		{"../*"},
+		{"main.go", "pkg/*.go", "README.md"},
+		{"nonexisting"},
+	}
+
+	for _, indexVersion := range []string{"2", "3", "4"} {
+		runGit(t, tempDir, "update-index", "--index-version", indexVersion)
+
+		for _, dir := range []string{tempDir, filepath.Join(tempDir, "app")} {
+			for _, specs := range pathspecs {
+				name := fmt.Sprintf("v%s/%s/%v", indexVersion, filepath.Base(dir), specs)
+
+				t.Run(name, func(t *testing.T) {
+					binRes, binErr := NewResolver(dir, specs...).WithBackend(git.BackendBinary).Resolve()
+					builtinRes, builtinErr := NewResolver(dir, specs...).WithBackend(git.BackendBuiltin).Resolve()
+
+					if (binErr == nil) != (builtinErr == nil) {
+						t.Fatalf("binary backend returned error %v, builtin backend returned error %v", binErr, builtinErr)
+					}
+
+					if !reflect.DeepEqual(binRes, builtinRes) {
+						t.Errorf("builtin backend returned %v, binary backend returned %v", builtinRes, binRes)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestBuiltinBackendInWorktree(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	repoDir := filepath.Join(tempDir, "repo")
+	if err := os.Mkdir(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	createGitRepo(t, repoDir)
+	runGit(t, repoDir, "worktree", "add", "-q", filepath.Join(tempDir, "worktree"))
+
+	appDir := filepath.Join(tempDir, "worktree", "app")
+
+	binRes, err := NewResolver(appDir, "*.go").WithBackend(git.BackendBinary).Resolve()
+	if err != nil {
+		t.Fatal("resolving with binary backend failed:", err)
+	}
+
+	builtinRes, err := NewResolver(appDir, "*.go").WithBackend(git.BackendBuiltin).Resolve()
+	if err != nil {
+		t.Fatal("resolving with builtin backend failed:", err)
+	}
+
+	if len(builtinRes) == 0 {
+		t.Error("builtin backend resolved 0 files")
+	}
+
+	if !reflect.DeepEqual(binRes, builtinRes) {
+		t.Errorf("builtin backend returned %v, binary backend returned %v", builtinRes, binRes)
+	}
+}
+
+func TestResolveOutsideGitRepositoryFails(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	// prevent that git finds a repository in a parent directory of the
+	// temporary directory
+	if err := os.Setenv("GIT_CEILING_DIRECTORIES", tempDir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("GIT_CEILING_DIRECTORIES")
+
+	appDir := filepath.Join(tempDir, "app")
+	writeFiles(t, appDir, "main.go")
+
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			_, err := NewResolver(appDir, "*.go").WithBackend(backend).Resolve()
+			if err == nil {
+				t.Fatal("Resolve() succeeded for a directory that is not part of a git repository")
+			}
+
+			if !strings.Contains(err.Error(), "not part of a git repository") {
+				t.Errorf("Resolve() error %q does not state that the directory is not a git repository", err)
+			}
+		})
+	}
+}
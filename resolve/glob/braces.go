@@ -0,0 +1,142 @@
+package glob
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// escapingSupported is true if filepath.Match() supports escaping special
+// characters with a backslash
+var escapingSupported = runtime.GOOS != "windows"
+
+// ExpandBraces expands brace groups in pattern to multiple patterns.
+// A brace group contains comma separated alternatives, e.g.
+// "src/*.{c,h}" is expanded to "src/*.c" and "src/*.h". Groups can be
+// nested, "{x,{y,z}}" is expanded to "x", "y" and "z".
+// Braces that are escaped with a backslash are not expanded.
+// If pattern does not contain brace groups, a slice containing only pattern
+// is returned. An error is returned if the braces in pattern are unbalanced.
+func ExpandBraces(pattern string) ([]string, error) {
+	res, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return uniq(res), nil
+}
+
+func expandBraces(pattern string) ([]string, error) {
+	start, end, err := findBraceGroup(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %s", pattern, err)
+	}
+
+	if start == -1 {
+		return []string{pattern}, nil
+	}
+
+	prefix := pattern[:start]
+	suffix := pattern[end+1:]
+
+	var res []string
+	for _, alt := range splitAlternatives(pattern[start+1 : end]) {
+		expanded, err := expandBraces(prefix + alt + suffix)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, expanded...)
+	}
+
+	return res, nil
+}
+
+// findBraceGroup returns the positions of the opening and closing brace of
+// the first top-level brace group in pattern.
+// If pattern contains no brace group, -1 is returned for both positions.
+func findBraceGroup(pattern string) (int, int, error) {
+	start := -1
+	depth := 0
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if escapingSupported {
+				i++
+			}
+
+		case '{':
+			if depth == 0 && start == -1 {
+				start = i
+			}
+			depth++
+
+		case '}':
+			if depth == 0 {
+				return -1, -1, errors.New("unbalanced braces, '}' without opening '{'")
+			}
+
+			depth--
+			if depth == 0 {
+				// the remaining part of the pattern is
+				// validated when the result is expanded
+				return start, i, nil
+			}
+		}
+	}
+
+	if depth != 0 {
+		return -1, -1, errors.New("unbalanced braces, '{' without closing '}'")
+	}
+
+	return -1, -1, nil
+}
+
+// splitAlternatives splits the content of a brace group at the top-level
+// commas
+func splitAlternatives(group string) []string {
+	var res []string
+
+	depth := 0
+	last := 0
+
+	for i := 0; i < len(group); i++ {
+		switch group[i] {
+		case '\\':
+			if escapingSupported {
+				i++
+			}
+
+		case '{':
+			depth++
+
+		case '}':
+			depth--
+
+		case ',':
+			if depth == 0 {
+				res = append(res, group[last:i])
+				last = i + 1
+			}
+		}
+	}
+
+	return append(res, group[last:])
+}
+
+func uniq(in []string) []string {
+	seen := make(map[string]struct{}, len(in))
+	res := make([]string, 0, len(in))
+
+	for _, s := range in {
+		if _, exist := seen[s]; exist {
+			continue
+		}
+
+		seen[s] = struct{}{}
+		res = append(res, s)
+	}
+
+	return res
+}
@@ -0,0 +1,46 @@
+package glob
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	testcases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{pattern: "src/*.go", expected: []string{"src/*.go"}},
+		{pattern: "*.{c,h}", expected: []string{"*.c", "*.h"}},
+		{pattern: "src/{a,b}/*.go", expected: []string{"src/a/*.go", "src/b/*.go"}},
+		{pattern: "{x,{y,z}}", expected: []string{"x", "y", "z"}},
+		{pattern: "{a,b}/{c,d}", expected: []string{"a/c", "a/d", "b/c", "b/d"}},
+		{pattern: "lib{,64}/*.so", expected: []string{"lib/*.so", "lib64/*.so"}},
+		{pattern: "{a,a}.go", expected: []string{"a.go"}},
+		{pattern: "**/*.{go,mod}", expected: []string{"**/*.go", "**/*.mod"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			res, err := ExpandBraces(tc.pattern)
+			if err != nil {
+				t.Fatal("ExpandBraces failed:", err)
+			}
+
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Errorf("ExpandBraces returned %q, expected %q", res, tc.expected)
+			}
+		})
+	}
+}
+
+func TestExpandBracesFailsOnUnbalancedBraces(t *testing.T) {
+	for _, pattern := range []string{"{a,b", "a,b}", "{a,{b,c}", "{a,b}}", "}{"} {
+		t.Run(pattern, func(t *testing.T) {
+			res, err := ExpandBraces(pattern)
+			if err == nil {
+				t.Errorf("ExpandBraces returned %q, expected an error", res)
+			}
+		})
+	}
+}
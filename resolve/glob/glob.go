@@ -12,9 +12,10 @@ import (
 
 // Resolver resolves a glob path to files. The functionality is the same then
 // filepath.Glob() with the addition that '**' is supported to match files
-// directories recursively.
+// directories recursively and that brace groups like '{a,b}' are expanded.
 type Resolver struct {
-	glob string
+	glob      string
+	statCache *fs.StatCache
 }
 
 // NewResolver returns a resolver that resolves glob relative to path
@@ -24,24 +25,40 @@ func NewResolver(glob string) *Resolver {
 	}
 }
 
+// WithStatCache sets the cache that is used to stat paths, by default paths
+// are stat'ed without caching.
+func (r *Resolver) WithStatCache(c *fs.StatCache) *Resolver {
+	r.statCache = c
+	return r
+}
+
 // Resolve returns absolute paths to files that specify the glob path
-// glob does the same  then filepath.Glob() with 2 Exceptions:
+// glob does the same  then filepath.Glob() with 3 Exceptions:
 //- it also supports '**' to match files and directories recursively
+// - brace groups are expanded, see ExpandBraces()
 //- it and only returns paths to files, no directory paths
 // If a Glob doesn't match any files an empty []string is returned and error is
 // nil
 func (r *Resolver) Resolve() ([]string, error) {
 	var globPaths []string
 
-	if strings.Contains(r.glob, "**") {
-		expandedPaths, err := expandDoubleStarGlob(r.glob)
+	patterns, err := ExpandBraces(r.glob)
+	if err != nil {
+		return nil, errors.Wrap(err, "expanding braces failed")
+	}
+
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "**") {
+			globPaths = append(globPaths, pattern)
+			continue
+		}
+
+		expandedPaths, err := expandDoubleStarGlob(r.statCache, pattern)
 		if err != nil {
 			return nil, errors.Wrap(err, "expanding '**' failed")
 		}
 
-		globPaths = expandedPaths
-	} else {
-		globPaths = []string{r.glob}
+		globPaths = append(globPaths, expandedPaths...)
 	}
 
 	paths := make([]string, 0, len(globPaths))
@@ -58,9 +75,12 @@ func (r *Resolver) Resolve() ([]string, error) {
 		paths = append(paths, path...)
 	}
 
+	// brace groups can expand to patterns that match the same files
+	paths = uniq(paths)
+
 	res := make([]string, 0, len(paths))
 	for _, p := range paths {
-		isFile, err := fs.IsFile(p)
+		isFile, err := r.statCache.IsFile(p)
 		if err != nil {
 			return nil, errors.Wrapf(err, "resolved path %q does not exist", p)
 		}
@@ -73,8 +93,8 @@ func (r *Resolver) Resolve() ([]string, error) {
 	return res, nil
 }
 
-func findAllDirsNoDups(result map[string]struct{}, path string) error {
-	isDir, err := fs.IsDir(path)
+func findAllDirsNoDups(statCache *fs.StatCache, result map[string]struct{}, path string) error {
+	isDir, err := statCache.IsDir(path)
 	if err != nil {
 		return errors.Wrapf(err, "IsDir(%s) failed", path)
 	}
@@ -100,7 +120,7 @@ func findAllDirsNoDups(result map[string]struct{}, path string) error {
 	}
 
 	for _, path := range rootGlob {
-		err = findAllDirsNoDups(result, path)
+		err = findAllDirsNoDups(statCache, result, path)
 		if err != nil {
 			return err
 		}
@@ -111,10 +131,10 @@ func findAllDirsNoDups(result map[string]struct{}, path string) error {
 
 // findAllDirs returns recursively all diretories in path, including the
 // passed path dir
-func findAllDirs(path string) ([]string, error) {
+func findAllDirs(statCache *fs.StatCache, path string) ([]string, error) {
 	resultMap := map[string]struct{}{}
 
-	err := findAllDirsNoDups(resultMap, path)
+	err := findAllDirsNoDups(statCache, resultMap, path)
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +151,7 @@ func findAllDirs(path string) ([]string, error) {
 // paths were ** is expanded recursively to all matching directories.  If '**'
 // is the last part in the path, the returned paths will end in '/*' to glob
 // match all files in those directories
-func expandDoubleStarGlob(absGlobPath string) ([]string, error) {
+func expandDoubleStarGlob(statCache *fs.StatCache, absGlobPath string) ([]string, error) {
 	spl := strings.Split(absGlobPath, "**")
 	if len(spl) < 2 {
 		return nil, fmt.Errorf("%q does not contain '**'", absGlobPath)
@@ -144,7 +164,7 @@ func expandDoubleStarGlob(absGlobPath string) ([]string, error) {
 		glob = "*"
 	}
 
-	dirs, err := findAllDirs(basePath)
+	dirs, err := findAllDirs(statCache, basePath)
 	if err != nil {
 		return nil, err
 	}
@@ -155,3 +175,25 @@ func expandDoubleStarGlob(absGlobPath string) ([]string, error) {
 
 	return dirs, nil
 }
+
+// ResolveFileInputs resolves the glob patterns with a Resolver and returns
+// the paths of all matched files without duplicates.
+// Relative patterns are resolved relative to root. statCache can be nil.
+func ResolveFileInputs(root string, patterns []string, statCache *fs.StatCache) ([]string, error) {
+	var res []string
+
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(root, pattern)
+		}
+
+		paths, err := NewResolver(pattern).WithStatCache(statCache).Resolve()
+		if err != nil {
+			return nil, errors.Wrap(err, pattern)
+		}
+
+		res = append(res, paths...)
+	}
+
+	return uniq(res), nil
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/simplesurance/baur/testutils/fstest"
@@ -26,7 +27,7 @@ func Test_FindAllSubDirs(t *testing.T) {
 		t.Fatal("creating subdirectories failed:", err)
 	}
 
-	res, err := findAllDirs(tempdir)
+	res, err := findAllDirs(nil, tempdir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,6 +47,12 @@ func Test_FindAllSubDirs(t *testing.T) {
 func createFiles(t *testing.T, basedir string, paths []string) {
 	for _, p := range paths {
 		fullpath := filepath.Join(basedir, p)
+
+		err := os.MkdirAll(filepath.Dir(fullpath), os.ModePerm)
+		if err != nil {
+			t.Fatal("creating directory failed:", err)
+		}
+
 		f, err := os.Create(fullpath)
 		if err != nil {
 			t.Fatal("creating file failed:", err)
@@ -170,6 +177,50 @@ func Test_Resolve(t *testing.T) {
 			},
 			fileSrcGlobPath: "1/**/*.go",
 		},
+
+		{
+			files: []string{
+				"src/a/a.go",
+				"src/b/b.go",
+				"src/b/b.c",
+				"src/c/c.go",
+			},
+			expectedMatches: []string{
+				"src/a/a.go",
+				"src/b/b.go",
+			},
+			fileSrcGlobPath: "src/{a,b}/*.go",
+		},
+
+		{
+			files: []string{
+				"x.go",
+				"y.go",
+				"z.go",
+				"w.go",
+			},
+			expectedMatches: []string{
+				"x.go",
+				"y.go",
+				"z.go",
+			},
+			fileSrcGlobPath: "{x,{y,z}}.go",
+		},
+
+		{
+			files: []string{
+				"main.c",
+				"1/main.h",
+				"1/2/lib.c",
+				"1/2/lib.o",
+			},
+			expectedMatches: []string{
+				"main.c",
+				"1/main.h",
+				"1/2/lib.c",
+			},
+			fileSrcGlobPath: "**/*.{c,h}",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -195,3 +246,52 @@ func Test_Resolve(t *testing.T) {
 	}
 
 }
+
+func TestResolveFileInputsSingleVsDoubleStar(t *testing.T) {
+	tempdir, cleanupFunc := fstest.CreateTempDir(t)
+	defer cleanupFunc()
+
+	createFiles(t, tempdir, []string{
+		"main.go",
+		"pkg/util.go",
+		"pkg/internal/helper.go",
+		"pkg/README.md",
+	})
+
+	testcases := []struct {
+		patterns        []string
+		expectedMatches []string
+	}{
+		{
+			patterns:        []string{"*.go"},
+			expectedMatches: []string{"main.go"},
+		},
+		{
+			patterns:        []string{"pkg/*.go"},
+			expectedMatches: []string{"pkg/util.go"},
+		},
+		{
+			patterns:        []string{"**/*.go"},
+			expectedMatches: []string{"main.go", "pkg/util.go", "pkg/internal/helper.go"},
+		},
+		{
+			patterns:        []string{"pkg/**/*.go"},
+			expectedMatches: []string{"pkg/util.go", "pkg/internal/helper.go"},
+		},
+		{
+			patterns:        []string{"pkg/**", "pkg/*.go"},
+			expectedMatches: []string{"pkg/util.go", "pkg/internal/helper.go", "pkg/README.md"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(strings.Join(tc.patterns, ","), func(t *testing.T) {
+			res, err := ResolveFileInputs(tempdir, tc.patterns, nil)
+			if err != nil {
+				t.Fatal("ResolveFileInputs failed:", err)
+			}
+
+			checkFilesInResolvedFiles(t, tempdir, res, &testcase{expectedMatches: tc.expectedMatches})
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package gosource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/fs"
+)
+
+// Cache stores the results of resolving Go source files in a directory.
+// Results are stored per resolved directory, environment and digest of the
+// go.mod and go.sum files. A result is only used if none of the resolved
+// files, the other Go files in the scanned directories and the directories
+// changed their size or modification time since it was stored.
+type Cache struct {
+	dir string
+}
+
+// cacheFormatVersion is part of the cache keys, it must be increased when
+// the information that is used to determine if an entry is current changes,
+// to not use entries of older versions.
+const cacheFormatVersion = "2"
+
+// cacheEntry is the stored result of resolving a directory
+type cacheEntry struct {
+	Files []*fileStat `json:"files"`
+	Dirs  []*fileStat `json:"dirs"`
+	// OtherGoFiles are the .go files in Dirs that were not resolved, e.g.
+	// because of build constraints. Changing them in place does not
+	// change the modification time of their directory but can change
+	// the result.
+	OtherGoFiles []*fileStat `json:"other_go_files"`
+}
+
+// fileStat contains the file attributes that are checked to determine if a
+// cacheEntry is outdated
+type fileStat struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	MTime int64  `json:"mtime"`
+}
+
+// NewCache returns a cache that stores results in dir.
+// The directory is created when the first result is stored.
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// key returns the key of the cache entry for resolving path with env.
+func (c *Cache) key(path string, env []string) (string, error) {
+	h := sha256.New()
+
+	_, _ = h.Write([]byte(cacheFormatVersion + "\x00"))
+	_, _ = h.Write([]byte(path + "\x00"))
+	for _, e := range env {
+		_, _ = h.Write([]byte(e + "\x00"))
+	}
+
+	goModPath, err := fs.FindFileInParentDirs(path, "go.mod")
+	if err != nil {
+		if err == os.ErrNotExist {
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}
+
+		return "", errors.Wrap(err, "searching go.mod file failed")
+	}
+
+	for _, p := range []string{goModPath, filepath.Join(filepath.Dir(goModPath), "go.sum")} {
+		if !fs.FileExists(p) {
+			continue
+		}
+
+		digest, err := fs.FileSHA256(p)
+		if err != nil {
+			return "", errors.Wrapf(err, "calculating digest of %s failed", p)
+		}
+
+		_, _ = h.Write([]byte(p + ":" + digest + "\x00"))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *Cache) entryPath(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// get returns the cached files for key. If no entry exists or it is
+// outdated, false is returned.
+func (c *Cache) get(key string) ([]string, bool, error) {
+	content, err := ioutil.ReadFile(c.entryPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false, errors.Wrapf(err, "parsing %s failed", c.entryPath(key))
+	}
+
+	for _, stats := range [][]*fileStat{entry.Dirs, entry.Files, entry.OtherGoFiles} {
+		for _, st := range stats {
+			if !st.isCurrent() {
+				return nil, false, nil
+			}
+		}
+	}
+
+	files := make([]string, 0, len(entry.Files))
+	for _, f := range entry.Files {
+		files = append(files, f.Path)
+	}
+
+	return files, true, nil
+}
+
+// put stores files as result of resolving path under key.
+func (c *Cache) put(key, path string, files []string) error {
+	var entry cacheEntry
+
+	dirs, err := subDirs(path)
+	if err != nil {
+		return err
+	}
+
+	seenDirs := make(map[string]struct{}, len(dirs))
+	for _, d := range dirs {
+		seenDirs[d] = struct{}{}
+	}
+
+	for _, f := range files {
+		d := filepath.Dir(f)
+		if _, exist := seenDirs[d]; !exist {
+			seenDirs[d] = struct{}{}
+			dirs = append(dirs, d)
+		}
+	}
+
+	for _, d := range dirs {
+		st, err := newFileStat(d)
+		if err != nil {
+			return err
+		}
+
+		entry.Dirs = append(entry.Dirs, st)
+	}
+
+	resolved := make(map[string]struct{}, len(files))
+
+	for _, f := range files {
+		st, err := newFileStat(f)
+		if err != nil {
+			return err
+		}
+
+		entry.Files = append(entry.Files, st)
+		resolved[f] = struct{}{}
+	}
+
+	for _, d := range dirs {
+		goFiles, err := goFilesInDir(d)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range goFiles {
+			if _, exist := resolved[f]; exist {
+				continue
+			}
+
+			st, err := newFileStat(f)
+			if err != nil {
+				return err
+			}
+
+			entry.OtherGoFiles = append(entry.OtherGoFiles, st)
+		}
+	}
+
+	content, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.Mkdir(c.dir); err != nil {
+		return errors.Wrapf(err, "creating directory %s failed", c.dir)
+	}
+
+	return fs.WriteFileAtomic(c.entryPath(key), bytes.NewReader(content), 0644)
+}
+
+// subDirs returns path and all directories below it. Changes of their
+// modification times indicate that Go files or packages were added or
+// removed.
+func subDirs(path string) ([]string, error) {
+	var dirs []string
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
+
+// goFilesInDir returns the paths of the .go files in dir, subdirectories are
+// not searched.
+func goFilesInDir(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".go" {
+			continue
+		}
+
+		res = append(res, filepath.Join(dir, e.Name()))
+	}
+
+	return res, nil
+}
+
+func newFileStat(path string) (*fileStat, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileStat{
+		Path:  path,
+		Size:  fi.Size(),
+		MTime: fi.ModTime().UnixNano(),
+	}, nil
+}
+
+// isCurrent returns true if the file still exists and its size and
+// modification time did not change.
+func (s *fileStat) isCurrent() bool {
+	cur, err := newFileStat(s.Path)
+	if err != nil {
+		return false
+	}
+
+	return *cur == *s
+}
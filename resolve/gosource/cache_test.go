@@ -0,0 +1,203 @@
+package gosource
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/fs"
+	"github.com/simplesurance/baur/testutils/fstest"
+	"github.com/simplesurance/baur/testutils/strtest"
+)
+
+// cacheHitLogger records if the resolver reported that it used a cached
+// result
+type cacheHitLogger struct {
+	hits int
+}
+
+func (l *cacheHitLogger) logf(format string, _ ...interface{}) {
+	if strings.HasPrefix(format, "gosource-resolver: using cached result") {
+		l.hits++
+	}
+}
+
+func appendToFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveWithCache(t *testing.T) {
+	tmpdir, projectPath, filepaths, cleanupFn := createGoProject(t, "baur-test/", true)
+	defer cleanupFn()
+
+	cache := NewCache(path.Join(tmpdir, "cache"))
+
+	resolveCached := func(t *testing.T, expectCacheHit bool, expectedFiles []string) {
+		t.Helper()
+
+		var logger cacheHitLogger
+
+		resolvedFiles, err := NewResolver(logger.logf, nil, projectPath).WithCache(cache).Resolve()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expectCacheHit && logger.hits == 0 {
+			t.Error("cached result was not used")
+		}
+
+		if !expectCacheHit && logger.hits != 0 {
+			t.Error("outdated cached result was used")
+		}
+
+		if len(resolvedFiles) != len(expectedFiles) {
+			t.Errorf("resolved %d files (%v), expected %d (%v)",
+				len(resolvedFiles), resolvedFiles, len(expectedFiles), expectedFiles)
+		}
+
+		for _, path := range expectedFiles {
+			if !strtest.InSlice(resolvedFiles, path) {
+				t.Errorf("resolved go source files are missing '%s'", path)
+			}
+		}
+	}
+
+	t.Run("first_run_resolves", func(t *testing.T) {
+		resolveCached(t, false, filepaths)
+	})
+
+	t.Run("unchanged_uses_cache", func(t *testing.T) {
+		resolveCached(t, true, filepaths)
+	})
+
+	t.Run("changed_go_file_invalidates", func(t *testing.T) {
+		appendToFile(t, filepaths[1], "\n// changed\n")
+		resolveCached(t, false, filepaths)
+		resolveCached(t, true, filepaths)
+	})
+
+	t.Run("new_go_file_invalidates", func(t *testing.T) {
+		newFilePath := path.Join(projectPath, "generator", "seed.go")
+		fstest.WriteToFile(t, []byte("package generator\n"), newFilePath)
+
+		expected := append([]string{newFilePath}, filepaths...)
+		resolveCached(t, false, expected)
+		resolveCached(t, true, expected)
+
+		filepaths = expected
+	})
+
+	t.Run("excluded_go_file_changed_in_place_invalidates", func(t *testing.T) {
+		excludedFilePath := path.Join(projectPath, "generator", "excluded.go")
+		fstest.WriteToFile(t, []byte("// +build ignore\n\npackage generator\n"), excludedFilePath)
+
+		resolveCached(t, false, filepaths)
+		resolveCached(t, true, filepaths)
+
+		// overwriting an existing file does not change the
+		// modification time of its directory
+		dirFi, err := os.Stat(path.Dir(excludedFilePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fstest.WriteToFile(t, []byte("package generator\n"), excludedFilePath)
+
+		if err := os.Chtimes(path.Dir(excludedFilePath), dirFi.ModTime(), dirFi.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := append([]string{excludedFilePath}, filepaths...)
+		resolveCached(t, false, expected)
+		resolveCached(t, true, expected)
+
+		filepaths = expected
+	})
+
+	t.Run("changed_go_mod_invalidates", func(t *testing.T) {
+		appendToFile(t, path.Join(projectPath, "go.mod"), "\n// changed\n")
+		resolveCached(t, false, filepaths)
+	})
+}
+
+// createLargeGoProject creates a Go module in dir with pkgCnt packages,
+// every package imports all packages with a higher number.
+func createLargeGoProject(b *testing.B, dir string, pkgCnt int) {
+	b.Helper()
+
+	if err := fs.Mkdir(dir); err != nil {
+		b.Fatal(err)
+	}
+
+	fstest.WriteToFile(b, []byte(testFileGoMod), path.Join(dir, "go.mod"))
+
+	for i := 0; i < pkgCnt; i++ {
+		var imports strings.Builder
+		var uses strings.Builder
+
+		for j := i + 1; j < pkgCnt; j++ {
+			fmt.Fprintf(&imports, "\t\"github.com/simplesurance/baur-test/pkg%d\"\n", j)
+			fmt.Fprintf(&uses, "\t_ = pkg%d.Value\n", j)
+		}
+
+		content := fmt.Sprintf("package pkg%d\n\nimport (\n%s)\n\nconst Value = %d\n\nfunc init() {\n%s}\n",
+			i, imports.String(), i, uses.String())
+
+		pkgDir := path.Join(dir, fmt.Sprintf("pkg%d", i))
+		if err := fs.Mkdir(pkgDir); err != nil {
+			b.Fatal(err)
+		}
+
+		fstest.WriteToFile(b, []byte(content), path.Join(pkgDir, "pkg.go"))
+	}
+}
+
+func BenchmarkResolve(b *testing.B) {
+	const pkgCnt = 200
+
+	tmpdir, cleanupFn := fstest.CreateTempDir(b)
+	defer cleanupFn()
+
+	projectPath := path.Join(tmpdir, "project")
+	createLargeGoProject(b, projectPath, pkgCnt)
+
+	b.Run("nocache", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			files, err := NewResolver(nil, nil, projectPath).Resolve()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if len(files) != pkgCnt {
+				b.Fatalf("resolved %d files, expected %d", len(files), pkgCnt)
+			}
+		}
+	})
+
+	b.Run("cache", func(b *testing.B) {
+		cache := NewCache(path.Join(tmpdir, "cache"))
+
+		for i := 0; i < b.N; i++ {
+			files, err := NewResolver(nil, nil, projectPath).WithCache(cache).Resolve()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if len(files) != pkgCnt {
+				b.Fatalf("resolved %d files, expected %d", len(files), pkgCnt)
+			}
+		}
+	})
+}
@@ -25,6 +25,7 @@ type Resolver struct {
 	env    []string
 	goDirs []string
 	logFn  func(string, ...interface{})
+	cache  *Cache
 }
 
 // NewResolver returns a resolver that resolves all go source files in the
@@ -79,9 +80,19 @@ func getLastEnv(env []string, key string) string {
 	return ""
 }
 
+// WithCache configures the resolver to use cached results from c and to
+// store new results in it.
+func (r *Resolver) WithCache(c *Cache) *Resolver {
+	r.cache = c
+	return r
+}
+
 // Resolve returns the Go source files in the passed directories plus all
 // source files of the imported packages.
 // Testfiles and stdlib dependencies are ignored.
+// Files are selected for the build context of the resolver environment
+// (GOOS, GOARCH, tags passed via GOFLAGS), files that are excluded by build
+// constraints are not returned.
 func (r *Resolver) Resolve() ([]string, error) {
 	var allFiles []string
 	var err error
@@ -107,7 +118,7 @@ func (r *Resolver) Resolve() ([]string, error) {
 	r.logFn("gosource-resolver: environment: '%s'\n", env)
 
 	for _, path := range r.goDirs {
-		files, err := r.resolve(path, goroot, env)
+		files, err := r.resolveCached(path, goroot, env)
 		if err != nil {
 			return nil, err
 		}
@@ -152,6 +163,41 @@ func whitelistedEnv() []string {
 	return env
 }
 
+// resolveCached returns the cached result for path if the resolver has a
+// cache and it contains a current result, otherwise path is resolved and the
+// result is stored in the cache.
+func (r *Resolver) resolveCached(path, goroot string, env []string) ([]string, error) {
+	if r.cache == nil {
+		return r.resolve(path, goroot, env)
+	}
+
+	key, err := r.cache.key(path, env)
+	if err != nil {
+		return nil, err
+	}
+
+	files, exist, err := r.cache.get(key)
+	if err != nil {
+		r.logFn("gosource-resolver: reading cached result of '%s' failed, ignoring cache: %s\n", path, err)
+	}
+
+	if exist {
+		r.logFn("gosource-resolver: using cached result for '%s'\n", path)
+		return files, nil
+	}
+
+	files, err = r.resolve(path, goroot, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.cache.put(key, path, files); err != nil {
+		r.logFn("gosource-resolver: storing result of '%s' in cache failed: %s\n", path, err)
+	}
+
+	return files, nil
+}
+
 func (r *Resolver) resolve(path, goroot string, env []string) ([]string, error) {
 	cfg := &packages.Config{
 		Mode: packages.LoadImports,
@@ -118,3 +118,60 @@ func TestResolveWithGoMod(t *testing.T) {
 	}
 
 }
+
+func TestResolveHonorsBuildConstraints(t *testing.T) {
+	_, projectPath, filepaths, cleanupFn := createGoProject(t, "baur-test/", true)
+	defer cleanupFn()
+
+	genDir := path.Join(projectPath, "generator")
+	linuxGoPath := path.Join(genDir, "seed_linux.go")
+	windowsGoPath := path.Join(genDir, "seed_windows.go")
+	integrationGoPath := path.Join(genDir, "integration.go")
+
+	fstest.WriteToFile(t, []byte("package generator\n"), linuxGoPath)
+	fstest.WriteToFile(t, []byte("package generator\n"), windowsGoPath)
+	fstest.WriteToFile(t, []byte("//go:build integration\n// +build integration\n\npackage generator\n"), integrationGoPath)
+
+	testcases := []struct {
+		name     string
+		env      []string
+		expected []string
+	}{
+		{
+			name:     "linux",
+			env:      []string{"GOOS=linux"},
+			expected: append([]string{linuxGoPath}, filepaths...),
+		},
+		{
+			name:     "windows",
+			env:      []string{"GOOS=windows"},
+			expected: append([]string{windowsGoPath}, filepaths...),
+		},
+		{
+			name:     "linux_integration_tag",
+			env:      []string{"GOOS=linux", "GOFLAGS=-tags=integration"},
+			expected: append([]string{linuxGoPath, integrationGoPath}, filepaths...),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolvedFiles, err := NewResolver(nil, tc.env, projectPath).Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range resolvedFiles {
+				if !strtest.InSlice(tc.expected, path) {
+					t.Errorf("resolved files contain '%s' but it's excluded by build constraints", path)
+				}
+			}
+
+			for _, path := range tc.expected {
+				if !strtest.InSlice(resolvedFiles, path) {
+					t.Errorf("resolved go source files are missing '%s'", path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NewClientFunc returns a Storer that is connected to the storage at uri.
+type NewClientFunc func(uri string) (Storer, error)
+
+// defaultScheme is used for URIs without a scheme, it keeps postgres
+// key=value connection strings working
+const defaultScheme = "postgres"
+
+var (
+	backendsLock sync.RWMutex
+	backends     = map[string]NewClientFunc{}
+)
+
+// RegisterBackend makes a storage backend available to NewClient for URIs
+// with the given scheme. It is called by the backend packages in their init
+// function.
+// If RegisterBackend is called twice for the same scheme it panics.
+func RegisterBackend(scheme string, newFn NewClientFunc) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	scheme = strings.ToLower(scheme)
+
+	if _, exist := backends[scheme]; exist {
+		panic(fmt.Sprintf("storage: RegisterBackend called twice for scheme %q", scheme))
+	}
+
+	backends[scheme] = newFn
+}
+
+// SchemaVersionError is returned by NewClient when the database structures
+// of the storage do not have the version that is required by the backend
+type SchemaVersionError struct {
+	Version         int
+	RequiredVersion int
+}
+
+func (e *SchemaVersionError) Error() string {
+	switch {
+	case e.Version == 0:
+		return "the database is not initialized, run 'baur init db'"
+	case e.Version < e.RequiredVersion:
+		return fmt.Sprintf("the database schema version %d is outdated, version %d is required, "+
+			"run 'baur init db --migrate' to migrate it", e.Version, e.RequiredVersion)
+	default:
+		return fmt.Sprintf("the database schema version %d is newer than version %d "+
+			"that is supported by this baur version, update baur", e.Version, e.RequiredVersion)
+	}
+}
+
+// NewClient returns a Storer for the storage at uri.
+// The backend is chosen by the scheme of the URI, the packages of the
+// backends must be imported to register them. If the uri has no scheme, the
+// postgres backend is used.
+// If the backend implements Migrator and the schema version of the storage
+// is not the LatestSchemaVersion of the backend, a *SchemaVersionError is
+// returned.
+func NewClient(uri string) (Storer, error) {
+	clt, err := NewUncheckedClient(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSchemaVersion(clt); err != nil {
+		// nolint: errcheck
+		clt.Close()
+		return nil, err
+	}
+
+	return clt, nil
+}
+
+// NewUncheckedClient returns a Storer like NewClient but does not check the
+// schema version of the storage. It is used to initialize and migrate the
+// storage.
+func NewUncheckedClient(uri string) (Storer, error) {
+	scheme := defaultScheme
+
+	if idx := strings.Index(uri, "://"); idx != -1 {
+		scheme = strings.ToLower(uri[:idx])
+	}
+
+	backendsLock.RLock()
+	newFn, exist := backends[scheme]
+	backendsLock.RUnlock()
+
+	if !exist {
+		return nil, fmt.Errorf("storage URI scheme %q is not supported, supported schemes: %s",
+			scheme, strings.Join(supportedSchemes(), ", "))
+	}
+
+	return newFn(uri)
+}
+
+func checkSchemaVersion(clt Storer) error {
+	migrator, ok := clt.(Migrator)
+	if !ok {
+		return nil
+	}
+
+	version, err := migrator.SchemaVersion(context.Background())
+	if err != nil {
+		return fmt.Errorf("querying schema version failed: %s", err)
+	}
+
+	if version != migrator.LatestSchemaVersion() {
+		return &SchemaVersionError{
+			Version:         version,
+			RequiredVersion: migrator.LatestSchemaVersion(),
+		}
+	}
+
+	return nil
+}
+
+func supportedSchemes() []string {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+
+	res := make([]string, 0, len(backends))
+	for scheme := range backends {
+		res = append(res, scheme)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
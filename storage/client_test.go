@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewClientDispatchesByScheme(t *testing.T) {
+	var calledWith string
+	errTest := errors.New("test backend")
+
+	RegisterBackend("baurtest", func(uri string) (Storer, error) {
+		calledWith = uri
+		return nil, errTest
+	})
+
+	const uri = "BaurTest://localhost/db"
+
+	_, err := NewClient(uri)
+	if err != errTest {
+		t.Fatalf("NewClient returned error %v, expected %v", err, errTest)
+	}
+
+	if calledWith != uri {
+		t.Errorf("backend was called with uri %q, expected %q", calledWith, uri)
+	}
+}
+
+func TestNewClientFailsForUnknownScheme(t *testing.T) {
+	_, err := NewClient("unknown://localhost/db")
+	if err == nil {
+		t.Fatal("NewClient succeeded for an unknown scheme")
+	}
+
+	if !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("error message %q does not contain the unsupported scheme", err)
+	}
+}
+
+// migratorStorer is a Storer with a fixed schema version
+type migratorStorer struct {
+	Storer
+
+	version       int
+	latestVersion int
+	closed        bool
+}
+
+func (m *migratorStorer) SchemaVersion(context.Context) (int, error) {
+	return m.version, nil
+}
+
+func (m *migratorStorer) LatestSchemaVersion() int {
+	return m.latestVersion
+}
+
+func (m *migratorStorer) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestNewClientChecksSchemaVersion(t *testing.T) {
+	testcases := []struct {
+		name          string
+		version       int
+		wantErr       bool
+		wantErrSubstr string
+	}{
+		{name: "uptodate", version: 3},
+		{name: "notinitialized", version: 0, wantErr: true, wantErrSubstr: "baur init db"},
+		{name: "outdated", version: 1, wantErr: true, wantErrSubstr: "baur init db --migrate"},
+		{name: "newer", version: 4, wantErr: true, wantErrSubstr: "update baur"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := migratorStorer{version: tc.version, latestVersion: 3}
+			scheme := "baurtestmigrator" + tc.name
+
+			RegisterBackend(scheme, func(uri string) (Storer, error) {
+				return &m, nil
+			})
+
+			_, err := NewClient(scheme + "://localhost/db")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if !tc.wantErr {
+				return
+			}
+
+			if _, ok := err.(*SchemaVersionError); !ok {
+				t.Errorf("returned error is of type %T, expected *SchemaVersionError", err)
+			}
+
+			if !strings.Contains(err.Error(), tc.wantErrSubstr) {
+				t.Errorf("error message %q does not contain %q", err, tc.wantErrSubstr)
+			}
+
+			if !m.closed {
+				t.Error("client was not closed")
+			}
+
+			if _, err := NewUncheckedClient(scheme + "://localhost/db"); err != nil {
+				t.Errorf("NewUncheckedClient() returned error %v, expected none", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,516 @@
+// Package memory provides a storage backend that keeps all records in
+// memory. It is intended for tests that need a storage without a database.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/simplesurance/baur/storage"
+)
+
+// Client is a storage client that stores records in memory, it is safe for
+// concurrent use.
+// Record IDs are assigned in ascending order, starting with 1.
+type Client struct {
+	lock     sync.Mutex
+	apps     map[string]*storage.Application
+	builds   []*storage.Build
+	releases map[string]*storage.Release
+	nextIDs  map[string]int
+}
+
+var _ storage.Storer = &Client{}
+
+// New returns a new empty Client
+func New() *Client {
+	return &Client{
+		apps:     map[string]*storage.Application{},
+		releases: map[string]*storage.Release{},
+		nextIDs:  map[string]int{},
+	}
+}
+
+func (c *Client) nextID(recordType string) int {
+	c.nextIDs[recordType]++
+	return c.nextIDs[recordType]
+}
+
+// Init does nothing, no structures have to be created to store records
+func (c *Client) Init(context.Context) error {
+	return nil
+}
+
+// Close does nothing
+func (c *Client) Close() error {
+	return nil
+}
+
+// SaveBuild stores a copy of the build. The ID fields of the build and it's
+// application are set to the assigned record IDs, the ID of the build is
+// returned.
+func (c *Client) SaveBuild(_ context.Context, b *storage.Build) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	app, exist := c.apps[b.Application.Name]
+	if !exist {
+		app = &storage.Application{
+			ID:   c.nextID("application"),
+			Name: b.Application.Name,
+		}
+		c.apps[app.Name] = app
+	}
+
+	b.Application.ID = app.ID
+	b.ID = c.nextID("build")
+
+	for _, o := range b.Outputs {
+		o.Upload.ID = c.nextID("upload")
+	}
+
+	c.builds = append(c.builds, copyBuild(b))
+
+	return b.ID, nil
+}
+
+func copyBuild(b *storage.Build) *storage.Build {
+	res := *b
+
+	res.Outputs = make([]*storage.Output, 0, len(b.Outputs))
+	for _, o := range b.Outputs {
+		out := *o
+		res.Outputs = append(res.Outputs, &out)
+	}
+
+	res.Inputs = make([]*storage.Input, 0, len(b.Inputs))
+	for _, in := range b.Inputs {
+		input := *in
+		res.Inputs = append(res.Inputs, &input)
+	}
+
+	return &res
+}
+
+func withDuration(b *storage.Build) *storage.BuildWithDuration {
+	return &storage.BuildWithDuration{
+		Build:    *b,
+		Duration: b.StopTimeStamp.Sub(b.StartTimeStamp),
+	}
+}
+
+// withoutInputsOutputs returns a copy of the build without inputs and
+// outputs
+func withoutInputsOutputs(b *storage.Build) *storage.BuildWithDuration {
+	res := withDuration(b)
+	res.Outputs = nil
+	res.Inputs = nil
+
+	return res
+}
+
+func (c *Client) build(id int) *storage.Build {
+	for _, b := range c.builds {
+		if b.ID == id {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// GetApps returns all applications sorted by name
+func (c *Client) GetApps(context.Context) ([]*storage.Application, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	res := make([]*storage.Application, 0, len(c.apps))
+	for _, app := range c.apps {
+		a := *app
+		res = append(res, &a)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+// GetSameTotalInputDigestsForAppBuilds returns the IDs of builds of the
+// application that were started at or after startTs and have the same
+// total input digest as another build. The keys of the returned map are the
+// digests.
+func (c *Client) GetSameTotalInputDigestsForAppBuilds(_ context.Context, appName string, startTs time.Time) (map[string][]int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	buildIDs := map[string][]int{}
+
+	for _, b := range c.builds {
+		if b.Application.Name != appName || b.TotalInputDigest == "" || b.StartTimeStamp.Before(startTs) {
+			continue
+		}
+
+		buildIDs[b.TotalInputDigest] = append(buildIDs[b.TotalInputDigest], b.ID)
+	}
+
+	res := map[string][]int{}
+	for digest, ids := range buildIDs {
+		if len(ids) > 1 {
+			res[digest] = ids
+		}
+	}
+
+	return res, nil
+}
+
+// GetLatestBuildByDigest returns the build of the application with the
+// total input digest. If multiple builds exist, the one with the latest stop
+// timestamp is returned.
+// The outputs of the build are returned, inputs are not.
+// If no build exists storage.ErrNotExist is returned.
+func (c *Client) GetLatestBuildByDigest(_ context.Context, appName, totalInputDigest string) (*storage.BuildWithDuration, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var latest *storage.Build
+
+	for _, b := range c.builds {
+		if b.Application.Name != appName || b.TotalInputDigest != totalInputDigest {
+			continue
+		}
+
+		if latest == nil || b.StopTimeStamp.After(latest.StopTimeStamp) {
+			latest = b
+		}
+	}
+
+	if latest == nil {
+		return nil, storage.ErrNotExist
+	}
+
+	res := withoutInputsOutputs(latest)
+	res.Outputs = copyBuild(latest).Outputs
+	sortOutputs(res.Outputs)
+
+	return res, nil
+}
+
+func sortOutputs(outputs []*storage.Output) {
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].Name < outputs[j].Name
+	})
+}
+
+// GetBuildOutputs returns the outputs of a build sorted by their name.
+// If no build with the ID exists, storage.ErrNotExist is returned.
+func (c *Client) GetBuildOutputs(_ context.Context, buildID int) ([]*storage.Output, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	b := c.build(buildID)
+	if b == nil {
+		return nil, storage.ErrNotExist
+	}
+
+	res := copyBuild(b).Outputs
+	sortOutputs(res)
+
+	return res, nil
+}
+
+// GetBuildInputs returns the inputs of a build sorted by their URI
+func (c *Client) GetBuildInputs(_ context.Context, buildID int) ([]*storage.Input, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	b := c.build(buildID)
+	if b == nil {
+		return nil, nil
+	}
+
+	res := copyBuild(b).Inputs
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].URI < res[j].URI
+	})
+
+	return res, nil
+}
+
+// BuildExist returns true if a build with the ID exists
+func (c *Client) BuildExist(_ context.Context, id int) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.build(id) != nil, nil
+}
+
+// GetBuildWithoutInputsOutputs returns the build with the ID, if it does not
+// exist storage.ErrNotExist is returned
+func (c *Client) GetBuildWithoutInputsOutputs(_ context.Context, id int) (*storage.BuildWithDuration, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	b := c.build(id)
+	if b == nil {
+		return nil, storage.ErrNotExist
+	}
+
+	return withoutInputsOutputs(b), nil
+}
+
+// GetBuildsWithoutInputsOutputs returns the builds that match all filters,
+// sorted by the sorters. If limit is greater than 0, at most limit builds are
+// returned.
+func (c *Client) GetBuildsWithoutInputsOutputs(_ context.Context, filters []*storage.Filter, sorters []*storage.Sorter, limit int) ([]*storage.BuildWithDuration, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var res []*storage.BuildWithDuration
+
+	for _, b := range c.builds {
+		build := withoutInputsOutputs(b)
+
+		matched, err := matchesFilters(build, filters)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			res = append(res, build)
+		}
+	}
+
+	for _, s := range sorters {
+		if _, err := fieldValue(&storage.BuildWithDuration{}, s.Field); err != nil {
+			return nil, err
+		}
+
+		if s.Order != storage.OrderAsc && s.Order != storage.OrderDesc {
+			return nil, fmt.Errorf("unsupported sort order %s", s.Order)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		for _, s := range sorters {
+			cmp := compare(mustFieldValue(res[i], s.Field), mustFieldValue(res[j], s.Field))
+			if cmp == 0 {
+				continue
+			}
+
+			if s.Order == storage.OrderDesc {
+				return cmp > 0
+			}
+
+			return cmp < 0
+		}
+
+		return false
+	})
+
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res, nil
+}
+
+func fieldValue(b *storage.BuildWithDuration, f storage.Field) (interface{}, error) {
+	switch f {
+	case storage.FieldApplicationName:
+		return b.Application.Name, nil
+	case storage.FieldBuildDuration:
+		return b.Duration, nil
+	case storage.FieldBuildStartTime:
+		return b.StartTimeStamp, nil
+	case storage.FieldBuildID:
+		return b.ID, nil
+	default:
+		return nil, fmt.Errorf("unsupported field %s", f)
+	}
+}
+
+func mustFieldValue(b *storage.BuildWithDuration, f storage.Field) interface{} {
+	v, err := fieldValue(b, f)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+func matchesFilters(b *storage.BuildWithDuration, filters []*storage.Filter) (bool, error) {
+	for _, f := range filters {
+		v, err := fieldValue(b, f.Field)
+		if err != nil {
+			return false, err
+		}
+
+		matched, err := matchesFilter(v, f)
+		if err != nil {
+			return false, err
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func matchesFilter(v interface{}, f *storage.Filter) (bool, error) {
+	switch f.Operator {
+	case storage.OpEQ:
+		return compare(v, f.Value) == 0, nil
+	case storage.OpGT:
+		return compare(v, f.Value) > 0, nil
+	case storage.OpLT:
+		return compare(v, f.Value) < 0, nil
+	case storage.OpIN:
+		values := reflect.ValueOf(f.Value)
+		if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+			return false, fmt.Errorf("value of %s filter must be a slice, is %T", f.Operator, f.Value)
+		}
+
+		for i := 0; i < values.Len(); i++ {
+			if compare(v, values.Index(i).Interface()) == 0 {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported filter operator %s", f.Operator)
+	}
+}
+
+// compare returns -1 if a is smaller then b, 1 if it is greater and 0 if
+// they are equal. Values of different types are compared by their string
+// representation.
+func compare(a, b interface{}) int {
+	switch av := a.(type) {
+	case int:
+		if bv, ok := b.(int); ok {
+			return compareInt64(int64(av), int64(bv))
+		}
+
+	case time.Duration:
+		if bv, ok := b.(time.Duration); ok {
+			return compareInt64(int64(av), int64(bv))
+		}
+
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			switch {
+			case av.Before(bv):
+				return -1
+			case av.After(bv):
+				return 1
+			default:
+				return 0
+			}
+		}
+
+	case string:
+		if bv, ok := b.(string); ok {
+			return compareStr(av, bv)
+		}
+	}
+
+	return compareStr(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareStr(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CreateRelease stores a release that references the builds with the passed
+// IDs. If a release with the name already exists storage.ErrExist is
+// returned.
+func (c *Client) CreateRelease(_ context.Context, name string, buildIDs []int) (*storage.Release, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, exist := c.releases[name]; exist {
+		return nil, storage.ErrExist
+	}
+
+	ids := uniqSortedInts(buildIDs)
+	for _, id := range ids {
+		if c.build(id) == nil {
+			return nil, fmt.Errorf("build with id %d does not exist", id)
+		}
+	}
+
+	release := storage.Release{
+		ID:        c.nextID("release"),
+		Name:      name,
+		CreatedAt: time.Now(),
+		BuildIDs:  ids,
+	}
+	c.releases[name] = &release
+
+	res := release
+	res.BuildIDs = append([]int(nil), ids...)
+
+	return &res, nil
+}
+
+// GetRelease returns the release with the given name. If it does not exist
+// storage.ErrNotExist is returned.
+func (c *Client) GetRelease(_ context.Context, name string) (*storage.Release, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	release, exist := c.releases[name]
+	if !exist {
+		return nil, storage.ErrNotExist
+	}
+
+	res := *release
+	res.BuildIDs = append([]int(nil), release.BuildIDs...)
+
+	return &res, nil
+}
+
+func uniqSortedInts(in []int) []int {
+	res := make([]int, 0, len(in))
+	seen := make(map[int]struct{}, len(in))
+
+	for _, i := range in {
+		if _, exist := seen[i]; exist {
+			continue
+		}
+
+		seen[i] = struct{}{}
+		res = append(res, i)
+	}
+
+	sort.Ints(res)
+
+	return res
+}
@@ -0,0 +1,244 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/baur/storage"
+)
+
+func saveBuild(t *testing.T, clt *Client, appName, digest string, start, stop time.Time) *storage.Build {
+	t.Helper()
+
+	b := storage.Build{
+		Application:      storage.Application{Name: appName},
+		StartTimeStamp:   start,
+		StopTimeStamp:    stop,
+		TotalInputDigest: digest,
+		Outputs: []*storage.Output{
+			{Name: "b", Upload: storage.Upload{URI: "s3://bucket/b", Method: storage.S3}},
+			{Name: "a", Upload: storage.Upload{URI: "s3://bucket/a", Method: storage.S3}},
+		},
+	}
+
+	if _, err := clt.SaveBuild(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	return &b
+}
+
+func TestGetLatestBuildByDigest(t *testing.T) {
+	ctx := context.Background()
+	clt := New()
+	ts := time.Now()
+
+	saveBuild(t, clt, "calc", "sha256:1", ts, ts.Add(2*time.Second))
+	latest := saveBuild(t, clt, "calc", "sha256:1", ts, ts.Add(3*time.Second))
+	saveBuild(t, clt, "calc", "sha256:1", ts, ts.Add(time.Second))
+	saveBuild(t, clt, "calc", "sha256:2", ts, ts.Add(time.Hour))
+	saveBuild(t, clt, "other", "sha256:1", ts, ts.Add(time.Hour))
+
+	build, err := clt.GetLatestBuildByDigest(ctx, "calc", "sha256:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if build.ID != latest.ID {
+		t.Errorf("returned build has id %d, expected %d", build.ID, latest.ID)
+	}
+
+	if build.Duration != 3*time.Second {
+		t.Errorf("returned build has duration %s, expected %s", build.Duration, 3*time.Second)
+	}
+
+	if len(build.Outputs) != 2 || build.Outputs[0].Name != "a" || build.Outputs[1].Name != "b" {
+		t.Errorf("returned build has outputs %+v, expected outputs a and b", build.Outputs)
+	}
+}
+
+func TestNotExist(t *testing.T) {
+	ctx := context.Background()
+	clt := New()
+
+	if _, err := clt.GetLatestBuildByDigest(ctx, "calc", "sha256:1"); err != storage.ErrNotExist {
+		t.Errorf("GetLatestBuildByDigest returned error %v, expected %v", err, storage.ErrNotExist)
+	}
+
+	if _, err := clt.GetBuildWithoutInputsOutputs(ctx, 1); err != storage.ErrNotExist {
+		t.Errorf("GetBuildWithoutInputsOutputs returned error %v, expected %v", err, storage.ErrNotExist)
+	}
+
+	if _, err := clt.GetBuildOutputs(ctx, 1); err != storage.ErrNotExist {
+		t.Errorf("GetBuildOutputs returned error %v, expected %v", err, storage.ErrNotExist)
+	}
+
+	if _, err := clt.GetRelease(ctx, "v1"); err != storage.ErrNotExist {
+		t.Errorf("GetRelease returned error %v, expected %v", err, storage.ErrNotExist)
+	}
+}
+
+func TestSaveBuild(t *testing.T) {
+	ctx := context.Background()
+	clt := New()
+	ts := time.Now()
+
+	b := storage.Build{
+		Application:      storage.Application{Name: "calc"},
+		StartTimeStamp:   ts,
+		StopTimeStamp:    ts.Add(time.Minute),
+		TotalInputDigest: "sha256:1",
+	}
+
+	id, err := clt.SaveBuild(ctx, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != b.ID {
+		t.Errorf("SaveBuild returned id %d, build has id %d", id, b.ID)
+	}
+
+	stored, err := clt.GetBuildWithoutInputsOutputs(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Application.Name != b.Application.Name || stored.TotalInputDigest != b.TotalInputDigest {
+		t.Errorf("stored build is %+v, expected it to match %+v", stored.Build, b)
+	}
+
+	if stored.Duration != time.Minute {
+		t.Errorf("stored build has duration %s, expected %s", stored.Duration, time.Minute)
+	}
+}
+
+func TestSaveAssignsIDs(t *testing.T) {
+	clt := New()
+	ts := time.Now()
+
+	b1 := saveBuild(t, clt, "calc", "sha256:1", ts, ts)
+	b2 := saveBuild(t, clt, "other", "sha256:1", ts, ts)
+	b3 := saveBuild(t, clt, "calc", "sha256:1", ts, ts)
+
+	if b1.ID != 1 || b2.ID != 2 || b3.ID != 3 {
+		t.Errorf("builds have ids %d, %d, %d, expected 1, 2, 3", b1.ID, b2.ID, b3.ID)
+	}
+
+	if b1.Application.ID != b3.Application.ID || b1.Application.ID == b2.Application.ID {
+		t.Errorf("application ids of builds are %d, %d, %d, expected the ids of builds of the same app to be equal",
+			b1.Application.ID, b2.Application.ID, b3.Application.ID)
+	}
+}
+
+func TestGetBuildsWithoutInputsOutputs(t *testing.T) {
+	ctx := context.Background()
+	clt := New()
+	ts := time.Now()
+
+	saveBuild(t, clt, "calc", "sha256:1", ts, ts.Add(time.Second))
+	saveBuild(t, clt, "other", "sha256:1", ts, ts.Add(3*time.Second))
+	saveBuild(t, clt, "calc", "sha256:1", ts, ts.Add(2*time.Second))
+
+	builds, err := clt.GetBuildsWithoutInputsOutputs(
+		ctx,
+		[]*storage.Filter{
+			{Field: storage.FieldApplicationName, Operator: storage.OpEQ, Value: "calc"},
+		},
+		[]*storage.Sorter{
+			{Field: storage.FieldBuildDuration, Order: storage.OrderDesc},
+		},
+		0,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for _, b := range builds {
+		ids = append(ids, b.ID)
+
+		if len(b.Outputs) != 0 {
+			t.Errorf("build %d has outputs, expected none", b.ID)
+		}
+	}
+
+	if !reflect.DeepEqual(ids, []int{3, 1}) {
+		t.Errorf("returned builds have ids %v, expected [3 1]", ids)
+	}
+
+	builds, err = clt.GetBuildsWithoutInputsOutputs(
+		ctx,
+		nil,
+		[]*storage.Sorter{
+			{Field: storage.FieldBuildDuration, Order: storage.OrderDesc},
+		},
+		2,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids = nil
+	for _, b := range builds {
+		ids = append(ids, b.ID)
+	}
+
+	if !reflect.DeepEqual(ids, []int{2, 3}) {
+		t.Errorf("returned builds with limit 2 have ids %v, expected [2 3]", ids)
+	}
+}
+
+func TestCreateRelease(t *testing.T) {
+	ctx := context.Background()
+	clt := New()
+	ts := time.Now()
+
+	saveBuild(t, clt, "calc", "sha256:1", ts, ts)
+	saveBuild(t, clt, "other", "sha256:1", ts, ts)
+
+	if _, err := clt.CreateRelease(ctx, "v1", []int{2, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := clt.CreateRelease(ctx, "v1", []int{1}); err != storage.ErrExist {
+		t.Errorf("CreateRelease returned error %v for an existing release, expected %v", err, storage.ErrExist)
+	}
+
+	if _, err := clt.CreateRelease(ctx, "v2", []int{3}); err == nil {
+		t.Error("CreateRelease succeeded for a non-existing build")
+	}
+
+	release, err := clt.GetRelease(ctx, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(release.BuildIDs, []int{1, 2}) {
+		t.Errorf("release has build ids %v, expected [1 2]", release.BuildIDs)
+	}
+}
+
+func TestGetSameTotalInputDigestsForAppBuilds(t *testing.T) {
+	ctx := context.Background()
+	clt := New()
+	ts := time.Now()
+
+	b1 := saveBuild(t, clt, "calc", "sha256:1", ts, ts)
+	saveBuild(t, clt, "calc", "sha256:2", ts, ts)
+	b3 := saveBuild(t, clt, "calc", "sha256:1", ts, ts)
+	saveBuild(t, clt, "calc", "sha256:1", ts.Add(-time.Hour), ts)
+	saveBuild(t, clt, "other", "sha256:1", ts, ts)
+
+	digests, err := clt.GetSameTotalInputDigestsForAppBuilds(ctx, "calc", ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]int{"sha256:1": {b1.ID, b3.ID}}
+	if !reflect.DeepEqual(digests, expected) {
+		t.Errorf("returned %v, expected %v", digests, expected)
+	}
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -18,8 +19,9 @@ FROM application
 JOIN build ON application.id = build.application_id
 LEFT OUTER JOIN vcs ON vcs.id = build.vcs_id`
 
-// GetBuildsWithoutInputsOutputs returns builds from the database
-func (c *Client) GetBuildsWithoutInputsOutputs(filters []*storage.Filter, sorters []*storage.Sorter) (
+// GetBuildsWithoutInputsOutputs returns builds from the database, if limit
+// is greater than 0 at most limit builds are returned
+func (c *Client) GetBuildsWithoutInputsOutputs(ctx context.Context, filters []*storage.Filter, sorters []*storage.Sorter, limit int) (
 	[]*storage.BuildWithDuration, error) {
 
 	var builds []*storage.BuildWithDuration
@@ -28,6 +30,7 @@ func (c *Client) GetBuildsWithoutInputsOutputs(filters []*storage.Filter, sorter
 		BaseQuery: buildQueryWithoutInputsOutputs,
 		Filters:   filters,
 		Sorters:   sorters,
+		Limit:     limit,
 	}
 
 	query, args, err := q.Compile()
@@ -35,7 +38,7 @@ func (c *Client) GetBuildsWithoutInputsOutputs(filters []*storage.Filter, sorter
 		return nil, errors.Wrap(err, "compiling query string failed")
 	}
 
-	rows, err := c.Db.Query(query, args...)
+	rows, err := c.Db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "db query '%s' (%q) failed", query, args)
 	}
@@ -83,13 +86,13 @@ func scanBuildRows(rows *sql.Rows) (*storage.BuildWithDuration, error) {
 // stop_timestamp is returned.
 // Inputs are not fetched from the database.
 // If no builds exist storage.ErrNotExist is returned
-func (c *Client) GetLatestBuildByDigest(appName, totalInputDigest string) (*storage.BuildWithDuration, error) {
+func (c *Client) GetLatestBuildByDigest(ctx context.Context, appName, totalInputDigest string) (*storage.BuildWithDuration, error) {
 	const query = buildQueryWithoutInputsOutputs + `
 	 WHERE application.name = $1 AND build.total_input_digest = $2
 	 ORDER BY build.stop_timestamp DESC LIMIT 1
 	 `
 
-	rows, err := c.Db.Query(query, appName, totalInputDigest)
+	rows, err := c.Db.QueryContext(ctx, query, appName, totalInputDigest)
 	if err != nil {
 		return nil, errors.Wrapf(err, "db query '%s' failed", query)
 	}
@@ -108,7 +111,7 @@ func (c *Client) GetLatestBuildByDigest(appName, totalInputDigest string) (*stor
 		return nil, errors.Wrapf(err, "scanning result of db query '%s' failed", query)
 	}
 
-	builds, err := c.GetBuildOutputs(build.ID)
+	builds, err := c.GetBuildOutputs(ctx, build.ID)
 	if err != nil {
 		return nil, errors.Wrap(err, "fetching build outputs failed")
 	}
@@ -119,13 +122,8 @@ func (c *Client) GetLatestBuildByDigest(appName, totalInputDigest string) (*stor
 }
 
 // GetBuildWithoutInputsOutputs retrieves a single build from the database
-func (c *Client) GetBuildWithoutInputsOutputs(id int) (*storage.BuildWithDuration, error) {
-	builds, err := c.GetBuildsWithoutInputsOutputs([]*storage.Filter{
-		{
-			Field:    storage.FieldBuildID,
-			Operator: storage.OpEQ,
-			Value:    id,
-		}}, nil)
+func (c *Client) GetBuildWithoutInputsOutputs(ctx context.Context, id int) (*storage.BuildWithDuration, error) {
+	builds, err := c.GetBuildsWithoutInputsOutputs(ctx, []*storage.Filter{storage.FilterByBuildID(id)}, nil, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -1,5 +1,15 @@
 package postgres
 
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// initQuery creates the schema of migration 1, it is the schema that baur
+// versions without migration support created. It must not be changed,
+// schema changes are done by appending migrations.
 const initQuery = `
 CREATE TABLE application (
 	id SERIAL PRIMARY KEY,
@@ -59,9 +69,29 @@ CREATE TABLE input_build (
 );
 `
 
-// Init creates the baur tables in the postgresql database
-func (c *Client) Init() error {
-	_, err := c.Db.Exec(initQuery)
+// Init creates the baur tables in the postgresql database or applies the
+// migrations that are pending for existing tables
+func (c *Client) Init(ctx context.Context) error {
+	return c.migrate(ctx, migrations)
+}
+
+// createInitialSchema creates the baur tables. If they already exist
+// because they were created by a baur version without migration support,
+// nothing is done.
+func createInitialSchema(ctx context.Context, tx *sql.Tx) error {
+	exist, err := tableExists(ctx, tx, "application")
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, initQuery)
+	if err != nil {
+		return errors.Wrap(err, "creating tables failed")
+	}
 
-	return err
+	return nil
 }
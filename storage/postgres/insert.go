@@ -1,15 +1,17 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/simplesurance/baur/storage"
 )
 
-func insertBuild(tx *sql.Tx, appID, vcsID int, b *storage.Build) (int, error) {
+func insertBuild(ctx context.Context, tx *sql.Tx, appID, vcsID int, b *storage.Build) (int, error) {
 	const stmt = `
 	INSERT INTO build
 	(application_id, vcs_id, start_timestamp, stop_timestamp, total_input_digest)
@@ -18,7 +20,7 @@ func insertBuild(tx *sql.Tx, appID, vcsID int, b *storage.Build) (int, error) {
 
 	var id int
 
-	r := tx.QueryRow(stmt, appID, vcsID, b.StartTimeStamp, b.StopTimeStamp, b.TotalInputDigest)
+	r := tx.QueryRowContext(ctx, stmt, appID, vcsID, b.StartTimeStamp, b.StopTimeStamp, b.TotalInputDigest)
 
 	if err := r.Scan(&id); err != nil {
 		return -1, err
@@ -27,7 +29,7 @@ func insertBuild(tx *sql.Tx, appID, vcsID int, b *storage.Build) (int, error) {
 	return id, nil
 }
 
-func insertBuildOutputs(tx *sql.Tx, buildID int, outputIDs []int) ([]int, error) {
+func insertBuildOutputs(ctx context.Context, tx *sql.Tx, buildID int, outputIDs []int) ([]int, error) {
 	const stmt1 = "INSERT INTO build_output(build_id, output_id) VALUES"
 	const stmt2 = "RETURNING ID"
 
@@ -43,7 +45,7 @@ func insertBuildOutputs(tx *sql.Tx, buildID int, outputIDs []int) ([]int, error)
 	}
 
 	query := stmt1 + stmtVals + stmt2
-	rows, err := tx.Query(query)
+	rows, err := tx.QueryContext(ctx, query)
 	if err != nil {
 		return nil, errors.Wrapf(err, "db query %q failed", query)
 	}
@@ -67,7 +69,7 @@ func insertBuildOutputs(tx *sql.Tx, buildID int, outputIDs []int) ([]int, error)
 	return ids, nil
 }
 
-func insertOutputsIfNotExist(tx *sql.Tx, outputs []*storage.Output) ([]int, error) {
+func insertOutputsIfNotExist(ctx context.Context, tx *sql.Tx, outputs []*storage.Output) ([]int, error) {
 	const stmt1 = "INSERT INTO output (name, type, digest, size_bytes) VALUES"
 	const stmt2 = `
 	ON CONFLICT ON CONSTRAINT output_digest_key
@@ -77,7 +79,7 @@ func insertOutputsIfNotExist(tx *sql.Tx, outputs []*storage.Output) ([]int, erro
 	var (
 		argCNT    = 1
 		stmtVals  string
-		queryArgs = make([]interface{}, 0, len(outputs)*4)
+		queryArgs = make([]interface{}, 0, len(outputs)*5)
 		ids       = make([]int, 0, len(outputs))
 	)
 
@@ -92,7 +94,7 @@ func insertOutputsIfNotExist(tx *sql.Tx, outputs []*storage.Output) ([]int, erro
 	}
 	query := stmt1 + stmtVals + stmt2
 
-	rows, err := tx.Query(query, queryArgs...)
+	rows, err := tx.QueryContext(ctx, query, queryArgs...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "db query %q failed", query)
 	}
@@ -116,68 +118,93 @@ func insertOutputsIfNotExist(tx *sql.Tx, outputs []*storage.Output) ([]int, erro
 	return ids, nil
 }
 
-func insertInputBuilds(tx *sql.Tx, buildID int, inputIDs []int) error {
-	const stmt1 = `
+// insertInputBuilds creates input_build records that reference the build and
+// the inputs. The records are inserted in chunks of batchSize records.
+func insertInputBuilds(ctx context.Context, tx *sql.Tx, buildID int, inputIDs []int, batchSize int) error {
+	const stmt = `
 		INSERT into input_build
 		(build_id, input_id)
 		VALUES
 	`
 
-	var stmtVals string
-	argCNT := 1
-	queryArgs := make([]interface{}, 0, len(inputIDs))
+	for len(inputIDs) > 0 {
+		n := min(batchSize, len(inputIDs))
+		batch := inputIDs[:n]
+		inputIDs = inputIDs[n:]
 
-	queryArgs = append(queryArgs, buildID)
-	argCNT++
+		var stmtVals strings.Builder
+		queryArgs := make([]interface{}, 0, len(batch)+1)
+		queryArgs = append(queryArgs, buildID)
 
-	for i, id := range inputIDs {
-		stmtVals += fmt.Sprintf("($1, $%d)", argCNT)
-		argCNT++
+		for i, id := range batch {
+			if i > 0 {
+				stmtVals.WriteString(", ")
+			}
 
-		queryArgs = append(queryArgs, id)
+			fmt.Fprintf(&stmtVals, "($1, $%d)", i+2)
+			queryArgs = append(queryArgs, id)
+		}
 
-		if i < len(inputIDs)-1 {
-			stmtVals += ", "
+		query := stmt + stmtVals.String()
+
+		_, err := tx.ExecContext(ctx, query, queryArgs...)
+		if err != nil {
+			return errors.Wrapf(err, "db query %q failed", query)
 		}
 	}
 
-	query := stmt1 + stmtVals
+	return nil
+}
 
-	_, err := tx.Exec(query, queryArgs...)
-	if err != nil {
-		return errors.Wrapf(err, "db query %q failed", query)
+// insertInputsIfNotExist creates records for the inputs that do not exist
+// yet and returns the IDs of the records of all inputs. Inputs with the same
+// URI and digest are only stored once, one ID is returned per unique
+// input.
+// The records are inserted in chunks of batchSize records.
+func insertInputsIfNotExist(ctx context.Context, tx *sql.Tx, inputs []*storage.Input, batchSize int) ([]int, error) {
+	inputs = uniqInputs(inputs)
+	ids := make([]int, 0, len(inputs))
+
+	for len(inputs) > 0 {
+		n := min(batchSize, len(inputs))
+
+		batchIDs, err := insertInputBatch(ctx, tx, inputs[:n])
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, batchIDs...)
+		inputs = inputs[n:]
 	}
 
-	return nil
+	return ids, nil
 }
 
-func insertInputsIfNotExist(tx *sql.Tx, inputs []*storage.Input) ([]int, error) {
+func insertInputBatch(ctx context.Context, tx *sql.Tx, inputs []*storage.Input) ([]int, error) {
 	const stmt1 = "INSERT INTO input (uri, digest) VALUES"
 	const stmt2 = `
 	ON CONFLICT ON CONSTRAINT input_uniq
 	DO UPDATE SET id=input.id RETURNING id
 	`
 	var (
-		stmtVals string
+		stmtVals strings.Builder
 
-		argCNT    = 1
 		queryArgs = make([]interface{}, 0, len(inputs)*2)
 		ids       = make([]int, 0, len(inputs))
 	)
 
 	for i, in := range inputs {
-		stmtVals += fmt.Sprintf("($%d, $%d)", argCNT, argCNT+1)
-		argCNT += 2
-		queryArgs = append(queryArgs, in.URI, in.Digest)
-
-		if i < len(inputs)-1 {
-			stmtVals += ", "
+		if i > 0 {
+			stmtVals.WriteString(", ")
 		}
+
+		fmt.Fprintf(&stmtVals, "($%d, $%d)", len(queryArgs)+1, len(queryArgs)+2)
+		queryArgs = append(queryArgs, in.URI, in.Digest)
 	}
 
-	query := stmt1 + stmtVals + stmt2
+	query := stmt1 + stmtVals.String() + stmt2
 
-	rows, err := tx.Query(query, queryArgs...)
+	rows, err := tx.QueryContext(ctx, query, queryArgs...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "db query %q failed", query)
 	}
@@ -199,10 +226,39 @@ func insertInputsIfNotExist(tx *sql.Tx, inputs []*storage.Input) ([]int, error)
 	}
 
 	return ids, nil
+}
+
+// uniqInputs returns inputs without duplicates, inputs are duplicates if
+// they have the same URI and digest. An upsert statement fails if it
+// contains the same row multiple times.
+func uniqInputs(inputs []*storage.Input) []*storage.Input {
+	type key struct{ uri, digest string }
+
+	res := make([]*storage.Input, 0, len(inputs))
+	seen := make(map[key]struct{}, len(inputs))
 
+	for _, in := range inputs {
+		k := key{uri: in.URI, digest: in.Digest}
+		if _, exist := seen[k]; exist {
+			continue
+		}
+
+		seen[k] = struct{}{}
+		res = append(res, in)
+	}
+
+	return res
 }
 
-func insertVCSIfNotExist(tx *sql.Tx, v *storage.VCSState) (int, error) {
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func insertVCSIfNotExist(ctx context.Context, tx *sql.Tx, v *storage.VCSState) (int, error) {
 	const stmt = `
 	INSERT INTO vcs
 	(commit, dirty)
@@ -212,7 +268,7 @@ func insertVCSIfNotExist(tx *sql.Tx, v *storage.VCSState) (int, error) {
 	`
 	var id int
 
-	err := tx.QueryRow(stmt, v.CommitID, v.IsDirty).Scan(&id)
+	err := tx.QueryRowContext(ctx, stmt, v.CommitID, v.IsDirty).Scan(&id)
 	if err != nil {
 		return -1, errors.Wrapf(err, "db query %q failed", stmt)
 	}
@@ -220,7 +276,7 @@ func insertVCSIfNotExist(tx *sql.Tx, v *storage.VCSState) (int, error) {
 	return id, nil
 }
 
-func insertAppIfNotExist(tx *sql.Tx, app *storage.Application) error {
+func insertAppIfNotExist(ctx context.Context, tx *sql.Tx, app *storage.Application) error {
 	const stmt = `
 	INSERT INTO application
 	(name)
@@ -229,7 +285,7 @@ func insertAppIfNotExist(tx *sql.Tx, app *storage.Application) error {
 	DO UPDATE SET id=application.id RETURNING id
 	`
 
-	err := tx.QueryRow(stmt, app.NameLower()).Scan(&app.ID)
+	err := tx.QueryRowContext(ctx, stmt, app.NameLower()).Scan(&app.ID)
 	if err != nil {
 		return errors.Wrapf(err, "db query %q failed", stmt)
 	}
@@ -237,10 +293,10 @@ func insertAppIfNotExist(tx *sql.Tx, app *storage.Application) error {
 	return nil
 }
 
-func insertUploads(tx *sql.Tx, buildOutputIDs []int, outputs []*storage.Output) error {
+func insertUploads(ctx context.Context, tx *sql.Tx, buildOutputIDs []int, outputs []*storage.Output) error {
 	const stmt = `
 	INSERT into upload
-	(build_output_id, uri, method, upload_duration_ns)
+	(build_output_id, uri, method, upload_duration_ns, registry_digest)
 	VALUES
 	`
 
@@ -257,9 +313,9 @@ func insertUploads(tx *sql.Tx, buildOutputIDs []int, outputs []*storage.Output)
 	}
 
 	for i, out := range outputs {
-		stmtVals += fmt.Sprintf("($%d, $%d,$%d, $%d)", argCNT, argCNT+1, argCNT+2, argCNT+3)
-		argCNT += 4
-		queryArgs = append(queryArgs, buildOutputIDs[i], out.Upload.URI, out.Upload.Method, out.Upload.UploadDuration)
+		stmtVals += fmt.Sprintf("($%d, $%d,$%d, $%d, $%d)", argCNT, argCNT+1, argCNT+2, argCNT+3, argCNT+4)
+		argCNT += 5
+		queryArgs = append(queryArgs, buildOutputIDs[i], out.Upload.URI, out.Upload.Method, out.Upload.UploadDuration, out.Upload.RegistryDigest)
 
 		if i < len(outputs)-1 {
 			stmtVals += ", "
@@ -268,7 +324,7 @@ func insertUploads(tx *sql.Tx, buildOutputIDs []int, outputs []*storage.Output)
 
 	query := stmt + stmtVals
 
-	_, err := tx.Exec(query, queryArgs...)
+	_, err := tx.ExecContext(ctx, query, queryArgs...)
 	if err != nil {
 		return errors.Wrapf(err, "db query %q failed", query)
 	}
@@ -276,13 +332,15 @@ func insertUploads(tx *sql.Tx, buildOutputIDs []int, outputs []*storage.Output)
 	return err
 }
 
-// Save stores a build in the database, the ID field of the passed Build is
-// ignored. The database generates a record ID and it will be stored in the
-// passed Build.
-func (c *Client) Save(b *storage.Build) error {
-	tx, err := c.Db.Begin()
+// SaveBuild stores a build in the database, the ID field of the passed
+// Build is ignored. The database generates a record ID, it is returned and
+// stored in the passed Build.
+// All records are inserted in a single transaction, if storing any of them
+// fails, no records are stored.
+func (c *Client) SaveBuild(ctx context.Context, b *storage.Build) (id int, err error) {
+	tx, err := c.Db.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.Wrap(err, "starting transaction failed")
+		return -1, errors.Wrap(err, "starting transaction failed")
 	}
 
 	defer func() {
@@ -291,58 +349,66 @@ func (c *Client) Save(b *storage.Build) error {
 			return
 		}
 
-		commitErr := tx.Commit()
-		if commitErr != nil {
-			err = errors.Wrap(err, "committing transaction failed")
+		if commitErr := tx.Commit(); commitErr != nil {
+			id = -1
+			err = errors.Wrap(commitErr, "committing transaction failed")
+			return
 		}
+
+		b.ID = id
 	}()
 
-	err = insertAppIfNotExist(tx, &b.Application)
+	return insertBuildRecords(ctx, tx, b, c.inputBatchSize())
+}
+
+// insertBuildRecords inserts the application, vcs, build, output, upload and
+// input records of the build via tx and returns the ID of the build record.
+// Input records are inserted in chunks of inputBatchSize records.
+func insertBuildRecords(ctx context.Context, tx *sql.Tx, b *storage.Build, inputBatchSize int) (int, error) {
+	err := insertAppIfNotExist(ctx, tx, &b.Application)
 	if err != nil {
-		return errors.Wrap(err, "storing application record failed")
+		return -1, errors.Wrap(err, "storing application record failed")
 	}
 
-	vcsID, err := insertVCSIfNotExist(tx, &b.VCSState)
+	vcsID, err := insertVCSIfNotExist(ctx, tx, &b.VCSState)
 	if err != nil {
-		return errors.Wrap(err, "storing vcs information failed")
+		return -1, errors.Wrap(err, "storing vcs information failed")
 	}
 
-	buildID, err := insertBuild(tx, b.Application.ID, vcsID, b)
+	buildID, err := insertBuild(ctx, tx, b.Application.ID, vcsID, b)
 	if err != nil {
-		return errors.Wrap(err, "storing build record failed")
+		return -1, errors.Wrap(err, "storing build record failed")
 	}
 
-	outputIDs, err := insertOutputsIfNotExist(tx, b.Outputs)
+	outputIDs, err := insertOutputsIfNotExist(ctx, tx, b.Outputs)
 	if err != nil {
-		return errors.Wrap(err, "storing output records failed")
+		return -1, errors.Wrap(err, "storing output records failed")
 	}
 
-	buildOutputIDs, err := insertBuildOutputs(tx, buildID, outputIDs)
+	buildOutputIDs, err := insertBuildOutputs(ctx, tx, buildID, outputIDs)
 	if err != nil {
-		return errors.Wrap(err, "storing buildOutput records failed")
+		return -1, errors.Wrap(err, "storing buildOutput records failed")
 	}
 
-	err = insertUploads(tx, buildOutputIDs, b.Outputs)
+	err = insertUploads(ctx, tx, buildOutputIDs, b.Outputs)
 	if err != nil {
-		return errors.Wrap(err, "storing upload record failed")
+		return -1, errors.Wrap(err, "storing upload record failed")
 	}
 
 	// inputs not specified in the baur app config
 	if len(b.Inputs) == 0 {
-		return nil
+		return buildID, nil
 	}
 
-	ids, err := insertInputsIfNotExist(tx, b.Inputs)
+	ids, err := insertInputsIfNotExist(ctx, tx, b.Inputs, inputBatchSize)
 	if err != nil {
-		return errors.Wrap(err, "storing inputs failed")
+		return -1, errors.Wrap(err, "storing inputs failed")
 	}
 
-	err = insertInputBuilds(tx, buildID, ids)
+	err = insertInputBuilds(ctx, tx, buildID, ids, inputBatchSize)
 	if err != nil {
-		return errors.Wrap(err, "storing input_build failed")
+		return -1, errors.Wrap(err, "storing input_build failed")
 	}
 
-	b.ID = buildID
-
-	return nil
+	return buildID, nil
 }
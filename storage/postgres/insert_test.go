@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simplesurance/baur/storage"
+)
+
+func TestUniqInputs(t *testing.T) {
+	in1 := &storage.Input{URI: "a", Digest: "1"}
+	in2 := &storage.Input{URI: "a", Digest: "2"}
+	in3 := &storage.Input{URI: "b", Digest: "1"}
+
+	res := uniqInputs([]*storage.Input{in1, in2, {URI: "a", Digest: "1"}, in3, in2})
+
+	expected := []*storage.Input{in1, in2, in3}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("uniqInputs returned %+v, expected %+v", res, expected)
+	}
+}
@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// migration is a change of the database schema
+type migration struct {
+	version     int
+	description string
+	apply       func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations are the schema changes that Init applies, ordered by their
+// versions. Migrations must never be changed after they were released,
+// schema changes are done by appending a new migration.
+var migrations = []*migration{
+	{
+		version:     1,
+		description: "create initial schema",
+		apply:       createInitialSchema,
+	},
+	{
+		version:     2,
+		description: "add upload.registry_digest",
+		apply:       execStmt(addRegistryDigestStmt),
+	},
+	{
+		version:     3,
+		description: "create release tables",
+		apply:       execStmt(createReleaseTablesStmt),
+	},
+}
+
+const addRegistryDigestStmt = `
+ALTER TABLE upload ADD COLUMN IF NOT EXISTS registry_digest TEXT NOT NULL DEFAULT '';`
+
+const createReleaseTablesStmt = `
+CREATE TABLE IF NOT EXISTS release (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS release_build (
+	release_id INTEGER REFERENCES release (id) ON DELETE CASCADE,
+	build_id INTEGER REFERENCES build (id),
+	CONSTRAINT release_build_uniq UNIQUE(release_id, build_id)
+);`
+
+const createMigrationsTableStmt = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);`
+
+// LatestSchemaVersion returns the schema version that Init migrates the
+// database to
+func (c *Client) LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the version of the latest applied migration. If the
+// baur tables were not created yet, 0 is returned.
+func (c *Client) SchemaVersion(ctx context.Context) (int, error) {
+	const stmt = "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"
+
+	var version int
+
+	tx, err := c.Db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return -1, errors.Wrap(err, "starting transaction failed")
+	}
+
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	exist, err := tableExists(ctx, tx, "schema_migrations")
+	if err != nil {
+		return -1, err
+	}
+
+	if !exist {
+		// tables that were created by a baur version without
+		// migration support have version 1
+		exist, err := tableExists(ctx, tx, "application")
+		if err != nil {
+			return -1, err
+		}
+
+		if exist {
+			return 1, nil
+		}
+
+		return 0, nil
+	}
+
+	if err := tx.QueryRowContext(ctx, stmt).Scan(&version); err != nil {
+		return -1, errors.Wrapf(err, "db query %q failed", stmt)
+	}
+
+	return version, nil
+}
+
+// migrate applies the migrations that have not been applied yet, in the
+// passed order. Every migration is applied in its own transaction.
+func (c *Client) migrate(ctx context.Context, migrations []*migration) error {
+	_, err := c.Db.ExecContext(ctx, createMigrationsTableStmt)
+	if err != nil {
+		return errors.Wrap(err, "creating schema_migrations table failed")
+	}
+
+	for _, m := range migrations {
+		if err := c.applyMigration(ctx, m); err != nil {
+			return errors.Wrapf(err, "applying migration %d (%s) failed", m.version, m.description)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies the migration if it is not recorded in the
+// schema_migrations table and records it.
+// The table is locked while the migration is applied, to prevent that
+// concurrently running baur processes apply it multiple times.
+func (c *Client) applyMigration(ctx context.Context, m *migration) (err error) {
+	const lockStmt = "LOCK TABLE schema_migrations IN EXCLUSIVE MODE"
+	const existStmt = "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)"
+	const insertStmt = "INSERT INTO schema_migrations (version, description) VALUES($1, $2)"
+
+	tx, err := c.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction failed")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = errors.Wrap(commitErr, "committing transaction failed")
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, lockStmt); err != nil {
+		return errors.Wrapf(err, "db query %q failed", lockStmt)
+	}
+
+	var applied bool
+	if err = tx.QueryRowContext(ctx, existStmt, m.version).Scan(&applied); err != nil {
+		return errors.Wrapf(err, "db query %q failed", existStmt)
+	}
+
+	if applied {
+		return nil
+	}
+
+	if err = m.apply(ctx, tx); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, insertStmt, m.version, m.description); err != nil {
+		return errors.Wrapf(err, "db query %q failed", insertStmt)
+	}
+
+	return nil
+}
+
+// execStmt returns a migration apply function that executes stmt
+func execStmt(stmt string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrapf(err, "db query %q failed", stmt)
+		}
+
+		return nil
+	}
+}
+
+func tableExists(ctx context.Context, tx *sql.Tx, name string) (bool, error) {
+	const stmt = "SELECT to_regclass($1) IS NOT NULL"
+
+	var exist bool
+
+	if err := tx.QueryRowContext(ctx, stmt, name).Scan(&exist); err != nil {
+		return false, errors.Wrapf(err, "db query %q failed", stmt)
+	}
+
+	return exist, nil
+}
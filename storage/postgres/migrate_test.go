@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/rs/xid"
+)
+
+func TestInitIsIdempotent(t *testing.T) {
+	c := newTestClient(t)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Init(ctx); err != nil {
+			t.Fatalf("Init() failed on run %d: %s", i+1, err)
+		}
+	}
+
+	version, err := c.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != c.LatestSchemaVersion() {
+		t.Errorf("schema version is %d after Init, expected %d", version, c.LatestSchemaVersion())
+	}
+}
+
+func TestPendingMigrationIsAppliedOnce(t *testing.T) {
+	const version = 1 << 30
+
+	c := newTestClient(t)
+
+	if err := c.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_, err := c.Db.Exec("DELETE FROM schema_migrations WHERE version = $1", version)
+		if err != nil {
+			t.Error("deleting test migration failed:", err)
+		}
+	}()
+
+	var applyCnt int
+	testMigrations := append(migrations, &migration{
+		version:     version,
+		description: "test migration",
+		apply: func(context.Context, *sql.Tx) error {
+			applyCnt++
+			return nil
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := c.migrate(ctx, testMigrations); err != nil {
+			t.Fatalf("migrate() failed on run %d: %s", i+1, err)
+		}
+	}
+
+	if applyCnt != 1 {
+		t.Errorf("migration was applied %d times, expected 1", applyCnt)
+	}
+
+	schemaVersion, err := c.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schemaVersion != version {
+		t.Errorf("schema version is %d, expected %d", schemaVersion, version)
+	}
+}
+
+// TestMigrateBaselineSchema verifies that a database that was created by a
+// baur version without migration support is migrated to the latest schema.
+// The tables are created in a separate postgresql schema, the client uses a
+// single connection that has it as search path.
+func TestMigrateBaselineSchema(t *testing.T) {
+	schema := "baur_test_" + xid.New().String()
+
+	c := newTestClient(t)
+	defer c.Close()
+
+	c.Db.SetMaxOpenConns(1)
+
+	if _, err := c.Db.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if _, err := c.Db.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error("dropping schema failed:", err)
+		}
+	}()
+
+	if _, err := c.Db.Exec("SET search_path TO " + schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Db.Exec(initQuery); err != nil {
+		t.Fatal("creating baseline schema failed:", err)
+	}
+
+	version, err := c.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != 1 {
+		t.Fatalf("schema version of baseline schema is %d, expected 1", version)
+	}
+
+	if err := c.Init(ctx); err != nil {
+		t.Fatal("migrating baseline schema failed:", err)
+	}
+
+	version, err = c.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != c.LatestSchemaVersion() {
+		t.Errorf("schema version is %d after migration, expected %d", version, c.LatestSchemaVersion())
+	}
+
+	b := build
+	id, err := c.SaveBuild(ctx, &b)
+	if err != nil {
+		t.Fatal("saving build in migrated schema failed:", err)
+	}
+
+	if _, err := c.CreateRelease(ctx, "release-"+xid.New().String(), []int{id}); err != nil {
+		t.Fatal("creating release in migrated schema failed:", err)
+	}
+}
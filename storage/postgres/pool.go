@@ -0,0 +1,45 @@
+package postgres
+
+import "time"
+
+// defConnMaxLifetime is the default max. duration a connection is reused
+const defConnMaxLifetime = 5 * time.Minute
+
+// PoolConfig configures the connection pool of a Client
+type PoolConfig struct {
+	// MaxOpenConns is the max. number of open connections, <= 0 means
+	// unlimited
+	MaxOpenConns int
+	// MaxIdleConns is the max. number of idle connections, <= 0 means no
+	// idle connections are kept
+	MaxIdleConns int
+	// ConnMaxLifetime is the max. duration a connection is reused, <= 0
+	// means connections are reused forever
+	ConnMaxLifetime time.Duration
+}
+
+// DefaultPoolConfig returns the pool configuration for a client that is used
+// by up to concurrency goroutines in parallel.
+// It allows 2 more connections than goroutines, to not block operations that
+// are run additionally to the parallel ones.
+func DefaultPoolConfig(concurrency int) PoolConfig {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return PoolConfig{
+		MaxOpenConns:    concurrency + 2,
+		MaxIdleConns:    concurrency + 2,
+		ConnMaxLifetime: defConnMaxLifetime,
+	}
+}
+
+// WithPoolConfig applies the pool configuration to the connection pool of
+// the client.
+func (c *Client) WithPoolConfig(cfg PoolConfig) *Client {
+	c.Db.SetMaxOpenConns(cfg.MaxOpenConns)
+	c.Db.SetMaxIdleConns(cfg.MaxIdleConns)
+	c.Db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	return c
+}
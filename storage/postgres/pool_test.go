@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+const mockDriverName = "baur-mock"
+
+// mockDriver is a database driver whose connections do not support any
+// operations, it is used to test the configuration of the connection pool
+type mockDriver struct{}
+
+type mockConn struct{}
+
+func (mockDriver) Open(string) (driver.Conn, error) { return mockConn{}, nil }
+
+func (mockConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (mockConn) Close() error                        { return nil }
+func (mockConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register(mockDriverName, mockDriver{})
+}
+
+func newMockClient(t *testing.T) *Client {
+	t.Helper()
+
+	db, err := sql.Open(mockDriverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Client{Db: db}
+}
+
+func TestWithPoolConfig(t *testing.T) {
+	clt := newMockClient(t).WithPoolConfig(PoolConfig{
+		MaxOpenConns:    5,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Millisecond,
+	})
+	defer clt.Close()
+
+	if maxOpen := clt.Db.Stats().MaxOpenConnections; maxOpen != 5 {
+		t.Errorf("max. open connections is %d, expected 5", maxOpen)
+	}
+
+	conns := make([]*sql.Conn, 0, 4)
+	for i := 0; i < cap(conns); i++ {
+		conn, err := clt.Db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conns = append(conns, conn)
+	}
+
+	for _, conn := range conns {
+		if err := conn.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if idle := clt.Db.Stats().Idle; idle != 2 {
+		t.Errorf("%d connections are idle, expected 2", idle)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	conn, err := clt.Db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if closed := clt.Db.Stats().MaxLifetimeClosed; closed == 0 {
+		t.Error("no connection was closed after exceeding the max. lifetime")
+	}
+}
+
+func TestDefaultPoolConfig(t *testing.T) {
+	cfg := DefaultPoolConfig(4)
+
+	if cfg.MaxOpenConns != 6 {
+		t.Errorf("MaxOpenConns is %d, expected 6", cfg.MaxOpenConns)
+	}
+
+	if cfg.MaxIdleConns != cfg.MaxOpenConns {
+		t.Errorf("MaxIdleConns is %d, expected %d", cfg.MaxIdleConns, cfg.MaxOpenConns)
+	}
+
+	if cfg := DefaultPoolConfig(0); cfg.MaxOpenConns != 3 {
+		t.Errorf("MaxOpenConns for concurrency 0 is %d, expected 3", cfg.MaxOpenConns)
+	}
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -10,13 +11,40 @@ import (
 	"github.com/simplesurance/baur/storage"
 )
 
+// DefaultInputBatchSize is the default max. number of input records that are
+// inserted with a single statement
+const DefaultInputBatchSize = 1000
+
 // Client is a postgres storage client
 type Client struct {
 	Db *sql.DB
+
+	// batchSize is the max. number of input records that are inserted
+	// with a single statement, if <= 0 DefaultInputBatchSize is used
+	batchSize int
+}
+
+var _ storage.Storer = &Client{}
+var _ storage.Migrator = &Client{}
+
+func init() {
+	newFn := func(uri string) (storage.Storer, error) {
+		return New(uri)
+	}
+
+	storage.RegisterBackend("postgres", newFn)
+	storage.RegisterBackend("postgresql", newFn)
 }
 
-// New establishes a connection a postgres db
+// New establishes a connection a postgres db.
+// url can be a connection URL or a key=value connection string. If it
+// references TLS certificate or key files that do not exist, an error is
+// returned.
 func New(url string) (*Client, error) {
+	if err := checkTLSFiles(url); err != nil {
+		return nil, err
+	}
+
 	db, err := sql.Open("postgres", url)
 	if err != nil {
 		return nil, err
@@ -31,23 +59,43 @@ func New(url string) (*Client, error) {
 	}, nil
 }
 
+// WithInputBatchSize sets the max. number of input records that are inserted
+// with a single statement when a build is saved. If size is <= 0,
+// DefaultInputBatchSize is used.
+func (c *Client) WithInputBatchSize(size int) *Client {
+	c.batchSize = size
+
+	return c
+}
+
+func (c *Client) inputBatchSize() int {
+	if c.batchSize <= 0 {
+		return DefaultInputBatchSize
+	}
+
+	return c.batchSize
+}
+
 // Close closes the connection
-func (c *Client) Close() {
-	c.Db.Close()
+func (c *Client) Close() error {
+	return c.Db.Close()
 }
 
-// GetBuildOutputs returns build outputs
-func (c *Client) GetBuildOutputs(buildID int) ([]*storage.Output, error) {
+// GetBuildOutputs returns the outputs of a build ordered by their names.
+// If no build with the ID exist, storage.ErrNotExist is returned.
+func (c *Client) GetBuildOutputs(ctx context.Context, buildID int) ([]*storage.Output, error) {
 	const stmt = `SELECT
 			output.name, output.digest, output.type, output.size_bytes,
-			upload.id, upload.uri, upload.method, upload.upload_duration_ns
+			upload.id, upload.uri, upload.method, upload.upload_duration_ns,
+			upload.registry_digest
 		      FROM output
 		      JOIN build_output ON output.id = build_output.output_id
 		      JOIN upload ON upload.build_output_id = build_output.id
 		      WHERE build_output.build_id = $1
+		      ORDER BY output.name
 		      `
 
-	rows, err := c.Db.Query(stmt, buildID)
+	rows, err := c.Db.QueryContext(ctx, stmt, buildID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "db query %q failed", stmt)
 	}
@@ -66,9 +114,11 @@ func (c *Client) GetBuildOutputs(buildID int) ([]*storage.Output, error) {
 			&output.Upload.URI,
 			&output.Upload.Method,
 			&output.Upload.UploadDuration,
+			&output.Upload.RegistryDigest,
 		)
 		if err != nil {
-			return nil, errors.Wrapf(err, "db query %q failed", stmt)
+			rows.Close()
+			return nil, errors.Wrapf(err, "parsing result of query %q failed", stmt)
 		}
 
 		outputs = append(outputs, &output)
@@ -78,15 +128,61 @@ func (c *Client) GetBuildOutputs(buildID int) ([]*storage.Output, error) {
 		return nil, errors.Wrap(err, "iterating over rows failed")
 	}
 
+	if len(outputs) == 0 {
+		exist, err := c.BuildExist(ctx, buildID)
+		if err != nil {
+			return nil, errors.Wrap(err, "checking if build exist failed")
+		}
+
+		if !exist {
+			return nil, storage.ErrNotExist
+		}
+	}
+
 	return outputs, nil
 }
 
+// GetBuildInputs returns the inputs of a build ordered by their URI
+func (c *Client) GetBuildInputs(ctx context.Context, buildID int) ([]*storage.Input, error) {
+	const stmt = `SELECT input.uri, input.digest
+		      FROM input
+		      JOIN input_build ON input.id = input_build.input_id
+		      WHERE input_build.build_id = $1
+		      ORDER BY input.uri
+		      `
+
+	rows, err := c.Db.QueryContext(ctx, stmt, buildID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db query %q failed", stmt)
+	}
+
+	var inputs []*storage.Input
+
+	for rows.Next() {
+		var input storage.Input
+
+		err := rows.Scan(&input.URI, &input.Digest)
+		if err != nil {
+			rows.Close()
+			return nil, errors.Wrapf(err, "parsing result of query %q failed", stmt)
+		}
+
+		inputs = append(inputs, &input)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating over rows failed")
+	}
+
+	return inputs, nil
+}
+
 // GetApps returns all application records ordered by Name
-func (c *Client) GetApps() ([]*storage.Application, error) {
+func (c *Client) GetApps(ctx context.Context) ([]*storage.Application, error) {
 	const query = "SELECT id, name FROM application ORDER BY name"
 	var res []*storage.Application
 
-	rows, err := c.Db.Query(query)
+	rows, err := c.Db.QueryContext(ctx, query)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, storage.ErrNotExist
@@ -115,10 +211,13 @@ func (c *Client) GetApps() ([]*storage.Application, error) {
 }
 
 // GetSameTotalInputDigestsForAppBuilds finds TotalInputDigests that are the
-// same for builds of an app with a build start time not before startTs
-// If not builds with the same totalInputDigest is found, an empty slice is
+// same for builds of an app with a build start time not before startTs.
+// The keys of the returned map are the digests, the values the IDs of the
+// builds in ascending order. Digests that were recorded for only one build
+// are not part of the result.
+// If not builds with the same totalInputDigest is found, an empty map is
 // returned.
-func (c *Client) GetSameTotalInputDigestsForAppBuilds(appName string, startTs time.Time) (map[string][]int, error) {
+func (c *Client) GetSameTotalInputDigestsForAppBuilds(ctx context.Context, appName string, startTs time.Time) (map[string][]int, error) {
 	const query = `
 		 WITH data AS(
 			 SELECT total_input_digest from build
@@ -133,11 +232,12 @@ func (c *Client) GetSameTotalInputDigestsForAppBuilds(appName string, startTs ti
 		JOIN build ON build.total_input_digest = data.total_input_digest
 		JOIN application on build.application_id = application.id
 		WHERE build.start_timestamp  >= $1
-		AND application.name = $2`
+		AND application.name = $2
+		ORDER BY build.id`
 
 	res := map[string][]int{}
 
-	rows, err := c.Db.Query(query, startTs, appName)
+	rows, err := c.Db.QueryContext(ctx, query, startTs, appName)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, storage.ErrNotExist
@@ -167,11 +267,11 @@ func (c *Client) GetSameTotalInputDigestsForAppBuilds(appName string, startTs ti
 }
 
 // BuildExist returns true if the build with the given ID exist.
-func (c *Client) BuildExist(id int) (bool, error) {
+func (c *Client) BuildExist(ctx context.Context, id int) (bool, error) {
 	const query = "SELECT 1 from build where id = $1"
 	var val int
 
-	row := c.Db.QueryRow(query, id)
+	row := c.Db.QueryRowContext(ctx, query, id)
 	err := row.Scan(&val)
 	if err != nil {
 		if err == sql.ErrNoRows {
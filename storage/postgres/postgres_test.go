@@ -1,8 +1,10 @@
 package postgres
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -13,6 +15,8 @@ import (
 
 var sqlConStr string
 
+var ctx = context.Background()
+
 var build = storage.Build{
 	Application:    storage.Application{Name: "baur-unittest"},
 	StartTimeStamp: time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
@@ -28,6 +32,18 @@ var build = storage.Build{
 			Digest:    "sha384:c825bb06739ba6b41f6cc0c123a5956bd65be9e22d51640a0460e0b16eb4523af4d68a1b56d63fd67dab484a0796fc69",
 			SizeBytes: 64,
 		},
+		{
+			Name: "baur-unittest:latest",
+			Type: storage.DockerArtifact,
+			Upload: storage.Upload{
+				URI:            "simplesurance/baur-unittest:latest",
+				Method:         storage.DockerRegistry,
+				UploadDuration: 10 * time.Second,
+				RegistryDigest: "sha256:9d2a1c0e6c7dc1fe0a5d1b4a8c9a0d4f8a3c6f2e1b7d5a4c3e2f1a0b9c8d7e6f",
+			},
+			Digest:    "sha256:5d0f7ab3c4a2e1f6b8d9c0a1e2f3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1",
+			SizeBytes: 128,
+		},
 	},
 	Inputs: []*storage.Input{
 		{
@@ -50,23 +66,37 @@ var build = storage.Build{
 func TestMain(m *testing.M) {
 	sqlConStr = os.Getenv("BAUR_POSTGRESQL_URL")
 	if sqlConStr == "" {
-		fmt.Println("BAUR_POSTGRESQL_URL environment variable not set")
-		os.Exit(1)
+		fmt.Println("BAUR_POSTGRESQL_URL environment variable not set, tests requiring a database are skipped")
 	}
 
 	os.Exit(m.Run())
 }
 
+// newTestClient returns a client that is connected to the database at
+// BAUR_POSTGRESQL_URL. If the environment variable is not set, the test is
+// skipped.
+func newTestClient(tb testing.TB) *Client {
+	tb.Helper()
+
+	if sqlConStr == "" {
+		tb.Skip("BAUR_POSTGRESQL_URL environment variable not set")
+	}
+
+	c, err := New(sqlConStr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return c
+}
+
 func TestInsertAppIfNotExist(t *testing.T) {
 	app := storage.Application{
 		ID:   -1,
 		Name: "TestInsertAppIfNotExist " + xid.New().String(),
 	}
 
-	c, err := New(sqlConStr)
-	if err != nil {
-		t.Fatal(err)
-	}
+	c := newTestClient(t)
 
 	tx, err := c.Db.Begin()
 	if err != nil {
@@ -76,7 +106,7 @@ func TestInsertAppIfNotExist(t *testing.T) {
 	// nolint: errcheck
 	defer tx.Rollback()
 
-	err = insertAppIfNotExist(tx, &app)
+	err = insertAppIfNotExist(ctx, tx, &app)
 	if err != nil {
 		t.Fatal("insertAppIfNotExist() failed:", err)
 	}
@@ -86,7 +116,7 @@ func TestInsertAppIfNotExist(t *testing.T) {
 	}
 	prevID := app.ID
 
-	err = insertAppIfNotExist(tx, &app)
+	err = insertAppIfNotExist(ctx, tx, &app)
 	if err != nil {
 		t.Fatal("insertAppIfNotExist() failed when record already exists", err)
 	}
@@ -98,24 +128,180 @@ func TestInsertAppIfNotExist(t *testing.T) {
 
 }
 
-func TestSave(t *testing.T) {
-	c, err := New(sqlConStr)
+func TestSaveBuild(t *testing.T) {
+	c := newTestClient(t)
+
+	b := build
+	b.Application.Name = xid.New().String()
+
+	id, err := c.SaveBuild(ctx, &b)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Saving build failed:", err)
 	}
 
-	err = c.Save(&build)
+	if id != b.ID {
+		t.Errorf("SaveBuild returned id %d, build has id %d", id, b.ID)
+	}
+
+	stored, err := c.GetBuildWithoutInputsOutputs(ctx, id)
 	if err != nil {
-		t.Error("Saving build failed:", err)
+		t.Fatal("fetching stored build failed:", err)
+	}
+
+	if stored.Application.Name != b.Application.Name {
+		t.Errorf("stored build has app name %q, expected %q", stored.Application.Name, b.Application.Name)
+	}
+
+	if stored.TotalInputDigest != b.TotalInputDigest {
+		t.Errorf("stored build has total input digest %q, expected %q", stored.TotalInputDigest, b.TotalInputDigest)
 	}
 }
 
-func TestGetSameTotalInputDigestsForAppBuilds(t *testing.T) {
-	c, err := New(sqlConStr)
+func TestSaveBuildIsAtomic(t *testing.T) {
+	c := newTestClient(t)
+
+	b := build
+	b.Application.Name = xid.New().String()
+
+	// outputs with the same digest can not be inserted in the same
+	// statement, inserting the outputs fails after the build record
+	// was created
+	digest := "sha256:" + xid.New().String()
+	b.Outputs = []*storage.Output{
+		{Name: "a", Type: storage.FileArtifact, Digest: digest},
+		{Name: "b", Type: storage.FileArtifact, Digest: digest},
+	}
+
+	if _, err := c.SaveBuild(ctx, &b); err == nil {
+		t.Fatal("SaveBuild succeeded for outputs with the same digest")
+	}
+
+	builds, err := c.GetBuildsWithoutInputsOutputs(ctx, []*storage.Filter{storage.FilterByApp(b.Application.Name)}, nil, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if len(builds) != 0 {
+		t.Errorf("%d builds were stored after SaveBuild failed, expected 0", len(builds))
+	}
+}
+
+func newInputs(cnt int) []*storage.Input {
+	inputs := make([]*storage.Input, 0, cnt)
+	prefix := xid.New().String()
+
+	for i := 0; i < cnt; i++ {
+		inputs = append(inputs, &storage.Input{
+			URI:    fmt.Sprintf("%s/file%d", prefix, i),
+			Digest: fmt.Sprintf("sha256:%d", i),
+		})
+	}
+
+	return inputs
+}
+
+func TestSaveBuildInsertsInputsInBatches(t *testing.T) {
+	c := newTestClient(t)
+	c.WithInputBatchSize(3)
+
+	b := build
+	b.Application.Name = xid.New().String()
+	b.Inputs = newInputs(10)
+	// duplicates are only stored once
+	b.Inputs = append(b.Inputs, b.Inputs[0], b.Inputs[9])
+
+	id, err := c.SaveBuild(ctx, &b)
+	if err != nil {
+		t.Fatal("Saving build failed:", err)
+	}
+
+	inputs, err := c.GetBuildInputs(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inputs) != 10 {
+		t.Errorf("build has %d inputs, expected 10", len(inputs))
+	}
+}
+
+func TestSharedInputsAreStoredOnce(t *testing.T) {
+	const stmt = "SELECT COUNT(*) FROM input WHERE uri = $1 AND digest = $2"
+
+	c := newTestClient(t)
+
+	input := newInputs(1)[0]
+
+	var buildIDs []int
+	for i := 0; i < 2; i++ {
+		b := build
+		b.Application.Name = xid.New().String()
+		b.Inputs = []*storage.Input{{URI: input.URI, Digest: input.Digest}}
+
+		id, err := c.SaveBuild(ctx, &b)
+		if err != nil {
+			t.Fatal("Saving build failed:", err)
+		}
+
+		buildIDs = append(buildIDs, id)
+	}
+
+	var cnt int
+	if err := c.Db.QueryRowContext(ctx, stmt, input.URI, input.Digest).Scan(&cnt); err != nil {
+		t.Fatalf("db query %q failed: %s", stmt, err)
+	}
+
+	if cnt != 1 {
+		t.Errorf("input is stored %d times, expected 1", cnt)
+	}
+
+	for _, id := range buildIDs {
+		inputs, err := c.GetBuildInputs(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(inputs) != 1 || inputs[0].URI != input.URI || inputs[0].Digest != input.Digest {
+			t.Errorf("build %d has inputs %+v, expected %+v", id, inputs, input)
+		}
+	}
+}
+
+func benchmarkSaveBuild(b *testing.B, inputBatchSize int) {
+	c := newTestClient(b)
+	c.WithInputBatchSize(inputBatchSize)
+
+	builds := make([]storage.Build, 0, b.N)
+	for i := 0; i < b.N; i++ {
+		bld := build
+		bld.Application.Name = xid.New().String()
+		bld.Inputs = newInputs(10000)
+
+		builds = append(builds, bld)
+	}
+
+	b.ResetTimer()
+
+	for i := range builds {
+		if _, err := c.SaveBuild(ctx, &builds[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSaveBuildInputs compares recording a build with 10k inputs
+// when inserting the inputs row by row and in batches.
+// Like the other tests it requires a database, run it with:
+//
+//	BAUR_POSTGRESQL_URL=<url> go test -run '^$' -bench SaveBuildInputs
+func BenchmarkSaveBuildInputs(b *testing.B) {
+	b.Run("per-row", func(b *testing.B) { benchmarkSaveBuild(b, 1) })
+	b.Run("batch-1000", func(b *testing.B) { benchmarkSaveBuild(b, DefaultInputBatchSize) })
+}
+
+func TestGetSameTotalInputDigestsForAppBuilds(t *testing.T) {
+	c := newTestClient(t)
+
 	b1 := build
 	b1.Application.Name = xid.New().String()
 	b1.TotalInputDigest = xid.New().String()
@@ -123,12 +309,12 @@ func TestGetSameTotalInputDigestsForAppBuilds(t *testing.T) {
 	b2 := b1
 	b2.TotalInputDigest = xid.New().String()
 
-	err = c.Save(&b1)
+	_, err := c.SaveBuild(ctx, &b1)
 	if err != nil {
 		t.Fatal("Saving b1 failed:", err)
 	}
 
-	digests, err := c.GetSameTotalInputDigestsForAppBuilds(b1.Application.Name, build.StartTimeStamp)
+	digests, err := c.GetSameTotalInputDigestsForAppBuilds(ctx, b1.Application.Name, build.StartTimeStamp)
 	if err != nil {
 		t.Errorf("returned error %q  when no builds with same input digest exist, expected no error", err)
 	}
@@ -136,12 +322,12 @@ func TestGetSameTotalInputDigestsForAppBuilds(t *testing.T) {
 		t.Errorf("returned %d digests, expected 0, if none exist with same input digest", len(digests))
 	}
 
-	err = c.Save(&b2)
+	_, err = c.SaveBuild(ctx, &b2)
 	if err != nil {
 		t.Fatal("Saving b2 failed:", err)
 	}
 
-	digests, err = c.GetSameTotalInputDigestsForAppBuilds(b1.Application.Name, build.StartTimeStamp)
+	digests, err = c.GetSameTotalInputDigestsForAppBuilds(ctx, b1.Application.Name, build.StartTimeStamp)
 	if err != nil {
 		t.Errorf("returned error %q expected no error when no builds with same input digest exist, expected no error", err)
 	}
@@ -149,12 +335,12 @@ func TestGetSameTotalInputDigestsForAppBuilds(t *testing.T) {
 		t.Errorf("returned %d digests, expected 0, if only builds with different input digest exist", len(digests))
 	}
 
-	err = c.Save(&b1)
+	_, err = c.SaveBuild(ctx, &b1)
 	if err != nil {
 		t.Fatal("Saving b1 a second time failed:", err)
 	}
 
-	digests, err = c.GetSameTotalInputDigestsForAppBuilds(b1.Application.Name, build.StartTimeStamp)
+	digests, err = c.GetSameTotalInputDigestsForAppBuilds(ctx, b1.Application.Name, build.StartTimeStamp)
 	if err != nil {
 		t.Error("returned an error instead of 1 digests:", err)
 	}
@@ -163,3 +349,122 @@ func TestGetSameTotalInputDigestsForAppBuilds(t *testing.T) {
 		t.Errorf("returned %d digests, expected 1", len(digests))
 	}
 }
+
+func TestGetSameTotalInputDigestsForAppBuildsReturnsBuildIDs(t *testing.T) {
+	c := newTestClient(t)
+
+	appName := xid.New().String()
+	sharedDigest := xid.New().String()
+
+	var ids []int
+	for _, digest := range []string{sharedDigest, xid.New().String(), sharedDigest} {
+		b := build
+		b.Application.Name = appName
+		b.TotalInputDigest = digest
+
+		id, err := c.SaveBuild(ctx, &b)
+		if err != nil {
+			t.Fatal("Saving build failed:", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	// builds that started before startTs are ignored
+	b := build
+	b.Application.Name = appName
+	b.TotalInputDigest = sharedDigest
+	b.StartTimeStamp = build.StartTimeStamp.Add(-time.Hour)
+
+	if _, err := c.SaveBuild(ctx, &b); err != nil {
+		t.Fatal("Saving build failed:", err)
+	}
+
+	digests, err := c.GetSameTotalInputDigestsForAppBuilds(ctx, appName, build.StartTimeStamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]int{sharedDigest: {ids[0], ids[2]}}
+	if !reflect.DeepEqual(digests, expected) {
+		t.Errorf("returned %v, expected %v", digests, expected)
+	}
+}
+
+func TestGetBuildOutputs(t *testing.T) {
+	c := newTestClient(t)
+
+	b := build
+	b.Application.Name = xid.New().String()
+
+	_, err := c.SaveBuild(ctx, &b)
+	if err != nil {
+		t.Fatal("saving build failed:", err)
+	}
+
+	outputs, err := c.GetBuildOutputs(ctx, b.ID)
+	if err != nil {
+		t.Fatal("GetBuildOutputs failed:", err)
+	}
+
+	if len(outputs) != len(b.Outputs) {
+		t.Fatalf("GetBuildOutputs returned %d outputs, expected %d", len(outputs), len(b.Outputs))
+	}
+
+	if outputs[0].Digest != b.Outputs[0].Digest {
+		t.Errorf("output has digest %q, expected %q", outputs[0].Digest, b.Outputs[0].Digest)
+	}
+
+	if outputs[0].Upload.URI != b.Outputs[0].Upload.URI {
+		t.Errorf("output has upload URI %q, expected %q", outputs[0].Upload.URI, b.Outputs[0].Upload.URI)
+	}
+
+	if outputs[1].Upload.RegistryDigest != b.Outputs[1].Upload.RegistryDigest {
+		t.Errorf("output has registry digest %q, expected %q", outputs[1].Upload.RegistryDigest, b.Outputs[1].Upload.RegistryDigest)
+	}
+
+	_, err = c.GetBuildOutputs(ctx, -1)
+	if err != storage.ErrNotExist {
+		t.Errorf("GetBuildOutputs returned %v for a non-existing build, expected storage.ErrNotExist", err)
+	}
+}
+
+func TestCreateAndGetRelease(t *testing.T) {
+	c := newTestClient(t)
+
+	b := build
+	b.Application.Name = xid.New().String()
+
+	_, err := c.SaveBuild(ctx, &b)
+	if err != nil {
+		t.Fatal("saving build failed:", err)
+	}
+
+	name := "release-" + xid.New().String()
+
+	created, err := c.CreateRelease(ctx, name, []int{b.ID, b.ID})
+	if err != nil {
+		t.Fatal("CreateRelease failed:", err)
+	}
+
+	release, err := c.GetRelease(ctx, name)
+	if err != nil {
+		t.Fatal("GetRelease failed:", err)
+	}
+
+	if release.ID != created.ID {
+		t.Errorf("GetRelease returned release with ID %d, expected %d", release.ID, created.ID)
+	}
+
+	if len(release.BuildIDs) != 1 || release.BuildIDs[0] != b.ID {
+		t.Errorf("release references builds %v, expected [%d]", release.BuildIDs, b.ID)
+	}
+
+	if _, err := c.CreateRelease(ctx, name, []int{b.ID}); err != storage.ErrExist {
+		t.Errorf("CreateRelease returned %v for an existing name, expected storage.ErrExist", err)
+	}
+
+	if _, err := c.GetRelease(ctx, "release-"+xid.New().String()); err != storage.ErrNotExist {
+		t.Errorf("GetRelease returned %v for a non-existing release, expected storage.ErrNotExist", err)
+	}
+}
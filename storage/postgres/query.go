@@ -41,6 +41,9 @@ type Query struct {
 	BaseQuery string
 	Filters   []*storage.Filter
 	Sorters   []*storage.Sorter
+	// Limit is the maximum number of rows that are returned, if it is 0
+	// the number is unlimited
+	Limit int
 }
 
 func toPQType(val interface{}) interface{} {
@@ -113,8 +116,13 @@ func (q *Query) compileSorterStr() (string, error) {
 // Compile compiles the actual sql query
 // and returns it along with the query params
 func (q *Query) Compile() (query string, args []interface{}, err error) {
+	var limitStr string
+	if q.Limit > 0 {
+		limitStr = fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
 	if len(q.Filters) == 0 && len(q.Sorters) == 0 {
-		return q.BaseQuery, nil, nil
+		return q.BaseQuery + limitStr, nil, nil
 	}
 
 	filterStr, args, err := q.compileFilterStr()
@@ -127,5 +135,5 @@ func (q *Query) Compile() (query string, args []interface{}, err error) {
 		return "", nil, err
 	}
 
-	return fmt.Sprintf("%s %s %s", q.BaseQuery, filterStr, orderStr), args, nil
+	return fmt.Sprintf("%s %s %s%s", q.BaseQuery, filterStr, orderStr, limitStr), args, nil
 }
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/simplesurance/baur/storage"
+)
+
+func TestCompileLimit(t *testing.T) {
+	testcases := []struct {
+		name          string
+		query         Query
+		expectedQuery string
+	}{
+		{
+			name:          "nolimit",
+			query:         Query{BaseQuery: "SELECT id FROM build"},
+			expectedQuery: "SELECT id FROM build",
+		},
+		{
+			name:          "limitwithoutfiltersorter",
+			query:         Query{BaseQuery: "SELECT id FROM build", Limit: 1},
+			expectedQuery: "SELECT id FROM build LIMIT 1",
+		},
+		{
+			name: "limitwithsorter",
+			query: Query{
+				BaseQuery: "SELECT id FROM build",
+				Sorters:   []*storage.Sorter{{Field: storage.FieldBuildStartTime, Order: storage.OrderDesc}},
+				Limit:     5,
+			},
+			expectedQuery: "SELECT id FROM build  ORDER BY build.start_timestamp DESC LIMIT 5",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, _, err := tc.query.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if query != tc.expectedQuery {
+				t.Errorf("compiled query is %q, expected %q", query, tc.expectedQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/storage"
+)
+
+// pqUniqueViolation is the postgresql error code for unique constraint
+// violations
+const pqUniqueViolation = "23505"
+
+// CreateRelease stores a release that references the builds with the passed
+// IDs. If a release with the name already exists storage.ErrExist is
+// returned.
+func (c *Client) CreateRelease(ctx context.Context, name string, buildIDs []int) (release *storage.Release, err error) {
+	const insertReleaseStmt = `
+	INSERT INTO release (name, created_at)
+	VALUES($1, $2)
+	RETURNING id`
+
+	const insertBuildsStmt = `
+	INSERT INTO release_build (release_id, build_id)
+	SELECT $1, unnest($2::INTEGER[])`
+
+	tx, err := c.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction failed")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+
+		if commitErr := tx.Commit(); commitErr != nil {
+			release = nil
+			err = errors.Wrap(commitErr, "committing transaction failed")
+		}
+	}()
+
+	release = &storage.Release{
+		Name:      name,
+		CreatedAt: time.Now(),
+		BuildIDs:  uniqSortedInts(buildIDs),
+	}
+
+	err = tx.QueryRowContext(ctx, insertReleaseStmt, release.Name, release.CreatedAt).Scan(&release.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+			return nil, storage.ErrExist
+		}
+
+		return nil, errors.Wrapf(err, "db query %q failed", insertReleaseStmt)
+	}
+
+	if len(release.BuildIDs) == 0 {
+		return release, nil
+	}
+
+	_, err = tx.ExecContext(ctx, insertBuildsStmt, release.ID, pq.Array(release.BuildIDs))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db query %q failed", insertBuildsStmt)
+	}
+
+	return release, nil
+}
+
+// GetRelease returns the release with the given name. If it does not exist
+// storage.ErrNotExist is returned.
+func (c *Client) GetRelease(ctx context.Context, name string) (*storage.Release, error) {
+	const releaseQuery = `
+	SELECT id, name, created_at
+	FROM release
+	WHERE name = $1`
+
+	const buildsQuery = `
+	SELECT build_id
+	FROM release_build
+	WHERE release_id = $1
+	ORDER BY build_id`
+
+	var release storage.Release
+
+	err := c.Db.QueryRowContext(ctx, releaseQuery, name).Scan(&release.ID, &release.Name, &release.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrNotExist
+		}
+
+		return nil, errors.Wrapf(err, "db query %q failed", releaseQuery)
+	}
+
+	rows, err := c.Db.QueryContext(ctx, buildsQuery, release.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db query %q failed", buildsQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrapf(err, "scanning result of db query %q failed", buildsQuery)
+		}
+
+		release.BuildIDs = append(release.BuildIDs, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterating over db results failed")
+	}
+
+	return &release, nil
+}
+
+func uniqSortedInts(in []int) []int {
+	res := make([]int, 0, len(in))
+	seen := make(map[int]struct{}, len(in))
+
+	for _, i := range in {
+		if _, exist := seen[i]; exist {
+			continue
+		}
+
+		seen[i] = struct{}{}
+		res = append(res, i)
+	}
+
+	sort.Ints(res)
+
+	return res
+}
@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// TLSFiles contains the paths of files that are used to establish TLS
+// connections to the server. Empty fields are not added to the connection
+// string.
+type TLSFiles struct {
+	// RootCert is the CA certificate file that the server certificate is
+	// verified with (sslrootcert)
+	RootCert string
+	// Cert is the client certificate file (sslcert)
+	Cert string
+	// Key is the private key file of the client certificate (sslkey)
+	Key string
+}
+
+// tlsFileParams are the connection string parameters that reference files
+// that are used for TLS connections
+var tlsFileParams = []string{"sslrootcert", "sslcert", "sslkey"}
+
+func (t *TLSFiles) params() map[string]string {
+	res := map[string]string{}
+
+	if t.RootCert != "" {
+		res["sslrootcert"] = t.RootCert
+	}
+
+	if t.Cert != "" {
+		res["sslcert"] = t.Cert
+	}
+
+	if t.Key != "" {
+		res["sslkey"] = t.Key
+	}
+
+	return res
+}
+
+// isURL returns true if connStr is a connection URL and not a key=value
+// connection string
+func isURL(connStr string) bool {
+	return strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://")
+}
+
+// ConnectionString returns connStr with the parameters for the files in
+// tlsFiles added. connStr can be a connection URL or a key=value connection
+// string. Parameters that are already part of connStr are replaced.
+func ConnectionString(connStr string, tlsFiles TLSFiles) (string, error) {
+	params := tlsFiles.params()
+	if len(params) == 0 {
+		return connStr, nil
+	}
+
+	if isURL(connStr) {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return "", errors.Wrap(err, "parsing connection URL failed")
+		}
+
+		q := u.Query()
+		for k, v := range params {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+
+		return u.String(), nil
+	}
+
+	// when a key is specified multiple times, the last value is used
+	res := strings.TrimSpace(connStr)
+	for _, k := range tlsFileParams {
+		v, exist := params[k]
+		if !exist {
+			continue
+		}
+
+		if res != "" {
+			res += " "
+		}
+
+		res += k + "=" + quoteConnStrValue(v)
+	}
+
+	return res, nil
+}
+
+// quoteConnStrValue quotes a value of a key=value connection string
+func quoteConnStrValue(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+
+	return "'" + v + "'"
+}
+
+// connStrParams returns the parameters of a connection URL or key=value
+// connection string
+func connStrParams(connStr string) (map[string]string, error) {
+	if isURL(connStr) {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing connection URL failed")
+		}
+
+		res := map[string]string{}
+		for k, v := range u.Query() {
+			res[k] = v[len(v)-1]
+		}
+
+		return res, nil
+	}
+
+	return parseKeyValueConnStr(connStr)
+}
+
+// parseKeyValueConnStr parses a connection string in the format
+// key=value [key=value...], values can be quoted with single quotes, single
+// quotes and backslashes in values are escaped with a backslash.
+func parseKeyValueConnStr(connStr string) (map[string]string, error) {
+	res := map[string]string{}
+	s := []rune(connStr)
+	i := 0
+
+	skipSpaces := func() {
+		for i < len(s) && unicode.IsSpace(s[i]) {
+			i++
+		}
+	}
+
+	for {
+		skipSpaces()
+		if i >= len(s) {
+			return res, nil
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && !unicode.IsSpace(s[i]) {
+			i++
+		}
+		key := string(s[start:i])
+
+		skipSpaces()
+		if i >= len(s) || s[i] != '=' {
+			return nil, fmt.Errorf("missing '=' after key %q in connection string", key)
+		}
+		i++
+		skipSpaces()
+
+		var val []rune
+		quoted := i < len(s) && s[i] == '\''
+		if quoted {
+			i++
+		}
+
+		for ; i < len(s); i++ {
+			c := s[i]
+
+			if c == '\\' && i+1 < len(s) {
+				i++
+				val = append(val, s[i])
+				continue
+			}
+
+			if quoted && c == '\'' {
+				break
+			}
+
+			if !quoted && unicode.IsSpace(c) {
+				break
+			}
+
+			val = append(val, c)
+		}
+
+		if quoted {
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated quoted value of key %q in connection string", key)
+			}
+			i++
+		}
+
+		res[key] = string(val)
+	}
+}
+
+// checkTLSFiles returns an error if a file that is referenced by a TLS file
+// parameter in connStr does not exist. The driver ignores some missing files
+// silently, which results in confusing certificate verification errors.
+func checkTLSFiles(connStr string) error {
+	params, err := connStrParams(connStr)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range tlsFileParams {
+		path := params[k]
+		if path == "" {
+			continue
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("file %q referenced by the %s connection parameter does not exist", path, k)
+			}
+
+			return errors.Wrapf(err, "checking file %q referenced by the %s connection parameter failed", path, k)
+		}
+	}
+
+	return nil
+}
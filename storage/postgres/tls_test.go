@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func TestConnectionString(t *testing.T) {
+	tlsFiles := TLSFiles{
+		RootCert: "/etc/baur/ca.crt",
+		Cert:     "/etc/baur/client.crt",
+		Key:      "/etc/baur/client key.pem",
+	}
+
+	expectedParams := map[string]string{
+		"sslrootcert": "/etc/baur/ca.crt",
+		"sslcert":     "/etc/baur/client.crt",
+		"sslkey":      "/etc/baur/client key.pem",
+	}
+
+	testcases := []struct {
+		name    string
+		connStr string
+		// expected contains parameters that must be part of the
+		// result additionally to expectedParams
+		expected map[string]string
+	}{
+		{
+			name:     "url",
+			connStr:  "postgres://baur@db.example.com:5432/baur?sslmode=verify-full",
+			expected: map[string]string{"sslmode": "verify-full"},
+		},
+		{
+			name:     "url_replaces_existing",
+			connStr:  "postgresql://baur@db.example.com/baur?sslrootcert=/tmp/old.crt&connect_timeout=5",
+			expected: map[string]string{"connect_timeout": "5"},
+		},
+		{
+			name:     "key_value",
+			connStr:  "host=db.example.com dbname=baur sslmode=verify-full",
+			expected: map[string]string{"host": "db.example.com", "dbname": "baur", "sslmode": "verify-full"},
+		},
+		{
+			name:     "key_value_replaces_existing",
+			connStr:  "host=db.example.com sslkey='/tmp/old key.pem'",
+			expected: map[string]string{"host": "db.example.com"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := ConnectionString(tc.connStr, tlsFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params, err := connStrParams(res)
+			if err != nil {
+				t.Fatalf("parsing result %q failed: %s", res, err)
+			}
+
+			expected := map[string]string{}
+			for k, v := range expectedParams {
+				expected[k] = v
+			}
+			for k, v := range tc.expected {
+				expected[k] = v
+			}
+
+			if !reflect.DeepEqual(params, expected) {
+				t.Errorf("ConnectionString returned %q with parameters %v, expected %v", res, params, expected)
+			}
+		})
+	}
+}
+
+func TestConnectionStringWithoutTLSFilesIsUnchanged(t *testing.T) {
+	const connStr = "host=localhost dbname=baur"
+
+	res, err := ConnectionString(connStr, TLSFiles{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != connStr {
+		t.Errorf("ConnectionString returned %q, expected %q", res, connStr)
+	}
+}
+
+func TestNewFailsForMissingTLSFile(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	caPath := filepath.Join(tempDir, "ca.crt")
+	fstest.WriteToFile(t, []byte("ca"), caPath)
+
+	missingPath := filepath.Join(tempDir, "client.crt")
+
+	connStr, err := ConnectionString(
+		"postgres://baur@localhost:1/baur?sslmode=verify-full",
+		TLSFiles{RootCert: caPath, Cert: missingPath},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(connStr)
+	if err == nil {
+		t.Fatal("New did not return an error for a missing certificate file")
+	}
+
+	if !strings.Contains(err.Error(), missingPath) || !strings.Contains(err.Error(), "sslcert") {
+		t.Errorf("error %q does not mention the missing file and the parameter", err)
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// FilterByApp returns a Filter that matches builds of the application with
+// the given name
+func FilterByApp(name string) *Filter {
+	return &Filter{
+		Field:    FieldApplicationName,
+		Operator: OpEQ,
+		Value:    name,
+	}
+}
+
+// FilterByBuildID returns a Filter that matches the build with the given ID
+func FilterByBuildID(id int) *Filter {
+	return &Filter{
+		Field:    FieldBuildID,
+		Operator: OpEQ,
+		Value:    id,
+	}
+}
+
+// FilterStartedAfter returns a Filter that matches builds that were started
+// after t
+func FilterStartedAfter(t time.Time) *Filter {
+	return &Filter{
+		Field:    FieldBuildStartTime,
+		Operator: OpGT,
+		Value:    t,
+	}
+}
+
+// FilterStartedBefore returns a Filter that matches builds that were started
+// before t
+func FilterStartedBefore(t time.Time) *Filter {
+	return &Filter{
+		Field:    FieldBuildStartTime,
+		Operator: OpLT,
+		Value:    t,
+	}
+}
+
+// SortBy returns a Sorter that sorts by field in the given order.
+// An error is returned if field or order is undefined.
+func SortBy(field Field, order Order) (*Sorter, error) {
+	switch field {
+	case FieldApplicationName, FieldBuildDuration, FieldBuildStartTime, FieldBuildID:
+	default:
+		return nil, fmt.Errorf("sorting by field %s is not supported", field)
+	}
+
+	if order != OrderAsc && order != OrderDesc {
+		return nil, fmt.Errorf("invalid sort order %s", order)
+	}
+
+	return &Sorter{Field: field, Order: order}, nil
+}
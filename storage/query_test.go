@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestSortBy(t *testing.T) {
+	s, err := SortBy(FieldBuildStartTime, OrderDesc)
+	if err != nil {
+		t.Fatal("SortBy failed:", err)
+	}
+
+	if s.Field != FieldBuildStartTime || s.Order != OrderDesc {
+		t.Errorf("SortBy returned %s, expected sorter for %s-%s", s, FieldBuildStartTime, OrderDesc)
+	}
+}
+
+func TestSortByFailsForUndefinedValues(t *testing.T) {
+	testcases := []struct {
+		name  string
+		field Field
+		order Order
+	}{
+		{name: "undefinedfield", field: FieldUndefined, order: OrderAsc},
+		{name: "unknownfield", field: Field(100), order: OrderAsc},
+		{name: "invalidorder", field: FieldBuildID, order: SortInvalid},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if s, err := SortBy(tc.field, tc.order); err == nil {
+				t.Errorf("SortBy returned %s, expected an error", s)
+			}
+		})
+	}
+}
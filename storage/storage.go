@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -23,13 +24,19 @@ type UploadMethod string
 // Description of UploadMethod Values
 const (
 	S3             UploadMethod = "s3"
+	GCS            UploadMethod = "gcs"
+	AzureBlob      UploadMethod = "azureblob"
 	DockerRegistry UploadMethod = "docker"
 	FileCopy       UploadMethod = "filecopy"
+	Command        UploadMethod = "command"
 )
 
 // ErrNotExist indicates that a record does not exist
 var ErrNotExist = errors.New("does not exist")
 
+// ErrExist indicates that a record already exists
+var ErrExist = errors.New("already exists")
+
 // VCSState contains informations about the VCS at the time of the build
 type VCSState struct {
 	CommitID string
@@ -71,6 +78,9 @@ type Upload struct {
 	UploadDuration time.Duration
 	URI            string
 	Method         UploadMethod
+	// RegistryDigest is the digest of the image manifest in the docker
+	// registry (sha256:<hex>), it is empty for other upload methods
+	RegistryDigest string
 }
 
 // Output represents a build output
@@ -82,6 +92,17 @@ type Output struct {
 	Upload    Upload
 }
 
+// Release is a named set of builds, it can not be changed after it was
+// created
+type Release struct {
+	ID        int
+	Name      string
+	CreatedAt time.Time
+	// BuildIDs are the IDs of the builds that are part of the release,
+	// sorted ascending
+	BuildIDs []int
+}
+
 // Field represents data fields that can be used in sort and filter operations
 type Field int
 
@@ -143,6 +164,10 @@ func (o Op) String() string {
 		return "OpEQ"
 	case OpGT:
 		return "OpGT"
+	case OpLT:
+		return "OpLT"
+	case OpIN:
+		return "OpIN"
 	default:
 		return "OpUndefined"
 	}
@@ -194,21 +219,64 @@ func (s *Sorter) String() string {
 	return fmt.Sprintf("%s-%s", s.Field, s.Order)
 }
 
-// Storer is an interface for persisting informations about builds
-type Storer interface {
-	Init() error
-	Save(b *Build) error
+// Client is the interface that is implemented by all storage backends to
+// query informations about builds.
+// Operations are aborted when the passed context is canceled or it's deadline
+// expires.
+type Client interface {
+	// Init creates the database structures that are required to store
+	// builds. If they already exist, they are updated to the current
+	// version.
+	Init(ctx context.Context) error
+	// Close closes the connection to the storage
+	Close() error
+
+	GetApps(ctx context.Context) ([]*Application, error)
+
+	GetSameTotalInputDigestsForAppBuilds(ctx context.Context, appName string, startTs time.Time) (map[string][]int, error)
+	GetLatestBuildByDigest(ctx context.Context, appName, totalInputDigest string) (*BuildWithDuration, error)
+
+	// GetBuildOutputs returns the outputs of a build sorted by their name,
+	// if no build with the ID exist ErrNotExist is returned
+	GetBuildOutputs(ctx context.Context, buildID int) ([]*Output, error)
+	// GetBuildInputs returns the inputs of a build sorted by their URI
+	GetBuildInputs(ctx context.Context, buildID int) ([]*Input, error)
+	BuildExist(ctx context.Context, id int) (bool, error)
 
-	GetApps() ([]*Application, error)
+	// GetBuildWithoutInputsOutputs returns a single build, if no build with the ID
+	// exist ErrNotExist is returned
+	GetBuildWithoutInputsOutputs(ctx context.Context, id int) (*BuildWithDuration, error)
+	// GetBuildsWithoutInputsOutputs returns the builds that match all
+	// filters, sorted by the sorters. If limit is greater than 0, at most
+	// limit builds are returned.
+	GetBuildsWithoutInputsOutputs(ctx context.Context, filters []*Filter, sorters []*Sorter, limit int) ([]*BuildWithDuration, error)
 
-	GetSameTotalInputDigestsForAppBuilds(appName string, startTs time.Time) (map[string][]int, error)
-	GetLatestBuildByDigest(appName, totalInputDigest string) (*BuildWithDuration, error)
+	// GetRelease returns the release with the given name, if it does not
+	// exist ErrNotExist is returned
+	GetRelease(ctx context.Context, name string) (*Release, error)
+}
 
-	GetBuildOutputs(buildID int) ([]*Output, error)
-	BuildExist(id int) (bool, error)
+// Storer is a Client that can also persist informations about builds
+type Storer interface {
+	Client
+
+	// SaveBuild records a build with it's inputs and outputs. The ID
+	// field of the passed build is ignored, the ID of the created record
+	// is returned and stored in the build.
+	SaveBuild(ctx context.Context, b *Build) (int, error)
+	// CreateRelease stores a release with the given name that references
+	// the builds, if a release with the name already exists ErrExist is
+	// returned
+	CreateRelease(ctx context.Context, name string, buildIDs []int) (*Release, error)
+}
 
-	// GetBuildWithoutInputsOutputs returns a single build, if no build with the ID
-	// exist ErrNotExist is returned
-	GetBuildWithoutInputsOutputs(id int) (*BuildWithDuration, error)
-	GetBuildsWithoutInputsOutputs(filters []*Filter, sorters []*Sorter) ([]*BuildWithDuration, error)
+// Migrator is implemented by storage backends that version their database
+// structures
+type Migrator interface {
+	// SchemaVersion returns the version of the existing database
+	// structures, if they were not created yet 0 is returned
+	SchemaVersion(ctx context.Context) (int, error)
+	// LatestSchemaVersion returns the version that Init updates the
+	// database structures to
+	LatestSchemaVersion() int
 }
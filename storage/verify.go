@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"sort"
 	"strings"
 	"time"
@@ -124,10 +125,10 @@ func findOutputsWithDifferentDigest(refBuild *BuildWithDuration, builds []*Build
 // those builds and checks if the other builds have the outputs with the same
 // digest.
 // For builds that don't match an Issue description is returned
-func VerifySameInputDigestSameOutputs(clt Storer, appName string, startTs time.Time) ([]*VerifyIssue, error) {
+func VerifySameInputDigestSameOutputs(ctx context.Context, clt Storer, appName string, startTs time.Time) ([]*VerifyIssue, error) {
 	var issues []*VerifyIssue
 
-	builds, err := clt.GetSameTotalInputDigestsForAppBuilds(appName, startTs)
+	builds, err := clt.GetSameTotalInputDigestsForAppBuilds(ctx, appName, startTs)
 	if err != nil {
 		if err == ErrNotExist {
 			return nil, err
@@ -137,18 +138,18 @@ func VerifySameInputDigestSameOutputs(clt Storer, appName string, startTs time.T
 	}
 
 	for totalInputDigest, buildIDs := range builds {
-		builds, err := clt.GetBuildsWithoutInputsOutputs([]*Filter{
+		builds, err := clt.GetBuildsWithoutInputsOutputs(ctx, []*Filter{
 			{
 				Field:    FieldBuildID,
 				Operator: OpIN,
 				Value:    buildIDs,
-			}}, nil)
+			}}, nil, 0)
 		if err != nil {
 			return nil, errors.Wrapf(err, "retrieving builds for %s with TotalInputDigest %q failed", appName, totalInputDigest)
 		}
 
 		for _, build := range builds {
-			build.Outputs, err = clt.GetBuildOutputs(build.ID)
+			build.Outputs, err = clt.GetBuildOutputs(ctx, build.ID)
 			if err != nil {
 				return nil, errors.Wrapf(err, "retrieving build outputs for build %d failed", build.ID)
 			}
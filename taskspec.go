@@ -0,0 +1,122 @@
+package baur
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// BuildTaskName is the name of the build task of an application
+const BuildTaskName = "build"
+
+// taskSpecSep separates the application and task name in a task specifier
+const taskSpecSep = "."
+
+// Task is a task of an application
+type Task struct {
+	App  *App
+	Name string
+}
+
+// String returns the task in the task specifier format <APP-NAME>.<TASK>
+func (t *Task) String() string {
+	return t.App.Name + taskSpecSep + t.Name
+}
+
+// Tasks returns the tasks of the application, every application has a
+// build task
+func (a *App) Tasks() []*Task {
+	return []*Task{{App: a, Name: BuildTaskName}}
+}
+
+// SortedTasks returns the tasks of the application sorted by name
+func (a *App) SortedTasks() []*Task {
+	tasks := a.Tasks()
+	SortTasksByName(tasks)
+
+	return tasks
+}
+
+// SortTasksByName sorts the tasks in the slice by the application name and
+// then by the task name
+func SortTasksByName(tasks []*Task) {
+	sort.Slice(tasks, func(i int, j int) bool {
+		if tasks[i].App.Name != tasks[j].App.Name {
+			return tasks[i].App.Name < tasks[j].App.Name
+		}
+
+		return tasks[i].Name < tasks[j].Name
+	})
+}
+
+// ParseTaskSpecifier splits a task specifier in the format
+// <APP-NAME>.<TASK> into the application and task pattern.
+// Both patterns can contain '*' wildcards that match any sequence of
+// characters.
+// An error is returned if s does not contain exactly one '.', if one of the
+// patterns is empty or malformed.
+func ParseTaskSpecifier(s string) (appPattern, taskPattern string, err error) {
+	spl := strings.Split(s, taskSpecSep)
+	if len(spl) != 2 {
+		return "", "", fmt.Errorf("task specifier %q must contain exactly one %q", s, taskSpecSep)
+	}
+
+	appPattern, taskPattern = spl[0], spl[1]
+
+	if appPattern == "" {
+		return "", "", fmt.Errorf("application name in task specifier %q is empty", s)
+	}
+
+	if taskPattern == "" {
+		return "", "", fmt.Errorf("task name in task specifier %q is empty", s)
+	}
+
+	for _, p := range []string{appPattern, taskPattern} {
+		if _, err := path.Match(p, ""); err != nil {
+			return "", "", fmt.Errorf("task specifier %q contains a malformed pattern", s)
+		}
+	}
+
+	return appPattern, taskPattern, nil
+}
+
+// MatchTasks returns the tasks of apps whose application name matches
+// appPattern and whose task name matches taskPattern.
+// The patterns are matched with path.Match().
+// The returned tasks are sorted with SortTasksByName.
+func MatchTasks(apps []*App, appPattern, taskPattern string) ([]*Task, error) {
+	var res []*Task
+
+	for _, app := range apps {
+		matched, err := path.Match(appPattern, app.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matched {
+			continue
+		}
+
+		for _, task := range app.SortedTasks() {
+			matched, err := path.Match(taskPattern, task.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			if matched {
+				res = append(res, task)
+			}
+		}
+	}
+
+	SortTasksByName(res)
+
+	return res, nil
+}
+
+// IsTaskSpecifier returns true if s has the format of a task specifier, it
+// does not validate the specifier
+func IsTaskSpecifier(s string) bool {
+	return strings.Contains(s, taskSpecSep)
+}
@@ -0,0 +1,150 @@
+package baur
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseTaskSpecifier(t *testing.T) {
+	testcases := []struct {
+		spec        string
+		appPattern  string
+		taskPattern string
+		wantErr     bool
+	}{
+		{spec: "calc.build", appPattern: "calc", taskPattern: "build"},
+		{spec: "*.build", appPattern: "*", taskPattern: "build"},
+		{spec: "payment-service.*", appPattern: "payment-service", taskPattern: "*"},
+		{spec: "*.*", appPattern: "*", taskPattern: "*"},
+		{spec: "calc", wantErr: true},
+		{spec: "calc.build.x", wantErr: true},
+		{spec: ".build", wantErr: true},
+		{spec: "calc.", wantErr: true},
+		{spec: "[calc.build", wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.spec, func(t *testing.T) {
+			appPattern, taskPattern, err := ParseTaskSpecifier(tc.spec)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseTaskSpecifier() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if appPattern != tc.appPattern || taskPattern != tc.taskPattern {
+				t.Errorf("ParseTaskSpecifier() returned (%q, %q), expected (%q, %q)",
+					appPattern, taskPattern, tc.appPattern, tc.taskPattern)
+			}
+		})
+	}
+}
+
+func TestMatchTasks(t *testing.T) {
+	apps := []*App{
+		{Name: "calc"},
+		{Name: "payment-service"},
+		{Name: "payment-gateway"},
+	}
+
+	testcases := []struct {
+		spec     string
+		expected []string
+	}{
+		{spec: "*.build", expected: []string{"calc.build", "payment-gateway.build", "payment-service.build"}},
+		{spec: "payment-service.*", expected: []string{"payment-service.build"}},
+		{spec: "payment-*.build", expected: []string{"payment-gateway.build", "payment-service.build"}},
+		{spec: "*.*", expected: []string{"calc.build", "payment-gateway.build", "payment-service.build"}},
+		{spec: "calc.test", expected: nil},
+		{spec: "unknown.*", expected: nil},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.spec, func(t *testing.T) {
+			appPattern, taskPattern, err := ParseTaskSpecifier(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tasks, err := MatchTasks(apps, appPattern, taskPattern)
+			if err != nil {
+				t.Fatal("MatchTasks failed:", err)
+			}
+
+			var res []string
+			for _, task := range tasks {
+				res = append(res, task.String())
+			}
+			sort.Strings(res)
+
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Errorf("MatchTasks returned %q, expected %q", res, tc.expected)
+			}
+		})
+	}
+}
+
+func taskStrings(tasks []*Task) []string {
+	res := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		res = append(res, task.String())
+	}
+
+	return res
+}
+
+func TestSortTasksByName(t *testing.T) {
+	calc := &App{Name: "calc"}
+	gateway := &App{Name: "payment-gateway"}
+	service := &App{Name: "payment-service"}
+
+	expected := []string{
+		"calc.build",
+		"calc.check",
+		"payment-gateway.build",
+		"payment-service.build",
+		"payment-service.test",
+	}
+
+	orders := [][]*Task{
+		{
+			{App: service, Name: "test"},
+			{App: calc, Name: "check"},
+			{App: gateway, Name: "build"},
+			{App: service, Name: "build"},
+			{App: calc, Name: "build"},
+		},
+		{
+			{App: calc, Name: "build"},
+			{App: service, Name: "build"},
+			{App: calc, Name: "check"},
+			{App: service, Name: "test"},
+			{App: gateway, Name: "build"},
+		},
+	}
+
+	for i, tasks := range orders {
+		SortTasksByName(tasks)
+
+		if res := taskStrings(tasks); !reflect.DeepEqual(res, expected) {
+			t.Errorf("sorting tasks in order %d returned %q, expected %q", i, res, expected)
+		}
+	}
+}
+
+func TestMatchTasksReturnsSortedTasks(t *testing.T) {
+	apps := []*App{
+		{Name: "payment-service"},
+		{Name: "calc"},
+		{Name: "payment-gateway"},
+	}
+
+	tasks, err := MatchTasks(apps, "*", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"calc.build", "payment-gateway.build", "payment-service.build"}
+	if res := taskStrings(tasks); !reflect.DeepEqual(res, expected) {
+		t.Errorf("MatchTasks returned %q, expected %q", res, expected)
+	}
+}
@@ -0,0 +1,127 @@
+package term
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+
+	"github.com/simplesurance/baur"
+)
+
+// noColorEnvVar is the name of the environment variable that disables colored
+// output when it is set to a non-empty value, see https://no-color.org/
+const noColorEnvVar = "NO_COLOR"
+
+var (
+	// GreenHighlight returns the string representation of the arguments
+	// colored in green
+	GreenHighlight = color.New(color.FgGreen).SprintFunc()
+	// RedHighlight returns the string representation of the arguments
+	// colored in red
+	RedHighlight = color.New(color.FgRed).SprintFunc()
+	// YellowHighlight returns the string representation of the arguments
+	// colored in yellow
+	YellowHighlight = color.New(color.FgYellow).SprintFunc()
+	// Underline returns the string representation of the arguments
+	// underlined
+	Underline = color.New(color.Underline).SprintFunc()
+	// Highlight is a function that highlights parts of strings in the cli
+	// output
+	Highlight = GreenHighlight
+)
+
+// ColorMode defines when output is colored
+type ColorMode string
+
+// Supported color modes
+const (
+	// ColorModeAuto colors output if stdout is a terminal that supports it
+	// and NO_COLOR is not set
+	ColorModeAuto ColorMode = "auto"
+	// ColorModeAlways always colors output
+	ColorModeAlways ColorMode = "always"
+	// ColorModeNever never colors output
+	ColorModeNever ColorMode = "never"
+)
+
+// ColorModes are all supported color modes
+var ColorModes = []ColorMode{ColorModeAuto, ColorModeAlways, ColorModeNever}
+
+func init() {
+	EnableColorFor(os.Stdout)
+}
+
+// IsTerminal returns true if w is a file descriptor that refers to a
+// terminal
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// ColorSupported returns true if colored output should be written to w.
+// It is false if w is not a terminal, if the terminal is dumb or if the
+// NO_COLOR environment variable is set.
+func ColorSupported(w io.Writer) bool {
+	if os.Getenv(noColorEnvVar) != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return IsTerminal(w)
+}
+
+// EnableColorFor enables colored output if it is supported by w and disables
+// it otherwise.
+// The setting applies to the helpers in this package and to all other users
+// of github.com/fatih/color, like the log package.
+// On startup it is set for os.Stdout.
+func EnableColorFor(w io.Writer) {
+	color.NoColor = !ColorSupported(w)
+}
+
+// DisableColor disables colored output
+func DisableColor() {
+	color.NoColor = true
+}
+
+// SetColorMode configures when output is colored, the setting applies to the
+// whole process like EnableColorFor.
+func SetColorMode(mode ColorMode) error {
+	switch mode {
+	case ColorModeAuto:
+		EnableColorFor(os.Stdout)
+	case ColorModeAlways:
+		color.NoColor = false
+	case ColorModeNever:
+		DisableColor()
+	default:
+		return fmt.Errorf("unsupported color mode %q", mode)
+	}
+
+	return nil
+}
+
+// ColoredBuildStatus returns the string representation of the build status,
+// colored by it's meaning
+func ColoredBuildStatus(status baur.BuildStatus) string {
+	switch status {
+	case baur.BuildStatusInputsUndefined:
+		return YellowHighlight(status.String())
+	case baur.BuildStatusBuildCommandUndefined:
+		return YellowHighlight(status.String())
+	case baur.BuildStatusExist:
+		return GreenHighlight(status.String())
+	case baur.BuildStatusPending:
+		return RedHighlight(status.String())
+	case baur.BuildStatusOutputsMissing:
+		return RedHighlight(status.String())
+	default:
+		return status.String()
+	}
+}
@@ -0,0 +1,175 @@
+package term
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+
+	"github.com/simplesurance/baur"
+)
+
+var allBuildStatuses = []baur.BuildStatus{
+	baur.BuildStatusInputsUndefined,
+	baur.BuildStatusBuildCommandUndefined,
+	baur.BuildStatusExist,
+	baur.BuildStatusPending,
+	baur.BuildStatusOutputsMissing,
+}
+
+// setNoColor sets color.NoColor and returns a function that restores the
+// previous value
+func setNoColor(val bool) func() {
+	old := color.NoColor
+	color.NoColor = val
+
+	return func() { color.NoColor = old }
+}
+
+func TestColoredBuildStatusIsPlainWithoutTTY(t *testing.T) {
+	defer setNoColor(true)()
+
+	for _, status := range allBuildStatuses {
+		s := ColoredBuildStatus(status)
+
+		if s != status.String() {
+			t.Errorf("ColoredBuildStatus(%d) returned %q, expected %q", status, s, status.String())
+		}
+	}
+}
+
+func TestColoredBuildStatusIsColoredOnTTY(t *testing.T) {
+	defer setNoColor(false)()
+
+	for _, status := range allBuildStatuses {
+		s := ColoredBuildStatus(status)
+
+		if !strings.Contains(s, "\x1b[") {
+			t.Errorf("ColoredBuildStatus(%d) returned %q, expected it to contain an ANSI escape sequence", status, s)
+		}
+
+		if !strings.Contains(s, status.String()) {
+			t.Errorf("ColoredBuildStatus(%d) returned %q, expected it to contain %q", status, s, status.String())
+		}
+	}
+}
+
+// setEnv sets an environment variable and returns a function that restores
+// the previous state
+func setEnv(t *testing.T, key, val string) func() {
+	t.Helper()
+
+	old, exists := os.LookupEnv(key)
+
+	if err := os.Setenv(key, val); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if exists {
+			os.Setenv(key, old)
+			return
+		}
+
+		os.Unsetenv(key)
+	}
+}
+
+func assertNoEscapeSequences(t *testing.T) {
+	t.Helper()
+
+	for _, s := range []string{
+		ColoredBuildStatus(baur.BuildStatusExist),
+		Highlight("highlighted"),
+		RedHighlight("red"),
+		YellowHighlight("yellow"),
+		Underline("underlined"),
+	} {
+		if strings.Contains(s, "\x1b") {
+			t.Errorf("%q contains an ANSI escape sequence", s)
+		}
+	}
+}
+
+func TestNoColorForNonTTYWriter(t *testing.T) {
+	defer setNoColor(false)()
+
+	var buf bytes.Buffer
+
+	if IsTerminal(&buf) {
+		t.Fatal("IsTerminal returned true for a bytes.Buffer")
+	}
+
+	EnableColorFor(&buf)
+
+	assertNoEscapeSequences(t)
+}
+
+func TestNoColorForNonTTYFile(t *testing.T) {
+	defer setNoColor(false)()
+
+	f, err := ioutil.TempFile("", "baur-term")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	EnableColorFor(f)
+
+	assertNoEscapeSequences(t)
+}
+
+func TestNoColorEnvVarDisablesColor(t *testing.T) {
+	defer setEnv(t, noColorEnvVar, "1")()
+
+	if ColorSupported(os.Stdout) {
+		t.Errorf("ColorSupported returned true while %s is set", noColorEnvVar)
+	}
+}
+
+func TestDisableColor(t *testing.T) {
+	defer setNoColor(false)()
+
+	DisableColor()
+
+	assertNoEscapeSequences(t)
+}
+
+func TestSetColorMode(t *testing.T) {
+	defer setNoColor(color.NoColor)()
+
+	if err := SetColorMode(ColorModeAlways); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(Highlight("x"), "\x1b[") {
+		t.Errorf("output is not colored with color mode %s", ColorModeAlways)
+	}
+
+	if err := SetColorMode(ColorModeNever); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNoEscapeSequences(t)
+
+	// stdout is not a terminal when running tests
+	if !IsTerminal(os.Stdout) {
+		if err := SetColorMode(ColorModeAlways); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SetColorMode(ColorModeAuto); err != nil {
+			t.Fatal(err)
+		}
+
+		assertNoEscapeSequences(t)
+	}
+
+	if err := SetColorMode("sometimes"); err == nil {
+		t.Error("SetColorMode did not return an error for an unsupported mode")
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 // CreateTempDir creates a new temporary directory, returns a name and a cleanup
 // function that removes the directory.
-func CreateTempDir(t *testing.T) (string, func()) {
+func CreateTempDir(t testing.TB) (string, func()) {
 	t.Helper()
 
 	dir, err := ioutil.TempDir("", "baur-filesrc-test")
@@ -21,7 +21,7 @@ func CreateTempDir(t *testing.T) (string, func()) {
 }
 
 // WriteToFile writes data to a file, calls t.Fatal() on an error
-func WriteToFile(t *testing.T, data []byte, path string) {
+func WriteToFile(t testing.TB, data []byte, path string) {
 	t.Helper()
 
 	err := ioutil.WriteFile(path, data, 0644)
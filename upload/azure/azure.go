@@ -0,0 +1,340 @@
+// Package azure uploads files to Azure Blob Storage containers.
+// The upload is done via the Put Blob operation of the Blob Storage REST API,
+// authentication happens with the storage account key (Shared Key) or a
+// shared access signature (SAS) token,
+// see https://docs.microsoft.com/en-us/rest/api/storageservices/put-blob
+package azure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// apiVersion is the version of the Blob Storage REST API that is used
+const apiVersion = "2019-12-12"
+
+// MaxBlobSize is the max. size of a file that can be uploaded
+const MaxBlobSize = 5000 * 1024 * 1024
+
+// uploadTimeout is the max. duration of an upload
+const uploadTimeout = 30 * time.Minute
+
+// Logger defines the interface for an Azure logger
+type Logger interface {
+	Debugf(format string, v ...interface{})
+}
+
+// UploadOptions configure how a file is uploaded
+type UploadOptions struct {
+	// AccountURL is the URL of the Blob Storage service, if it is empty
+	// https://<account>.blob.core.windows.net is used.
+	AccountURL string
+}
+
+// Client is an Azure Blob Storage uploader client
+type Client struct {
+	logger     Logger
+	account    string
+	key        string
+	sasToken   string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewClient returns a new Client for the storage account.
+// Requests are authorized with the base64 encoded account key, if key is
+// empty the sasToken is appended to the request URLs instead.
+// The credentials are only validated when a file is uploaded.
+func NewClient(logger Logger, account, key, sasToken string) *Client {
+	return &Client{
+		logger:     logger,
+		account:    account,
+		key:        key,
+		sasToken:   strings.TrimPrefix(sasToken, "?"),
+		httpClient: &http.Client{Timeout: uploadTimeout},
+		now:        time.Now,
+	}
+}
+
+// parseURL parses an URL in the format azblob://<container>/<blob> and
+// returns the container and blob name
+func parseURL(dest string) (string, string, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Scheme != "azblob" {
+		return "", "", fmt.Errorf("unsupported URL scheme '%s'", u.Scheme)
+	}
+
+	if len(u.Host) == 0 {
+		return "", "", fmt.Errorf("container missing in url '%s'", dest)
+	}
+
+	blob := strings.TrimPrefix(u.Path, "/")
+	if len(blob) == 0 {
+		return "", "", fmt.Errorf("blob name missing in url '%s'", dest)
+	}
+
+	return u.Host, blob, nil
+}
+
+// Upload uploads a file to the default Blob Storage service of the account.
+// dest must be an URL in the format azblob://<container>/<blob>.
+// On success it returns the URL of the blob.
+func (c *Client) Upload(file, dest string) (string, error) {
+	return c.UploadWithOptions(file, dest, UploadOptions{})
+}
+
+// UploadWithOptions uploads a file as block blob to a container.
+// dest must be an URL in the format azblob://<container>/<blob>, an existing
+// blob is overwritten. On success it returns the URL of the blob.
+func (c *Client) UploadWithOptions(file, dest string, opts UploadOptions) (string, error) {
+	if err := c.verifyCredentials(); err != nil {
+		return "", err
+	}
+
+	container, blob, err := parseURL(dest)
+	if err != nil {
+		return "", err
+	}
+
+	blobURL, err := c.blobURL(opts.AccountURL, container, blob)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if fi.Size() > MaxBlobSize {
+		return "", fmt.Errorf("%s is bigger than the max. supported size of %d bytes", file, MaxBlobSize)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, blobURL.String(), f)
+	if err != nil {
+		return "", err
+	}
+
+	req.ContentLength = fi.Size()
+	// with a non-nil body and a ContentLength of 0 net/http does not
+	// know that the body is empty and sends a chunked request, Put Blob
+	// rejects chunked requests
+	if req.ContentLength == 0 {
+		req.Body = http.NoBody
+	}
+
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-date", c.now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", apiVersion)
+
+	if err := c.authorize(req); err != nil {
+		return "", err
+	}
+
+	c.logger.Debugf("azure: uploading %s to %s", file, blobURL)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", responseError(resp)
+	}
+
+	return blobURL.String(), nil
+}
+
+// Exists returns true if the blob that uri refers to exists.
+// uri must be an URL as returned by Upload or in the format
+// azblob://<container>/<blob>.
+func (c *Client) Exists(uri string) (bool, error) {
+	if err := c.verifyCredentials(); err != nil {
+		return false, err
+	}
+
+	blobURL, err := url.Parse(uri)
+	if err != nil {
+		return false, err
+	}
+
+	if blobURL.Scheme == "azblob" {
+		container, blob, err := parseURL(uri)
+		if err != nil {
+			return false, err
+		}
+
+		blobURL, err = c.blobURL("", container, blob)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodHead, blobURL.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("x-ms-date", c.now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", apiVersion)
+
+	if err := c.authorize(req); err != nil {
+		return false, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, responseError(resp)
+	}
+}
+
+// verifyCredentials returns an error if the account name or the
+// credentials are missing
+func (c *Client) verifyCredentials() error {
+	if len(c.account) == 0 {
+		return errors.New("storage account name is not set")
+	}
+
+	if len(c.key) == 0 && len(c.sasToken) == 0 {
+		return errors.New("neither a storage account key nor a SAS token is set")
+	}
+
+	return nil
+}
+
+// authorize signs req with the account key, if no key is set the SAS token
+// is added to the request URL instead
+func (c *Client) authorize(req *http.Request) error {
+	if len(c.key) != 0 {
+		return c.sign(req)
+	}
+
+	req.URL.RawQuery = c.sasToken
+
+	return nil
+}
+
+func (c *Client) blobURL(accountURL, container, blob string) (*url.URL, error) {
+	if len(accountURL) == 0 {
+		accountURL = "https://" + c.account + ".blob.core.windows.net"
+	}
+
+	u, err := url.Parse(accountURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing account URL '%s' failed", accountURL)
+	}
+
+	u.Path = path.Join("/", u.Path, container, blob)
+
+	return u, nil
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	code := resp.Header.Get("x-ms-error-code")
+	if len(code) != 0 {
+		return fmt.Errorf("server responded with status %q, error code: %s", resp.Status, code)
+	}
+
+	return fmt.Errorf("server responded with status %q: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// sign adds the Authorization header with a Shared Key signature to the
+// request,
+// see https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (c *Client) sign(req *http.Request) error {
+	key, err := base64.StdEncoding.DecodeString(c.key)
+	if err != nil {
+		return errors.Wrap(err, "decoding storage account key failed")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(stringToSign(req, c.account)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Authorization", "SharedKey "+c.account+":"+signature)
+
+	return nil
+}
+
+// stringToSign returns the string that is signed for a Shared Key
+// authorization of req
+func stringToSign(req *http.Request, account string) string {
+	var contentLength string
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var msHeaders []string
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-ms-") {
+			msHeaders = append(msHeaders, k+":"+strings.Join(v, ","))
+		}
+	}
+	sort.Strings(msHeaders)
+
+	resource := "/" + account + req.URL.EscapedPath()
+
+	var queryParams []string
+	for k, v := range req.URL.Query() {
+		sort.Strings(v)
+		queryParams = append(queryParams, strings.ToLower(k)+":"+strings.Join(v, ","))
+	}
+	sort.Strings(queryParams)
+
+	for _, p := range queryParams {
+		resource += "\n" + p
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		req.Header.Get("Date"),
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}, "\n") + "\n" + strings.Join(msHeaders, "\n") + "\n" + resource
+}
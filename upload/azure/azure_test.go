@@ -0,0 +1,305 @@
+package azure
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAccount = "baurtest"
+	// testKey is the base64 encoded string "secret"
+	testKey     = "c2VjcmV0"
+	testContent = "artifact"
+)
+
+type testLogger struct{}
+
+func (*testLogger) Debugf(format string, v ...interface{}) {}
+
+func createTempFile(t *testing.T) (string, func()) {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "baur-azure-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.WriteString(testContent); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name(), func() { os.Remove(f.Name()) }
+}
+
+func TestStringToSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "https://baurtest.blob.core.windows.net/artifacts/app/app%201.tar.xz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.ContentLength = 8
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("x-ms-date", "Mon, 02 Jan 2006 15:04:05 GMT")
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	expected := "PUT\n\n\n8\n\n\n\n\n\n\n\n\n" +
+		"x-ms-blob-type:BlockBlob\n" +
+		"x-ms-date:Mon, 02 Jan 2006 15:04:05 GMT\n" +
+		"x-ms-version:2019-12-12\n" +
+		"/baurtest/artifacts/app/app%201.tar.xz"
+
+	if s := stringToSign(req, testAccount); s != expected {
+		t.Errorf("stringToSign returned:\n%q\nexpected:\n%q", s, expected)
+	}
+}
+
+func TestUploadWithSharedKey(t *testing.T) {
+	var authHeader, expectedAuthHeader, body, path string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		path = r.URL.Path
+
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+
+		// the request is signed again on the server side, the
+		// Authorization header must match
+		r.Header.Del("Authorization")
+		clt := NewClient(&testLogger{}, testAccount, testKey, "")
+		if err := clt.sign(r); err != nil {
+			t.Error(err)
+		}
+		expectedAuthHeader = r.Header.Get("Authorization")
+
+		if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	clt := NewClient(&testLogger{}, testAccount, testKey, "")
+	clt.now = func() time.Time { return time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC) }
+
+	url, err := clt.UploadWithOptions(file, "azblob://artifacts/app/app.tar.xz", UploadOptions{AccountURL: srv.URL})
+	if err != nil {
+		t.Fatal("upload failed:", err)
+	}
+
+	if url != srv.URL+"/artifacts/app/app.tar.xz" {
+		t.Errorf("upload returned url %q, expected %q", url, srv.URL+"/artifacts/app/app.tar.xz")
+	}
+
+	if path != "/artifacts/app/app.tar.xz" {
+		t.Errorf("request was sent to path %q", path)
+	}
+
+	if body != testContent {
+		t.Errorf("uploaded content is %q, expected %q", body, testContent)
+	}
+
+	if !strings.HasPrefix(authHeader, "SharedKey "+testAccount+":") || authHeader != expectedAuthHeader {
+		t.Errorf("Authorization header is %q, expected %q", authHeader, expectedAuthHeader)
+	}
+}
+
+func TestUploadWithSASToken(t *testing.T) {
+	var query, authHeader string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		authHeader = r.Header.Get("Authorization")
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	clt := NewClient(&testLogger{}, testAccount, "", "?sv=2019-12-12&sig=abc")
+
+	url, err := clt.UploadWithOptions(file, "azblob://artifacts/app.tar.xz", UploadOptions{AccountURL: srv.URL})
+	if err != nil {
+		t.Fatal("upload failed:", err)
+	}
+
+	if strings.Contains(url, "sig=") {
+		t.Errorf("returned url %q contains the SAS token", url)
+	}
+
+	if query != "sv=2019-12-12&sig=abc" {
+		t.Errorf("request query is %q, expected the SAS token", query)
+	}
+
+	if authHeader != "" {
+		t.Errorf("request has an Authorization header %q, expected none", authHeader)
+	}
+}
+
+func TestUploadEmptyFile(t *testing.T) {
+	var transferEncoding []string
+	var contentLength int64
+	var contentLengthHeader string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transferEncoding = r.TransferEncoding
+		contentLength = r.ContentLength
+		contentLengthHeader = r.Header.Get("Content-Length")
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "baur-azure-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	clt := NewClient(&testLogger{}, testAccount, testKey, "")
+
+	_, err = clt.UploadWithOptions(f.Name(), "azblob://artifacts/empty", UploadOptions{AccountURL: srv.URL})
+	if err != nil {
+		t.Fatal("upload failed:", err)
+	}
+
+	if len(transferEncoding) != 0 {
+		t.Errorf("request was sent with Transfer-Encoding %v, expected none", transferEncoding)
+	}
+
+	if contentLength != 0 || contentLengthHeader != "0" {
+		t.Errorf("request has content length %d and Content-Length header %q, expected 0", contentLength, contentLengthHeader)
+	}
+}
+
+func TestUploadFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-error-code", "AuthenticationFailed")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	testcases := []struct {
+		name string
+		clt  *Client
+		dest string
+	}{
+		{
+			name: "forbidden",
+			clt:  NewClient(&testLogger{}, testAccount, testKey, ""),
+			dest: "azblob://artifacts/app.tar.xz",
+		},
+		{
+			name: "nocredentials",
+			clt:  NewClient(&testLogger{}, testAccount, "", ""),
+			dest: "azblob://artifacts/app.tar.xz",
+		},
+		{
+			name: "invalidkey",
+			clt:  NewClient(&testLogger{}, testAccount, "not base64!", ""),
+			dest: "azblob://artifacts/app.tar.xz",
+		},
+		{
+			name: "noblob",
+			clt:  NewClient(&testLogger{}, testAccount, testKey, ""),
+			dest: "azblob://artifacts/",
+		},
+		{
+			name: "wrongscheme",
+			clt:  NewClient(&testLogger{}, testAccount, testKey, ""),
+			dest: "s3://artifacts/app.tar.xz",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.clt.UploadWithOptions(file, tc.dest, UploadOptions{AccountURL: srv.URL})
+			if err == nil {
+				t.Error("upload succeeded, expected an error")
+			}
+		})
+	}
+}
+
+func TestExists(t *testing.T) {
+	var method, authHeader string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		authHeader = r.Header.Get("Authorization")
+
+		switch r.URL.Path {
+		case "/artifacts/exists.tar.xz":
+			w.WriteHeader(http.StatusOK)
+		case "/artifacts/missing.tar.xz":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	clt := NewClient(&testLogger{}, testAccount, testKey, "")
+
+	testcases := []struct {
+		path        string
+		expected    bool
+		expectError bool
+	}{
+		{path: "/artifacts/exists.tar.xz", expected: true},
+		{path: "/artifacts/missing.tar.xz", expected: false},
+		{path: "/other/forbidden.tar.xz", expectError: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			exists, err := clt.Exists(srv.URL + tc.path)
+			if tc.expectError {
+				if err == nil {
+					t.Fatal("Exists succeeded, expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal("Exists failed:", err)
+			}
+
+			if exists != tc.expected {
+				t.Errorf("Exists returned %t, expected %t", exists, tc.expected)
+			}
+
+			if method != http.MethodHead {
+				t.Errorf("request method was %s, expected %s", method, http.MethodHead)
+			}
+
+			if !strings.HasPrefix(authHeader, "SharedKey "+testAccount+":") {
+				t.Errorf("request was not signed, Authorization header is %q", authHeader)
+			}
+		})
+	}
+}
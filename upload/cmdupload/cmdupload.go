@@ -0,0 +1,84 @@
+// Package cmdupload uploads files by running user-defined commands
+package cmdupload
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvVarOutputPath is the environment variable that contains the path
+	// of the uploaded file
+	EnvVarOutputPath = "BAUR_OUTPUT_PATH"
+	// EnvVarAppName is the environment variable that contains the name of
+	// the application the file belongs to
+	EnvVarAppName = "BAUR_APPNAME"
+	// EnvVarGitCommit is the environment variable that contains the Git
+	// commit ID of the repository
+	EnvVarGitCommit = "BAUR_GITCOMMIT"
+)
+
+var defLogFn = func(string, ...interface{}) {}
+
+// Client uploads files by running commands
+type Client struct {
+	debugLogFn func(string, ...interface{})
+}
+
+// New returns a client
+func New(debugLogFn func(string, ...interface{})) *Client {
+	logFn := defLogFn
+	if debugLogFn != nil {
+		logFn = debugLogFn
+	}
+
+	return &Client{debugLogFn: logFn}
+}
+
+// UploadOptions configures how the upload command is run
+type UploadOptions struct {
+	// Dir is the directory the command is run in, if it is empty the
+	// current working directory is used
+	Dir string
+	// Env contains additional environment variables in the format
+	// KEY=VALUE that are set when the command is run
+	Env []string
+}
+
+// Upload runs command in a shell to upload the file at filePath.
+// The path is passed as first argument to the command and is set in the
+// BAUR_OUTPUT_PATH environment variable.
+// The output of the command to stdout, without leading and trailing
+// whitespace, is returned as the URI of the uploaded file. If the command
+// exits with a code != 0 or prints nothing to stdout, an error is returned.
+func (c *Client) Upload(filePath, command string, opts UploadOptions) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command, "sh", filePath)
+	cmd.Dir = opts.Dir
+	cmd.Env = append(os.Environ(), opts.Env...)
+	cmd.Env = append(cmd.Env, EnvVarOutputPath+"="+filePath)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	c.debugLogFn("command: running %q in directory %q to upload %s", command, opts.Dir, filePath)
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "running %q failed, output: %q", command, strings.TrimSpace(stderr.String()))
+	}
+
+	if stderr.Len() > 0 {
+		c.debugLogFn("command: %q printed to stderr: %s", command, stderr.String())
+	}
+
+	uri := strings.TrimSpace(stdout.String())
+	if len(uri) == 0 {
+		return "", errors.Errorf("%q printed no URI to stdout", command)
+	}
+
+	return uri, nil
+}
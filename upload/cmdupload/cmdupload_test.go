@@ -0,0 +1,58 @@
+package cmdupload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadReturnsStdout(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "baur-command-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	filePath := filepath.Join(tempDir, "app.tar")
+
+	clt := New(t.Logf)
+
+	uri, err := clt.Upload(filePath,
+		`echo "store://$BAUR_APPNAME/$1" && test "$1" = "$BAUR_OUTPUT_PATH" && test "$(pwd)" = "$EXPECTED_DIR" && echo warning >&2`,
+		UploadOptions{
+			Dir: tempDir,
+			Env: []string{EnvVarAppName + "=calc", "EXPECTED_DIR=" + tempDir},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "store://calc/" + filePath
+	if uri != expected {
+		t.Errorf("Upload returned %q, expected %q", uri, expected)
+	}
+}
+
+func TestUploadFailsOnNonZeroExitCode(t *testing.T) {
+	clt := New(t.Logf)
+
+	_, err := clt.Upload("app.tar", "echo store://app.tar; echo no permission >&2; exit 3", UploadOptions{})
+	if err == nil {
+		t.Fatal("Upload succeeded for a command that exited with code 3")
+	}
+
+	if !strings.Contains(err.Error(), "no permission") {
+		t.Errorf("error %q does not contain the stderr output of the command", err)
+	}
+}
+
+func TestUploadFailsWhenNoURIIsPrinted(t *testing.T) {
+	clt := New(t.Logf)
+
+	if _, err := clt.Upload("app.tar", "true", UploadOptions{}); err == nil {
+		t.Fatal("Upload succeeded for a command that printed nothing")
+	}
+}
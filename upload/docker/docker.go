@@ -5,8 +5,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	docker "github.com/fsouza/go-dockerclient"
@@ -146,45 +149,96 @@ func (c *Client) getAuth(server string) docker.AuthConfiguration {
 }
 
 // parseRepositoryURI splits a URI in the format:
-// [<host[:port]>]/<owner>/<repository>:<tag> into it's parts
+// [<host[:port]>/]<path-component>[/<path-component>...]:<tag> into it's
+// parts.
+// Like in docker, the first component is only treated as host if it
+// contains a "." or ":" or is "localhost".
 func parseRepositoryURI(dest string) (server, repository, tag string, err error) {
-	spl := strings.Split(dest, "/")
-	if len(spl) == 3 {
-		server = spl[0]
-		repository = spl[1] + "/" + spl[2]
-	} else if len(spl) == 2 {
-		repository = spl[0] + "/" + spl[1]
-	} else {
-		return "", "", "", errors.New("invalid repository URI")
+	tagSepIdx := strings.LastIndex(dest, ":")
+	if tagSepIdx == -1 || tagSepIdx < strings.LastIndex(dest, "/") {
+		return "", "", "", errors.New("parsing tag failed")
 	}
 
-	spl = strings.Split(repository, ":")
-	// can contain up to 2 colons, one for the port in the server address,
-	// one for the tag
-	if len(spl) < 2 {
-		return "", "", "", errors.New("parsing tag failed")
+	repository = dest[:tagSepIdx]
+	tag = dest[tagSepIdx+1:]
+
+	spl := strings.SplitN(repository, "/", 2)
+	if len(spl) == 2 && (strings.ContainsAny(spl[0], ".:") || spl[0] == "localhost") {
+		server = spl[0]
+		repository = spl[1]
 	}
 
-	tag = spl[len(spl)-1]
-	repository = spl[len(spl)-2]
+	if repository == "" || tag == "" {
+		return "", "", "", errors.New("invalid repository URI")
+	}
 
 	return
 }
 
 // Upload tags and uploads an image into a docker registry repository
-// destURI format: [<server[:port]>/]<owner>/<repository>:<tag>
+// destURI format: [<server[:port]>/]<path-component>[/<path-component>...]:<tag>
 func (c *Client) Upload(image, destURI string) (string, error) {
+	uri, _, err := c.UploadWithDigest(image, destURI)
+	return uri, err
+}
+
+// UploadWithDigest uploads an image like Upload() and additionally returns
+// the digest of the image manifest in the registry (sha256:<hex>).
+// If the digest can not be determined from the output of the push operation,
+// an empty string is returned as digest.
+func (c *Client) UploadWithDigest(image, destURI string) (string, string, error) {
 	server, repository, tag, err := parseRepositoryURI(destURI)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	res, err := c.tagAndPush(image, server, repository, tag)
+	if err != nil {
+		return "", "", err
+	}
+
+	return destURI, res.digest, nil
+}
+
+// Exists returns true if the image that uri refers to exists in the docker
+// registry. The existence is checked by requesting the manifest of the tag.
+// uri format: [<server[:port]>/]<path-component>[/<path-component>...]:<tag>
+func (c *Client) Exists(uri string) (bool, error) {
+	server, repository, tag, err := parseRepositoryURI(uri)
+	if err != nil {
+		return false, err
+	}
+
+	reg := registryClient{
+		url:        registryURL(server),
+		auth:       c.getAuth(server),
+		httpClient: &http.Client{Timeout: registryTimeout},
 	}
 
-	err = c.clt.TagImage(image, docker.TagImageOptions{
+	c.debugLogFn("docker: checking if manifest %s:%s exists in %s", repository, tag, reg.url)
+
+	exists, err := reg.manifestExists(repository, tag)
+	if err != nil {
+		return false, errors.Wrapf(err, "checking if %s exists failed", uri)
+	}
+
+	return exists, nil
+}
+
+// pushResult contains the information about the pushed manifest that is
+// parsed from the output of a push operation
+type pushResult struct {
+	digest string
+	size   int64
+}
+
+func (c *Client) tagAndPush(image, server, repository, tag string) (*pushResult, error) {
+	err := c.clt.TagImage(image, docker.TagImageOptions{
 		Repo: repository,
 		Tag:  tag,
 	})
 	if err != nil {
-		return "", errors.Wrapf(err, "tagging image failed")
+		return nil, errors.Wrapf(err, "tagging image failed")
 	}
 
 	auth := c.getAuth(server)
@@ -198,6 +252,8 @@ func (c *Client) Upload(image, destURI string) (string, error) {
 		OutputStream: outStream,
 	}, auth)
 
+	var res pushResult
+
 	for {
 		outStream.Flush()
 		line, err := outBuf.ReadString('\n')
@@ -206,13 +262,38 @@ func (c *Client) Upload(image, destURI string) (string, error) {
 		}
 
 		c.debugLogFn("docker: " + line)
+
+		if r := parsePushResult(line); r != nil {
+			res = *r
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// pushResultRe matches the line of the push output that contains the
+// manifest digest and size, e.g.: "latest: digest: sha256:<hex> size: 528"
+var pushResultRe = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64}) size: ([0-9]+)`)
+
+// parsePushResult returns the manifest digest and size from a line of the
+// output of a push operation, if the line does not contain it nil is
+// returned
+func parsePushResult(line string) *pushResult {
+	m := pushResultRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil
 	}
 
+	size, err := strconv.ParseInt(m[2], 10, 64)
 	if err != nil {
-		return "", err
+		return nil
 	}
 
-	return destURI, nil
+	return &pushResult{digest: m[1], size: size}
 }
 
 // Size returns the size of an image in Bytes
@@ -1,7 +1,14 @@
 package docker
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
 )
 
 func Test_parseRepositoryURI(t *testing.T) {
@@ -37,6 +44,36 @@ func Test_parseRepositoryURI(t *testing.T) {
 			wantTag:        "latest",
 		},
 
+		{
+			name:           "nestedPath",
+			destArg:        "gcr.io/project/team/app:1.0",
+			wantServer:     "gcr.io",
+			wantRepository: "project/team/app",
+			wantTag:        "1.0",
+		},
+
+		{
+			name:           "serverWithPortSingleComponent",
+			destArg:        "localhost:5000/app:latest",
+			wantServer:     "localhost:5000",
+			wantRepository: "app",
+			wantTag:        "latest",
+		},
+
+		{
+			name:           "noServerNestedPath",
+			destArg:        "group/sub/app:latest",
+			wantServer:     "",
+			wantRepository: "group/sub/app",
+			wantTag:        "latest",
+		},
+
+		{
+			name:    "missingTag",
+			destArg: "localhost:5000/app",
+			wantErr: true,
+		},
+
 		{
 			name:    "emptyArg",
 			wantErr: true,
@@ -62,3 +99,102 @@ func Test_parseRepositoryURI(t *testing.T) {
 		})
 	}
 }
+
+func Test_parsePushResult(t *testing.T) {
+	const digest = "sha256:c3a4ff2f6a3e5d8a8f3bb7c5c9e1d0f4a2b6e8d1c7f9a0b3e5d2c4f6a8b0e1d3"
+
+	tests := []struct {
+		line string
+		want *pushResult
+	}{
+		{line: "latest: digest: " + digest + " size: 528\n", want: &pushResult{digest: digest, size: 528}},
+		{line: "The push refers to repository [docker.io/simplesurance/calculator]\n", want: nil},
+		{line: "a1b2c3d4e5f6: Pushed\n", want: nil},
+		{line: "latest: digest: sha256:1234 size: 528\n", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := parsePushResult(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePushResult() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExists(t *testing.T) {
+	const token = "abc"
+
+	var srv *httptest.Server
+	var receivedAccept string
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			fmt.Fprintf(w, `{"token": %q}`, token)
+			return
+
+		case "/v2/simplesurance/calculator/manifests/latest",
+			"/v2/simplesurance/calculator/manifests/missing":
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="registry",scope="repository:simplesurance/calculator:pull"`,
+				srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		receivedAccept = strings.Join(r.Header["Accept"], ",")
+
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	server := strings.TrimPrefix(srv.URL, "http://")
+	clt := Client{
+		auth:       &docker.AuthConfiguration{Username: "user", Password: "pass"},
+		debugLogFn: defLogFn,
+	}
+
+	exists, err := clt.Exists(server + "/simplesurance/calculator:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists {
+		t.Error("Exists returned false for an existing image")
+	}
+
+	if !strings.Contains(receivedAccept, mediaTypeManifestList) || !strings.Contains(receivedAccept, mediaTypeManifest) {
+		t.Errorf("manifest was requested with accept header %q, expected it to contain the manifest and manifest list media types", receivedAccept)
+	}
+
+	exists, err = clt.Exists(server + "/simplesurance/calculator:missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exists {
+		t.Error("Exists returned true for a missing image")
+	}
+
+	_, err = clt.Exists(server + "/simplesurance/unknown:latest")
+	if err != nil {
+		t.Errorf("Exists returned an error for an unknown repository: %s", err)
+	}
+}
@@ -0,0 +1,354 @@
+package docker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+)
+
+const (
+	mediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+)
+
+// dockerHubRegistryURL is the URL of the registry API of Docker Hub, it's
+// used when the repository URI does not contain a server
+const dockerHubRegistryURL = "https://registry-1.docker.io"
+
+// registryTimeout is the max. duration of a request to the registry API
+const registryTimeout = 5 * time.Minute
+
+// ManifestImage is an image that is referenced in a manifest list
+type ManifestImage struct {
+	ImageID string
+	// Platform of the image in the format <os>/<arch>[/<variant>]
+	Platform string
+}
+
+type manifestList struct {
+	SchemaVersion int                   `json:"schemaVersion"`
+	MediaType     string                `json:"mediaType"`
+	Manifests     []*manifestDescriptor `json:"manifests"`
+}
+
+type manifestDescriptor struct {
+	MediaType string   `json:"mediaType"`
+	Size      int64    `json:"size"`
+	Digest    string   `json:"digest"`
+	Platform  platform `json:"platform"`
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// parsePlatform parses a platform string in the format
+// <os>/<arch>[/<variant>]
+func parsePlatform(s string) (*platform, error) {
+	spl := strings.Split(s, "/")
+	if len(spl) < 2 || len(spl) > 3 {
+		return nil, fmt.Errorf("invalid platform '%s', must be in the format <os>/<arch>[/<variant>]", s)
+	}
+
+	for _, e := range spl {
+		if len(e) == 0 {
+			return nil, fmt.Errorf("invalid platform '%s', must be in the format <os>/<arch>[/<variant>]", s)
+		}
+	}
+
+	p := platform{OS: spl[0], Architecture: spl[1]}
+	if len(spl) == 3 {
+		p.Variant = spl[2]
+	}
+
+	return &p, nil
+}
+
+// UploadManifestList uploads images for multiple platforms and a manifest
+// list that references them into a docker registry repository.
+// Each image is tagged and pushed with the tag <tag>-<os>-<arch>[-<variant>],
+// the manifest list is tagged with the tag from destURI.
+// destURI format: [<server[:port]>/]<path-component>[/<path-component>...]:<tag>
+// It returns destURI and the digest of the manifest list (sha256:<hex>).
+func (c *Client) UploadManifestList(images []*ManifestImage, destURI string) (string, string, error) {
+	server, repository, tag, err := parseRepositoryURI(destURI)
+	if err != nil {
+		return "", "", err
+	}
+
+	list := manifestList{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeManifestList,
+	}
+
+	for _, img := range images {
+		p, err := parsePlatform(img.Platform)
+		if err != nil {
+			return "", "", err
+		}
+
+		platformTag := tag + "-" + strings.Replace(img.Platform, "/", "-", -1)
+
+		res, err := c.tagAndPush(img.ImageID, server, repository, platformTag)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "pushing image for platform %s failed", img.Platform)
+		}
+
+		if len(res.digest) == 0 {
+			return "", "", fmt.Errorf("could not determine the manifest digest of the pushed image for platform %s", img.Platform)
+		}
+
+		list.Manifests = append(list.Manifests, &manifestDescriptor{
+			MediaType: mediaTypeManifest,
+			Size:      res.size,
+			Digest:    res.digest,
+			Platform:  *p,
+		})
+	}
+
+	manifest, err := json.Marshal(&list)
+	if err != nil {
+		return "", "", errors.Wrap(err, "encoding manifest list failed")
+	}
+
+	reg := registryClient{
+		url:        registryURL(server),
+		auth:       c.getAuth(server),
+		httpClient: &http.Client{Timeout: registryTimeout},
+	}
+
+	c.debugLogFn("docker: pushing manifest list %s:%s to %s", repository, tag, reg.url)
+
+	digest, err := reg.putManifest(repository, tag, mediaTypeManifestList, manifest)
+	if err != nil {
+		return "", "", errors.Wrap(err, "pushing manifest list failed")
+	}
+
+	return destURI, digest, nil
+}
+
+// registryURL returns the URL of the registry API of server. Registries on
+// the loopback interface are accessed via HTTP, like the docker daemon does.
+func registryURL(server string) string {
+	if len(server) == 0 {
+		return dockerHubRegistryURL
+	}
+
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+
+	if host == "localhost" {
+		return "http://" + server
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "http://" + server
+	}
+
+	return "https://" + server
+}
+
+// registryClient is a minimal client for the docker registry HTTP API V2,
+// see https://docs.docker.com/registry/spec/api/
+type registryClient struct {
+	url        string
+	auth       docker.AuthConfiguration
+	httpClient *http.Client
+}
+
+// putManifest uploads a manifest and returns it's digest
+func (r *registryClient) putManifest(repository, reference, mediaType string, manifest []byte) (string, error) {
+	u := r.url + "/v2/" + repository + "/manifests/" + reference
+
+	resp, err := r.do(http.MethodPut, u, http.Header{"Content-Type": {mediaType}}, manifest)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", responseError(resp)
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) != 0 {
+		return digest, nil
+	}
+
+	sum := sha256.Sum256(manifest)
+
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// manifestExists returns true if a manifest or manifest list with the
+// reference exists in the repository
+func (r *registryClient) manifestExists(repository, reference string) (bool, error) {
+	u := r.url + "/v2/" + repository + "/manifests/" + reference
+
+	header := http.Header{
+		"Accept": {mediaTypeManifest, mediaTypeManifestList, mediaTypeOCIManifest, mediaTypeOCIIndex},
+	}
+
+	resp, err := r.do(http.MethodHead, u, header, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, responseError(resp)
+	}
+}
+
+// do sends a request to the registry. If the registry responds with a
+// Bearer authentication challenge, a token is requested from the
+// authorization server and the request is sent again with it.
+func (r *registryClient) do(method, u string, header http.Header, body []byte) (*http.Response, error) {
+	newReq := func() (*http.Request, error) {
+		req, err := http.NewRequest(method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		return req, nil
+	}
+
+	req, err := newReq()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.auth.Username) != 0 {
+		req.SetBasicAuth(r.auth.Username, r.auth.Password)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+
+	token, err := r.fetchToken(challenge)
+	if err != nil {
+		return nil, errors.Wrap(err, "requesting registry authorization token failed")
+	}
+
+	req, err = newReq()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return r.httpClient.Do(req)
+}
+
+var challengeParamRe = regexp.MustCompile(`([a-zA-Z]+)="([^"]*)"`)
+
+// fetchToken requests a token from the authorization server that is
+// specified in the Bearer challenge,
+// see https://docs.docker.com/registry/spec/auth/token/
+func (r *registryClient) fetchToken(challenge string) (string, error) {
+	params := map[string]string{}
+	for _, m := range challengeParamRe.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+
+	realm := params["realm"]
+	if len(realm) == 0 {
+		return "", fmt.Errorf("realm missing in authentication challenge '%s'", challenge)
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing realm '%s' failed", realm)
+	}
+
+	q := u.Query()
+	if service, exist := params["service"]; exist {
+		q.Set("service", service)
+	}
+
+	if scope, exist := params["scope"]; exist {
+		q.Set("scope", scope)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if len(r.auth.Username) != 0 {
+		req.SetBasicAuth(r.auth.Username, r.auth.Password)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp)
+	}
+
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", errors.Wrap(err, "decoding token response failed")
+	}
+
+	if len(tokenResp.Token) != 0 {
+		return tokenResp.Token, nil
+	}
+
+	if len(tokenResp.AccessToken) != 0 {
+		return tokenResp.AccessToken, nil
+	}
+
+	return "", errors.New("token response does not contain a token")
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	return fmt.Errorf("%s %s: server responded with status %q: %s",
+		resp.Request.Method, resp.Request.URL, resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,138 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+func Test_parsePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     *platform
+		wantErr  bool
+	}{
+		{platform: "linux/amd64", want: &platform{OS: "linux", Architecture: "amd64"}},
+		{platform: "linux/arm/v7", want: &platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{platform: "linux", wantErr: true},
+		{platform: "linux/", wantErr: true},
+		{platform: "linux/arm/v7/x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			got, err := parsePlatform(tt.platform)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePlatform() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_registryURL(t *testing.T) {
+	tests := []struct {
+		server string
+		want   string
+	}{
+		{server: "", want: dockerHubRegistryURL},
+		{server: "registry.example.com", want: "https://registry.example.com"},
+		{server: "registry.example.com:5000", want: "https://registry.example.com:5000"},
+		{server: "localhost:5000", want: "http://localhost:5000"},
+		{server: "127.0.0.1:5000", want: "http://127.0.0.1:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			if got := registryURL(tt.server); got != tt.want {
+				t.Errorf("registryURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutManifestWithBearerAuth(t *testing.T) {
+	const token = "abc"
+
+	manifest := []byte(`{"schemaVersion":2}`)
+	sum := sha256.Sum256(manifest)
+	expectedDigest := "sha256:" + hex.EncodeToString(sum[:])
+
+	var (
+		srv             *httptest.Server
+		receivedScope   string
+		receivedBody    []byte
+		receivedCType   string
+		receivedBasicOK bool
+	)
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			receivedScope = r.URL.Query().Get("scope")
+			user, pass, ok := r.BasicAuth()
+			receivedBasicOK = ok && user == "user" && pass == "pass"
+
+			fmt.Fprintf(w, `{"token": %q}`, token)
+
+		case "/v2/simplesurance/calculator/manifests/latest":
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`Bearer realm="%s/token",service="registry",scope="repository:simplesurance/calculator:pull,push"`,
+					srv.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			receivedCType = r.Header.Get("Content-Type")
+			receivedBody, _ = ioutil.ReadAll(r.Body)
+
+			w.WriteHeader(http.StatusCreated)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	reg := registryClient{
+		url:        srv.URL,
+		auth:       docker.AuthConfiguration{Username: "user", Password: "pass"},
+		httpClient: http.DefaultClient,
+	}
+
+	digest, err := reg.putManifest("simplesurance/calculator", "latest", mediaTypeManifestList, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest != expectedDigest {
+		t.Errorf("putManifest returned digest %q, expected %q", digest, expectedDigest)
+	}
+
+	if receivedScope != "repository:simplesurance/calculator:pull,push" {
+		t.Errorf("token was requested with scope %q", receivedScope)
+	}
+
+	if !receivedBasicOK {
+		t.Error("token was not requested with the basic auth credentials")
+	}
+
+	if receivedCType != mediaTypeManifestList {
+		t.Errorf("manifest was uploaded with content-type %q, expected %q", receivedCType, mediaTypeManifestList)
+	}
+
+	if string(receivedBody) != string(manifest) {
+		t.Errorf("uploaded manifest is %q, expected %q", receivedBody, manifest)
+	}
+}
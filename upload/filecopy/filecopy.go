@@ -27,7 +27,25 @@ func New(debugLogFn func(string, ...interface{})) *Client {
 	return &Client{debugLogFn: logFn}
 }
 
-func copyFile(src, dst string) error {
+// Options specifies how files are copied and which attributes of the source
+// file are applied to the copy
+type Options struct {
+	// Atomic writes the copy to a temporary file in the destination
+	// directory and renames it to the destination path afterwards.
+	// Readers of the destination file never see a partially written
+	// file.
+	Atomic bool
+	// PreserveMode sets the permissions of the copy to the ones of the
+	// source file. If it is false, the permissions of newly created files
+	// are the ones of the source file with the umask applied, the
+	// permissions of existing files are not changed.
+	PreserveMode bool
+	// PreserveTimestamps sets the access and modification time of the copy
+	// to the modification time of the source file.
+	PreserveTimestamps bool
+}
+
+func copyFile(src, dst string, opts Options) error {
 	srcFd, err := os.Open(src)
 	if err != nil {
 		return errors.Wrapf(err, "opening %s failed", src)
@@ -43,6 +61,10 @@ func copyFile(src, dst string) error {
 
 	srcFileMode := srcFi.Mode().Perm()
 
+	if opts.Atomic {
+		return copyFileAtomic(srcFd, srcFi, dst, opts)
+	}
+
 	dstFd, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, srcFileMode)
 	if err != nil {
 		return errors.Wrapf(err, "opening %s failed", dst)
@@ -55,14 +77,68 @@ func copyFile(src, dst string) error {
 		return err
 	}
 
-	return dstFd.Close()
+	if err := dstFd.Close(); err != nil {
+		return err
+	}
+
+	return applyAttributes(srcFi, dst, opts)
 }
 
-// Upload copies the file with src path to the dst path.
+// copyFileAtomic copies the content of srcFd to dst with
+// fs.WriteFileAtomic.
+// Like for non-atomic copies, an existing destination file keeps it's
+// permissions if PreserveMode is not enabled.
+func copyFileAtomic(srcFd *os.File, srcFi os.FileInfo, dst string, opts Options) error {
+	mode := srcFi.Mode().Perm()
+
+	if !opts.PreserveMode {
+		dstFi, err := os.Stat(dst)
+		if err == nil {
+			mode = dstFi.Mode().Perm()
+		} else if !os.IsNotExist(err) {
+			return errors.Wrapf(err, "stat %s failed", dst)
+		}
+	}
+
+	if err := fs.WriteFileAtomic(dst, srcFd, mode); err != nil {
+		return err
+	}
+
+	return applyAttributes(srcFi, dst, opts)
+}
+
+// applyAttributes applies the attributes of the source file to dst that are
+// enabled in opts
+func applyAttributes(srcFi os.FileInfo, dst string, opts Options) error {
+	if opts.PreserveMode {
+		if err := os.Chmod(dst, srcFi.Mode().Perm()); err != nil {
+			return errors.Wrapf(err, "changing permissions of %s failed", dst)
+		}
+	}
+
+	if opts.PreserveTimestamps {
+		mtime := srcFi.ModTime()
+
+		if err := os.Chtimes(dst, mtime, mtime); err != nil {
+			return errors.Wrapf(err, "changing timestamps of %s failed", dst)
+		}
+	}
+
+	return nil
+}
+
+// Upload copies the file with src path to the dst path atomically, other
+// attributes of the file are not preserved.
 // If the destination directory does not exist, it is created.
 // If the destination path exist and is not a regular file an error is returned.
 // If it exist and is a file, the file is overwritten if it's not the same.
 func (c *Client) Upload(src string, dst string) (string, error) {
+	return c.UploadWithOptions(src, dst, Options{Atomic: true})
+}
+
+// UploadWithOptions copies the file with src path to the dst path like
+// Upload() and applies the attributes of src to dst that are enabled in opts.
+func (c *Client) UploadWithOptions(src string, dst string, opts Options) (string, error) {
 	destDir := path.Dir(dst)
 
 	isDir, err := fs.IsDir(destDir)
@@ -89,7 +165,7 @@ func (c *Client) Upload(src string, dst string) (string, error) {
 			return "", err
 		}
 
-		return dst, copyFile(src, dst)
+		return dst, copyFile(src, dst, opts)
 	}
 
 	if !regFile {
@@ -103,10 +179,30 @@ func (c *Client) Upload(src string, dst string) (string, error) {
 
 	if sameFile {
 		c.debugLogFn("filecopy: '%s' already exist and is the same then '%s'", dst, src)
-		return dst, nil
+
+		srcFi, err := os.Stat(src)
+		if err != nil {
+			return "", errors.Wrapf(err, "stat %s failed", src)
+		}
+
+		return dst, applyAttributes(srcFi, dst, opts)
 	}
 
 	c.debugLogFn("filecopy: '%s' already exist, overwriting file", dst)
 
-	return dst, copyFile(src, dst)
+	return dst, copyFile(src, dst, opts)
+}
+
+// Exists returns true if uri is the path of an existing regular file.
+func (c *Client) Exists(uri string) (bool, error) {
+	regFile, err := fs.IsRegularFile(uri)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return regFile, nil
 }
@@ -0,0 +1,147 @@
+package filecopy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+func createSrcFile(t *testing.T, dir string) (string, time.Time) {
+	src := filepath.Join(dir, "src")
+	fstest.WriteToFile(t, []byte("artifact"), src)
+
+	if err := os.Chmod(src, 0751); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	return src, mtime
+}
+
+func TestUploadPreservesAttributes(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	src, mtime := createSrcFile(t, tempDir)
+	dst := filepath.Join(tempDir, "dst", "artifact")
+
+	_, err := New(nil).UploadWithOptions(src, dst, Options{PreserveMode: true, PreserveTimestamps: true})
+	if err != nil {
+		t.Fatal("copying file failed:", err)
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0751 {
+		t.Errorf("copy has permissions %s, expected %s", fi.Mode().Perm(), os.FileMode(0751))
+	}
+
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("copy has modification time %s, expected %s", fi.ModTime(), mtime)
+	}
+}
+
+func TestUploadPreservesAttributesOfExistingDst(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	src, mtime := createSrcFile(t, tempDir)
+	dst := filepath.Join(tempDir, "artifact")
+	fstest.WriteToFile(t, []byte("old"), dst)
+
+	if err := os.Chmod(dst, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(nil).UploadWithOptions(src, dst, Options{PreserveMode: true, PreserveTimestamps: true})
+	if err != nil {
+		t.Fatal("copying file failed:", err)
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0751 {
+		t.Errorf("copy has permissions %s, expected %s", fi.Mode().Perm(), os.FileMode(0751))
+	}
+
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("copy has modification time %s, expected %s", fi.ModTime(), mtime)
+	}
+}
+
+func TestUploadDoesNotPreserveAttributesByDefault(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	src, mtime := createSrcFile(t, tempDir)
+	dst := filepath.Join(tempDir, "artifact")
+	fstest.WriteToFile(t, []byte("old"), dst)
+
+	if err := os.Chmod(dst, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(nil).Upload(src, dst)
+	if err != nil {
+		t.Fatal("copying file failed:", err)
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("permissions of existing file changed to %s, expected %s", fi.Mode().Perm(), os.FileMode(0600))
+	}
+
+	if fi.ModTime().Equal(mtime) {
+		t.Error("modification time of the copy is the one of the source file")
+	}
+}
+
+func TestExists(t *testing.T) {
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	src, _ := createSrcFile(t, tempDir)
+	dst := filepath.Join(tempDir, "dst", "artifact")
+
+	clt := New(nil)
+
+	exists, err := clt.Exists(dst)
+	if err != nil {
+		t.Fatal("Exists failed:", err)
+	}
+
+	if exists {
+		t.Error("Exists returned true before the file was copied")
+	}
+
+	uri, err := clt.Upload(src, dst)
+	if err != nil {
+		t.Fatal("Upload failed:", err)
+	}
+
+	exists, err = clt.Exists(uri)
+	if err != nil {
+		t.Fatal("Exists failed:", err)
+	}
+
+	if !exists {
+		t.Error("Exists returned false for the copied file")
+	}
+}
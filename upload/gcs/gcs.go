@@ -0,0 +1,176 @@
+// Package gcs uploads files to Google Cloud Storage buckets.
+// The upload is done via the S3 compatible XML API of Google Cloud Storage,
+// authentication happens with HMAC keys,
+// see https://cloud.google.com/storage/docs/interoperability
+package gcs
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// Endpoint is the URL of the Google Cloud Storage XML API
+const Endpoint = "https://storage.googleapis.com"
+
+// DefaultRetries is the number of retries for a GCS upload until an error is
+// raised
+const DefaultRetries = 3
+
+// fileUploader is the interface of the s3manager.Uploader that is used by
+// the Client
+type fileUploader interface {
+	Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error)
+}
+
+// objectHeader is the interface of the S3 service client that is used to
+// check if objects exist
+type objectHeader interface {
+	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+}
+
+// Client is a Google Cloud Storage uploader client
+type Client struct {
+	uploader fileUploader
+	svc      objectHeader
+}
+
+// Logger defines the interface for a GCS logger
+type Logger interface {
+	Debugln(v ...interface{})
+	DebugEnabled() bool
+}
+
+// NewClient returns a new GCS Client that authenticates with the passed HMAC
+// key.
+func NewClient(logger Logger, accessKeyID, secret string) (*Client, error) {
+	loglvl := aws.LogLevel(aws.LogOff)
+	if logger.DebugEnabled() {
+		loglvl = aws.LogLevel(aws.LogDebug)
+	}
+
+	cfg := aws.Config{
+		Credentials: credentials.NewStaticCredentials(accessKeyID, secret, ""),
+		Endpoint:    aws.String(Endpoint),
+		// the region is ignored by GCS but required by the aws-sdk
+		Region:           aws.String("auto"),
+		Logger:           aws.LoggerFunc(logger.Debugln),
+		LogLevel:         loglvl,
+		MaxRetries:       aws.Int(DefaultRetries),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+
+	sess, err := session.NewSession(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		uploader: s3manager.NewUploader(sess),
+		svc:      s3.New(sess),
+	}, nil
+}
+
+func verifyURL(u *url.URL) error {
+	if u.Scheme != "gs" {
+		return fmt.Errorf("unsupported URL scheme '%s'", u.Scheme)
+	}
+
+	if len(u.Host) == 0 {
+		return fmt.Errorf("bucket missing in url '%s'", u)
+	}
+
+	if len(u.Path) == 0 {
+		return fmt.Errorf("filename missing in url '%s'", u)
+	}
+
+	return nil
+}
+
+// Upload uploads a file to a GCS bucket, dest must be an URL in the format
+// gs://<bucket>/<path>. On success it returns the URL to the file.
+func (c *Client) Upload(file string, dest string) (string, error) {
+	url, err := url.Parse(dest)
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifyURL(url); err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	res, err := c.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(url.Host),
+		Key:    aws.String(url.Path),
+		Body:   f,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return res.Location, err
+}
+
+// objectFromURL returns the bucket and key of the object that u refers to.
+// u must be in the format gs://<bucket>/<path> or a path-style URL as
+// returned by Upload: https://storage.googleapis.com/<bucket>/<path>
+func objectFromURL(u *url.URL) (bucket, key string, err error) {
+	if u.Scheme == "gs" {
+		if err := verifyURL(u); err != nil {
+			return "", "", err
+		}
+
+		return u.Host, u.Path, nil
+	}
+
+	spl := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if len(spl) != 2 || len(spl[0]) == 0 || len(spl[1]) == 0 {
+		return "", "", fmt.Errorf("bucket or filename missing in url '%s'", u)
+	}
+
+	return spl[0], spl[1], nil
+}
+
+// Exists returns true if the object that uri refers to exists.
+// uri must be an URL as returned by Upload or in the format
+// gs://<bucket>/<path>.
+func (c *Client) Exists(uri string) (bool, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false, err
+	}
+
+	bucket, key, err := objectFromURL(u)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = c.svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,276 @@
+package gcs
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// fakeUploader records the inputs it is called with and fails with err if
+// it is set
+type fakeUploader struct {
+	err    error
+	inputs []*s3manager.UploadInput
+}
+
+func (f *fakeUploader) Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	f.inputs = append(f.inputs, input)
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &s3manager.UploadOutput{
+		Location: Endpoint + "/" + *input.Bucket + *input.Key,
+	}, nil
+}
+
+// fakeHeader records the inputs it is called with and fails with err if it
+// is set
+type fakeHeader struct {
+	err    error
+	inputs []*s3.HeadObjectInput
+}
+
+func (f *fakeHeader) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	f.inputs = append(f.inputs, input)
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func createTempFile(t *testing.T) (string, func()) {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "baur-gcs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name(), func() { os.Remove(f.Name()) }
+}
+
+func TestVerifyURL(t *testing.T) {
+	testcases := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "gs://bucket/file"},
+		{url: "gs://bucket/dir/file.tar.xz"},
+
+		{url: "s3://bucket/file", wantErr: true},
+		{url: "https://storage.googleapis.com/bucket/file", wantErr: true},
+		{url: "gs:///file", wantErr: true},
+		{url: "gs://bucket", wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = verifyURL(u)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("verifyURL() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestObjectFromURL(t *testing.T) {
+	testcases := []struct {
+		url            string
+		expectedBucket string
+		expectedKey    string
+		wantErr        bool
+	}{
+		{
+			url:            "gs://bucket/dir/file.tar.xz",
+			expectedBucket: "bucket",
+			expectedKey:    "/dir/file.tar.xz",
+		},
+		{
+			url:            "https://storage.googleapis.com/bucket/dir/file.tar.xz",
+			expectedBucket: "bucket",
+			expectedKey:    "dir/file.tar.xz",
+		},
+
+		{url: "gs://bucket", wantErr: true},
+		{url: "https://storage.googleapis.com/bucket", wantErr: true},
+		{url: "https://storage.googleapis.com/bucket/", wantErr: true},
+		{url: "https://storage.googleapis.com/", wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bucket, key, err := objectFromURL(u)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("objectFromURL() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if bucket != tc.expectedBucket {
+				t.Errorf("bucket is %q, expected %q", bucket, tc.expectedBucket)
+			}
+
+			if key != tc.expectedKey {
+				t.Errorf("key is %q, expected %q", key, tc.expectedKey)
+			}
+		})
+	}
+}
+
+func TestUpload(t *testing.T) {
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	uploader := &fakeUploader{}
+	clt := Client{uploader: uploader}
+
+	url, err := clt.Upload(file, "gs://bucket/dir/file.tar.xz")
+	if err != nil {
+		t.Fatal("upload failed:", err)
+	}
+
+	expectedURL := "https://storage.googleapis.com/bucket/dir/file.tar.xz"
+	if url != expectedURL {
+		t.Errorf("upload returned url %q, expected %q", url, expectedURL)
+	}
+
+	if len(uploader.inputs) != 1 {
+		t.Fatalf("uploader was called %d times, expected 1", len(uploader.inputs))
+	}
+
+	if bucket := aws.StringValue(uploader.inputs[0].Bucket); bucket != "bucket" {
+		t.Errorf("file was uploaded to bucket %q, expected %q", bucket, "bucket")
+	}
+
+	if key := aws.StringValue(uploader.inputs[0].Key); key != "/dir/file.tar.xz" {
+		t.Errorf("file was uploaded with key %q, expected %q", key, "/dir/file.tar.xz")
+	}
+}
+
+func TestUploadFails(t *testing.T) {
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	testcases := []struct {
+		name              string
+		file              string
+		dest              string
+		uploaderErr       error
+		expectedCallCount int
+	}{
+		{
+			name:              "uploaderror",
+			file:              file,
+			dest:              "gs://bucket/file",
+			uploaderErr:       errors.New("error"),
+			expectedCallCount: 1,
+		},
+		{
+			name: "invalidscheme",
+			file: file,
+			dest: "s3://bucket/file",
+		},
+		{
+			name: "missingfilename",
+			file: file,
+			dest: "gs://bucket",
+		},
+		{
+			name: "nonexistingfile",
+			file: file + "-nonexisting",
+			dest: "gs://bucket/file",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			uploader := &fakeUploader{err: tc.uploaderErr}
+			clt := Client{uploader: uploader}
+
+			_, err := clt.Upload(tc.file, tc.dest)
+			if err == nil {
+				t.Fatal("upload succeeded, expected an error")
+			}
+
+			if len(uploader.inputs) != tc.expectedCallCount {
+				t.Errorf("uploader was called %d times, expected %d", len(uploader.inputs), tc.expectedCallCount)
+			}
+		})
+	}
+}
+
+func TestExists(t *testing.T) {
+	testcases := []struct {
+		name           string
+		uri            string
+		headErr        error
+		expectedExists bool
+		wantErr        bool
+	}{
+		{
+			name:           "exists",
+			uri:            "https://storage.googleapis.com/bucket/file",
+			expectedExists: true,
+		},
+		{
+			name:           "gsurl",
+			uri:            "gs://bucket/file",
+			expectedExists: true,
+		},
+		{
+			name:    "notfound",
+			uri:     "https://storage.googleapis.com/bucket/file",
+			headErr: awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), http.StatusNotFound, ""),
+		},
+		{
+			name:    "forbidden",
+			uri:     "https://storage.googleapis.com/bucket/file",
+			headErr: awserr.NewRequestFailure(awserr.New("Forbidden", "forbidden", nil), http.StatusForbidden, ""),
+			wantErr: true,
+		},
+		{
+			name:    "invalidurl",
+			uri:     "https://storage.googleapis.com/bucket",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			clt := Client{svc: &fakeHeader{err: tc.headErr}}
+
+			exists, err := clt.Exists(tc.uri)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Exists() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if exists != tc.expectedExists {
+				t.Errorf("Exists() returned %v, expected %v", exists, tc.expectedExists)
+			}
+		})
+	}
+}
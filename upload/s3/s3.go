@@ -2,18 +2,61 @@ package s3
 
 import (
 	"fmt"
+	"math/rand"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
 )
 
+// fileUploader is the interface of the s3manager.Uploader that is used by
+// the Client
+type fileUploader interface {
+	Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error)
+}
+
+// objectHeader is the interface of the S3 service client that is used to
+// check if objects exist
+type objectHeader interface {
+	HeadObject(input *awss3.HeadObjectInput) (*awss3.HeadObjectOutput, error)
+}
+
 // Client is a S3 uploader client
 type Client struct {
-	sess     *session.Session
-	uploader *s3manager.Uploader
+	logger Logger
+	sleep  func(time.Duration)
+
+	lock      sync.Mutex
+	uploaders map[Endpoint]fileUploader
+	headers   map[Endpoint]objectHeader
+}
+
+// Endpoint describes the S3 service that files are uploaded to.
+// The zero value is the AWS S3 service.
+type Endpoint struct {
+	// URL of an S3 compatible service, e.g. a MinIO server. If it is
+	// empty the AWS S3 endpoint for the configured region is used.
+	URL string
+	// ForcePathStyle enables path-style addressing
+	// (https://<endpoint>/<bucket>/<key>) instead of virtual-hosted-style
+	// addressing, it only applies when URL is set.
+	ForcePathStyle bool
+}
+
+// UploadOptions configure how a file is uploaded
+type UploadOptions struct {
+	Endpoint    Endpoint
+	RetryPolicy RetryPolicy
 }
 
 // Logger defines the interface for an S3 logger
@@ -22,34 +65,116 @@ type Logger interface {
 	DebugEnabled() bool
 }
 
-// DefaultRetries is the number of retries for a S3 upload until an error is
-// raised
-const DefaultRetries = 3
+const (
+	// DefaultRetries is the number of retries for a S3 upload until an
+	// error is raised
+	DefaultRetries = 3
+	// DefaultRetryBaseDelay is the delay before the first retry of a
+	// failed upload
+	DefaultRetryBaseDelay = time.Second
+)
+
+// RetryPolicy defines how often and when failed uploads are retried.
+// Only uploads that failed with a temporary error (5xx response, throttling,
+// connection errors) are retried.
+type RetryPolicy struct {
+	// MaxRetries is the max. number of retries after a failed upload
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, it is doubled
+	// for every following retry. A random jitter is applied to the delays.
+	BaseDelay time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy that is used by Upload
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: DefaultRetries,
+	BaseDelay:  DefaultRetryBaseDelay,
+}
 
 // NewClient returns a new S3 Client, configuration is read from env variables
 // or configuration files,
 // see https://docs.aws.amazon.com/sdk-for-go/v1/developer-guide/configuring-sdk.html
+// The AWS_REGION environment variable must also be set when files are
+// uploaded to a custom Endpoint.
 func NewClient(logger Logger) (*Client, error) {
+	sess, err := newSession(logger, Endpoint{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		logger: logger,
+		sleep:  time.Sleep,
+		uploaders: map[Endpoint]fileUploader{
+			{}: s3manager.NewUploader(sess),
+		},
+		headers: map[Endpoint]objectHeader{},
+	}, nil
+}
+
+func newSession(logger Logger, endpoint Endpoint) (*session.Session, error) {
 	loglvl := aws.LogLevel(aws.LogOff)
 	if logger.DebugEnabled() {
 		loglvl = aws.LogLevel(aws.LogDebug)
 	}
 
 	cfg := aws.Config{
-		Logger:           aws.LoggerFunc(logger.Debugln),
-		LogLevel:         loglvl,
-		MaxRetries:       aws.Int(DefaultRetries),
-		S3ForcePathStyle: aws.Bool(true),
+		Logger:   aws.LoggerFunc(logger.Debugln),
+		LogLevel: loglvl,
+		// retries are done by the Client according to it's RetryPolicy
+		MaxRetries: aws.Int(0),
+	}
+
+	if endpoint.URL == "" {
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	} else {
+		cfg.Endpoint = aws.String(endpoint.URL)
+		cfg.S3ForcePathStyle = aws.Bool(endpoint.ForcePathStyle)
 	}
 
-	sess, err := session.NewSession(&cfg)
+	return session.NewSession(&cfg)
+}
+
+// uploader returns the uploader for the endpoint, uploaders are created on
+// the first use
+func (c *Client) uploader(endpoint Endpoint) (fileUploader, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if u, exist := c.uploaders[endpoint]; exist {
+		return u, nil
+	}
+
+	sess, err := newSession(c.logger, endpoint)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "creating session for endpoint %q failed", endpoint.URL)
 	}
 
-	return &Client{sess: sess,
-		uploader: s3manager.NewUploader(sess),
-	}, nil
+	u := s3manager.NewUploader(sess)
+	c.uploaders[endpoint] = u
+
+	return u, nil
+}
+
+// header returns the S3 service client for the endpoint, clients are created
+// on the first use
+func (c *Client) header(endpoint Endpoint) (objectHeader, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if h, exist := c.headers[endpoint]; exist {
+		return h, nil
+	}
+
+	sess, err := newSession(c.logger, endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating session for endpoint %q failed", endpoint.URL)
+	}
+
+	h := awss3.New(sess)
+	c.headers[endpoint] = h
+
+	return h, nil
 }
 
 func bucketFromURL(u *url.URL) string {
@@ -78,7 +203,19 @@ func verifyURL(u *url.URL) error {
 
 // Upload uploads a file to an s3 bucket, On success it returns the URL to the
 // file.
+// The file is uploaded to AWS S3, failed uploads are retried according to the
+// DefaultRetryPolicy.
 func (c *Client) Upload(file string, dest string) (string, error) {
+	return c.UploadWithOptions(file, dest, UploadOptions{RetryPolicy: DefaultRetryPolicy})
+}
+
+// UploadWithOptions uploads a file to an s3 bucket of the endpoint in opts,
+// On success it returns the URL to the file.
+// Uploads that failed because of a temporary error are retried according to
+// the RetryPolicy in opts.
+func (c *Client) UploadWithOptions(file string, dest string, opts UploadOptions) (string, error) {
+	policy := opts.RetryPolicy
+
 	url, err := url.Parse(dest)
 	if err != nil {
 		return "", err
@@ -88,13 +225,36 @@ func (c *Client) Upload(file string, dest string) (string, error) {
 		return "", err
 	}
 
+	uploader, err := c.uploader(opts.Endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	for retry := 0; ; retry++ {
+		location, err := upload(uploader, file, url)
+		if err == nil {
+			return location, nil
+		}
+
+		if retry >= policy.MaxRetries || !isRetryable(err) {
+			return "", err
+		}
+
+		delay := backoff(policy.BaseDelay, retry)
+		c.logger.Debugln(fmt.Sprintf("s3: uploading %s to %s failed, retrying in %s (%d/%d): %s",
+			file, dest, delay, retry+1, policy.MaxRetries, err))
+		c.sleep(delay)
+	}
+}
+
+func upload(uploader fileUploader, file string, url *url.URL) (string, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
 
-	res, err := c.uploader.Upload(&s3manager.UploadInput{
+	res, err := uploader.Upload(&s3manager.UploadInput{
 		Bucket: aws.String(bucketFromURL(url)),
 		Key:    aws.String(fileFromURL(url)),
 		Body:   f,
@@ -105,3 +265,126 @@ func (c *Client) Upload(file string, dest string) (string, error) {
 
 	return res.Location, err
 }
+
+// objectFromURL returns the endpoint, bucket and key of the object that u
+// refers to. u can be an URL in the format s3://<bucket>/<key> or an HTTP(S)
+// URL as returned by Upload.
+// HTTP(S) URLs of AWS S3 can be in path-style or virtual-hosted-style, for
+// other hosts path-style is assumed.
+func objectFromURL(u *url.URL) (endpoint Endpoint, bucket, key string, err error) {
+	if u.Scheme == "s3" {
+		if err := verifyURL(u); err != nil {
+			return Endpoint{}, "", "", err
+		}
+
+		return Endpoint{}, bucketFromURL(u), fileFromURL(u), nil
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Endpoint{}, "", "", fmt.Errorf("unsupported URL scheme '%s'", u.Scheme)
+	}
+
+	host := u.Hostname()
+	path := strings.TrimPrefix(u.Path, "/")
+
+	if strings.HasSuffix(host, ".amazonaws.com") {
+		if !strings.HasPrefix(host, "s3.") && !strings.HasPrefix(host, "s3-") {
+			idx := strings.Index(host, ".s3")
+			if idx <= 0 || len(path) == 0 {
+				return Endpoint{}, "", "", fmt.Errorf("bucket or filename missing in url '%s'", u)
+			}
+
+			return Endpoint{}, host[:idx], path, nil
+		}
+	} else {
+		endpoint = Endpoint{
+			URL:            u.Scheme + "://" + u.Host,
+			ForcePathStyle: true,
+		}
+	}
+
+	spl := strings.SplitN(path, "/", 2)
+	if len(spl) != 2 || len(spl[0]) == 0 || len(spl[1]) == 0 {
+		return Endpoint{}, "", "", fmt.Errorf("bucket or filename missing in url '%s'", u)
+	}
+
+	return endpoint, spl[0], spl[1], nil
+}
+
+// Exists returns true if the object that uri refers to exists.
+// uri must be an URL as returned by Upload or in the format
+// s3://<bucket>/<key>.
+func (c *Client) Exists(uri string) (bool, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false, err
+	}
+
+	endpoint, bucket, key, err := objectFromURL(u)
+	if err != nil {
+		return false, err
+	}
+
+	header, err := c.header(endpoint)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = header.HeadObject(&awss3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// backoff returns the delay before the retry with the passed number.
+// The delay is baseDelay*2^retry, a random jitter of up to -50% is applied.
+func backoff(baseDelay time.Duration, retry int) time.Duration {
+	delay := baseDelay << uint(retry)
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isRetryable returns true if err is a temporary error returned by S3.
+// Authentication and authorization errors are never retryable.
+func isRetryable(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		switch code := reqErr.StatusCode(); {
+		case code == http.StatusUnauthorized, code == http.StatusForbidden:
+			return false
+		case code == http.StatusTooManyRequests, code >= http.StatusInternalServerError:
+			return true
+		}
+	}
+
+	if request.IsErrorExpiredCreds(err) {
+		return false
+	}
+
+	if request.IsErrorThrottle(err) || request.IsErrorRetryable(err) {
+		return true
+	}
+
+	// s3manager wraps errors of failed (multipart) uploads
+	if aerr.OrigErr() != nil {
+		return isRetryable(aerr.OrigErr())
+	}
+
+	return false
+}
@@ -0,0 +1,366 @@
+package s3
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+type testLogger struct{}
+
+func (*testLogger) Debugln(v ...interface{}) {}
+func (*testLogger) DebugEnabled() bool       { return false }
+
+// fakeUploader fails the first failCnt calls with err and succeeds afterwards
+type fakeUploader struct {
+	failCnt int
+	err     error
+	calls   int
+}
+
+func (f *fakeUploader) Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	f.calls++
+
+	if f.calls <= f.failCnt {
+		return nil, f.err
+	}
+
+	return &s3manager.UploadOutput{Location: "https://bucket.s3/" + *input.Key}, nil
+}
+
+func newTestClient(uploader fileUploader) (*Client, *[]time.Duration) {
+	var delays []time.Duration
+
+	clt := Client{
+		uploaders: map[Endpoint]fileUploader{{}: uploader},
+		logger:    &testLogger{},
+		sleep: func(d time.Duration) {
+			delays = append(delays, d)
+		},
+	}
+
+	return &clt, &delays
+}
+
+func createTempFile(t *testing.T) (string, func()) {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "baur-s3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name(), func() { os.Remove(f.Name()) }
+}
+
+func serverError(statusCode int) error {
+	return awserr.NewRequestFailure(awserr.New("InternalError", "failed", nil), statusCode, "")
+}
+
+func TestUploadRetriesTemporaryErrors(t *testing.T) {
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	testcases := []struct {
+		name string
+		err  error
+	}{
+		{name: "5xx", err: serverError(http.StatusServiceUnavailable)},
+		{name: "throttling", err: awserr.New("Throttling", "rate exceeded", nil)},
+		{name: "429", err: serverError(http.StatusTooManyRequests)},
+		{name: "wrapped", err: awserr.New("MultipartUpload", "upload failed", serverError(http.StatusInternalServerError))},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			uploader := &fakeUploader{failCnt: 2, err: tc.err}
+			clt, delays := newTestClient(uploader)
+
+			url, err := clt.UploadWithOptions(file, "s3://bucket/file", UploadOptions{
+				RetryPolicy: RetryPolicy{
+					MaxRetries: 3,
+					BaseDelay:  time.Second,
+				},
+			})
+			if err != nil {
+				t.Fatal("upload failed:", err)
+			}
+
+			if url != "https://bucket.s3//file" {
+				t.Errorf("upload returned url %q, expected %q", url, "https://bucket.s3//file")
+			}
+
+			if uploader.calls != 3 {
+				t.Errorf("upload was tried %d times, expected 3", uploader.calls)
+			}
+
+			if len(*delays) != 2 {
+				t.Fatalf("%d delays between retries, expected 2", len(*delays))
+			}
+
+			for i, d := range *delays {
+				max := time.Second << uint(i)
+				if d < max/2 || d > max {
+					t.Errorf("delay before retry %d is %s, expected to be in [%s, %s]", i+1, d, max/2, max)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadFailsWhenRetriesAreExhausted(t *testing.T) {
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	uploader := &fakeUploader{failCnt: 5, err: serverError(http.StatusInternalServerError)}
+	clt, _ := newTestClient(uploader)
+
+	_, err := clt.UploadWithOptions(file, "s3://bucket/file", UploadOptions{
+		RetryPolicy: RetryPolicy{MaxRetries: 2},
+	})
+	if err == nil {
+		t.Fatal("upload succeeded, expected an error")
+	}
+
+	if uploader.calls != 3 {
+		t.Errorf("upload was tried %d times, expected 3", uploader.calls)
+	}
+}
+
+func TestUploadDoesNotRetryPermanentErrors(t *testing.T) {
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	testcases := []struct {
+		name string
+		err  error
+	}{
+		{name: "forbidden", err: awserr.NewRequestFailure(awserr.New("AccessDenied", "access denied", nil), http.StatusForbidden, "")},
+		{name: "expiredcreds", err: awserr.New("ExpiredToken", "expired", nil)},
+		{name: "notfound", err: awserr.NewRequestFailure(awserr.New("NoSuchBucket", "no such bucket", nil), http.StatusNotFound, "")},
+		{name: "non-aws", err: errors.New("error")},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			uploader := &fakeUploader{failCnt: 1, err: tc.err}
+			clt, _ := newTestClient(uploader)
+
+			_, err := clt.Upload(file, "s3://bucket/file")
+			if err == nil {
+				t.Fatal("upload succeeded, expected an error")
+			}
+
+			if uploader.calls != 1 {
+				t.Errorf("upload was tried %d times, expected 1", uploader.calls)
+			}
+		})
+	}
+}
+
+func TestNewSessionHonorsEndpoint(t *testing.T) {
+	testcases := []struct {
+		name              string
+		endpoint          Endpoint
+		expectedEndpoint  string
+		expectedPathStyle bool
+	}{
+		{
+			name:              "aws",
+			expectedPathStyle: true,
+		},
+		{
+			name:             "custom",
+			endpoint:         Endpoint{URL: "https://minio.example.com:9000"},
+			expectedEndpoint: "https://minio.example.com:9000",
+		},
+		{
+			name:              "custompathstyle",
+			endpoint:          Endpoint{URL: "http://localhost:9000", ForcePathStyle: true},
+			expectedEndpoint:  "http://localhost:9000",
+			expectedPathStyle: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			sess, err := newSession(&testLogger{}, tc.endpoint)
+			if err != nil {
+				t.Fatal("creating session failed:", err)
+			}
+
+			if endpoint := aws.StringValue(sess.Config.Endpoint); endpoint != tc.expectedEndpoint {
+				t.Errorf("session endpoint is %q, expected %q", endpoint, tc.expectedEndpoint)
+			}
+
+			if pathStyle := aws.BoolValue(sess.Config.S3ForcePathStyle); pathStyle != tc.expectedPathStyle {
+				t.Errorf("session S3ForcePathStyle is %v, expected %v", pathStyle, tc.expectedPathStyle)
+			}
+		})
+	}
+}
+
+func TestUploadUsesUploaderOfEndpoint(t *testing.T) {
+	file, cleanupFn := createTempFile(t)
+	defer cleanupFn()
+
+	endpoint := Endpoint{URL: "http://localhost:9000", ForcePathStyle: true}
+	awsUploader := &fakeUploader{}
+	endpointUploader := &fakeUploader{}
+
+	clt, _ := newTestClient(awsUploader)
+	clt.uploaders[endpoint] = endpointUploader
+
+	_, err := clt.UploadWithOptions(file, "s3://bucket/file", UploadOptions{Endpoint: endpoint})
+	if err != nil {
+		t.Fatal("upload failed:", err)
+	}
+
+	if endpointUploader.calls != 1 {
+		t.Errorf("uploader of the endpoint was called %d times, expected 1", endpointUploader.calls)
+	}
+
+	if awsUploader.calls != 0 {
+		t.Errorf("uploader for AWS was called %d times, expected 0", awsUploader.calls)
+	}
+}
+
+func TestObjectFromURL(t *testing.T) {
+	testcases := []struct {
+		url              string
+		expectedEndpoint Endpoint
+		expectedBucket   string
+		expectedKey      string
+	}{
+		{
+			url:            "s3://bucket/dir/file.tar.xz",
+			expectedBucket: "bucket",
+			expectedKey:    "/dir/file.tar.xz",
+		},
+		{
+			url:            "https://s3.eu-central-1.amazonaws.com/bucket/dir/file.tar.xz",
+			expectedBucket: "bucket",
+			expectedKey:    "dir/file.tar.xz",
+		},
+		{
+			url:            "https://s3-eu-west-1.amazonaws.com/bucket/file.tar.xz",
+			expectedBucket: "bucket",
+			expectedKey:    "file.tar.xz",
+		},
+		{
+			url:            "https://bucket.s3.eu-central-1.amazonaws.com/dir/file.tar.xz",
+			expectedBucket: "bucket",
+			expectedKey:    "dir/file.tar.xz",
+		},
+		{
+			url:              "http://minio:9000/bucket/dir/file.tar.xz",
+			expectedEndpoint: Endpoint{URL: "http://minio:9000", ForcePathStyle: true},
+			expectedBucket:   "bucket",
+			expectedKey:      "dir/file.tar.xz",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			endpoint, bucket, key, err := objectFromURL(u)
+			if err != nil {
+				t.Fatal("objectFromURL failed:", err)
+			}
+
+			if endpoint != tc.expectedEndpoint || bucket != tc.expectedBucket || key != tc.expectedKey {
+				t.Errorf("objectFromURL returned (%+v, %q, %q), expected (%+v, %q, %q)",
+					endpoint, bucket, key, tc.expectedEndpoint, tc.expectedBucket, tc.expectedKey)
+			}
+		})
+	}
+
+	for _, invalidURL := range []string{"ftp://bucket/file", "https://s3.amazonaws.com/bucket", "http://minio:9000/bucket"} {
+		u, err := url.Parse(invalidURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, _, err := objectFromURL(u); err == nil {
+			t.Errorf("objectFromURL succeeded for invalid url %q", invalidURL)
+		}
+	}
+}
+
+// fakeHeader returns err for HeadObject calls
+type fakeHeader struct {
+	err   error
+	input *awss3.HeadObjectInput
+}
+
+func (f *fakeHeader) HeadObject(input *awss3.HeadObjectInput) (*awss3.HeadObjectOutput, error) {
+	f.input = input
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &awss3.HeadObjectOutput{}, nil
+}
+
+func TestExists(t *testing.T) {
+	const uri = "https://s3.eu-central-1.amazonaws.com/bucket/dir/file.tar.xz"
+
+	testcases := []struct {
+		name        string
+		err         error
+		expected    bool
+		expectError bool
+	}{
+		{name: "exists", expected: true},
+		{name: "notfound", err: serverError(http.StatusNotFound), expected: false},
+		{name: "forbidden", err: serverError(http.StatusForbidden), expectError: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := fakeHeader{err: tc.err}
+			clt, _ := newTestClient(&fakeUploader{})
+			clt.headers = map[Endpoint]objectHeader{{}: &header}
+
+			exists, err := clt.Exists(uri)
+			if tc.expectError {
+				if err == nil {
+					t.Fatal("Exists succeeded, expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal("Exists failed:", err)
+			}
+
+			if exists != tc.expected {
+				t.Errorf("Exists returned %t, expected %t", exists, tc.expected)
+			}
+
+			if aws.StringValue(header.input.Bucket) != "bucket" || aws.StringValue(header.input.Key) != "dir/file.tar.xz" {
+				t.Errorf("HeadObject was called for bucket %q, key %q, expected bucket, dir/file.tar.xz",
+					aws.StringValue(header.input.Bucket), aws.StringValue(header.input.Key))
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/simplesurance/baur/upload/azure"
+)
+
+// AzureBlobJob is an upload job for files to Azure Blob Storage containers
+type AzureBlobJob struct {
+	UserData interface{}
+	FilePath string
+	DestURL  string
+	Options  azure.UploadOptions
+}
+
+// LocalPath returns the local path of the file that is uploaded
+func (a *AzureBlobJob) LocalPath() string {
+	return a.FilePath
+}
+
+// RemoteDest returns the path in Azure Blob Storage
+func (a *AzureBlobJob) RemoteDest() string {
+	return a.DestURL
+}
+
+// Type returns JobAzureBlob
+func (a *AzureBlobJob) Type() JobType {
+	return JobAzureBlob
+}
+
+// GetUserData returns the UserData
+func (a *AzureBlobJob) GetUserData() interface{} {
+	return a.UserData
+}
+
+// SetUserData sets the UserData
+func (a *AzureBlobJob) SetUserData(u interface{}) {
+	a.UserData = u
+}
+
+// String returns the string representation
+func (a *AzureBlobJob) String() string {
+	return fmt.Sprintf("%s -> %s", a.FilePath, a.DestURL)
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/simplesurance/baur/upload/cmdupload"
+)
+
+// CommandJob is an upload job for files that are uploaded by running a
+// command
+type CommandJob struct {
+	UserData interface{}
+	FilePath string
+	Command  string
+	Options  cmdupload.UploadOptions
+}
+
+// LocalPath returns the local path of the file that is uploaded
+func (c *CommandJob) LocalPath() string {
+	return c.FilePath
+}
+
+// RemoteDest returns the command that uploads the file
+func (c *CommandJob) RemoteDest() string {
+	return c.Command
+}
+
+// Type returns JobCommand
+func (c *CommandJob) Type() JobType {
+	return JobCommand
+}
+
+// GetUserData returns the UserData
+func (c *CommandJob) GetUserData() interface{} {
+	return c.UserData
+}
+
+// SetUserData sets the UserData
+func (c *CommandJob) SetUserData(u interface{}) {
+	c.UserData = u
+}
+
+// String returns the string representation
+func (c *CommandJob) String() string {
+	return fmt.Sprintf("%s -> %q", c.FilePath, c.Command)
+}
@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/simplesurance/baur/upload/docker"
+)
+
+// DockerManifestJob is an upload job for docker images of multiple platforms
+// that are referenced by a manifest list
+type DockerManifestJob struct {
+	UserData   interface{}
+	Images     []*docker.ManifestImage
+	Repository string
+	Tag        string
+}
+
+// LocalPath returns the image IDs of the images
+func (d *DockerManifestJob) LocalPath() string {
+	ids := make([]string, 0, len(d.Images))
+	for _, img := range d.Images {
+		ids = append(ids, img.ImageID)
+	}
+
+	return strings.Join(ids, ", ")
+}
+
+// RemoteDest returns the upload path of the manifest list in the docker
+// registry
+func (d *DockerManifestJob) RemoteDest() string {
+	return d.Repository + ":" + d.Tag
+}
+
+// Type returns JobDockerManifest
+func (d *DockerManifestJob) Type() JobType {
+	return JobDockerManifest
+}
+
+// GetUserData returns the UserData
+func (d *DockerManifestJob) GetUserData() interface{} {
+	return d.UserData
+}
+
+// SetUserData sets the UserData
+func (d *DockerManifestJob) SetUserData(u interface{}) {
+	d.UserData = u
+}
+
+// String returns the string representation
+func (d *DockerManifestJob) String() string {
+	return fmt.Sprintf("docker manifest list: %s:%s", d.Repository, d.Tag)
+}
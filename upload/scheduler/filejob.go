@@ -1,12 +1,17 @@
 package scheduler
 
-import "fmt"
+import (
+	"fmt"
 
-// FileCopyJob is an upload jobs for files to S3 repositories
+	"github.com/simplesurance/baur/upload/filecopy"
+)
+
+// FileCopyJob is an upload jobs for copying files to a local directory
 type FileCopyJob struct {
 	UserData interface{}
 	Src      string
 	Dst      string
+	Options  filecopy.Options
 }
 
 // LocalPath returns the local path of the file that is uploaded
@@ -0,0 +1,40 @@
+package scheduler
+
+import "fmt"
+
+// GCSJob is an upload job for files to Google Cloud Storage buckets
+type GCSJob struct {
+	UserData interface{}
+	FilePath string
+	DestURL  string
+}
+
+// LocalPath returns the local path of the file that is uploaded
+func (g *GCSJob) LocalPath() string {
+	return g.FilePath
+}
+
+// RemoteDest returns the path in GCS
+func (g *GCSJob) RemoteDest() string {
+	return g.DestURL
+}
+
+// Type returns JobGCS
+func (g *GCSJob) Type() JobType {
+	return JobGCS
+}
+
+// GetUserData returns the UserData
+func (g *GCSJob) GetUserData() interface{} {
+	return g.UserData
+}
+
+// SetUserData sets the UserData
+func (g *GCSJob) SetUserData(u interface{}) {
+	g.UserData = u
+}
+
+// String returns the string representation
+func (g *GCSJob) String() string {
+	return fmt.Sprintf("%s -> %s", g.FilePath, g.DestURL)
+}
@@ -11,6 +11,16 @@ const (
 	JobDocker
 	// JobFileCopy is a job for copying files from one place to another
 	JobFileCopy
+	// JobGCS is the type for Google Cloud Storage file upload jobs
+	JobGCS
+	// JobAzureBlob is the type for Azure Blob Storage file upload jobs
+	JobAzureBlob
+	// JobDockerManifest is the type for uploads of docker images for
+	// multiple platforms and their manifest list
+	JobDockerManifest
+	// JobCommand is the type for file uploads that are done by running a
+	// command
+	JobCommand
 )
 
 // Job is the interface for upload jobs
@@ -1,12 +1,18 @@
 package scheduler
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/simplesurance/baur/upload/s3"
+)
 
 // S3Job is an upload jobs for files to S3 repositories
 type S3Job struct {
-	UserData interface{}
-	FilePath string
-	DestURL  string
+	UserData    interface{}
+	FilePath    string
+	DestURL     string
+	Endpoint    s3.Endpoint
+	RetryPolicy s3.RetryPolicy
 }
 
 // LocalPath returns the local path of the file that is uploaded
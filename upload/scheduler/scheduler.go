@@ -15,4 +15,7 @@ type Result struct {
 	URL      string
 	Duration time.Duration
 	Job      Job
+	// RegistryDigest is the digest of the uploaded image manifest in the
+	// docker registry, it is only set for docker jobs
+	RegistryDigest string
 }
@@ -10,6 +10,11 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/simplesurance/baur/upload"
+	"github.com/simplesurance/baur/upload/azure"
+	"github.com/simplesurance/baur/upload/cmdupload"
+	"github.com/simplesurance/baur/upload/docker"
+	"github.com/simplesurance/baur/upload/filecopy"
+	"github.com/simplesurance/baur/upload/s3"
 	"github.com/simplesurance/baur/upload/scheduler"
 )
 
@@ -18,11 +23,42 @@ type Logger interface {
 	Debugf(format string, v ...interface{})
 }
 
+// FileCopier copies files according to the passed Options
+type FileCopier interface {
+	UploadWithOptions(src, dst string, opts filecopy.Options) (string, error)
+}
+
+// S3Uploader uploads files to S3 according to the passed UploadOptions
+type S3Uploader interface {
+	UploadWithOptions(from, to string, opts s3.UploadOptions) (string, error)
+}
+
+// AzureBlobUploader uploads files to Azure Blob Storage according to the
+// passed UploadOptions
+type AzureBlobUploader interface {
+	UploadWithOptions(from, to string, opts azure.UploadOptions) (string, error)
+}
+
+// CommandUploader uploads files by running a command
+type CommandUploader interface {
+	Upload(filePath, command string, opts cmdupload.UploadOptions) (string, error)
+}
+
+// DockerUploader uploads docker images and manifest lists and returns their
+// digests in the registry
+type DockerUploader interface {
+	UploadWithDigest(image, dest string) (string, string, error)
+	UploadManifestList(images []*docker.ManifestImage, dest string) (string, string, error)
+}
+
 // Uploader is a sequential uploader
 type Uploader struct {
-	filecopy       upload.Uploader
-	s3             upload.Uploader
-	docker         upload.Uploader
+	filecopy       FileCopier
+	s3             S3Uploader
+	gcs            upload.Uploader
+	azureBlob      AzureBlobUploader
+	command        CommandUploader
+	docker         DockerUploader
 	lock           sync.Mutex
 	queue          []scheduler.Job
 	stopProcessing bool
@@ -32,10 +68,13 @@ type Uploader struct {
 
 // New initializes a sequential uploader
 // Status chan must have a buffer count > 1 otherwise a deadlock occurs
-func New(logger Logger, filecopyUploader, s3Uploader, dockerUploader upload.Uploader, status chan<- *scheduler.Result) *Uploader {
+func New(logger Logger, filecopyUploader FileCopier, s3Uploader S3Uploader, gcsUploader upload.Uploader, azureBlobUploader AzureBlobUploader, commandUploader CommandUploader, dockerUploader DockerUploader, status chan<- *scheduler.Result) *Uploader {
 	return &Uploader{
 		logger:     logger,
 		s3:         s3Uploader,
+		gcs:        gcsUploader,
+		azureBlob:  azureBlobUploader,
+		command:    commandUploader,
 		statusChan: status,
 		lock:       sync.Mutex{},
 		queue:      []scheduler.Job{},
@@ -67,35 +106,64 @@ func (u *Uploader) Start() {
 
 		if job != nil {
 			var err error
-			var url string
+			var url, registryDigest string
 			startTs := time.Now()
 
 			u.logger.Debugf("uploading %s", job)
 			switch job.Type() {
 			case scheduler.JobFileCopy:
-				url, err = u.filecopy.Upload(job.LocalPath(), job.RemoteDest())
+				fileCopyJob := job.(*scheduler.FileCopyJob)
+				url, err = u.filecopy.UploadWithOptions(job.LocalPath(), job.RemoteDest(), fileCopyJob.Options)
 				if err != nil {
 					err = errors.Wrap(err, "file copy failed")
 				}
 			case scheduler.JobS3:
-				url, err = u.s3.Upload(job.LocalPath(), job.RemoteDest())
+				s3Job := job.(*scheduler.S3Job)
+				url, err = u.s3.UploadWithOptions(job.LocalPath(), job.RemoteDest(), s3.UploadOptions{
+					Endpoint:    s3Job.Endpoint,
+					RetryPolicy: s3Job.RetryPolicy,
+				})
 				if err != nil {
 					err = errors.Wrap(err, "S3 upload failed")
 				}
+			case scheduler.JobGCS:
+				url, err = u.gcs.Upload(job.LocalPath(), job.RemoteDest())
+				if err != nil {
+					err = errors.Wrap(err, "GCS upload failed")
+				}
+			case scheduler.JobAzureBlob:
+				azureBlobJob := job.(*scheduler.AzureBlobJob)
+				url, err = u.azureBlob.UploadWithOptions(job.LocalPath(), job.RemoteDest(), azureBlobJob.Options)
+				if err != nil {
+					err = errors.Wrap(err, "Azure Blob Storage upload failed")
+				}
+			case scheduler.JobCommand:
+				commandJob := job.(*scheduler.CommandJob)
+				url, err = u.command.Upload(commandJob.FilePath, commandJob.Command, commandJob.Options)
+				if err != nil {
+					err = errors.Wrap(err, "command upload failed")
+				}
 			case scheduler.JobDocker:
-				url, err = u.docker.Upload(job.LocalPath(), job.RemoteDest())
+				url, registryDigest, err = u.docker.UploadWithDigest(job.LocalPath(), job.RemoteDest())
 				if err != nil {
 					err = errors.Wrap(err, "Docker upload failed")
 				}
+			case scheduler.JobDockerManifest:
+				manifestJob := job.(*scheduler.DockerManifestJob)
+				url, registryDigest, err = u.docker.UploadManifestList(manifestJob.Images, job.RemoteDest())
+				if err != nil {
+					err = errors.Wrap(err, "Docker manifest list upload failed")
+				}
 			default:
 				panic(fmt.Sprintf("invalid job %+v", job))
 			}
 
 			u.statusChan <- &scheduler.Result{
-				Err:      err,
-				URL:      url,
-				Duration: time.Since(startTs),
-				Job:      job,
+				Err:            err,
+				URL:            url,
+				Duration:       time.Since(startTs),
+				Job:            job,
+				RegistryDigest: registryDigest,
 			}
 		}
 
@@ -4,3 +4,11 @@ package upload
 type Uploader interface {
 	Upload(from, to string) (string, error)
 }
+
+// ExistChecker is an interface for checking if a file that was stored by an
+// Uploader still exists
+type ExistChecker interface {
+	// Exists returns true if a file exists at uri, uri is the location
+	// that was returned by Upload
+	Exists(uri string) (bool, error)
+}
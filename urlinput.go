@@ -0,0 +1,164 @@
+package baur
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/fs"
+)
+
+// urlInputDownloadTimeout is the max. duration of a download of an URLInput
+const urlInputDownloadTimeout = 30 * time.Minute
+
+// URLInputEnvVarPrefix is the prefix of the environment variables that
+// contain the paths of the downloaded URLInputs of an application when it's
+// build command runs. The variable of the n-th URLInput is named
+// URLInputEnvVarPrefix<n>, n starts at 0.
+const URLInputEnvVarPrefix = "BAUR_URL_INPUT_"
+
+// URLInput is a file that is downloaded via HTTP(S) and is a build input.
+// It's digest is calculated from the URL and the declared sha256 digest of
+// the file, the file is not downloaded to calculate the digest.
+type URLInput struct {
+	url       string
+	sha256    string
+	algorithm digest.Algorithm
+	digest    *digest.Digest
+}
+
+// NewURLInput returns a new URLInput, it's digest is calculated with the
+// passed algorithm
+func NewURLInput(url, sha256 string, algorithm digest.Algorithm) *URLInput {
+	return &URLInput{
+		url:       url,
+		sha256:    strings.ToLower(sha256),
+		algorithm: algorithm,
+	}
+}
+
+// Digest returns a digest of the URL and the declared sha256 digest
+func (u *URLInput) Digest() (digest.Digest, error) {
+	if u.digest != nil {
+		return *u.digest, nil
+	}
+
+	sha, err := newInputHash(u.algorithm)
+	if err != nil {
+		return digest.Digest{}, err
+	}
+
+	// URLs can not contain spaces, the 2 values can not collide
+	err = sha.AddBytes([]byte(u.url + " sha256:" + u.sha256))
+	if err != nil {
+		return digest.Digest{}, err
+	}
+
+	u.digest = sha.Digest()
+
+	return *u.digest, nil
+}
+
+// URI returns the URL of the file
+func (u *URLInput) URI() string {
+	return u.url
+}
+
+// String returns it's string representation
+func (u *URLInput) String() string {
+	return u.URI()
+}
+
+// URLInputCacheDir returns the default directory in that URLInputs are
+// stored
+func URLInputCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "baur", "downloads"), nil
+}
+
+// Fetch ensures that the file exists in cacheDir and returns it's path.
+// The file is stored with it's sha256 digest as name. If it does not exist
+// in cacheDir, it is downloaded. An error is returned if the sha256 digest
+// of the downloaded file does not match the declared one.
+func (u *URLInput) Fetch(cacheDir string) (string, error) {
+	path := filepath.Join(cacheDir, u.sha256)
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := fs.Mkdir(cacheDir); err != nil {
+		return "", errors.Wrapf(err, "creating directory %s failed", cacheDir)
+	}
+
+	tmpFile, err := ioutil.TempFile(cacheDir, u.sha256+".download")
+	if err != nil {
+		return "", err
+	}
+
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	err = download(tmpFile, u.url)
+	tmpFile.Close()
+	if err != nil {
+		return "", errors.Wrapf(err, "downloading %s failed", u.url)
+	}
+
+	_, sum, err := fs.FileDigest(tmpPath, digest.SHA256)
+	if err != nil {
+		return "", errors.Wrapf(err, "calculating digest of %s failed", tmpPath)
+	}
+
+	if sum != u.sha256 {
+		return "", fmt.Errorf("sha256 digest of %s is %s, expected %s", u.url, sum, u.sha256)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// URLInputEnv returns environment variables in the format KEY=VALUE that
+// contain the passed paths of downloaded URLInputs, in the order of paths.
+func URLInputEnv(paths []string) []string {
+	res := make([]string, 0, len(paths))
+
+	for i, p := range paths {
+		res = append(res, fmt.Sprintf("%s%d=%s", URLInputEnvVarPrefix, i, p))
+	}
+
+	return res
+}
+
+func download(w io.Writer, url string) error {
+	client := http.Client{Timeout: urlInputDownloadTimeout}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server responded with status %q", resp.Status)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+
+	return err
+}
@@ -0,0 +1,264 @@
+package baur
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/baur/build"
+	"github.com/simplesurance/baur/digest"
+	"github.com/simplesurance/baur/testutils/fstest"
+)
+
+const urlInputContent = "toolchain"
+
+func urlInputContentSHA256() string {
+	sum := sha256.Sum256([]byte(urlInputContent))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestURLInputDigestDoesNotDownload(t *testing.T) {
+	const url = "http://127.0.0.1:1/toolchain.tar.gz"
+	sum := urlInputContentSHA256()
+
+	d1, err := NewURLInput(url, sum, digest.SHA256).Digest()
+	if err != nil {
+		t.Fatal("calculating digest failed:", err)
+	}
+
+	d2, err := NewURLInput(url, strings.ToUpper(sum), digest.SHA256).Digest()
+	if err != nil {
+		t.Fatal("calculating digest failed:", err)
+	}
+
+	if d1.String() != d2.String() {
+		t.Errorf("digests differ for the same sha256 in different case: %s, %s", &d1, &d2)
+	}
+
+	otherSum := strings.Repeat("0", 64)
+	d3, err := NewURLInput(url, otherSum, digest.SHA256).Digest()
+	if err != nil {
+		t.Fatal("calculating digest failed:", err)
+	}
+
+	if d1.String() == d3.String() {
+		t.Error("digests of URLInputs with different sha256 digests are equal")
+	}
+}
+
+func TestURLInputFetch(t *testing.T) {
+	var requestCnt int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCnt++
+
+		if r.URL.Path != "/toolchain.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte(urlInputContent))
+	}))
+	defer srv.Close()
+
+	cacheDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	in := NewURLInput(srv.URL+"/toolchain.tar.gz", urlInputContentSHA256(), digest.SHA256)
+
+	path, err := in.Fetch(cacheDir)
+	if err != nil {
+		t.Fatal("Fetch failed:", err)
+	}
+
+	if path != filepath.Join(cacheDir, urlInputContentSHA256()) {
+		t.Errorf("Fetch returned path %q, expected the file to be named by it's digest", path)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != urlInputContent {
+		t.Errorf("fetched file contains %q, expected %q", content, urlInputContent)
+	}
+
+	if _, err := in.Fetch(cacheDir); err != nil {
+		t.Fatal("second Fetch failed:", err)
+	}
+
+	if requestCnt != 1 {
+		t.Errorf("file was downloaded %d times, expected 1, cached file was not used", requestCnt)
+	}
+}
+
+func TestURLInputFetchFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/toolchain.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte(urlInputContent))
+	}))
+	defer srv.Close()
+
+	testcases := []struct {
+		name   string
+		url    string
+		sha256 string
+	}{
+		{name: "digestmismatch", url: srv.URL + "/toolchain.tar.gz", sha256: strings.Repeat("0", 64)},
+		{name: "notfound", url: srv.URL + "/missing", sha256: urlInputContentSHA256()},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cacheDir, cleanupFn := fstest.CreateTempDir(t)
+			defer cleanupFn()
+
+			_, err := NewURLInput(tc.url, tc.sha256, digest.SHA256).Fetch(cacheDir)
+			if err == nil {
+				t.Fatal("Fetch succeeded, expected an error")
+			}
+
+			files, err := ioutil.ReadDir(cacheDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(files) != 0 {
+				t.Errorf("cache directory contains %d files after failed fetch, expected 0", len(files))
+			}
+		})
+	}
+}
+
+func TestURLInputPathsArePassedToBuild(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(urlInputContent))
+	}))
+	defer srv.Close()
+
+	tempDir, cleanupFn := fstest.CreateTempDir(t)
+	defer cleanupFn()
+
+	cacheDir, cacheCleanupFn := fstest.CreateTempDir(t)
+	defer cacheCleanupFn()
+
+	repoCfgPath := createTestRepository(t, tempDir)
+	appDir := filepath.Join(tempDir, "app")
+
+	createTestApp(t, appDir, "app")
+	fstest.WriteToFile(t, []byte(fmt.Sprintf(`
+name = "app"
+
+[Build]
+command = "make"
+
+[Build.Input.Files]
+paths = ["main.c"]
+
+[[Build.Input.URLs.File]]
+url = "%s/toolchain.tar.gz"
+sha256 = "%s"
+`, srv.URL, urlInputContentSHA256())), filepath.Join(appDir, AppCfgFile))
+
+	repo, err := NewRepository(repoCfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := NewApp(repo, filepath.Join(appDir, AppCfgFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := app.FetchURLInputs(cacheDir)
+	if err != nil {
+		t.Fatal("FetchURLInputs failed:", err)
+	}
+
+	res := build.Run(&build.Job{
+		Application: app.Name,
+		Directory:   app.BuildDir,
+		Command:     `cat "$BAUR_URL_INPUT_0"`,
+		Environment: URLInputEnv(paths),
+	})
+	if res.Failed() {
+		t.Fatalf("build failed: %+v", res)
+	}
+
+	if res.Output != urlInputContent {
+		t.Errorf("build command read %q from $BAUR_URL_INPUT_0, expected %q", res.Output, urlInputContent)
+	}
+}
+
+func TestNewAppFailsForConflictingURLInputsInIncludes(t *testing.T) {
+	const url = "https://example.com/toolchain.tar.gz"
+	const sha256A = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	const sha256B = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	testcases := []struct {
+		name          string
+		includeSHA256 string
+		wantErr       bool
+	}{
+		{name: "identical", includeSHA256: sha256A},
+		{name: "conflicting", includeSHA256: sha256B, wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, cleanupFn := fstest.CreateTempDir(t)
+			defer cleanupFn()
+
+			repoCfgPath := createTestRepository(t, tempDir)
+			appDir := filepath.Join(tempDir, "app")
+
+			createTestApp(t, appDir, "app")
+			fstest.WriteToFile(t, []byte(fmt.Sprintf(`
+name = "app"
+
+[Build]
+command = "make"
+includes = ["$ROOT/include.toml"]
+
+[[Build.Input.URLs.File]]
+url = %q
+sha256 = %q
+`, url, sha256A)), filepath.Join(appDir, AppCfgFile))
+
+			fstest.WriteToFile(t, []byte(fmt.Sprintf(`
+[[BuildInput.URLs.File]]
+url = %q
+sha256 = %q
+`, url, tc.includeSHA256)), filepath.Join(tempDir, "include.toml"))
+
+			repo, err := NewRepository(repoCfgPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			app, err := NewApp(repo, filepath.Join(appDir, AppCfgFile))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewApp() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if cnt := len(app.URLInputs()); cnt != 1 {
+				t.Errorf("app has %d URLInputs, expected 1", cnt)
+			}
+		})
+	}
+}